@@ -0,0 +1,16 @@
+// Package adapters converts between currencies using third-party exchange
+// rate providers behind a common ExchangeRateAdapter interface.
+package adapters
+
+import (
+	"context"
+	"time"
+)
+
+// ExchangeRateAdapter is implemented by every rate provider.
+type ExchangeRateAdapter interface {
+	GetRate(ctx context.Context, from, to string) (RateResult, error)
+	GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error)
+	GetExchangeRate(ctx context.Context, from, to string) (float64, error)
+	ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error)
+}
@@ -0,0 +1,149 @@
+package adapters
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// replayTransport sends every request, whatever its host, to target.
+type replayTransport struct {
+	target *url.URL
+}
+
+func (t replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// replay returns a client whose requests are answered by a server serving,
+// for each path, the recorded payload of that name in testdata.
+func replay(t *testing.T, payloads map[string]string) *http.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := payloads[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Errorf("payload for %s: %v", r.URL.Path, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+	return &http.Client{Transport: replayTransport{target: target}}
+}
+
+func TestAdaptersFillRateResult(t *testing.T) {
+	published := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+	atTimestamp := time.Unix(1714752000, 0).UTC()
+
+	tests := []struct {
+		name      string
+		adapter   func(client *http.Client) ExchangeRateAdapter
+		payloads  map[string]string
+		from, to  string
+		rate      float64
+		timestamp time.Time
+		date      string
+	}{
+		{
+			name:     "fixerio",
+			adapter:  func(client *http.Client) ExchangeRateAdapter { return NewFixerIo(client, "key") },
+			payloads: map[string]string{"/api/latest": "fixerio_latest.json"},
+			from:     "USD", to: "KHR", rate: 4390.5 / 1.0762, timestamp: atTimestamp,
+		},
+		{
+			name:     "openexchangerates",
+			adapter:  func(client *http.Client) ExchangeRateAdapter { return NewOpenExchangeRates(client, "key") },
+			payloads: map[string]string{"/api/latest.json": "openexchangerates_latest.json"},
+			from:     "EUR", to: "KHR", rate: 4075 / 0.9293, timestamp: atTimestamp,
+		},
+		{
+			name:     "currencylayer",
+			adapter:  func(client *http.Client) ExchangeRateAdapter { return NewCurrencyLayer(client, "key") },
+			payloads: map[string]string{"/live": "currencylayer_live.json"},
+			from:     "EUR", to: "KHR", rate: 4075 / 0.9293, timestamp: atTimestamp,
+		},
+		{
+			name:     "exchangeratehost",
+			adapter:  func(client *http.Client) ExchangeRateAdapter { return NewExchangeRateHost(client, "") },
+			payloads: map[string]string{"/latest": "exchangeratehost_latest.json"},
+			from:     "USD", to: "EUR", rate: 0.9293, timestamp: published,
+		},
+		{
+			name:     "ecb",
+			adapter:  func(client *http.Client) ExchangeRateAdapter { return NewECB(client) },
+			payloads: map[string]string{"/stats/eurofxref/eurofxref-daily.xml": "ecb_daily.xml"},
+			from:     "USD", to: "GBP", rate: 0.85773 / 1.0762, timestamp: published, date: "2024-05-03",
+		},
+		{
+			name:     "nbc",
+			adapter:  func(client *http.Client) ExchangeRateAdapter { return NewNBC(client) },
+			payloads: map[string]string{"/english/economic_research/exchange_rate.php": "nbc_rates.html"},
+			from:     "KHR", to: "USD", rate: 1.0 / 4075, timestamp: published, date: "2024-05-03",
+		},
+		{
+			name:     "coingecko",
+			adapter:  func(client *http.Client) ExchangeRateAdapter { return NewCoinGecko(client, "") },
+			payloads: map[string]string{"/api/v3/simple/price": "coingecko_price.json"},
+			from:     "BTC", to: "USD", rate: 63012.5,
+		},
+		{
+			name: "ratesservice",
+			adapter: func(client *http.Client) ExchangeRateAdapter {
+				return NewRatesService(client, "http://rates.internal/v1/rates", "key")
+			},
+			payloads: map[string]string{"/v1/rates": "ratesservice_rates.json"},
+			from:     "USD", to: "EUR", rate: 0.9293, timestamp: atTimestamp,
+		},
+		{
+			name: "external",
+			adapter: func(client *http.Client) ExchangeRateAdapter {
+				return NewExternal(client, "external", "http://adapter.internal", "key")
+			},
+			payloads: map[string]string{"/v1/rates": "external_rates.json"},
+			from:     "USD", to: "EUR", rate: 0.9293, timestamp: time.Date(2024, 5, 3, 16, 0, 0, 0, time.UTC), date: "2024-05-03",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			adapter := test.adapter(replay(t, test.payloads))
+			result, err := adapter.GetRate(context.Background(), test.from, test.to)
+			if err != nil {
+				t.Fatalf("GetRate: %v", err)
+			}
+
+			if diff := result.Rate/test.rate - 1; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("Rate = %v, want %v", result.Rate, test.rate)
+			}
+			if result.Base != test.from || result.Target != test.to {
+				t.Errorf("pair = %s/%s, want %s/%s", result.Base, result.Target, test.from, test.to)
+			}
+			if result.Provider != test.name {
+				t.Errorf("Provider = %q, want %q", result.Provider, test.name)
+			}
+			switch {
+			case result.Timestamp.IsZero():
+				t.Error("Timestamp is not set")
+			case !test.timestamp.IsZero() && !result.Timestamp.Equal(test.timestamp):
+				t.Errorf("Timestamp = %v, want %v", result.Timestamp, test.timestamp)
+			}
+			if result.Date != test.date {
+				t.Errorf("Date = %q, want %q", result.Date, test.date)
+			}
+		})
+	}
+}
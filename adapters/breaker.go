@@ -0,0 +1,341 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cubetiq-samples/exchanger-go/config"
+	"cubetiq-samples/exchanger-go/metrics"
+)
+
+// ErrCircuitOpen is returned without contacting the provider while its
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("provider is unavailable, circuit breaker open")
+
+// Circuit breaker states.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+const (
+	defaultBreakerFailures = 5
+	defaultBreakerCooldown = 30 * time.Second
+)
+
+// BreakerStatus reports the state of one provider's circuit breaker.
+type BreakerStatus struct {
+	Provider string     `json:"provider"`
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"openedAt,omitempty"`
+	RetryAt  *time.Time `json:"retryAt,omitempty"`
+}
+
+// Breaker stops calls to a provider after threshold consecutive failures.
+// Once cooldown has passed a single trial call is let through: success
+// closes the circuit again, failure keeps it open for another cooldown.
+type Breaker struct {
+	provider  string
+	threshold int
+	cooldown  time.Duration
+	breakers  *Breakers
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func newBreaker(provider string, breakers *Breakers) *Breaker {
+	name := strings.ToUpper(provider)
+	failures := config.Int("EXCHANGER_BREAKER_FAILURES", defaultBreakerFailures)
+	cooldown := config.Duration("EXCHANGER_BREAKER_COOLDOWN", defaultBreakerCooldown)
+	return &Breaker{
+		provider:  provider,
+		threshold: config.Int("EXCHANGER_BREAKER_FAILURES_"+name, failures),
+		cooldown:  config.Duration("EXCHANGER_BREAKER_COOLDOWN_"+name, cooldown),
+		breakers:  breakers,
+		state:     CircuitClosed,
+	}
+}
+
+// allow reports whether a call may go to the provider.
+func (b *Breaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.setState(CircuitHalfOpen)
+		b.trial = true
+		return nil
+	case CircuitHalfOpen:
+		if b.trial {
+			return ErrCircuitOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of an allowed call.
+func (b *Breaker) record(err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	switch {
+	case providerDown(err):
+		b.failures++
+		if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+			if b.state != CircuitOpen {
+				log.Printf("circuit breaker for %s opened after %d failures: %v", b.provider, b.failures, err)
+			}
+			if b.state == CircuitClosed {
+				b.breakers.changed(b.provider, true, err)
+			}
+			b.openedAt = time.Now()
+			b.setState(CircuitOpen)
+		}
+	case errors.Is(err, ErrHistoricalNotSupported), errors.Is(err, ErrSymbolsNotSupported),
+		errors.Is(err, ErrRateTableNotSupported), errors.Is(err, context.Canceled):
+		// The provider wasn't asked, or the caller gave up, so this says
+		// nothing about its health
+	default:
+		if b.state != CircuitClosed {
+			log.Printf("circuit breaker for %s closed", b.provider)
+			b.breakers.changed(b.provider, false, nil)
+		}
+		b.failures = 0
+		b.setState(CircuitClosed)
+	}
+}
+
+// release ends an allowed call without counting its outcome, so a trial
+// call doesn't decide the state of the circuit.
+func (b *Breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *Breaker) setState(state string) {
+	b.state = state
+	open := 0.0
+	if state == CircuitOpen {
+		open = 1
+	}
+	metrics.CircuitOpen.WithLabelValues(b.provider).Set(open)
+}
+
+// Status returns the current state of the breaker.
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := BreakerStatus{Provider: b.provider, State: b.state, Failures: b.failures}
+	if b.state != CircuitClosed {
+		openedAt, retryAt := b.openedAt, b.openedAt.Add(b.cooldown)
+		status.OpenedAt, status.RetryAt = &openedAt, &retryAt
+	}
+	return status
+}
+
+// providerDown reports whether err means the provider couldn't be reached or
+// is failing, as opposed to answering a request it didn't like.
+func providerDown(err error) bool {
+	var statusErr *UpstreamStatusError
+	var urlErr *url.Error
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return false
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == 429
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &urlErr):
+		return true
+	}
+	return false
+}
+
+// credentialError reports whether err means the provider refused the API
+// key, its quota or its plan rather than failing.
+func credentialError(err error) bool {
+	var statusErr *UpstreamStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case 401, 402, 403, 429:
+			return true
+		}
+	}
+	return errors.Is(err, ErrProviderAuth) || errors.Is(err, ErrProviderQuota) || errors.Is(err, ErrProviderPlan)
+}
+
+// Breakers holds one circuit breaker per provider. Adapters are built per
+// request, so the breakers live as long as the server does.
+type Breakers struct {
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+	onChange func(provider string, open bool, err error)
+}
+
+// NewBreakers returns an empty set of breakers; each provider's is created on
+// first use from EXCHANGER_BREAKER_FAILURES and EXCHANGER_BREAKER_COOLDOWN.
+func NewBreakers() *Breakers {
+	return &Breakers{breakers: map[string]*Breaker{}}
+}
+
+// Get returns the breaker of provider.
+func (b *Breakers) Get(provider string) *Breaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	breaker, ok := b.breakers[provider]
+	if !ok {
+		breaker = newBreaker(provider, b)
+		b.breakers[provider] = breaker
+	}
+	return breaker
+}
+
+// OnChange has f called, in its own goroutine, when the circuit of a
+// provider opens, with the failure that opened it, and when it closes again.
+// Trial calls failing while it is open don't call f.
+func (b *Breakers) OnChange(f func(provider string, open bool, err error)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onChange = f
+}
+
+func (b *Breakers) changed(provider string, open bool, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	f := b.onChange
+	b.mu.Unlock()
+	if f != nil {
+		go f(provider, open, err)
+	}
+}
+
+// Statuses returns the state of every breaker used so far, sorted by
+// provider.
+func (b *Breakers) Statuses() []BreakerStatus {
+	b.mu.Lock()
+	breakers := make([]*Breaker, 0, len(b.breakers))
+	for _, breaker := range b.breakers {
+		breakers = append(breakers, breaker)
+	}
+	b.mu.Unlock()
+
+	statuses := make([]BreakerStatus, len(breakers))
+	for i, breaker := range breakers {
+		statuses[i] = breaker.Status()
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Provider < statuses[j].Provider })
+	return statuses
+}
+
+// BreakerAdapter decorates an adapter with a circuit breaker, so a failing
+// provider is skipped right away instead of costing every request a timeout.
+type BreakerAdapter struct {
+	adapter   ExchangeRateAdapter
+	breaker   *Breaker
+	clientKey bool
+}
+
+// NewBreaker guards the calls adapter makes with breaker. clientKey tells
+// that adapter uses an API key the client supplied: the provider rejecting
+// it, or its quota running out, is the client's problem and leaves the
+// breaker, shared by every caller, as it is.
+func NewBreaker(adapter ExchangeRateAdapter, breaker *Breaker, clientKey bool) *BreakerAdapter {
+	return &BreakerAdapter{adapter: adapter, breaker: breaker, clientKey: clientKey}
+}
+
+// record reports the outcome of an allowed call to the breaker.
+func (b *BreakerAdapter) record(err error) {
+	if b.clientKey && credentialError(err) {
+		b.breaker.release()
+		return
+	}
+	b.breaker.record(err)
+}
+
+func (b *BreakerAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	if err := b.breaker.allow(); err != nil {
+		return RateResult{}, err
+	}
+	result, err := b.adapter.GetRate(ctx, from, to)
+	b.record(err)
+	return result, err
+}
+
+func (b *BreakerAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	if err := b.breaker.allow(); err != nil {
+		return RateResult{}, err
+	}
+	result, err := b.adapter.GetExchangeRateAt(ctx, from, to, date)
+	b.record(err)
+	return result, err
+}
+
+func (b *BreakerAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	series, ok := b.adapter.(TimeSeriesAdapter)
+	if !ok {
+		return dailySeries(ctx, b, from, to, start, end)
+	}
+	if err := b.breaker.allow(); err != nil {
+		return nil, err
+	}
+	results, err := series.GetTimeSeries(ctx, from, to, start, end)
+	b.record(err)
+	return results, err
+}
+
+func (b *BreakerAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	if err := b.breaker.allow(); err != nil {
+		return nil, err
+	}
+	results, err := FetchRates(ctx, b.adapter, from, to)
+	b.record(err)
+	return results, err
+}
+
+func (b *BreakerAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	if err := b.breaker.allow(); err != nil {
+		return nil, err
+	}
+	symbols, err := FetchSymbols(ctx, b.adapter)
+	b.record(err)
+	return symbols, err
+}
+
+func (b *BreakerAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := b.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (b *BreakerAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := b.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
@@ -0,0 +1,371 @@
+package adapters
+
+import (
+	"context"
+	"cubetiq-samples/exchanger-go/cache"
+	"cubetiq-samples/exchanger-go/config"
+	"cubetiq-samples/exchanger-go/metrics"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// defaultCacheTTL is used for providers without their own TTL setting.
+const defaultCacheTTL = time.Minute
+
+// HistoricalCacheTTL applies to rates of past days, which no longer change,
+// in the cache and in the Cache-Control of responses serving them.
+const HistoricalCacheTTL = 24 * time.Hour
+
+// CacheTTL returns how long rates from provider stay cached, taken from
+// EXCHANGER_CACHE_TTL_<PROVIDER> or EXCHANGER_CACHE_TTL. Zero disables caching.
+// The static and mock sources aren't cached by default: they answer from
+// memory, and a cache would only delay reloads and hide injected latency and
+// errors.
+func CacheTTL(provider string) time.Duration {
+	ttl := config.Duration("EXCHANGER_CACHE_TTL", defaultCacheTTL)
+	if provider == "static" || provider == "mock" {
+		ttl = 0
+	}
+	return config.Duration("EXCHANGER_CACHE_TTL_"+strings.ToUpper(provider), ttl)
+}
+
+// CachedAdapter decorates an adapter with a rate cache so repeated lookups of
+// the same pair within ttl don't hit the provider. Entries are kept for
+// maxStale past ttl: when the provider fails, lookups fail with a StaleError
+// carrying them, and they are refreshed in the background. Entries are kept
+// per API key, so a client's own key always reaches the provider.
+type CachedAdapter struct {
+	adapter  ExchangeRateAdapter
+	cache    cache.Cache
+	provider string
+	// scope prefixes the cache keys: the provider and a hash of the API key.
+	scope    string
+	ttl      time.Duration
+	maxStale time.Duration
+}
+
+// NewCached caches the rates adapter fetches for provider with apiKey in
+// store for ttl, keeping them for CacheMaxStale(provider) longer.
+func NewCached(adapter ExchangeRateAdapter, store cache.Cache, provider, apiKey string, ttl time.Duration) *CachedAdapter {
+	return &CachedAdapter{adapter: adapter, cache: store, provider: provider, scope: credentialScope(provider, apiKey),
+		ttl: ttl, maxStale: CacheMaxStale(provider)}
+}
+
+func (c *CachedAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	key := "rate:" + c.scope + ":" + from + ":" + to
+	return c.cached(ctx, key, c.ttl, func(ctx context.Context) (RateResult, error) {
+		return c.adapter.GetRate(ctx, from, to)
+	})
+}
+
+func (c *CachedAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	day := date.Format("2006-01-02")
+	ttl := c.ttl
+	if day < time.Now().UTC().Format("2006-01-02") {
+		ttl = HistoricalCacheTTL
+	}
+
+	key := "rate:" + c.scope + ":" + from + ":" + to + ":" + day
+	return c.cached(ctx, key, ttl, func(ctx context.Context) (RateResult, error) {
+		return c.adapter.GetExchangeRateAt(ctx, from, to, date)
+	})
+}
+
+func (c *CachedAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	// Past days no longer change, so a range of them is served from the
+	// per-day cache when it has every day, and otherwise fetched whole, in
+	// one call from providers with a time-series API, and cached day by day
+	if series, ok := c.cachedSeries(from, to, start, end); ok {
+		return series, nil
+	}
+	series, err := FetchTimeSeries(ctx, c.adapter, from, to, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	fetchedAt := time.Now()
+	for i, result := range series {
+		result.FetchedAt = fetchedAt
+		if day := seriesDay(result); day < today() {
+			if data, err := json.Marshal(result); err == nil {
+				c.cache.Set("rate:"+c.scope+":"+from+":"+to+":"+day, data, HistoricalCacheTTL+c.maxStale)
+			}
+		}
+		series[i] = result
+	}
+	return series, nil
+}
+
+// cachedSeries returns the rates between start and end from the cache, when
+// every day of the range is past and cached.
+func (c *CachedAdapter) cachedSeries(from, to string, start, end time.Time) ([]RateResult, bool) {
+	if end.Format("2006-01-02") >= today() {
+		return nil, false
+	}
+	var series []RateResult
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		data, ok := c.lookup("rate:" + c.scope + ":" + from + ":" + to + ":" + day.Format("2006-01-02"))
+		if !ok {
+			return nil, false
+		}
+		var result RateResult
+		if err := json.Unmarshal(data, &result); err != nil || !c.fresh(result, HistoricalCacheTTL) {
+			return nil, false
+		}
+		result.Cached = true
+		series = append(series, result)
+	}
+	return series, true
+}
+
+// seriesDay is the day a rate of a series is for.
+func seriesDay(result RateResult) string {
+	if result.Date != "" {
+		return result.Date
+	}
+	return result.Timestamp.UTC().Format("2006-01-02")
+}
+
+func (c *CachedAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	// Whole tables are cached as one entry
+	if to == nil {
+		return c.cachedTable(ctx, from)
+	}
+
+	// Serve what the cache has and fetch the remaining targets together
+	results := make(map[string]RateResult, len(to))
+	stale := map[string]RateResult{}
+	var missing []string
+	for _, target := range to {
+		if data, ok := c.lookup("rate:" + c.scope + ":" + from + ":" + target); ok {
+			var result RateResult
+			if err := json.Unmarshal(data, &result); err == nil {
+				result.Cached = true
+				if c.fresh(result, c.ttl) {
+					results[target] = result
+					continue
+				}
+				result.Stale = true
+				stale[target] = result
+			}
+		}
+		missing = append(missing, target)
+	}
+	if len(missing) == 0 {
+		return results, nil
+	}
+
+	// When every missing target has a stale rate, those can stand in
+	revalidation := "rates:" + c.scope + ":" + from + ":" + strings.Join(missing, ",")
+	withStale := func(err error) error {
+		for target, result := range stale {
+			results[target] = result
+		}
+		return &StaleError{Results: results, Err: err}
+	}
+	if len(stale) == len(missing) && isRevalidating(revalidation) {
+		return nil, withStale(errRevalidating)
+	}
+
+	fetched, err := c.fetch(ctx, from, missing, c.ttl)
+	if err != nil {
+		if len(stale) < len(missing) || !servesStale(err) {
+			return nil, err
+		}
+		until := time.Now().Add(c.maxStale)
+		for _, result := range stale {
+			if expires := result.FetchedAt.Add(c.ttl + c.maxStale); expires.Before(until) {
+				until = expires
+			}
+		}
+		revalidate(revalidation, until, func(ctx context.Context) error {
+			_, err := c.fetch(ctx, from, missing, c.ttl)
+			return err
+		})
+		return nil, withStale(err)
+	}
+	for target, result := range fetched {
+		results[target] = result
+	}
+	return results, nil
+}
+
+// Refresh fetches the rates of from against to from the provider, bypassing
+// the cache, and stores them for ttl. A background refresher uses it to keep
+// entries warm so lookups never wait on the provider.
+func (c *CachedAdapter) Refresh(ctx context.Context, from string, to []string, ttl time.Duration) (map[string]RateResult, error) {
+	return c.fetch(ctx, from, to, ttl)
+}
+
+// fetch gets the rates of from against to from the provider and caches each
+// for ttl.
+func (c *CachedAdapter) fetch(ctx context.Context, from string, to []string, ttl time.Duration) (map[string]RateResult, error) {
+	results, err := FetchRates(ctx, c.adapter, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	fetchedAt := time.Now()
+	for target, result := range results {
+		result.FetchedAt = fetchedAt
+		if data, err := json.Marshal(result); err == nil {
+			c.cache.Set("rate:"+c.scope+":"+from+":"+target, data, ttl+c.maxStale)
+		}
+		results[target] = result
+	}
+	return results, nil
+}
+
+func (c *CachedAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	// Supported currencies rarely change, so they are kept as long as past rates
+	key := "symbols:" + c.scope
+	if data, ok := c.cache.Get(key); ok {
+		var symbols map[string]string
+		if err := json.Unmarshal(data, &symbols); err == nil {
+			return symbols, nil
+		}
+	}
+
+	symbols, err := FetchSymbols(ctx, c.adapter)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(symbols); err == nil {
+		c.cache.Set(key, data, HistoricalCacheTTL)
+	}
+	return symbols, nil
+}
+
+// cachedTable returns the full rate table for base, cached for ttl.
+func (c *CachedAdapter) cachedTable(ctx context.Context, base string) (map[string]RateResult, error) {
+	key := "rates:" + c.scope + ":" + base
+	var stale map[string]RateResult
+	if data, ok := c.lookup(key); ok {
+		var results map[string]RateResult
+		if err := json.Unmarshal(data, &results); err == nil {
+			fresh := true
+			for target, result := range results {
+				result.Cached = true
+				fresh = fresh && c.fresh(result, c.ttl)
+				results[target] = result
+			}
+			if fresh {
+				return results, nil
+			}
+			for target, result := range results {
+				result.Stale = true
+				results[target] = result
+			}
+			stale = results
+		}
+	}
+	if stale != nil && isRevalidating(key) {
+		return nil, &StaleError{Results: stale, Err: errRevalidating}
+	}
+
+	fetch := func(ctx context.Context) (map[string]RateResult, error) {
+		results, err := FetchRates(ctx, c.adapter, base, nil)
+		if err != nil {
+			return nil, err
+		}
+		fetchedAt := time.Now()
+		for target, result := range results {
+			result.FetchedAt = fetchedAt
+			results[target] = result
+		}
+		if data, err := json.Marshal(results); err == nil {
+			c.cache.Set(key, data, c.ttl+c.maxStale)
+		}
+		return results, nil
+	}
+	results, err := fetch(ctx)
+	if err != nil && stale != nil && servesStale(err) {
+		var fetchedAt time.Time
+		for _, result := range stale {
+			fetchedAt = result.FetchedAt
+			break
+		}
+		revalidate(key, fetchedAt.Add(c.ttl+c.maxStale), func(ctx context.Context) error {
+			_, err := fetch(ctx)
+			return err
+		})
+		return nil, &StaleError{Results: stale, Err: err}
+	}
+	return results, err
+}
+
+// cached returns the result stored under key, or calls fetch and stores its
+// result for ttl, and maxStale longer. A result past ttl is served, as a
+// StaleError, when fetch fails or has been failing.
+func (c *CachedAdapter) cached(ctx context.Context, key string, ttl time.Duration, fetch func(ctx context.Context) (RateResult, error)) (RateResult, error) {
+	var stale *RateResult
+	if data, ok := c.lookup(key); ok {
+		var result RateResult
+		if err := json.Unmarshal(data, &result); err == nil {
+			result.Cached = true
+			if c.fresh(result, ttl) {
+				return result, nil
+			}
+			result.Stale = true
+			stale = &result
+		}
+	}
+	if stale != nil && isRevalidating(key) {
+		return RateResult{}, &StaleError{Result: *stale, Err: errRevalidating}
+	}
+
+	store := func(ctx context.Context) (RateResult, error) {
+		result, err := fetch(ctx)
+		if err != nil {
+			return RateResult{}, err
+		}
+		result.FetchedAt = time.Now()
+		if data, err := json.Marshal(result); err == nil {
+			c.cache.Set(key, data, ttl+c.maxStale)
+		}
+		return result, nil
+	}
+	result, err := store(ctx)
+	if err != nil && stale != nil && servesStale(err) {
+		revalidate(key, stale.FetchedAt.Add(ttl+c.maxStale), func(ctx context.Context) error {
+			_, err := store(ctx)
+			return err
+		})
+		return RateResult{}, &StaleError{Result: *stale, Err: err}
+	}
+	return result, err
+}
+
+// fresh reports whether a cached result is within ttl. Without a maximum
+// staleness entries expire with their TTL, so every cached one is fresh.
+func (c *CachedAdapter) fresh(result RateResult, ttl time.Duration) bool {
+	return c.maxStale <= 0 || time.Since(result.FetchedAt) <= ttl
+}
+
+// lookup reads key from the cache and counts the hit or miss.
+func (c *CachedAdapter) lookup(key string) ([]byte, bool) {
+	data, ok := c.cache.Get(key)
+	result := "miss"
+	if ok {
+		result = "hit"
+	}
+	metrics.CacheLookups.WithLabelValues(c.provider, result).Inc()
+	return data, ok
+}
+
+func (c *CachedAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := c.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (c *CachedAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := c.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
@@ -0,0 +1,24 @@
+package adapters
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type callCounterKey struct{}
+
+// CountCalls returns a context under which every request sent to a provider
+// is added to the returned counter, so callers can account for the upstream
+// calls a lookup caused. Lookups answered from the cache or by a shared
+// in-flight request add nothing.
+func CountCalls(ctx context.Context) (context.Context, *int64) {
+	calls := new(int64)
+	return context.WithValue(ctx, callCounterKey{}, calls), calls
+}
+
+// countCall adds a provider request to the counter of ctx, if it has one.
+func countCall(ctx context.Context) {
+	if calls, ok := ctx.Value(callCounterKey{}).(*int64); ok {
+		atomic.AddInt64(calls, 1)
+	}
+}
@@ -0,0 +1,217 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// cryptoAliases maps alternative tickers to the symbol used internally.
+var cryptoAliases = map[string]string{
+	"XBT": "BTC",
+	"XDG": "DOGE",
+}
+
+// coinGeckoIDs maps crypto symbols to CoinGecko coin ids.
+var coinGeckoIDs = map[string]string{
+	"BTC":  "bitcoin",
+	"ETH":  "ethereum",
+	"USDT": "tether",
+	"USDC": "usd-coin",
+	"BNB":  "binancecoin",
+	"XRP":  "ripple",
+	"SOL":  "solana",
+	"ADA":  "cardano",
+	"DOGE": "dogecoin",
+	"LTC":  "litecoin",
+}
+
+// CoinGeckoAdapter converts between cryptocurrencies and fiat using
+// CoinGecko's simple price API. CoinGecko only quotes coins in fiat terms, so
+// fiat to crypto pairs are inverted and crypto to crypto pairs are crossed
+// through USD.
+type CoinGeckoAdapter struct {
+	client *http.Client
+	apiKey string
+}
+
+// NewCoinGecko returns a CoinGecko adapter; apiKey is an optional demo key.
+func NewCoinGecko(client *http.Client, apiKey string) *CoinGeckoAdapter {
+	return &CoinGeckoAdapter{client: client, apiKey: apiKey}
+}
+
+// CanonicalCrypto resolves aliases such as XBT and reports whether symbol is
+// a supported cryptocurrency.
+func CanonicalCrypto(symbol string) (string, bool) {
+	if alias, ok := cryptoAliases[symbol]; ok {
+		symbol = alias
+	}
+	_, ok := coinGeckoIDs[symbol]
+	return symbol, ok
+}
+
+// prices returns the price of each coin in each of the vs currencies, keyed
+// by coin id and lower-case currency code.
+func (g *CoinGeckoAdapter) prices(ctx context.Context, ids []string, vsCurrencies []string) (map[string]map[string]float64, error) {
+	// Build the API URL, asking for full precision prices
+	query := url.Values{
+		"ids":           {strings.Join(ids, ",")},
+		"vs_currencies": {strings.ToLower(strings.Join(vsCurrencies, ","))},
+		"precision":     {"full"},
+	}
+	header := http.Header{}
+	if g.apiKey != "" {
+		header.Set("x-cg-demo-api-key", g.apiKey)
+	}
+
+	// Send a GET request to the API
+	body, err := fetchProvider(ctx, g.client, providerRequest{
+		Method: http.MethodGet,
+		URL:    "https://api.coingecko.com/api/v3/simple/price?" + query.Encode(),
+		Header: header,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the response against the bundled schema
+	if err := validateProviderResponse("coingecko", body, g.apiKey); err != nil {
+		return nil, err
+	}
+
+	var prices map[string]map[string]float64
+	if err := json.Unmarshal(body, &prices); err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// historyPrices returns each coin's price on date in the same shape as prices.
+// CoinGecko's history endpoint takes one coin per request.
+func (g *CoinGeckoAdapter) historyPrices(ctx context.Context, ids []string, date time.Time) (map[string]map[string]float64, error) {
+	header := http.Header{}
+	if g.apiKey != "" {
+		header.Set("x-cg-demo-api-key", g.apiKey)
+	}
+
+	prices := make(map[string]map[string]float64, len(ids))
+	for _, id := range ids {
+		query := url.Values{"date": {date.Format("02-01-2006")}, "localization": {"false"}}
+		body, err := fetchProvider(ctx, g.client, providerRequest{
+			Method: http.MethodGet,
+			URL:    "https://api.coingecko.com/api/v3/coins/" + id + "/history?" + query.Encode(),
+			Header: header,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := validateResponse("coingecko_history", "coingecko", body, g.apiKey); err != nil {
+			return nil, err
+		}
+
+		var data struct {
+			MarketData struct {
+				CurrentPrice map[string]float64 `json:"current_price"`
+			} `json:"market_data"`
+		}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return nil, err
+		}
+		prices[id] = data.MarketData.CurrentPrice
+	}
+	return prices, nil
+}
+
+func (g *CoinGeckoAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	result, err := g.resolve(from, to, func(ids, vsCurrencies []string) (map[string]map[string]float64, error) {
+		return g.prices(ctx, ids, vsCurrencies)
+	})
+	if err != nil {
+		return RateResult{}, err
+	}
+	result.Timestamp = time.Now().UTC()
+	return result, nil
+}
+
+func (g *CoinGeckoAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	result, err := g.resolve(from, to, func(ids []string, _ []string) (map[string]map[string]float64, error) {
+		return g.historyPrices(ctx, ids, date)
+	})
+	if err != nil {
+		return RateResult{}, err
+	}
+	result.Timestamp = date
+	return result, nil
+}
+
+// resolve computes the rate for a pair from coin prices returned by lookup.
+func (g *CoinGeckoAdapter) resolve(from, to string, lookup func(ids, vsCurrencies []string) (map[string]map[string]float64, error)) (RateResult, error) {
+	from, fromCrypto := CanonicalCrypto(from)
+	to, toCrypto := CanonicalCrypto(to)
+
+	var rate float64
+	switch {
+	case fromCrypto && toCrypto:
+		// Cross both coins through their USD price
+		prices, err := lookup([]string{coinGeckoIDs[from], coinGeckoIDs[to]}, []string{"USD"})
+		if err != nil {
+			return RateResult{}, err
+		}
+		fromPrice := prices[coinGeckoIDs[from]]["usd"]
+		toPrice := prices[coinGeckoIDs[to]]["usd"]
+		if fromPrice == 0 || toPrice == 0 {
+			return RateResult{}, fmt.Errorf("CoinGecko has no USD price for %s/%s: %w", from, to, ErrProviderCurrency)
+		}
+		rate = fromPrice / toPrice
+	case fromCrypto:
+		prices, err := lookup([]string{coinGeckoIDs[from]}, []string{to})
+		if err != nil {
+			return RateResult{}, err
+		}
+		price := prices[coinGeckoIDs[from]][strings.ToLower(to)]
+		if price == 0 {
+			return RateResult{}, fmt.Errorf("CoinGecko has no %s price for %s: %w", to, from, ErrProviderCurrency)
+		}
+		rate = price
+	case toCrypto:
+		// CoinGecko quotes the opposite direction, so invert the price
+		prices, err := lookup([]string{coinGeckoIDs[to]}, []string{from})
+		if err != nil {
+			return RateResult{}, err
+		}
+		price := prices[coinGeckoIDs[to]][strings.ToLower(from)]
+		if price == 0 {
+			return RateResult{}, fmt.Errorf("CoinGecko has no %s price for %s: %w", from, to, ErrProviderCurrency)
+		}
+		rate = 1 / price
+	default:
+		return RateResult{}, fmt.Errorf("CoinGecko needs a cryptocurrency on one side of %s/%s", from, to)
+	}
+
+	return RateResult{
+		Rate:     rate,
+		Base:     from,
+		Target:   to,
+		Provider: "coingecko",
+	}, nil
+}
+
+func (g *CoinGeckoAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := g.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (g *CoinGeckoAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := g.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
@@ -0,0 +1,326 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cubetiq-samples/exchanger-go/config"
+)
+
+// Consensus methods accepted by EXCHANGER_CONSENSUS_METHOD.
+const (
+	ConsensusMedian = "median"
+	ConsensusMean   = "mean"
+)
+
+// Consensus configures how the rates of several providers are combined.
+type Consensus struct {
+	// Method is ConsensusMedian or ConsensusMean, a weighted mean of the
+	// rates that aren't outliers.
+	Method string
+	// Weights weigh providers in the mean; providers not listed weigh 1.
+	Weights map[string]float64
+	// Tolerance is how far, in percent, a rate may stray from the median
+	// before it is an outlier.
+	Tolerance float64
+	// MinProviders is how many providers must answer.
+	MinProviders int
+}
+
+// ConsensusFromEnv reads the consensus settings: EXCHANGER_CONSENSUS_METHOD,
+// EXCHANGER_CONSENSUS_WEIGHTS such as "fixerio=2,ecb=0.5",
+// EXCHANGER_CONSENSUS_TOLERANCE and EXCHANGER_CONSENSUS_MIN_PROVIDERS.
+func ConsensusFromEnv() (Consensus, error) {
+	consensus := Consensus{
+		Method:       os.Getenv("EXCHANGER_CONSENSUS_METHOD"),
+		Weights:      map[string]float64{},
+		Tolerance:    1,
+		MinProviders: config.Int("EXCHANGER_CONSENSUS_MIN_PROVIDERS", 1),
+	}
+	switch consensus.Method {
+	case "":
+		consensus.Method = ConsensusMedian
+	case ConsensusMedian, ConsensusMean:
+	default:
+		return Consensus{}, fmt.Errorf("unknown consensus method %q, expected %s or %s", consensus.Method, ConsensusMedian, ConsensusMean)
+	}
+	if tolerance := os.Getenv("EXCHANGER_CONSENSUS_TOLERANCE"); tolerance != "" {
+		value, err := strconv.ParseFloat(tolerance, 64)
+		if err != nil || value < 0 {
+			return Consensus{}, fmt.Errorf("invalid consensus tolerance %q", tolerance)
+		}
+		consensus.Tolerance = value
+	}
+	if weights := os.Getenv("EXCHANGER_CONSENSUS_WEIGHTS"); weights != "" {
+		for _, entry := range strings.Split(weights, ",") {
+			name, weight, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			value, err := strconv.ParseFloat(weight, 64)
+			if !ok || err != nil || value < 0 {
+				return Consensus{}, fmt.Errorf("invalid consensus weight %q, expected provider=weight", entry)
+			}
+			consensus.Weights[strings.TrimSpace(name)] = value
+		}
+	}
+	return consensus, nil
+}
+
+// ConsensusQuote is one provider's part in a consensus rate.
+type ConsensusQuote struct {
+	Provider string  `json:"provider"`
+	Rate     float64 `json:"rate,omitempty"`
+	// Outlier is set for rates further from the median than the tolerance.
+	Outlier bool   `json:"outlier,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ConsensusAdapter asks every provider for the same rates at once and
+// combines their answers, so one provider publishing a bad rate can't skew
+// the result. RateResult.Quotes lists what each provider answered. Time
+// series and currency lists come from the first provider that has them.
+type ConsensusAdapter struct {
+	providers []NamedAdapter
+	consensus Consensus
+}
+
+// NewConsensus combines the rates of providers as consensus says.
+func NewConsensus(providers []NamedAdapter, consensus Consensus) *ConsensusAdapter {
+	return &ConsensusAdapter{providers: providers, consensus: consensus}
+}
+
+func (a *ConsensusAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	return a.combine(from, to, a.gather(func(provider NamedAdapter) (RateResult, error) {
+		return provider.Adapter.GetRate(ctx, from, to)
+	}))
+}
+
+func (a *ConsensusAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	result, err := a.combine(from, to, a.gather(func(provider NamedAdapter) (RateResult, error) {
+		return provider.Adapter.GetExchangeRateAt(ctx, from, to, date)
+	}))
+	if err == nil {
+		result.Date = date.Format("2006-01-02")
+	}
+	return result, err
+}
+
+func (a *ConsensusAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	tables := make([]map[string]RateResult, len(a.providers))
+	errs := make([]error, len(a.providers))
+	var wg sync.WaitGroup
+	for i, provider := range a.providers {
+		wg.Add(1)
+		go func(i int, provider NamedAdapter) {
+			defer wg.Done()
+			tables[i], errs[i] = FetchRates(ctx, provider.Adapter, from, to)
+		}(i, provider)
+	}
+	wg.Wait()
+
+	// Every target quoted by some provider gets the consensus of those that
+	// quote it
+	targets := to
+	if targets == nil {
+		seen := map[string]bool{}
+		for _, table := range tables {
+			for target := range table {
+				if !seen[target] {
+					seen[target] = true
+					targets = append(targets, target)
+				}
+			}
+		}
+		if len(targets) == 0 {
+			if unsupported(errs) {
+				return nil, ErrRateTableNotSupported
+			}
+			var failures []error
+			for i, err := range errs {
+				failures = append(failures, fmt.Errorf("%s: %w", a.providers[i].Name, err))
+			}
+			return nil, a.failed(from, "*", failures)
+		}
+	}
+
+	results := make(map[string]RateResult, len(targets))
+	for _, target := range targets {
+		answers := make([]consensusAnswer, len(a.providers))
+		for i, provider := range a.providers {
+			answers[i].provider = provider.Name
+			if result, ok := tables[i][target]; ok {
+				answers[i].result = result
+			} else if answers[i].err = errs[i]; errs[i] == nil {
+				answers[i].err = fmt.Errorf("%s has no rate for %s/%s: %w", provider.Name, from, target, ErrProviderCurrency)
+			}
+		}
+		result, err := a.combine(from, target, answers)
+		if err != nil {
+			return nil, err
+		}
+		results[target] = result
+	}
+	return results, nil
+}
+
+// consensusAnswer is a provider's answer to a consensus lookup.
+type consensusAnswer struct {
+	provider string
+	result   RateResult
+	err      error
+}
+
+// gather makes lookup of every provider at once.
+func (a *ConsensusAdapter) gather(lookup func(NamedAdapter) (RateResult, error)) []consensusAnswer {
+	answers := make([]consensusAnswer, len(a.providers))
+	var wg sync.WaitGroup
+	for i, provider := range a.providers {
+		wg.Add(1)
+		go func(i int, provider NamedAdapter) {
+			defer wg.Done()
+			result, err := lookup(provider)
+			answers[i] = consensusAnswer{provider: provider.Name, result: result, err: err}
+		}(i, provider)
+	}
+	wg.Wait()
+	return answers
+}
+
+// combine takes the median of the answered rates, flags the outliers and,
+// with the mean method, averages the rest by weight.
+func (a *ConsensusAdapter) combine(from, to string, answers []consensusAnswer) (RateResult, error) {
+	var rates []float64
+	var errs []error
+	for _, answer := range answers {
+		if answer.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", answer.provider, answer.err))
+			continue
+		}
+		rates = append(rates, answer.result.Rate)
+	}
+	if len(rates) == 0 || len(rates) < a.consensus.MinProviders {
+		log.Printf("consensus for %s/%s: only %d of %d providers answered", from, to, len(rates), len(answers))
+		return RateResult{}, a.failed(from, to, errs)
+	}
+	median := medianOf(rates)
+
+	result := RateResult{Rate: median, Base: from, Target: to, Provider: "consensus", Cached: true}
+	var sum, weights float64
+	for _, answer := range answers {
+		quote := ConsensusQuote{Provider: answer.provider}
+		if answer.err != nil {
+			quote.Error = answer.err.Error()
+			result.Quotes = append(result.Quotes, quote)
+			continue
+		}
+		quote.Rate = answer.result.Rate
+		quote.Outlier = median != 0 && abs(quote.Rate-median)/median*100 > a.consensus.Tolerance
+		result.Quotes = append(result.Quotes, quote)
+
+		if !quote.Outlier {
+			weight, ok := a.consensus.Weights[answer.provider]
+			if !ok {
+				weight = 1
+			}
+			sum += weight * quote.Rate
+			weights += weight
+		}
+		if answer.result.Timestamp.After(result.Timestamp) {
+			result.Timestamp = answer.result.Timestamp
+		}
+		result.Cached = result.Cached && answer.result.Cached
+		if result.FetchedAt.IsZero() || answer.result.FetchedAt.Before(result.FetchedAt) {
+			result.FetchedAt = answer.result.FetchedAt
+		}
+	}
+	if a.consensus.Method == ConsensusMean && weights > 0 {
+		result.Rate = sum / weights
+	}
+	if !result.Cached {
+		result.FetchedAt = time.Time{}
+	}
+	return result, nil
+}
+
+// failed returns the error of a consensus without enough answers. When every
+// provider failed the same way, like not knowing a currency, it keeps that
+// kind for errors.Is.
+func (a *ConsensusAdapter) failed(from, to string, errs []error) error {
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	err := fmt.Errorf("no consensus for %s/%s from fewer than %d providers: %s", from, to, a.consensus.MinProviders, strings.Join(messages, "; "))
+	for _, kind := range []error{ErrProviderCurrency, ErrHistoricalNotSupported} {
+		if len(errs) > 0 && allAre(errs, kind) {
+			return fmt.Errorf("%v: %w", err, kind)
+		}
+	}
+	return err
+}
+
+func allAre(errs []error, kind error) bool {
+	for _, err := range errs {
+		if !errors.Is(err, kind) {
+			return false
+		}
+	}
+	return true
+}
+
+// unsupported reports whether no provider has full rate tables.
+func unsupported(errs []error) bool {
+	for _, err := range errs {
+		if err == nil || !errors.Is(err, ErrRateTableNotSupported) {
+			return false
+		}
+	}
+	return true
+}
+
+func medianOf(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func (a *ConsensusAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	return NewFallback(a.providers).GetTimeSeries(ctx, from, to, start, end)
+}
+
+func (a *ConsensusAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	return NewFallback(a.providers).GetSymbols(ctx)
+}
+
+func (a *ConsensusAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := a.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (a *ConsensusAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := a.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
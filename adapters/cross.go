@@ -0,0 +1,255 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"cubetiq-samples/exchanger-go/config"
+)
+
+// CrossAdapter answers pairs its provider doesn't quote directly through
+// intermediate currencies, e.g. KHR/THB as KHR/USD times USD/THB, and
+// records the currencies it went through in RateResult.Path. Historical
+// rates are never crossed.
+type CrossAdapter struct {
+	adapter ExchangeRateAdapter
+	via     []string
+	hops    int
+}
+
+// NewCross crosses the pairs adapter lacks through the currencies of
+// EXCHANGER_CROSS_CURRENCIES, USD and EUR by default, chaining at most
+// EXCHANGER_CROSS_MAX_HOPS of them.
+func NewCross(adapter ExchangeRateAdapter) *CrossAdapter {
+	list, ok := os.LookupEnv("EXCHANGER_CROSS_CURRENCIES")
+	if !ok {
+		list = "USD,EUR"
+	}
+	var via []string
+	for _, currency := range strings.Split(list, ",") {
+		if currency = strings.ToUpper(strings.TrimSpace(currency)); currency != "" {
+			via = append(via, currency)
+		}
+	}
+	return &CrossAdapter{adapter: adapter, via: via, hops: config.Int("EXCHANGER_CROSS_MAX_HOPS", 2)}
+}
+
+func (c *CrossAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	result, err := c.adapter.GetRate(ctx, from, to)
+	if !errors.Is(err, ErrProviderCurrency) || len(c.via) == 0 || c.hops <= 0 {
+		return result, err
+	}
+	crossed, ok, crossErr := newRateGraph(c.adapter, c.via).cross(ctx, from, to, c.hops)
+	switch {
+	case crossErr != nil:
+		return RateResult{}, crossErr
+	case !ok:
+		return RateResult{}, err
+	}
+	return crossed, nil
+}
+
+func (c *CrossAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	results, err := FetchRates(ctx, c.adapter, from, to)
+	if to == nil || !errors.Is(err, ErrProviderCurrency) {
+		return results, err
+	}
+
+	// Some target is missing, so look each up on its own to cross just those
+	pairs := make([]Pair, len(to))
+	for i, target := range to {
+		pairs[i] = Pair{From: from, To: target}
+	}
+	results = make(map[string]RateResult, len(to))
+	for pair, rate := range FetchPairs(ctx, c, pairs, config.Int("EXCHANGER_BATCH_WORKERS", 8)) {
+		if rate.Err != nil {
+			return nil, rate.Err
+		}
+		results[pair.To] = rate.Result
+	}
+	return results, nil
+}
+
+// rateGraph is the graph of the rates a provider quotes between the
+// intermediate currencies and the rest, whose edges are looked up as a search
+// reaches them: from the rate tables of the intermediate currencies, or as
+// single pairs from providers without tables.
+type rateGraph struct {
+	adapter ExchangeRateAdapter
+	via     []string
+	tables  map[string]map[string]RateResult
+}
+
+func newRateGraph(adapter ExchangeRateAdapter, via []string) *rateGraph {
+	return &rateGraph{adapter: adapter, via: via, tables: map[string]map[string]RateResult{}}
+}
+
+// crossStep is a path of the search with the rate along it.
+type crossStep struct {
+	path []string
+	legs []RateResult
+}
+
+// cross searches breadth first for the path from from to to through the
+// fewest intermediate currencies, trying them in order. It reports false when
+// no path through at most hops of them exists.
+func (g *rateGraph) cross(ctx context.Context, from, to string, hops int) (RateResult, bool, error) {
+	queue := []crossStep{{path: []string{from}}}
+	for len(queue) > 0 {
+		step := queue[0]
+		queue = queue[1:]
+		last := step.path[len(step.path)-1]
+
+		if len(step.legs) > 0 {
+			leg, ok, err := g.edge(ctx, last, to)
+			if err != nil {
+				return RateResult{}, false, err
+			}
+			if ok {
+				return crossResult(from, to, append(step.path, to), append(step.legs, leg)), true, nil
+			}
+		}
+		if len(step.legs) == hops {
+			continue
+		}
+		for _, currency := range g.via {
+			if currency == to || containsCurrency(step.path, currency) {
+				continue
+			}
+			leg, ok, err := g.edge(ctx, last, currency)
+			if err != nil {
+				return RateResult{}, false, err
+			}
+			if ok {
+				queue = append(queue, crossStep{
+					path: append(append([]string{}, step.path...), currency),
+					legs: append(append([]RateResult{}, step.legs...), leg),
+				})
+			}
+		}
+	}
+	return RateResult{}, false, nil
+}
+
+// edge returns the rate from from to to, one of them an intermediate
+// currency, from the intermediate's table or, when the provider has no
+// tables, a lookup of the pair. It reports false when the provider doesn't
+// quote the pair.
+func (g *rateGraph) edge(ctx context.Context, from, to string) (RateResult, bool, error) {
+	tables := false
+	if containsCurrency(g.via, from) {
+		table, err := g.table(ctx, from)
+		if err != nil && !errors.Is(err, ErrRateTableNotSupported) {
+			return RateResult{}, false, err
+		}
+		if result, ok := table[to]; ok && result.Rate != 0 {
+			return result, true, nil
+		}
+		tables = tables || err == nil
+	}
+	if containsCurrency(g.via, to) {
+		table, err := g.table(ctx, to)
+		if err != nil && !errors.Is(err, ErrRateTableNotSupported) {
+			return RateResult{}, false, err
+		}
+		if result, ok := table[from]; ok && result.Rate != 0 {
+			result.Base, result.Target, result.Rate = from, to, 1/result.Rate
+			return result, true, nil
+		}
+		tables = tables || err == nil
+	}
+	if tables {
+		return RateResult{}, false, nil
+	}
+
+	result, err := g.adapter.GetRate(ctx, from, to)
+	switch {
+	case errors.Is(err, ErrProviderCurrency):
+		return RateResult{}, false, nil
+	case err != nil:
+		return RateResult{}, false, err
+	case result.Rate == 0:
+		return RateResult{}, false, nil
+	}
+	return result, true, nil
+}
+
+// table returns the rate table of base, fetched once per search. A base the
+// provider doesn't know has an empty table.
+func (g *rateGraph) table(ctx context.Context, base string) (map[string]RateResult, error) {
+	if table, ok := g.tables[base]; ok {
+		return table, nil
+	}
+	table, err := FetchRates(ctx, g.adapter, base, nil)
+	switch {
+	case errors.Is(err, ErrProviderCurrency):
+		table = map[string]RateResult{}
+	case err != nil:
+		return nil, err
+	}
+	g.tables[base] = table
+	return table, nil
+}
+
+func containsCurrency(currencies []string, currency string) bool {
+	for _, c := range currencies {
+		if c == currency {
+			return true
+		}
+	}
+	return false
+}
+
+// crossResult multiplies the rates of legs into the rate of from/to, as old
+// as its oldest leg and cached only when they all were.
+func crossResult(from, to string, path []string, legs []RateResult) RateResult {
+	result := RateResult{Rate: 1, Base: from, Target: to, Provider: legs[0].Provider, Path: path, Cached: true}
+	for _, leg := range legs {
+		result.Rate *= leg.Rate
+		if result.Timestamp.IsZero() || leg.Timestamp.Before(result.Timestamp) {
+			result.Timestamp = leg.Timestamp
+		}
+		if result.Date == "" || (leg.Date != "" && leg.Date < result.Date) {
+			result.Date = leg.Date
+		}
+		result.Cached = result.Cached && leg.Cached
+		if result.FetchedAt.IsZero() || leg.FetchedAt.Before(result.FetchedAt) {
+			result.FetchedAt = leg.FetchedAt
+		}
+	}
+	if !result.Cached {
+		result.FetchedAt = time.Time{}
+	}
+	return result
+}
+
+func (c *CrossAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	return c.adapter.GetExchangeRateAt(ctx, from, to, date)
+}
+
+func (c *CrossAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	return FetchTimeSeries(ctx, c.adapter, from, to, start, end)
+}
+
+func (c *CrossAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	return FetchSymbols(ctx, c.adapter)
+}
+
+func (c *CrossAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := c.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (c *CrossAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := c.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
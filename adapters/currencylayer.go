@@ -0,0 +1,132 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CurrencyLayerAdapter uses CurrencyLayer (apilayer). Quotes are keyed by the
+// concatenated pair, e.g. "USDEUR", and the free tier only quotes against USD,
+// so every pair is crossed through USD server-side.
+type CurrencyLayerAdapter struct {
+	client *http.Client
+	apiKey string
+}
+
+// NewCurrencyLayer returns an adapter using the CurrencyLayer access key.
+func NewCurrencyLayer(client *http.Client, apiKey string) *CurrencyLayerAdapter {
+	return &CurrencyLayerAdapter{client: client, apiKey: apiKey}
+}
+
+func (l *CurrencyLayerAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	return l.fetchRate(ctx, "live", url.Values{}, from, to)
+}
+
+func (l *CurrencyLayerAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	return l.fetchRate(ctx, "historical", url.Values{"date": {date.Format("2006-01-02")}}, from, to)
+}
+
+func (l *CurrencyLayerAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	query := url.Values{"access_key": {l.apiKey}}
+	body, err := fetchProvider(ctx, l.client, providerRequest{Method: http.MethodGet, URL: "http://api.currencylayer.com/list?" + query.Encode()})
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Success    bool              `json:"success"`
+		Currencies map[string]string `json:"currencies"`
+		Error      apilayerError     `json:"error"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	if !data.Success {
+		return nil, data.Error.providerError("currencylayer")
+	}
+	return data.Currencies, nil
+}
+
+func (l *CurrencyLayerAdapter) fetchRate(ctx context.Context, endpoint string, query url.Values, from, to string) (RateResult, error) {
+	// Build the API URL, always quoting against the USD source
+	query.Set("access_key", l.apiKey)
+	query.Set("source", "USD")
+	query.Set("currencies", from+","+to)
+	apiURL := "http://api.currencylayer.com/" + endpoint + "?" + query.Encode()
+
+	// Send a GET request to the API
+	body, err := fetchProvider(ctx, l.client, providerRequest{Method: http.MethodGet, URL: apiURL})
+	if err != nil {
+		return RateResult{}, err
+	}
+
+	// Errors come in the body of a 200 response, without the quotes
+	if err := apilayerFailure("currencylayer", body); err != nil {
+		return RateResult{}, err
+	}
+
+	// Validate the response against the bundled schema
+	if err := validateProviderResponse("currencylayer", body, l.apiKey); err != nil {
+		return RateResult{}, err
+	}
+
+	// Unmarshal the JSON response
+	var data struct {
+		Success   bool               `json:"success"`
+		Timestamp int64              `json:"timestamp"`
+		Source    string             `json:"source"`
+		Quotes    map[string]float64 `json:"quotes"`
+		Error     apilayerError      `json:"error"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return RateResult{}, err
+	}
+	if !data.Success {
+		return RateResult{}, data.Error.providerError("currencylayer")
+	}
+
+	// Quotes are USD per pair; USD itself is implied
+	quote := func(currency string) (float64, bool) {
+		if currency == data.Source {
+			return 1, true
+		}
+		value, ok := data.Quotes[data.Source+currency]
+		return value, ok && value > 0
+	}
+	fromQuote, ok := quote(from)
+	if !ok {
+		return RateResult{}, fmt.Errorf("currencylayer has no quote for %s: %w", from, ErrProviderCurrency)
+	}
+	toQuote, ok := quote(to)
+	if !ok {
+		return RateResult{}, fmt.Errorf("currencylayer has no quote for %s: %w", to, ErrProviderCurrency)
+	}
+
+	return RateResult{
+		Rate:      toQuote / fromQuote,
+		Base:      from,
+		Target:    to,
+		Timestamp: time.Unix(data.Timestamp, 0).UTC(),
+		Provider:  "currencylayer",
+	}, nil
+}
+
+func (l *CurrencyLayerAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := l.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (l *CurrencyLayerAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := l.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
@@ -0,0 +1,228 @@
+package adapters
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	ecbDailyURL      = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+	ecbRecentURL     = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist-90d.xml"
+	ecbHistoricalURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.xml"
+)
+
+// ecbRecentDays is how far back the smaller 90 day feed reaches.
+const ecbRecentDays = 90
+
+// ECBAdapter uses the European Central Bank daily reference rates. The feed
+// quotes every currency against EUR, so other pairs are crossed through EUR.
+type ECBAdapter struct {
+	client *http.Client
+}
+
+// NewECB returns an adapter for the ECB reference rates.
+func NewECB(client *http.Client) *ECBAdapter {
+	return &ECBAdapter{client: client}
+}
+
+// ecbEnvelope mirrors the nested Cube elements of the eurofxref feed.
+type ecbEnvelope struct {
+	Cube struct {
+		Days []struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string  `xml:"currency,attr"`
+				Rate     float64 `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+// parseECBRates returns the EUR-based rates and reference date for date in an
+// eurofxref XML document, or for the latest day when date is zero.
+func parseECBRates(body []byte, date time.Time) (Rates, time.Time, error) {
+	var envelope ecbEnvelope
+	if err := xml.Unmarshal(body, &envelope); err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(envelope.Cube.Days) == 0 {
+		return nil, time.Time{}, fmt.Errorf("ECB feed contains no reference rates")
+	}
+
+	// Feeds list days newest first
+	day := envelope.Cube.Days[0]
+	if !date.IsZero() {
+		found := false
+		for _, candidate := range envelope.Cube.Days {
+			if candidate.Time == date.Format("2006-01-02") {
+				day, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			return nil, time.Time{}, fmt.Errorf("ECB published no reference rates on %s: %w", date.Format("2006-01-02"), ErrNoRatesOnDate)
+		}
+	}
+
+	dayDate, err := time.Parse("2006-01-02", day.Time)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	rates := Rates{"EUR": 1}
+	for _, rate := range day.Rates {
+		rates[rate.Currency] = rate.Rate
+	}
+	return rates, dayDate, nil
+}
+
+func (e *ECBAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	return e.fetchRate(ctx, ecbDailyURL, from, to, time.Time{})
+}
+
+func (e *ECBAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	body, err := fetchProvider(ctx, e.client, providerRequest{Method: http.MethodGet, URL: ecbDailyURL})
+	if err != nil {
+		return nil, err
+	}
+
+	rates, date, err := parseECBRates(body, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	fromRate, ok := rates[from]
+	if !ok {
+		return nil, fmt.Errorf("ECB publishes no reference rate for %s: %w", from, ErrProviderCurrency)
+	}
+	if to == nil {
+		to = tableTargets(rates)
+	}
+
+	// Cross every target through EUR
+	results := make(map[string]RateResult, len(to))
+	for _, target := range to {
+		toRate, ok := rates[target]
+		if !ok {
+			return nil, fmt.Errorf("ECB publishes no reference rate for %s: %w", target, ErrProviderCurrency)
+		}
+		results[target] = RateResult{
+			Rate:      toRate / fromRate,
+			Base:      from,
+			Target:    target,
+			Timestamp: date,
+			Date:      date.Format("2006-01-02"),
+			Provider:  "ecb",
+		}
+	}
+	return results, nil
+}
+
+func (e *ECBAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	body, err := fetchProvider(ctx, e.client, providerRequest{Method: http.MethodGet, URL: ecbDailyURL})
+	if err != nil {
+		return nil, err
+	}
+
+	// The feed carries codes only
+	rates, _, err := parseECBRates(body, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	symbols := make(map[string]string, len(rates))
+	for code := range rates {
+		symbols[code] = ""
+	}
+	return symbols, nil
+}
+
+func (e *ECBAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	// Only download the full history when the 90 day feed doesn't reach back far enough
+	feed := ecbRecentURL
+	if time.Since(date) > ecbRecentDays*24*time.Hour {
+		feed = ecbHistoricalURL
+	}
+	return e.fetchRate(ctx, feed, from, to, date)
+}
+
+func (e *ECBAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	feed := ecbRecentURL
+	if time.Since(start) > ecbRecentDays*24*time.Hour {
+		feed = ecbHistoricalURL
+	}
+
+	body, err := fetchProvider(ctx, e.client, providerRequest{Method: http.MethodGet, URL: feed})
+	if err != nil {
+		return nil, err
+	}
+
+	var envelope ecbEnvelope
+	if err := xml.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+
+	// Collect the business days inside the range; weekends have no entry
+	first, last := start.Format("2006-01-02"), end.Format("2006-01-02")
+	daily := map[string]Rates{}
+	for _, day := range envelope.Cube.Days {
+		if day.Time < first || day.Time > last {
+			continue
+		}
+		rates := Rates{}
+		for _, rate := range day.Rates {
+			rates[rate.Currency] = rate.Rate
+		}
+		daily[day.Time] = rates
+	}
+	return seriesFromDailyRates("ecb", "EUR", from, to, daily)
+}
+
+func (e *ECBAdapter) fetchRate(ctx context.Context, feed, from, to string, day time.Time) (RateResult, error) {
+	// Send a GET request for the feed
+	body, err := fetchProvider(ctx, e.client, providerRequest{Method: http.MethodGet, URL: feed})
+	if err != nil {
+		return RateResult{}, err
+	}
+
+	rates, date, err := parseECBRates(body, day)
+	if err != nil {
+		return RateResult{}, err
+	}
+
+	fromRate, ok := rates[from]
+	if !ok {
+		return RateResult{}, fmt.Errorf("ECB publishes no reference rate for %s: %w", from, ErrProviderCurrency)
+	}
+	toRate, ok := rates[to]
+	if !ok {
+		return RateResult{}, fmt.Errorf("ECB publishes no reference rate for %s: %w", to, ErrProviderCurrency)
+	}
+
+	// Cross the pair through EUR
+	return RateResult{
+		Rate:      toRate / fromRate,
+		Base:      from,
+		Target:    to,
+		Timestamp: date,
+		Date:      date.Format("2006-01-02"),
+		Provider:  "ecb",
+	}, nil
+}
+
+func (e *ECBAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := e.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (e *ECBAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := e.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
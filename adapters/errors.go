@@ -0,0 +1,81 @@
+package adapters
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Kinds of ProviderError, for matching with errors.Is.
+var (
+	// ErrProviderAuth means the provider rejected the API key.
+	ErrProviderAuth = errors.New("provider rejected the API key")
+	// ErrProviderQuota means the API key used up its quota at the provider.
+	ErrProviderQuota = errors.New("provider quota is exceeded")
+	// ErrProviderPlan means the API key's plan doesn't cover the request.
+	ErrProviderPlan = errors.New("provider plan doesn't cover the request")
+	// ErrProviderCurrency means the provider doesn't know a currency.
+	ErrProviderCurrency = errors.New("provider doesn't know the currency")
+	// ErrProviderFailed is any other error a provider reports.
+	ErrProviderFailed = errors.New("provider failed")
+)
+
+// ProviderError is an error a provider reports in the body of a successful
+// response, such as fixer.io's {"success": false}.
+type ProviderError struct {
+	Provider string
+	// Code is the provider's own error code.
+	Code    int
+	Message string
+	// Kind is one of the ErrProvider errors.
+	Kind error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s error %d: %s", e.Provider, e.Code, e.Message)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Kind
+}
+
+// apilayerError is the error object of the apilayer APIs, fixer.io and
+// CurrencyLayer, sent along with "success": false.
+type apilayerError struct {
+	Code int    `json:"code"`
+	Type string `json:"type"`
+	Info string `json:"info"`
+}
+
+// providerError classifies the error by the codes apilayer documents.
+func (e apilayerError) providerError(provider string) *ProviderError {
+	kind := ErrProviderFailed
+	switch e.Code {
+	case 101, 102:
+		kind = ErrProviderAuth
+	case 104:
+		kind = ErrProviderQuota
+	case 105:
+		kind = ErrProviderPlan
+	case 201, 202:
+		kind = ErrProviderCurrency
+	}
+	message := e.Info
+	if message == "" {
+		message = e.Type
+	}
+	return &ProviderError{Provider: provider, Code: e.Code, Message: message, Kind: kind}
+}
+
+// apilayerFailure returns the ProviderError of an apilayer response that
+// reports "success": false, or nil for a successful one.
+func apilayerFailure(provider string, body []byte) error {
+	var data struct {
+		Success *bool         `json:"success"`
+		Error   apilayerError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil || data.Success == nil || *data.Success {
+		return nil
+	}
+	return data.Error.providerError(provider)
+}
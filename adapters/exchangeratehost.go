@@ -0,0 +1,167 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ExchangeRateHostAdapter uses exchangerate.host, which needs no API key and
+// quotes rates against any requested base currency.
+type ExchangeRateHostAdapter struct {
+	client *http.Client
+	apiKey string
+}
+
+// NewExchangeRateHost returns an adapter for exchangerate.host; apiKey may be
+// empty.
+func NewExchangeRateHost(client *http.Client, apiKey string) *ExchangeRateHostAdapter {
+	return &ExchangeRateHostAdapter{client: client, apiKey: apiKey}
+}
+
+func (e *ExchangeRateHostAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	return e.fetchRate(ctx, "latest", from, to)
+}
+
+func (e *ExchangeRateHostAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	return e.fetchRate(ctx, date.Format("2006-01-02"), from, to)
+}
+
+func (e *ExchangeRateHostAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	query := url.Values{
+		"base":       {from},
+		"symbols":    {to},
+		"start_date": {start.Format("2006-01-02")},
+		"end_date":   {end.Format("2006-01-02")},
+	}
+	if e.apiKey != "" {
+		query.Set("access_key", e.apiKey)
+	}
+
+	body, err := fetchProvider(ctx, e.client, providerRequest{Method: http.MethodGet, URL: "https://api.exchangerate.host/timeseries?" + query.Encode()})
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Rates map[string]Rates `json:"rates"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	return seriesFromDailyRates("exchangeratehost", from, from, to, data.Rates)
+}
+
+func (e *ExchangeRateHostAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	return e.fetchRates(ctx, "latest", from, to)
+}
+
+func (e *ExchangeRateHostAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	query := url.Values{}
+	if e.apiKey != "" {
+		query.Set("access_key", e.apiKey)
+	}
+
+	body, err := fetchProvider(ctx, e.client, providerRequest{Method: http.MethodGet, URL: "https://api.exchangerate.host/symbols?" + query.Encode()})
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Symbols map[string]struct {
+			Description string `json:"description"`
+		} `json:"symbols"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+
+	symbols := make(map[string]string, len(data.Symbols))
+	for code, symbol := range data.Symbols {
+		symbols[code] = symbol.Description
+	}
+	return symbols, nil
+}
+
+// fetchRate queries endpoint, which is "latest" or a YYYY-MM-DD date.
+func (e *ExchangeRateHostAdapter) fetchRate(ctx context.Context, endpoint, from, to string) (RateResult, error) {
+	results, err := e.fetchRates(ctx, endpoint, from, []string{to})
+	if err != nil {
+		return RateResult{}, err
+	}
+	return results[to], nil
+}
+
+func (e *ExchangeRateHostAdapter) fetchRates(ctx context.Context, endpoint, from string, to []string) (map[string]RateResult, error) {
+	// Build the API URL with the source currency as base
+	query := url.Values{"base": {from}}
+	if len(to) > 0 {
+		query.Set("symbols", strings.Join(to, ","))
+	}
+	if e.apiKey != "" {
+		query.Set("access_key", e.apiKey)
+	}
+	apiURL := "https://api.exchangerate.host/" + endpoint + "?" + query.Encode()
+
+	// Send a GET request to the API
+	body, err := fetchProvider(ctx, e.client, providerRequest{Method: http.MethodGet, URL: apiURL})
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the response against the bundled schema
+	if err := validateProviderResponse("exchangeratehost", body, e.apiKey); err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the JSON response
+	var data struct {
+		Base  string `json:"base"`
+		Date  string `json:"date"`
+		Rates Rates  `json:"rates"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	date, _ := time.Parse("2006-01-02", data.Date)
+
+	// Return the exchange rate of each target, or of every currency quoted
+	if to == nil {
+		to = tableTargets(data.Rates)
+	}
+	results := make(map[string]RateResult, len(to))
+	for _, target := range to {
+		rate, ok := data.Rates[target]
+		if !ok || rate <= 0 {
+			return nil, fmt.Errorf("exchangerate.host has no rate for %s/%s: %w", from, target, ErrProviderCurrency)
+		}
+		results[target] = RateResult{
+			Rate:      rate,
+			Base:      from,
+			Target:    target,
+			Timestamp: date,
+			Provider:  "exchangeratehost",
+		}
+	}
+	return results, nil
+}
+
+func (e *ExchangeRateHostAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := e.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (e *ExchangeRateHostAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := e.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
@@ -0,0 +1,250 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ExternalAdapter fetches rates from an external adapter: a separate process,
+// such as a sidecar, that serves the external adapter protocol at its base
+// URL so proprietary or regional sources can be added without changing this
+// service. The protocol is two JSON endpoints:
+//
+//	GET /v1/rates?base=USD&symbols=EUR,KHR&date=2024-05-01
+//	{"base":"USD","timestamp":"2024-05-01T16:00:00Z","date":"2024-05-01","rates":{"EUR":0.92,"KHR":4100}}
+//
+//	GET /v1/currencies
+//	{"currencies":{"USD":"US Dollar","KHR":"Cambodian Riel"}}
+//
+// Without symbols the rates request asks for the whole table, and date asks
+// for the rates of a past day. In the answer only rates is required; quality
+// and confidence may annotate the quotes as for the ratesservice source.
+// Failures answer an error status with {"code":"...","message":"..."},
+// where code is one of externalErrorKinds or NOT_SUPPORTED for requests the
+// adapter doesn't serve, such as past days. The source's API key, when one
+// is configured, is sent as a bearer token.
+type ExternalAdapter struct {
+	client *http.Client
+	name   string
+	url    string
+	apiKey string
+}
+
+// externalErrorKinds are the error codes of the protocol and the errors they
+// stand for.
+var externalErrorKinds = map[string]error{
+	"UNAUTHORIZED":     ErrProviderAuth,
+	"QUOTA_EXCEEDED":   ErrProviderQuota,
+	"NOT_ENTITLED":     ErrProviderPlan,
+	"UNKNOWN_CURRENCY": ErrProviderCurrency,
+}
+
+// externalNamePattern keeps source names usable in EXCHANGER_KEY_<PROVIDER>
+// and the other per-provider settings.
+var externalNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ExternalAdaptersFromEnv reads the external adapters declared in
+// EXCHANGER_EXTERNAL_ADAPTERS as comma-separated name=url entries, e.g.
+// bankx=http://bankx-adapter:9000, keyed by source name.
+func ExternalAdaptersFromEnv() (map[string]string, error) {
+	urls := map[string]string{}
+	declared := os.Getenv("EXCHANGER_EXTERNAL_ADAPTERS")
+	if declared == "" {
+		return urls, nil
+	}
+	for _, entry := range strings.Split(declared, ",") {
+		name, rawURL, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || !externalNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid external adapter %q, expected name=url with a lowercase name", entry)
+		}
+		parsed, err := url.Parse(rawURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("external adapter %s: %q is not an http or https URL", name, rawURL)
+		}
+		if _, ok := urls[name]; ok {
+			return nil, fmt.Errorf("external adapter %s is declared twice", name)
+		}
+		urls[name] = strings.TrimSuffix(rawURL, "/")
+	}
+	return urls, nil
+}
+
+// NewExternal returns an adapter for the external adapter serving the source
+// name at url.
+func NewExternal(client *http.Client, name, url, apiKey string) *ExternalAdapter {
+	return &ExternalAdapter{client: client, name: name, url: url, apiKey: apiKey}
+}
+
+func (e *ExternalAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	results, err := e.fetchRates(ctx, from, []string{to}, "")
+	if err != nil {
+		return RateResult{}, err
+	}
+	return results[to], nil
+}
+
+func (e *ExternalAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	results, err := e.fetchRates(ctx, from, []string{to}, date.Format("2006-01-02"))
+	if err != nil {
+		return RateResult{}, err
+	}
+	return results[to], nil
+}
+
+func (e *ExternalAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	return e.fetchRates(ctx, from, to, "")
+}
+
+func (e *ExternalAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	body, err := e.fetch(ctx, "/v1/currencies", nil)
+	if err != nil {
+		return nil, e.failure(err, ErrSymbolsNotSupported)
+	}
+
+	var data struct {
+		Currencies map[string]string `json:"currencies"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("%s: invalid currencies response: %v", e.name, err)
+	}
+	return data.Currencies, nil
+}
+
+// fetchRates asks for the rates from from to each of to, or the whole table
+// when to is nil, on date when it is set.
+func (e *ExternalAdapter) fetchRates(ctx context.Context, from string, to []string, date string) (map[string]RateResult, error) {
+	query := url.Values{"base": {from}}
+	if to != nil {
+		query.Set("symbols", strings.Join(to, ","))
+	}
+	if date != "" {
+		query.Set("date", date)
+	}
+
+	unsupported := ErrProviderFailed
+	switch {
+	case date != "":
+		unsupported = ErrHistoricalNotSupported
+	case to == nil:
+		unsupported = ErrRateTableNotSupported
+	}
+	body, err := e.fetch(ctx, "/v1/rates", query)
+	if err != nil {
+		return nil, e.failure(err, unsupported)
+	}
+
+	// Validate the response against the protocol's bundled schema
+	if err := validateResponse("external", e.name, body, e.apiKey); err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Base       string    `json:"base"`
+		Timestamp  time.Time `json:"timestamp"`
+		Date       string    `json:"date"`
+		Rates      Rates     `json:"rates"`
+		Quality    string    `json:"quality"`
+		Confidence *float64  `json:"confidence"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("%s: invalid rates response: %v", e.name, err)
+	}
+	if data.Base != "" && data.Base != from {
+		return nil, fmt.Errorf("%s answered rates of %s when asked for %s", e.name, data.Base, from)
+	}
+	timestamp := data.Timestamp.UTC()
+	if data.Timestamp.IsZero() {
+		timestamp = time.Now().UTC()
+	}
+	if date != "" && data.Date == "" {
+		data.Date = date
+	}
+
+	if to == nil {
+		to = tableTargets(data.Rates)
+	}
+	results := make(map[string]RateResult, len(to))
+	for _, target := range to {
+		rate, ok := data.Rates[target]
+		if !ok || rate <= 0 {
+			return nil, fmt.Errorf("%s has no rate for %s/%s: %w", e.name, from, target, ErrProviderCurrency)
+		}
+		results[target] = RateResult{
+			Rate:       rate,
+			Base:       from,
+			Target:     target,
+			Timestamp:  timestamp,
+			Provider:   e.name,
+			Date:       data.Date,
+			Quality:    data.Quality,
+			Confidence: data.Confidence,
+		}
+	}
+	return results, nil
+}
+
+// fetch sends a GET for path with query to the external adapter.
+func (e *ExternalAdapter) fetch(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	target := e.url + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	header := http.Header{"Accept": {"application/json"}}
+	if e.apiKey != "" {
+		header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+	return fetchProvider(ctx, e.client, providerRequest{Method: http.MethodGet, URL: target, Header: header})
+}
+
+// failure classifies err by the code of the error body the external adapter
+// answered with; NOT_SUPPORTED stands for unsupported. Errors without a
+// known code are returned as they are.
+func (e *ExternalAdapter) failure(err error, unsupported error) error {
+	var statusErr *UpstreamStatusError
+	if !errors.As(err, &statusErr) {
+		return err
+	}
+	var answer struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(statusErr.Body, &answer) != nil {
+		return err
+	}
+	kind, ok := externalErrorKinds[answer.Code]
+	if answer.Code == "NOT_SUPPORTED" {
+		kind, ok = unsupported, true
+	}
+	if !ok {
+		return err
+	}
+	message := answer.Message
+	if message == "" {
+		message = answer.Code
+	}
+	return &ProviderError{Provider: e.name, Code: statusErr.StatusCode, Message: message, Kind: kind}
+}
+
+func (e *ExternalAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := e.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (e *ExternalAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := e.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
@@ -0,0 +1,170 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// NamedAdapter pairs an adapter with the source name it was selected by.
+type NamedAdapter struct {
+	Name    string
+	Adapter ExchangeRateAdapter
+}
+
+// FallbackAdapter tries providers in priority order and returns the first
+// successful result. RateResult.Provider reports which provider answered.
+// Providers failing with a stale cached rate are passed over too, and the
+// first such StaleError is carried in the error when every provider fails.
+type FallbackAdapter struct {
+	providers []NamedAdapter
+}
+
+// NewFallback tries providers in the given order.
+func NewFallback(providers []NamedAdapter) *FallbackAdapter {
+	return &FallbackAdapter{providers: providers}
+}
+
+func (f *FallbackAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	var failures []string
+	var stale staleFallback
+	for _, provider := range f.providers {
+		result, err := provider.Adapter.GetRate(ctx, from, to)
+		if err == nil {
+			return result, nil
+		}
+
+		log.Printf("provider %s failed for %s/%s, falling back: %v", provider.Name, from, to, err)
+		failures = append(failures, fmt.Sprintf("%s: %v", provider.Name, err))
+		stale.add(err)
+		if ctx.Err() != nil {
+			// The caller gave up, so there is no point in trying the rest
+			break
+		}
+	}
+	return RateResult{}, stale.wrap(fmt.Errorf("all providers failed: %s", strings.Join(failures, "; ")))
+}
+
+func (f *FallbackAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	var failures []string
+	var stale staleFallback
+	for _, provider := range f.providers {
+		results, err := FetchRates(ctx, provider.Adapter, from, to)
+		if err == nil {
+			return results, nil
+		}
+		if errors.Is(err, ErrRateTableNotSupported) {
+			continue
+		}
+
+		log.Printf("provider %s failed for %s/%s, falling back: %v", provider.Name, from, strings.Join(to, ","), err)
+		failures = append(failures, fmt.Sprintf("%s: %v", provider.Name, err))
+		stale.add(err)
+		if ctx.Err() != nil {
+			// The caller gave up, so there is no point in trying the rest
+			break
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil, ErrRateTableNotSupported
+	}
+	return nil, stale.wrap(fmt.Errorf("all providers failed: %s", strings.Join(failures, "; ")))
+}
+
+func (f *FallbackAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	var failures []string
+	for _, provider := range f.providers {
+		symbols, err := FetchSymbols(ctx, provider.Adapter)
+		if err == nil {
+			return symbols, nil
+		}
+		if errors.Is(err, ErrSymbolsNotSupported) {
+			continue
+		}
+
+		log.Printf("provider %s failed to list currencies, falling back: %v", provider.Name, err)
+		failures = append(failures, fmt.Sprintf("%s: %v", provider.Name, err))
+		if ctx.Err() != nil {
+			// The caller gave up, so there is no point in trying the rest
+			break
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil, ErrSymbolsNotSupported
+	}
+	return nil, fmt.Errorf("all providers failed: %s", strings.Join(failures, "; "))
+}
+
+func (f *FallbackAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	var failures []string
+	var stale staleFallback
+	for _, provider := range f.providers {
+		result, err := provider.Adapter.GetExchangeRateAt(ctx, from, to, date)
+		if err == nil {
+			return result, nil
+		}
+		if errors.Is(err, ErrHistoricalNotSupported) {
+			continue
+		}
+
+		log.Printf("provider %s failed for %s/%s on %s, falling back: %v", provider.Name, from, to, date.Format("2006-01-02"), err)
+		failures = append(failures, fmt.Sprintf("%s: %v", provider.Name, err))
+		stale.add(err)
+		if ctx.Err() != nil {
+			// The caller gave up, so there is no point in trying the rest
+			break
+		}
+	}
+
+	// Only report unsupported when no provider could even try
+	if len(failures) == 0 {
+		return RateResult{}, ErrHistoricalNotSupported
+	}
+	return RateResult{}, stale.wrap(fmt.Errorf("all providers failed: %s", strings.Join(failures, "; ")))
+}
+
+func (f *FallbackAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	var failures []string
+	for _, provider := range f.providers {
+		series, err := FetchTimeSeries(ctx, provider.Adapter, from, to, start, end)
+		if err == nil {
+			return series, nil
+		}
+		if errors.Is(err, ErrHistoricalNotSupported) {
+			continue
+		}
+
+		log.Printf("provider %s failed for %s/%s series, falling back: %v", provider.Name, from, to, err)
+		failures = append(failures, fmt.Sprintf("%s: %v", provider.Name, err))
+		if ctx.Err() != nil {
+			// The caller gave up, so there is no point in trying the rest
+			break
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil, ErrHistoricalNotSupported
+	}
+	return nil, fmt.Errorf("all providers failed: %s", strings.Join(failures, "; "))
+}
+
+func (f *FallbackAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := f.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (f *FallbackAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := f.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
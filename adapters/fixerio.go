@@ -0,0 +1,163 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FixerIoAdapter uses fixer.io, whose free tier quotes every rate against EUR.
+type FixerIoAdapter struct {
+	client *http.Client
+	apiKey string
+}
+
+// NewFixerIo returns an adapter using the fixer.io access key.
+func NewFixerIo(client *http.Client, apiKey string) *FixerIoAdapter {
+	return &FixerIoAdapter{client: client, apiKey: apiKey}
+}
+
+func (f *FixerIoAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	// Build the API URL
+	url := fmt.Sprintf("http://data.fixer.io/api/latest?access_key=%s&symbols=%s,%s", f.apiKey, from, to)
+	return f.fetchRate(ctx, url, from, to)
+}
+
+func (f *FixerIoAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	// Build the API URL with every target in one symbols list, or no list for the whole table
+	url := fmt.Sprintf("http://data.fixer.io/api/latest?access_key=%s", f.apiKey)
+	if len(to) > 0 {
+		url += fmt.Sprintf("&symbols=%s,%s", from, strings.Join(to, ","))
+	}
+	return f.fetchRates(ctx, url, from, to)
+}
+
+func (f *FixerIoAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	// Build the historical API URL
+	url := fmt.Sprintf("http://data.fixer.io/api/%s?access_key=%s&symbols=%s,%s", date.Format("2006-01-02"), f.apiKey, from, to)
+	return f.fetchRate(ctx, url, from, to)
+}
+
+func (f *FixerIoAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	// Build the time-series API URL; rates are quoted against EUR
+	url := fmt.Sprintf("http://data.fixer.io/api/timeseries?access_key=%s&start_date=%s&end_date=%s&symbols=%s,%s",
+		f.apiKey, start.Format("2006-01-02"), end.Format("2006-01-02"), from, to)
+
+	body, err := fetchProvider(ctx, f.client, providerRequest{Method: http.MethodGet, URL: url})
+	if err != nil {
+		return nil, err
+	}
+	if err := apilayerFailure("fixerio", body); err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Base  string           `json:"base"`
+		Rates map[string]Rates `json:"rates"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	return seriesFromDailyRates("fixerio", data.Base, from, to, data.Rates)
+}
+
+func (f *FixerIoAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	url := fmt.Sprintf("http://data.fixer.io/api/symbols?access_key=%s", f.apiKey)
+	body, err := fetchProvider(ctx, f.client, providerRequest{Method: http.MethodGet, URL: url})
+	if err != nil {
+		return nil, err
+	}
+	if err := apilayerFailure("fixerio", body); err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Symbols map[string]string `json:"symbols"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	return data.Symbols, nil
+}
+
+func (f *FixerIoAdapter) fetchRate(ctx context.Context, url, from, to string) (RateResult, error) {
+	results, err := f.fetchRates(ctx, url, from, []string{to})
+	if err != nil {
+		return RateResult{}, err
+	}
+	return results[to], nil
+}
+
+func (f *FixerIoAdapter) fetchRates(ctx context.Context, url, from string, to []string) (map[string]RateResult, error) {
+	// Send a GET request to the API
+	body, err := fetchProvider(ctx, f.client, providerRequest{Method: http.MethodGet, URL: url})
+	if err != nil {
+		return nil, err
+	}
+
+	// Errors come in the body of a 200 response, without the rates
+	if err := apilayerFailure("fixerio", body); err != nil {
+		return nil, err
+	}
+
+	// Validate the response against the bundled schema
+	if err := validateProviderResponse("fixerio", body, f.apiKey); err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the JSON response
+	var data struct {
+		Timestamp int64  `json:"timestamp"`
+		Rates     Rates  `json:"rates"`
+		Base      string `json:"base"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+
+	// Return the exchange rate of each target, rebased onto from
+	if to == nil {
+		if _, ok := data.Rates[from]; !ok {
+			return nil, fmt.Errorf("fixerio has no rate for %s: %w", from, ErrProviderCurrency)
+		}
+		to = tableTargets(data.Rates)
+	}
+	fromRate := data.Rates[from]
+	if fromRate <= 0 {
+		return nil, fmt.Errorf("fixerio has no rate for %s: %w", from, ErrProviderCurrency)
+	}
+	results := make(map[string]RateResult, len(to))
+	for _, target := range to {
+		rate, ok := data.Rates[target]
+		if !ok || rate <= 0 {
+			return nil, fmt.Errorf("fixerio has no rate for %s: %w", target, ErrProviderCurrency)
+		}
+		results[target] = RateResult{
+			Rate:      rate / fromRate,
+			Base:      from,
+			Target:    target,
+			Timestamp: time.Unix(data.Timestamp, 0).UTC(),
+			Provider:  "fixerio",
+		}
+	}
+	return results, nil
+}
+
+func (f *FixerIoAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := f.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (f *FixerIoAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := f.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
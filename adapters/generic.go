@@ -0,0 +1,234 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GenericAdapterConfig declares an HTTP/JSON rate source without code.
+//
+// URL is a template where {base}, {from}, {to} and {symbols} are replaced with
+// the requested currencies. HistoricalURL is the optional template for
+// historical rates and may also use {date} (YYYY-MM-DD). Auth places the API key in a query parameter or
+// header. RatesPath, BasePath and TimestampPath are JSONPath-style
+// expressions such as "$.data.rates" or "result[0].quotes"; the rates value
+// may be an object or an array of {"currency","rate"} objects.
+type GenericAdapterConfig struct {
+	Name          string `json:"name"`
+	URL           string `json:"url"`
+	HistoricalURL string `json:"historicalUrl"`
+	Method        string `json:"method"`
+	Auth          struct {
+		In     string `json:"in"`
+		Name   string `json:"name"`
+		Prefix string `json:"prefix"`
+	} `json:"auth"`
+	RatesPath     string `json:"ratesPath"`
+	BasePath      string `json:"basePath"`
+	TimestampPath string `json:"timestampPath"`
+}
+
+// LoadGenericAdapters reads a JSON array of GenericAdapterConfig.
+func LoadGenericAdapters(path string) (map[string]GenericAdapterConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []GenericAdapterConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, err
+	}
+
+	adapters := make(map[string]GenericAdapterConfig, len(configs))
+	for _, config := range configs {
+		if config.Name == "" || config.URL == "" || config.RatesPath == "" {
+			return nil, errors.New("generic adapters need a name, url and ratesPath")
+		}
+		if config.Auth.In != "" && config.Auth.In != "query" && config.Auth.In != "header" {
+			return nil, fmt.Errorf("adapter %s: auth.in must be query or header", config.Name)
+		}
+		adapters[config.Name] = config
+	}
+	return adapters, nil
+}
+
+// GenericAdapter fetches rates from a source declared in configuration.
+type GenericAdapter struct {
+	client *http.Client
+	config GenericAdapterConfig
+	apiKey string
+}
+
+// NewGeneric returns an adapter for the declared source config. A key sent
+// in the query is redacted from logged URLs by its parameter name.
+func NewGeneric(client *http.Client, config GenericAdapterConfig, apiKey string) *GenericAdapter {
+	if config.Auth.In == "query" && config.Auth.Name != "" {
+		redactParam(config.Auth.Name)
+	}
+	return &GenericAdapter{client: client, config: config, apiKey: apiKey}
+}
+
+func (g *GenericAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	return g.fetchRate(ctx, g.config.URL, from, to, "")
+}
+
+func (g *GenericAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	if g.config.HistoricalURL == "" {
+		return RateResult{}, ErrHistoricalNotSupported
+	}
+	return g.fetchRate(ctx, g.config.HistoricalURL, from, to, date.Format("2006-01-02"))
+}
+
+func (g *GenericAdapter) fetchRate(ctx context.Context, template, from, to, date string) (RateResult, error) {
+	// Expand the URL template
+	apiURL := strings.NewReplacer(
+		"{base}", url.QueryEscape(from),
+		"{from}", url.QueryEscape(from),
+		"{to}", url.QueryEscape(to),
+		"{symbols}", url.QueryEscape(from+","+to),
+		"{date}", date,
+	).Replace(template)
+
+	// Place the API key where the provider expects it
+	header := http.Header{}
+	switch g.config.Auth.In {
+	case "query":
+		parsed, err := url.Parse(apiURL)
+		if err != nil {
+			return RateResult{}, err
+		}
+		query := parsed.Query()
+		query.Set(g.config.Auth.Name, g.config.Auth.Prefix+g.apiKey)
+		parsed.RawQuery = query.Encode()
+		apiURL = parsed.String()
+	case "header":
+		header.Set(g.config.Auth.Name, g.config.Auth.Prefix+g.apiKey)
+	}
+
+	body, err := fetchProvider(ctx, g.client, providerRequest{Method: g.config.Method, URL: apiURL, Header: header})
+	if err != nil {
+		return RateResult{}, err
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return RateResult{}, err
+	}
+
+	// Extract the rates through the same decoder the built-in adapters use
+	ratesValue, err := extractPath(document, g.config.RatesPath)
+	if err != nil {
+		return RateResult{}, err
+	}
+	ratesJSON, err := json.Marshal(ratesValue)
+	if err != nil {
+		return RateResult{}, err
+	}
+	var rates Rates
+	if err := json.Unmarshal(ratesJSON, &rates); err != nil {
+		return RateResult{}, fmt.Errorf("%s: rates at %s: %v", g.config.Name, g.config.RatesPath, err)
+	}
+
+	// The base currency is implied at 1, defaulting to the requested one
+	base := from
+	if g.config.BasePath != "" {
+		value, err := extractPath(document, g.config.BasePath)
+		if err != nil {
+			return RateResult{}, err
+		}
+		if s, ok := value.(string); ok {
+			base = s
+		}
+	}
+	if _, ok := rates[base]; !ok {
+		rates[base] = 1
+	}
+
+	fromRate, fromOK := rates[from]
+	toRate, toOK := rates[to]
+	if !fromOK || !toOK || fromRate <= 0 || toRate <= 0 {
+		return RateResult{}, fmt.Errorf("%s has no rate for %s/%s: %w", g.config.Name, from, to, ErrProviderCurrency)
+	}
+
+	timestamp := time.Now().UTC()
+	if g.config.TimestampPath != "" {
+		if value, err := extractPath(document, g.config.TimestampPath); err == nil {
+			if seconds, ok := value.(float64); ok {
+				timestamp = time.Unix(int64(seconds), 0).UTC()
+			}
+		}
+	}
+
+	return RateResult{
+		Rate:      toRate / fromRate,
+		Base:      from,
+		Target:    to,
+		Timestamp: timestamp,
+		Provider:  g.config.Name,
+	}, nil
+}
+
+func (g *GenericAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := g.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (g *GenericAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := g.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
+
+var pathIndexPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// extractPath walks a decoded JSON document along a dotted path with optional
+// array indexes, e.g. "$.data.items[0].rates".
+func extractPath(document interface{}, path string) (interface{}, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return document, nil
+	}
+
+	current := document
+	for _, segment := range strings.Split(path, ".") {
+		name := segment
+		if i := strings.Index(segment, "["); i >= 0 {
+			name = segment[:i]
+		}
+
+		if name != "" {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("path %s: %s is not an object", path, name)
+			}
+			if current, ok = object[name]; !ok {
+				return nil, fmt.Errorf("path %s: missing field %s", path, name)
+			}
+		}
+
+		for _, match := range pathIndexPattern.FindAllStringSubmatch(segment, -1) {
+			index, _ := strconv.Atoi(match[1])
+			array, ok := current.([]interface{})
+			if !ok || index >= len(array) {
+				return nil, fmt.Errorf("path %s: no element %d in %s", path, index, segment)
+			}
+			current = array[index]
+		}
+	}
+	return current, nil
+}
@@ -0,0 +1,172 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// History stores fetched rates and answers rates of past days from them.
+// Days are formatted YYYY-MM-DD.
+type History interface {
+	Record(provider, day string, results []RateResult) error
+	// RateOn returns the latest rate recorded for the pair on day.
+	RateOn(provider, from, to, day string) (RateResult, bool, error)
+	// Series returns the latest recorded rate of each day from start to end
+	// that has one.
+	Series(provider, from, to, start, end string) ([]RateResult, error)
+}
+
+// HistoryAdapter decorates an adapter so every rate it fetches is recorded.
+// Rates of past days, which no longer change, are answered from the history
+// when it has them, and so are today's rates while the provider's circuit
+// breaker is open.
+type HistoryAdapter struct {
+	adapter  ExchangeRateAdapter
+	history  History
+	provider string
+}
+
+// NewHistory records the rates adapter fetches for provider in history.
+func NewHistory(adapter ExchangeRateAdapter, history History, provider string) *HistoryAdapter {
+	return &HistoryAdapter{adapter: adapter, history: history, provider: provider}
+}
+
+func (h *HistoryAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	result, err := h.adapter.GetRate(ctx, from, to)
+	if errors.Is(err, ErrCircuitOpen) {
+		// While the provider is down, today's last recorded rate stands in
+		if recorded, ok := h.recorded(from, to, today()); ok {
+			recorded.Cached = true
+			return recorded, nil
+		}
+	}
+	if err != nil {
+		return RateResult{}, err
+	}
+	h.record(recordDay(result), result)
+	return result, nil
+}
+
+func (h *HistoryAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	day := date.Format("2006-01-02")
+	if day < today() {
+		if result, ok := h.recorded(from, to, day); ok {
+			return result, nil
+		}
+	}
+
+	result, err := h.adapter.GetExchangeRateAt(ctx, from, to, date)
+	if err != nil {
+		return RateResult{}, err
+	}
+	h.record(day, result)
+	return result, nil
+}
+
+func (h *HistoryAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	// Serve the range from the history only if it has every day of it
+	first, last := start.Format("2006-01-02"), end.Format("2006-01-02")
+	if last < today() {
+		series, err := h.history.Series(h.provider, from, to, first, last)
+		if err != nil {
+			log.Printf("history: %s %s/%s series: %v", h.provider, from, to, err)
+		}
+		if err == nil && len(series) == int(end.Sub(start).Hours()/24)+1 {
+			return series, nil
+		}
+	}
+
+	// Without a time-series API each day goes through the history on its own
+	series, ok := h.adapter.(TimeSeriesAdapter)
+	if !ok {
+		return dailySeries(ctx, h, from, to, start, end)
+	}
+	results, err := series.GetTimeSeries(ctx, from, to, start, end)
+	if err != nil {
+		return nil, err
+	}
+	h.recordAll(results, func(result RateResult) string {
+		return result.Timestamp.Format("2006-01-02")
+	})
+	return results, nil
+}
+
+func (h *HistoryAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	results, err := FetchRates(ctx, h.adapter, from, to)
+	if err != nil {
+		return nil, err
+	}
+	all := make([]RateResult, 0, len(results))
+	for _, result := range results {
+		all = append(all, result)
+	}
+	h.recordAll(all, recordDay)
+	return results, nil
+}
+
+func (h *HistoryAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	return FetchSymbols(ctx, h.adapter)
+}
+
+// recorded looks up the pair on day in the history.
+func (h *HistoryAdapter) recorded(from, to, day string) (RateResult, bool) {
+	result, ok, err := h.history.RateOn(h.provider, from, to, day)
+	if err != nil {
+		log.Printf("history: %s %s/%s on %s: %v", h.provider, from, to, day, err)
+		return RateResult{}, false
+	}
+	return result, ok
+}
+
+// record stores result under day. Failures are logged rather than failing
+// the lookup, which already succeeded.
+func (h *HistoryAdapter) record(day string, result RateResult) {
+	if err := h.history.Record(h.provider, day, []RateResult{result}); err != nil {
+		log.Printf("history: recording %s %s/%s: %v", h.provider, result.Base, result.Target, err)
+	}
+}
+
+// recordAll stores results in one Record call per day.
+func (h *HistoryAdapter) recordAll(results []RateResult, dayOf func(RateResult) string) {
+	days := map[string][]RateResult{}
+	for _, result := range results {
+		day := dayOf(result)
+		days[day] = append(days[day], result)
+	}
+	for day, results := range days {
+		if err := h.history.Record(h.provider, day, results); err != nil {
+			log.Printf("history: recording %d %s rates: %v", len(results), h.provider, err)
+		}
+	}
+}
+
+// recordDay is the day a latest rate belongs to: its publication date for
+// daily reference rates, otherwise today.
+func recordDay(result RateResult) string {
+	if result.Date != "" {
+		return result.Date
+	}
+	return today()
+}
+
+func today() string {
+	return time.Now().UTC().Format("2006-01-02")
+}
+
+func (h *HistoryAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := h.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (h *HistoryAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := h.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
@@ -0,0 +1,104 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cubetiq-samples/exchanger-go/metrics"
+)
+
+// InstrumentedAdapter decorates an adapter with request and latency metrics
+// for provider, and keeps its call statistics.
+type InstrumentedAdapter struct {
+	adapter  ExchangeRateAdapter
+	provider string
+	stats    *CallStats
+}
+
+// NewInstrumented records metrics for the lookups adapter sends to provider,
+// and their outcome and the quota the provider reports in stats.
+func NewInstrumented(adapter ExchangeRateAdapter, provider string, stats *CallStats) *InstrumentedAdapter {
+	return &InstrumentedAdapter{adapter: adapter, provider: provider, stats: stats}
+}
+
+func (i *InstrumentedAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	start := time.Now()
+	ctx = withCallStats(ctx, i.stats)
+	result, err := i.adapter.GetRate(ctx, from, to)
+	i.done("rate", start, err)
+	return result, err
+}
+
+func (i *InstrumentedAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	start := time.Now()
+	ctx = withCallStats(ctx, i.stats)
+	result, err := i.adapter.GetExchangeRateAt(ctx, from, to, date)
+	i.done("historical", start, err)
+	return result, err
+}
+
+func (i *InstrumentedAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	started := time.Now()
+	ctx = withCallStats(ctx, i.stats)
+	series, err := FetchTimeSeries(ctx, i.adapter, from, to, start, end)
+	i.done("timeseries", started, err)
+	return series, err
+}
+
+func (i *InstrumentedAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	start := time.Now()
+	ctx = withCallStats(ctx, i.stats)
+	results, err := FetchRates(ctx, i.adapter, from, to)
+	i.done("rates", start, err)
+	return results, err
+}
+
+func (i *InstrumentedAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	start := time.Now()
+	ctx = withCallStats(ctx, i.stats)
+	symbols, err := FetchSymbols(ctx, i.adapter)
+	i.done("symbols", start, err)
+	return symbols, err
+}
+
+// done records a lookup that started at start and ended with err.
+func (i *InstrumentedAdapter) done(operation string, start time.Time, err error) {
+	latency := time.Since(start)
+	metrics.ProviderDuration.WithLabelValues(i.provider, operation).Observe(latency.Seconds())
+
+	outcome := "ok"
+	switch {
+	case errors.Is(err, ErrHistoricalNotSupported), errors.Is(err, ErrRateTableNotSupported),
+		errors.Is(err, ErrSymbolsNotSupported):
+		outcome = "unsupported"
+	case err != nil:
+		outcome = "error"
+	}
+	metrics.ProviderRequests.WithLabelValues(i.provider, operation, outcome).Inc()
+	switch {
+	case outcome == "unsupported", errors.Is(err, context.Canceled):
+		// The provider wasn't asked, or the caller gave up
+	case errors.Is(err, ErrProviderCurrency):
+		// Not knowing a currency is an answer, like the circuit breaker says
+		i.stats.record(latency, nil)
+	default:
+		i.stats.record(latency, err)
+	}
+}
+
+func (i *InstrumentedAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := i.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (i *InstrumentedAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := i.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
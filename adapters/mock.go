@@ -0,0 +1,276 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cubetiq-samples/exchanger-go/config"
+)
+
+// Error scenarios the mock source can simulate.
+const (
+	// MockTimeout never answers, so the lookup runs into its upstream timeout.
+	MockTimeout = "timeout"
+	// MockRateLimited fails as a provider answering 429.
+	MockRateLimited = "rate_limit"
+	// MockUnavailable fails as a provider answering 503.
+	MockUnavailable = "unavailable"
+	// MockAuth fails as a provider rejecting the API key with 401.
+	MockAuth = "auth"
+)
+
+// mockScenarios are the statuses of the scenarios that fail with one.
+var mockScenarios = map[string]int{
+	MockTimeout:     0,
+	MockRateLimited: http.StatusTooManyRequests,
+	MockUnavailable: http.StatusServiceUnavailable,
+	MockAuth:        http.StatusUnauthorized,
+}
+
+// mockRates are the mock source's USD rates before seeding, close enough to
+// real ones for amounts to look plausible.
+var mockRates = Rates{
+	"USD": 1,
+	"EUR": 0.92,
+	"GBP": 0.79,
+	"JPY": 155,
+	"CNY": 7.24,
+	"KHR": 4100,
+	"THB": 36.5,
+	"VND": 25400,
+	"SGD": 1.36,
+	"AUD": 1.52,
+	"CAD": 1.37,
+	"CHF": 0.91,
+	"INR": 83.4,
+	"KWD": 0.31,
+	"BTC": 0.000016,
+	"ETH": 0.00033,
+}
+
+// MockConfig shapes the mock source's answers.
+type MockConfig struct {
+	// Seed shifts every rate by a fixed amount of up to ±10%; 0 keeps the
+	// built-in rates. The same seed always gives the same rates.
+	Seed int64
+	// Latency is how long each lookup takes.
+	Latency time.Duration
+	// Error is the scenario lookups fail with, empty for none.
+	Error string
+	// ErrorRate is the fraction of lookups that fail with Error (default 1).
+	ErrorRate float64
+}
+
+// MockConfigFromEnv reads the mock source's configuration from
+// EXCHANGER_MOCK_SEED, EXCHANGER_MOCK_LATENCY, EXCHANGER_MOCK_ERROR and
+// EXCHANGER_MOCK_ERROR_RATE.
+func MockConfigFromEnv() (MockConfig, error) {
+	mock := MockConfig{
+		Seed:      int64(config.Int("EXCHANGER_MOCK_SEED", 0)),
+		Latency:   config.Duration("EXCHANGER_MOCK_LATENCY", 0),
+		Error:     os.Getenv("EXCHANGER_MOCK_ERROR"),
+		ErrorRate: 1,
+	}
+	if _, ok := mockScenarios[mock.Error]; mock.Error != "" && !ok {
+		return MockConfig{}, fmt.Errorf("EXCHANGER_MOCK_ERROR must be one of %s", strings.Join(mockScenarioNames(), ", "))
+	}
+	if value := os.Getenv("EXCHANGER_MOCK_ERROR_RATE"); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return MockConfig{}, fmt.Errorf("EXCHANGER_MOCK_ERROR_RATE must be between 0 and 1")
+		}
+		mock.ErrorRate = rate
+	}
+	return mock, nil
+}
+
+func mockScenarioNames() []string {
+	names := make([]string, 0, len(mockScenarios))
+	for name := range mockScenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MockAdapter returns predictable rates without calling a provider, for
+// developing and testing against the service at no cost. Rates depend only on
+// the seed, the currencies and, for historical ones, the date.
+type MockAdapter struct {
+	config   MockConfig
+	scenario string
+
+	// random decides which lookups fail at ErrorRate; shared by WithScenario
+	random *lockedRand
+}
+
+type lockedRand struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.Float64()
+}
+
+// NewMock returns a mock adapter configured by config.
+func NewMock(config MockConfig) *MockAdapter {
+	return &MockAdapter{
+		config:   config,
+		scenario: config.Error,
+		random:   &lockedRand{rand: rand.New(rand.NewSource(config.Seed))},
+	}
+}
+
+// WithScenario returns the adapter failing every lookup with the named error
+// scenario, so a request can pick one with its key, e.g. key=rate_limit.
+// Other names return the adapter unchanged.
+func (m *MockAdapter) WithScenario(name string) *MockAdapter {
+	if _, ok := mockScenarios[name]; !ok {
+		return m
+	}
+	scenario := *m
+	scenario.scenario = name
+	scenario.config.ErrorRate = 1
+	return &scenario
+}
+
+// lookup waits out the latency and fails as the scenario asks.
+func (m *MockAdapter) lookup(ctx context.Context) error {
+	if m.config.Latency > 0 {
+		timer := time.NewTimer(m.config.Latency)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if m.scenario == "" || m.random.Float64() >= m.config.ErrorRate {
+		return nil
+	}
+	if m.scenario == MockTimeout {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return &UpstreamStatusError{StatusCode: mockScenarios[m.scenario]}
+}
+
+// usdRate is the seeded USD rate of code on date, or the latest one when
+// date is empty.
+func (m *MockAdapter) usdRate(code, date string) (float64, error) {
+	rate, ok := mockRates[code]
+	if !ok {
+		return 0, fmt.Errorf("mock rates have no rate for %s: %w", code, ErrProviderCurrency)
+	}
+	if code == "USD" {
+		return 1, nil
+	}
+	if m.config.Seed != 0 {
+		rate *= 1 + mockShift(fmt.Sprint(m.config.Seed, code), 0.1)
+	}
+	if date != "" {
+		rate *= 1 + mockShift(fmt.Sprint(m.config.Seed, code, date), 0.02)
+	}
+	return rate, nil
+}
+
+// mockShift hashes key to a fraction between -limit and limit.
+func mockShift(key string, limit float64) float64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return (float64(hash.Sum64()%20001)/10000 - 1) * limit
+}
+
+func (m *MockAdapter) rate(from, to, date string, timestamp time.Time) (RateResult, error) {
+	fromRate, err := m.usdRate(from, date)
+	if err != nil {
+		return RateResult{}, err
+	}
+	toRate, err := m.usdRate(to, date)
+	if err != nil {
+		return RateResult{}, err
+	}
+	return RateResult{
+		Rate:      toRate / fromRate,
+		Base:      from,
+		Target:    to,
+		Timestamp: timestamp,
+		Date:      date,
+		Provider:  "mock",
+	}, nil
+}
+
+func (m *MockAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	if err := m.lookup(ctx); err != nil {
+		return RateResult{}, err
+	}
+	return m.rate(from, to, "", time.Now().UTC().Truncate(time.Minute))
+}
+
+func (m *MockAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	if err := m.lookup(ctx); err != nil {
+		return nil, err
+	}
+	if to == nil {
+		to = tableTargets(mockRates)
+	}
+
+	timestamp := time.Now().UTC().Truncate(time.Minute)
+	results := make(map[string]RateResult, len(to))
+	for _, target := range to {
+		result, err := m.rate(from, target, "", timestamp)
+		if err != nil {
+			return nil, err
+		}
+		results[target] = result
+	}
+	return results, nil
+}
+
+func (m *MockAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	if err := m.lookup(ctx); err != nil {
+		return nil, err
+	}
+	symbols := make(map[string]string, len(mockRates))
+	for code := range mockRates {
+		symbols[code] = ""
+	}
+	return symbols, nil
+}
+
+func (m *MockAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	if err := m.lookup(ctx); err != nil {
+		return RateResult{}, err
+	}
+	day := date.Format("2006-01-02")
+	timestamp, _ := time.Parse("2006-01-02", day)
+	return m.rate(from, to, day, timestamp)
+}
+
+func (m *MockAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := m.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (m *MockAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := m.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
@@ -0,0 +1,48 @@
+package adapters
+
+import (
+	"context"
+	"cubetiq-samples/exchanger-go/config"
+)
+
+// MultiRateAdapter is implemented by adapters whose provider quotes several
+// target currencies in one request. A nil to asks for every currency the
+// provider quotes.
+type MultiRateAdapter interface {
+	GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error)
+}
+
+// FetchRates returns the rate from from to each currency in to, keyed by
+// target. Adapters without a multi-currency API get one lookup per target
+// and cannot list the whole table.
+func FetchRates(ctx context.Context, adapter ExchangeRateAdapter, from string, to []string) (map[string]RateResult, error) {
+	if multi, ok := adapter.(MultiRateAdapter); ok {
+		return multi.GetRates(ctx, from, to)
+	}
+	if to == nil {
+		return nil, ErrRateTableNotSupported
+	}
+
+	pairs := make([]Pair, len(to))
+	for i, target := range to {
+		pairs[i] = Pair{From: from, To: target}
+	}
+
+	results := make(map[string]RateResult, len(to))
+	for pair, rate := range FetchPairs(ctx, adapter, pairs, config.Int("EXCHANGER_BATCH_WORKERS", 8)) {
+		if rate.Err != nil {
+			return nil, rate.Err
+		}
+		results[pair.To] = rate.Result
+	}
+	return results, nil
+}
+
+// tableTargets lists every currency in a provider's rate table.
+func tableTargets(rates Rates) []string {
+	targets := make([]string, 0, len(rates))
+	for currency := range rates {
+		targets = append(targets, currency)
+	}
+	return targets
+}
@@ -0,0 +1,122 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const nbcRatePageURL = "https://www.nbc.gov.kh/english/economic_research/exchange_rate.php"
+
+var (
+	htmlTagPattern     = regexp.MustCompile(`<[^>]*>`)
+	nbcOfficialPattern = regexp.MustCompile(`Official Exchange Rate\s*:\s*([\d,.]+)\s*KHR\s*/\s*USD`)
+	nbcDatePattern     = regexp.MustCompile(`Exchange Rate on\s*:\s*(\d{4}-\d{2}-\d{2})`)
+)
+
+// NBCAdapter uses the official KHR/USD rate published daily by the National
+// Bank of Cambodia. Only USD and KHR pairs are supported.
+type NBCAdapter struct {
+	client *http.Client
+}
+
+// NewNBC returns an adapter for the NBC official rate.
+func NewNBC(client *http.Client) *NBCAdapter {
+	return &NBCAdapter{client: client}
+}
+
+// parseNBCRate extracts the official KHR per USD rate and its publication date
+// from the NBC exchange rate page.
+func parseNBCRate(page []byte) (float64, time.Time, error) {
+	// Drop the markup so the patterns only have to match the visible text
+	text := htmlTagPattern.ReplaceAllString(string(page), " ")
+	text = strings.Join(strings.Fields(text), " ")
+
+	rateMatch := nbcOfficialPattern.FindStringSubmatch(text)
+	if rateMatch == nil {
+		return 0, time.Time{}, errors.New("NBC page has no official exchange rate")
+	}
+	rate, err := strconv.ParseFloat(strings.ReplaceAll(rateMatch[1], ",", ""), 64)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	dateMatch := nbcDatePattern.FindStringSubmatch(text)
+	if dateMatch == nil {
+		return 0, time.Time{}, errors.New("NBC page has no exchange rate date")
+	}
+	date, err := time.Parse("2006-01-02", dateMatch[1])
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return rate, date, nil
+}
+
+func (n *NBCAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	// Other pairs aren't published, so don't fetch the page for them
+	if !nbcPair(from, to) {
+		return RateResult{}, fmt.Errorf("NBC only publishes the official USD/KHR rate, not %s/%s: %w", from, to, ErrProviderCurrency)
+	}
+
+	// Send a GET request for the published rate page
+	body, err := fetchProvider(ctx, n.client, providerRequest{Method: http.MethodGet, URL: nbcRatePageURL})
+	if err != nil {
+		return RateResult{}, err
+	}
+
+	khrPerUSD, date, err := parseNBCRate(body)
+	if err != nil {
+		return RateResult{}, err
+	}
+
+	rate := 1.0
+	switch {
+	case from == "USD" && to == "KHR":
+		rate = khrPerUSD
+	case from == "KHR" && to == "USD":
+		rate = 1 / khrPerUSD
+	}
+
+	return RateResult{
+		Rate:      rate,
+		Base:      from,
+		Target:    to,
+		Timestamp: date,
+		Date:      date.Format("2006-01-02"),
+		Provider:  "nbc",
+	}, nil
+}
+
+// nbcPair reports whether from/to is a pair of the published USD/KHR rate.
+func nbcPair(from, to string) bool {
+	return (from == "USD" || from == "KHR") && (to == "USD" || to == "KHR")
+}
+
+func (n *NBCAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	return map[string]string{"USD": "US Dollar", "KHR": "Riel"}, nil
+}
+
+func (n *NBCAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	return RateResult{}, ErrHistoricalNotSupported
+}
+
+func (n *NBCAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := n.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (n *NBCAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := n.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
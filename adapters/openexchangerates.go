@@ -0,0 +1,151 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenExchangeRatesAdapter uses openexchangerates.org, which quotes every rate
+// against USD.
+type OpenExchangeRatesAdapter struct {
+	client *http.Client
+	apiKey string
+}
+
+// NewOpenExchangeRates returns an adapter using the openexchangerates app id.
+func NewOpenExchangeRates(client *http.Client, apiKey string) *OpenExchangeRatesAdapter {
+	return &OpenExchangeRatesAdapter{client: client, apiKey: apiKey}
+}
+
+func (o *OpenExchangeRatesAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	// Build the API URL
+	url := fmt.Sprintf("https://openexchangerates.org/api/latest.json?app_id=%s&symbols=%s,%s", o.apiKey, from, to)
+	return o.fetchRate(ctx, url, from, to)
+}
+
+func (o *OpenExchangeRatesAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	// Build the API URL with every target in one symbols list, or no list for the whole table
+	url := fmt.Sprintf("https://openexchangerates.org/api/latest.json?app_id=%s", o.apiKey)
+	if len(to) > 0 {
+		url += fmt.Sprintf("&symbols=%s,%s", from, strings.Join(to, ","))
+	}
+	return o.fetchRates(ctx, url, from, to)
+}
+
+func (o *OpenExchangeRatesAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	// Build the historical API URL
+	url := fmt.Sprintf("https://openexchangerates.org/api/historical/%s.json?app_id=%s&symbols=%s,%s", date.Format("2006-01-02"), o.apiKey, from, to)
+	return o.fetchRate(ctx, url, from, to)
+}
+
+func (o *OpenExchangeRatesAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	// Build the time-series API URL; rates are quoted against USD
+	url := fmt.Sprintf("https://openexchangerates.org/api/time-series.json?app_id=%s&start=%s&end=%s&symbols=%s,%s",
+		o.apiKey, start.Format("2006-01-02"), end.Format("2006-01-02"), from, to)
+
+	body, err := fetchProvider(ctx, o.client, providerRequest{Method: http.MethodGet, URL: url})
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Base  string           `json:"base"`
+		Rates map[string]Rates `json:"rates"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	return seriesFromDailyRates("openexchangerates", data.Base, from, to, data.Rates)
+}
+
+func (o *OpenExchangeRatesAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	url := fmt.Sprintf("https://openexchangerates.org/api/currencies.json?app_id=%s", o.apiKey)
+	body, err := fetchProvider(ctx, o.client, providerRequest{Method: http.MethodGet, URL: url})
+	if err != nil {
+		return nil, err
+	}
+
+	// The response is a plain map of code to name
+	var symbols map[string]string
+	if err := json.Unmarshal(body, &symbols); err != nil {
+		return nil, err
+	}
+	return symbols, nil
+}
+
+func (o *OpenExchangeRatesAdapter) fetchRate(ctx context.Context, url, from, to string) (RateResult, error) {
+	results, err := o.fetchRates(ctx, url, from, []string{to})
+	if err != nil {
+		return RateResult{}, err
+	}
+	return results[to], nil
+}
+
+func (o *OpenExchangeRatesAdapter) fetchRates(ctx context.Context, url, from string, to []string) (map[string]RateResult, error) {
+	// Send a GET request to the API
+	body, err := fetchProvider(ctx, o.client, providerRequest{Method: http.MethodGet, URL: url})
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the response against the bundled schema
+	if err := validateProviderResponse("openexchangerates", body, o.apiKey); err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the JSON response
+	var data struct {
+		Timestamp int64 `json:"timestamp"`
+		Rates     Rates `json:"rates"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+
+	// Return the exchange rate of each target, rebased onto from
+	if to == nil {
+		if _, ok := data.Rates[from]; !ok {
+			return nil, fmt.Errorf("openexchangerates has no rate for %s: %w", from, ErrProviderCurrency)
+		}
+		to = tableTargets(data.Rates)
+	}
+	fromRate := data.Rates[from]
+	if fromRate <= 0 {
+		return nil, fmt.Errorf("openexchangerates has no rate for %s: %w", from, ErrProviderCurrency)
+	}
+	results := make(map[string]RateResult, len(to))
+	for _, target := range to {
+		rate, ok := data.Rates[target]
+		if !ok || rate <= 0 {
+			return nil, fmt.Errorf("openexchangerates has no rate for %s: %w", target, ErrProviderCurrency)
+		}
+		results[target] = RateResult{
+			Rate:      rate / fromRate,
+			Base:      from,
+			Target:    target,
+			Timestamp: time.Unix(data.Timestamp, 0).UTC(),
+			Provider:  "openexchangerates",
+		}
+	}
+	return results, nil
+}
+
+func (o *OpenExchangeRatesAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := o.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (o *OpenExchangeRatesAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := o.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
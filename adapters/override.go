@@ -0,0 +1,242 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"cubetiq-samples/exchanger-go/cache"
+)
+
+// RateOverride pins the rate of a pair regardless of what providers report,
+// until it is deleted or expires.
+type RateOverride struct {
+	From      string     `json:"from"`
+	To        string     `json:"to"`
+	Rate      float64    `json:"rate"`
+	Reason    string     `json:"reason,omitempty"`
+	SetAt     time.Time  `json:"setAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+func (o RateOverride) expired(now time.Time) bool {
+	return o.ExpiresAt != nil && !now.Before(*o.ExpiresAt)
+}
+
+const (
+	// overridesKey holds every override as one JSON document, so a shared
+	// cache shares them between instances.
+	overridesKey = "overrides"
+	// overridesTTL keeps the document as long as the cache keeps anything.
+	overridesTTL = 100 * 365 * 24 * time.Hour
+	// overridesReload is how often overrides set by other instances are
+	// picked up.
+	overridesReload = 5 * time.Second
+)
+
+// Overrides stores the rate overrides in a cache.
+type Overrides struct {
+	store cache.Cache
+
+	mu       sync.Mutex
+	entries  map[string]RateOverride
+	loadedAt time.Time
+}
+
+// NewOverrides returns the overrides kept in store.
+func NewOverrides(store cache.Cache) *Overrides {
+	return &Overrides{store: store}
+}
+
+// current returns the overrides, reloading them from the store when the
+// local copy is older than overridesReload. The caller holds o.mu.
+func (o *Overrides) current() map[string]RateOverride {
+	if o.entries != nil && time.Since(o.loadedAt) < overridesReload {
+		return o.entries
+	}
+	entries := map[string]RateOverride{}
+	if data, ok := o.store.Get(overridesKey); ok {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			entries = map[string]RateOverride{}
+		}
+	}
+	o.entries, o.loadedAt = entries, time.Now()
+	return entries
+}
+
+// save writes entries back to the store, dropping expired ones. The caller
+// holds o.mu.
+func (o *Overrides) save(entries map[string]RateOverride) error {
+	now := time.Now()
+	for pair, override := range entries {
+		if override.expired(now) {
+			delete(entries, pair)
+		}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	o.store.Set(overridesKey, data, overridesTTL)
+	o.entries, o.loadedAt = entries, now
+	return nil
+}
+
+// Set adds or replaces the override of its pair.
+func (o *Overrides) Set(override RateOverride) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// Reread so overrides another instance just set aren't lost
+	o.entries = nil
+	entries := o.current()
+	entries[override.From+"/"+override.To] = override
+	return o.save(entries)
+}
+
+// Delete removes the override of from/to, reporting whether there was one.
+func (o *Overrides) Delete(from, to string) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.entries = nil
+	entries := o.current()
+	override, ok := entries[from+"/"+to]
+	if !ok || override.expired(time.Now()) {
+		return false, nil
+	}
+	delete(entries, from+"/"+to)
+	return true, o.save(entries)
+}
+
+// List returns the overrides in effect, ordered by pair.
+func (o *Overrides) List() []RateOverride {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	list := []RateOverride{}
+	for _, override := range o.current() {
+		if !override.expired(now) {
+			list = append(list, override)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].From != list[j].From {
+			return list[i].From < list[j].From
+		}
+		return list[i].To < list[j].To
+	})
+	return list
+}
+
+// rate returns the overridden rate of from/to: the pinned rate, or the
+// inverse of a pinned to/from rate.
+func (o *Overrides) rate(from, to string) (RateResult, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entries := o.current()
+	if len(entries) == 0 {
+		return RateResult{}, false
+	}
+	now := time.Now()
+	result := RateResult{Base: from, Target: to, Provider: "override"}
+	if override, ok := entries[from+"/"+to]; ok && !override.expired(now) {
+		result.Rate, result.Timestamp = override.Rate, override.SetAt
+		return result, true
+	}
+	if override, ok := entries[to+"/"+from]; ok && !override.expired(now) {
+		result.Rate, result.Timestamp = 1/override.Rate, override.SetAt
+		return result, true
+	}
+	return RateResult{}, false
+}
+
+// OverrideAdapter answers the latest rate of overridden pairs from the
+// overrides and every other lookup from the adapter it decorates. Historical
+// rates are never overridden.
+type OverrideAdapter struct {
+	adapter   ExchangeRateAdapter
+	overrides *Overrides
+}
+
+// NewOverride applies overrides to the latest rates of adapter.
+func NewOverride(adapter ExchangeRateAdapter, overrides *Overrides) *OverrideAdapter {
+	return &OverrideAdapter{adapter: adapter, overrides: overrides}
+}
+
+func (o *OverrideAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	if result, ok := o.overrides.rate(from, to); ok {
+		return result, nil
+	}
+	return o.adapter.GetRate(ctx, from, to)
+}
+
+func (o *OverrideAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	// A full table comes from the provider with the overridden rates swapped in
+	if to == nil {
+		results, err := FetchRates(ctx, o.adapter, from, nil)
+		if err != nil {
+			return nil, err
+		}
+		for target := range results {
+			if result, ok := o.overrides.rate(from, target); ok {
+				results[target] = result
+			}
+		}
+		return results, nil
+	}
+
+	results := make(map[string]RateResult, len(to))
+	var missing []string
+	for _, target := range to {
+		if result, ok := o.overrides.rate(from, target); ok {
+			results[target] = result
+			continue
+		}
+		missing = append(missing, target)
+	}
+	if len(missing) == 0 {
+		return results, nil
+	}
+
+	fetched, err := FetchRates(ctx, o.adapter, from, missing)
+	if err != nil {
+		return nil, err
+	}
+	for target, result := range fetched {
+		results[target] = result
+	}
+	return results, nil
+}
+
+func (o *OverrideAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	return o.adapter.GetExchangeRateAt(ctx, from, to, date)
+}
+
+func (o *OverrideAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	return FetchTimeSeries(ctx, o.adapter, from, to, start, end)
+}
+
+func (o *OverrideAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	return FetchSymbols(ctx, o.adapter)
+}
+
+func (o *OverrideAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := o.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (o *OverrideAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := o.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
@@ -0,0 +1,63 @@
+package adapters
+
+import (
+	"context"
+	"sync"
+)
+
+// Pair is a from/to currency pair.
+type Pair struct {
+	From string
+	To   string
+}
+
+// PairResult is the outcome of looking up one Pair.
+type PairResult struct {
+	Result RateResult
+	Err    error
+}
+
+// FetchPairs looks up each distinct pair once, with at most workers requests
+// in flight, and at least one. Pairs not yet started when ctx ends fail with
+// its error.
+func FetchPairs(ctx context.Context, adapter ExchangeRateAdapter, pairs []Pair, workers int) map[Pair]PairResult {
+	results := make(map[Pair]PairResult, len(pairs))
+	var mu sync.Mutex
+	if workers < 1 {
+		workers = 1
+	}
+
+	queue := make(chan Pair)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pair := range queue {
+				result, err := adapter.GetRate(ctx, pair.From, pair.To)
+				mu.Lock()
+				results[pair] = PairResult{Result: result, Err: err}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i, pair := range pairs {
+		select {
+		case queue <- pair:
+			continue
+		case <-ctx.Done():
+		}
+		mu.Lock()
+		for _, pair := range pairs[i:] {
+			if _, ok := results[pair]; !ok {
+				results[pair] = PairResult{Err: ctx.Err()}
+			}
+		}
+		mu.Unlock()
+		break
+	}
+	close(queue)
+	wg.Wait()
+
+	return results
+}
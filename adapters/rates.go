@@ -0,0 +1,91 @@
+package adapters
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrHistoricalNotSupported is returned by adapters whose provider has no
+// historical rates API.
+var ErrHistoricalNotSupported = errors.New("historical rates are not supported by this provider")
+
+// ErrNoRatesOnDate is returned by adapters whose provider published no rates
+// on the requested day, such as a weekend or holiday.
+var ErrNoRatesOnDate = errors.New("provider published no rates on the date")
+
+// ErrSymbolsNotSupported is returned by adapters that cannot list the
+// currencies their provider supports.
+var ErrSymbolsNotSupported = errors.New("listing currencies is not supported by this provider")
+
+// ErrRateTableNotSupported is returned for full rate table requests to
+// adapters that can only quote individual pairs.
+var ErrRateTableNotSupported = errors.New("full rate tables are not supported by this provider")
+
+// Rates is the internal rate table keyed by currency code. Providers return
+// it either as an object ({"EUR":0.92}) or as an array of objects
+// ([{"currency":"EUR","rate":0.92}]); both decode to the same map.
+type Rates map[string]float64
+
+func (r *Rates) UnmarshalJSON(data []byte) error {
+	// Peek at the first token to detect the array shape
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var entries []struct {
+			Currency string  `json:"currency"`
+			Rate     float64 `json:"rate"`
+		}
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return err
+		}
+
+		rates := make(Rates, len(entries))
+		for _, entry := range entries {
+			rates[entry.Currency] = entry.Rate
+		}
+		*r = rates
+		return nil
+	}
+
+	var rates map[string]float64
+	if err := json.Unmarshal(trimmed, &rates); err != nil {
+		return err
+	}
+	*r = rates
+	return nil
+}
+
+// RateResult is the provider-neutral result every adapter returns, so callers
+// never depend on a provider's response shape.
+type RateResult struct {
+	Rate      float64
+	Base      string
+	Target    string
+	Timestamp time.Time
+	Provider  string
+
+	// Date is the official publication date for providers that publish daily
+	// reference rates, formatted as YYYY-MM-DD.
+	Date string
+
+	// Quality and Confidence are set only by providers that annotate their
+	// quotes, e.g. "indicative" vs "firm" and a 0-1 score.
+	Quality    string
+	Confidence *float64
+
+	// Cached is set when the result was served from the rate cache, which
+	// records when it was originally fetched in FetchedAt.
+	Cached    bool
+	FetchedAt time.Time
+	// Stale is set on cached results past their TTL, served because the
+	// provider failed.
+	Stale bool
+
+	// Path lists the currencies of a rate crossed through intermediate
+	// currencies, from Base to Target, e.g. KHR, USD, THB.
+	Path []string
+
+	// Quotes lists what each provider answered for a consensus rate.
+	Quotes []ConsensusQuote
+}
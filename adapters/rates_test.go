@@ -0,0 +1,59 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRatesShapes(t *testing.T) {
+	want := Rates{"EUR": 0.92, "KHR": 4100}
+	for _, payload := range []string{
+		`{"EUR":0.92,"KHR":4100}`,
+		`[{"currency":"EUR","rate":0.92},{"currency":"KHR","rate":4100}]`,
+		"\n  [{\"currency\":\"KHR\",\"rate\":4100},{\"currency\":\"EUR\",\"rate\":0.92}]",
+	} {
+		var rates Rates
+		if err := json.Unmarshal([]byte(payload), &rates); err != nil {
+			t.Errorf("%s: %v", payload, err)
+			continue
+		}
+		if !reflect.DeepEqual(rates, want) {
+			t.Errorf("%s decoded to %v, want %v", payload, rates, want)
+		}
+	}
+
+	var rates Rates
+	if err := json.Unmarshal([]byte(`[{"currency":"EUR","rate":"high"}]`), &rates); err == nil {
+		t.Error("an array with a non-numeric rate decoded")
+	}
+}
+
+func TestNonPositiveRates(t *testing.T) {
+	client := replay(t, map[string]string{
+		"/api/latest":      "fixerio_nonpositive.json",
+		"/api/latest.json": "openexchangerates_nonpositive.json",
+	})
+	service := postStub(t, `{"base":"USD","timestamp":1714752000,"rates":{"EUR":0}}`)
+	tests := []struct {
+		name    string
+		adapter ExchangeRateAdapter
+		from    string
+		to      string
+	}{
+		{"fixerio zero target", NewFixerIo(client, "key"), "USD", "KHR"},
+		{"fixerio negative target", NewFixerIo(client, "key"), "USD", "JPY"},
+		{"fixerio zero base", NewFixerIo(client, "key"), "KHR", "USD"},
+		{"openexchangerates zero target", NewOpenExchangeRates(client, "key"), "EUR", "KHR"},
+		{"openexchangerates negative base", NewOpenExchangeRates(client, "key"), "JPY", "EUR"},
+		{"ratesservice zero", NewRatesService(service.Client(), service.URL, "key"), "USD", "EUR"},
+	}
+	for _, test := range tests {
+		_, err := test.adapter.GetRate(context.Background(), test.from, test.to)
+		if !errors.Is(err, ErrProviderCurrency) {
+			t.Errorf("%s: error = %v, want ErrProviderCurrency", test.name, err)
+		}
+	}
+}
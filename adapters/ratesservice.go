@@ -0,0 +1,100 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RatesServiceAdapter talks to rate services that take a POST with a JSON
+// body instead of query parameters, such as in-house pricing services.
+//
+// It sends {"base":"USD","symbols":["EUR"]} with the API key as a bearer
+// token and expects {"base":"USD","timestamp":1683000000,"rates":{"EUR":0.92}},
+// optionally annotated with "quality" ("indicative" or "firm") and a
+// "confidence" score between 0 and 1.
+type RatesServiceAdapter struct {
+	client *http.Client
+	url    string
+	apiKey string
+}
+
+// NewRatesService returns an adapter posting to the rate service at url.
+func NewRatesService(client *http.Client, url, apiKey string) *RatesServiceAdapter {
+	return &RatesServiceAdapter{client: client, url: url, apiKey: apiKey}
+}
+
+func (r *RatesServiceAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	if r.url == "" {
+		return RateResult{}, errors.New("rates service URL is not configured")
+	}
+
+	// Send a POST request with the pair in the JSON body
+	body, err := fetchProvider(ctx, r.client, providerRequest{
+		Method: http.MethodPost,
+		URL:    r.url,
+		Header: http.Header{"Authorization": {"Bearer " + r.apiKey}},
+		Body: map[string]interface{}{
+			"base":    from,
+			"symbols": []string{to},
+		},
+	})
+	if err != nil {
+		return RateResult{}, err
+	}
+
+	// Validate the response against the bundled schema
+	if err := validateProviderResponse("ratesservice", body, r.apiKey); err != nil {
+		return RateResult{}, err
+	}
+
+	// Unmarshal the JSON response
+	var data struct {
+		Base       string   `json:"base"`
+		Timestamp  int64    `json:"timestamp"`
+		Rates      Rates    `json:"rates"`
+		Quality    string   `json:"quality"`
+		Confidence *float64 `json:"confidence"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return RateResult{}, err
+	}
+
+	// Rates are quoted against the requested base
+	rate, ok := data.Rates[to]
+	if !ok || rate <= 0 {
+		return RateResult{}, fmt.Errorf("ratesservice has no rate for %s/%s: %w", from, to, ErrProviderCurrency)
+	}
+	return RateResult{
+		Rate:       rate,
+		Base:       from,
+		Target:     to,
+		Timestamp:  time.Unix(data.Timestamp, 0).UTC(),
+		Provider:   "ratesservice",
+		Quality:    data.Quality,
+		Confidence: data.Confidence,
+	}, nil
+}
+
+func (r *RatesServiceAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	return RateResult{}, ErrHistoricalNotSupported
+}
+
+func (r *RatesServiceAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := r.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (r *RatesServiceAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := r.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
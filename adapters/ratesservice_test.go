@@ -0,0 +1,103 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// postStub is a rate service that only answers POSTs carrying a JSON body.
+func postStub(t *testing.T, answer string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "bad headers", http.StatusBadRequest)
+			return
+		}
+		var request struct {
+			Base    string   `json:"base"`
+			Symbols []string `json:"symbols"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Base != "USD" || !reflect.DeepEqual(request.Symbols, []string{"EUR"}) {
+			t.Errorf("request = %+v, want USD to [EUR]", request)
+		}
+		w.Write([]byte(answer))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRatesServicePosts(t *testing.T) {
+	server := postStub(t, `{"base":"USD","timestamp":1714752000,"rates":{"EUR":0.9293}}`)
+
+	result, err := NewRatesService(server.Client(), server.URL, "key").GetRate(context.Background(), "USD", "EUR")
+	if err != nil {
+		t.Fatalf("GetRate: %v", err)
+	}
+	if result.Rate != 0.9293 || result.Base != "USD" || result.Target != "EUR" || result.Provider != "ratesservice" {
+		t.Errorf("result = %+v, want USD/EUR at 0.9293 from ratesservice", result)
+	}
+}
+
+func TestFetchProviderMethod(t *testing.T) {
+	server := postStub(t, `{}`)
+
+	// Without a declared method the request is a GET, which the stub refuses
+	_, err := fetchProvider(context.Background(), server.Client(), providerRequest{URL: server.URL})
+	var statusErr *UpstreamStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET error = %v, want status 405", err)
+	}
+
+	body, err := fetchProvider(context.Background(), server.Client(), providerRequest{
+		Method: http.MethodPost,
+		URL:    server.URL,
+		Header: http.Header{"Authorization": {"Bearer key"}},
+		Body:   map[string]interface{}{"base": "USD", "symbols": []string{"EUR"}},
+	})
+	if err != nil || string(body) != `{}` {
+		t.Errorf("POST = %q, %v, want the stub's answer", body, err)
+	}
+}
+
+func TestRatesServiceUnconfigured(t *testing.T) {
+	if _, err := NewRatesService(nil, "", "key").GetRate(context.Background(), "USD", "EUR"); err == nil {
+		t.Error("GetRate succeeded without a URL")
+	}
+}
+
+func TestRatesServiceQuality(t *testing.T) {
+	confidence := 0.98
+	tests := []struct {
+		answer     string
+		quality    string
+		confidence *float64
+	}{
+		{`{"base":"USD","timestamp":1714752000,"rates":{"EUR":0.9293},"quality":"firm","confidence":0.98}`, "firm", &confidence},
+		{`{"base":"USD","timestamp":1714752000,"rates":{"EUR":0.9293},"quality":"indicative"}`, "indicative", nil},
+		{`{"base":"USD","timestamp":1714752000,"rates":{"EUR":0.9293}}`, "", nil},
+	}
+	for _, test := range tests {
+		server := postStub(t, test.answer)
+		result, err := NewRatesService(server.Client(), server.URL, "key").GetRate(context.Background(), "USD", "EUR")
+		if err != nil {
+			t.Errorf("%s: %v", test.answer, err)
+			continue
+		}
+		if result.Quality != test.quality || !reflect.DeepEqual(result.Confidence, test.confidence) {
+			t.Errorf("%s: quality %q confidence %v, want %q %v", test.answer, result.Quality, result.Confidence, test.quality, test.confidence)
+		}
+	}
+}
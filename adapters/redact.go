@@ -0,0 +1,64 @@
+package adapters
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// sensitiveParams are query parameters that carry provider credentials.
+var sensitiveParams = []string{"app_id", "access_key", "apikey", "api_key", "key", "token"}
+
+// declaredParams are the credential parameters of generic adapters, which
+// their configuration names.
+var declaredParams struct {
+	sync.RWMutex
+	names map[string]bool
+}
+
+// redactParam adds name to the query parameters redactURL masks.
+func redactParam(name string) {
+	declaredParams.Lock()
+	defer declaredParams.Unlock()
+	if declaredParams.names == nil {
+		declaredParams.names = map[string]bool{}
+	}
+	declaredParams.names[strings.ToLower(name)] = true
+}
+
+// sensitiveParam tells whether the query parameter name carries credentials.
+func sensitiveParam(name string) bool {
+	for _, sensitive := range sensitiveParams {
+		if strings.EqualFold(name, sensitive) {
+			return true
+		}
+	}
+	declaredParams.RLock()
+	defer declaredParams.RUnlock()
+	return declaredParams.names[strings.ToLower(name)]
+}
+
+// redactURL masks credential query parameters so the URL is safe to log.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "[unparseable url]"
+	}
+
+	query := u.Query()
+	for name := range query {
+		if sensitiveParam(name) {
+			query.Set(name, "REDACTED")
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// redactSecret masks every occurrence of secret in s.
+func redactSecret(s, secret string) string {
+	if secret == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, secret, "REDACTED")
+}
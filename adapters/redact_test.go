@@ -0,0 +1,79 @@
+package adapters
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"https://api.example.com/latest?access_key=secret&base=USD", "https://api.example.com/latest?access_key=REDACTED&base=USD"},
+		{"https://api.example.com/latest?APP_ID=secret", "https://api.example.com/latest?APP_ID=REDACTED"},
+		{"https://api.example.com/latest?api_key=a&api_key=b", "https://api.example.com/latest?api_key=REDACTED"},
+		{"https://api.example.com/latest?base=USD&symbols=EUR", "https://api.example.com/latest?base=USD&symbols=EUR"},
+		{"https://api.example.com/latest", "https://api.example.com/latest"},
+		{"://bad", "[unparseable url]"},
+	}
+	for _, test := range tests {
+		if got := redactURL(test.raw); got != test.want {
+			t.Errorf("redactURL(%q) = %q, want %q", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestRedactDeclaredParam(t *testing.T) {
+	raw := "https://api.example.com/latest?redact_test_appid=secret"
+	if got := redactURL(raw); strings.Contains(got, "REDACTED") {
+		t.Fatalf("redactURL(%q) = %q before the parameter was declared", raw, got)
+	}
+
+	var config GenericAdapterConfig
+	config.Name = "redacted"
+	config.Auth.In = "query"
+	config.Auth.Name = "Redact_Test_AppID"
+	NewGeneric(nil, config, "secret")
+	if got := redactURL(raw); strings.Contains(got, "secret") {
+		t.Errorf("redactURL(%q) = %q, want the declared parameter masked", raw, got)
+	}
+}
+
+// closedURL returns the URL of a server that no longer accepts connections.
+func closedURL() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+func TestFetchProviderRedactsURLError(t *testing.T) {
+	_, err := fetchProvider(context.Background(), nil, providerRequest{URL: closedURL() + "/latest?access_key=secret123&base=USD"})
+	if err == nil {
+		t.Fatal("fetching from a closed server succeeded")
+	}
+	if strings.Contains(err.Error(), "secret123") || !strings.Contains(err.Error(), "access_key=REDACTED") {
+		t.Errorf("error %q doesn't redact the access key", err)
+	}
+}
+
+func TestGenericAdapterRedactsURLError(t *testing.T) {
+	var config GenericAdapterConfig
+	config.Name = "redacted"
+	config.URL = closedURL() + "/latest?base={base}"
+	config.RatesPath = "$.rates"
+	config.Auth.In = "query"
+	config.Auth.Name = "appid"
+	adapter := NewGeneric(nil, config, "secret123")
+
+	_, err := adapter.GetRate(context.Background(), "USD", "EUR")
+	if err == nil {
+		t.Fatal("fetching from a closed server succeeded")
+	}
+	if strings.Contains(err.Error(), "secret123") || !strings.Contains(err.Error(), "appid=REDACTED") {
+		t.Errorf("error %q doesn't redact the declared key parameter", err)
+	}
+}
@@ -0,0 +1,122 @@
+package adapters
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Factory builds an adapter that authenticates with apiKey.
+type Factory func(apiKey string) ExchangeRateAdapter
+
+// Registry maps source names to adapter factories.
+type Registry struct {
+	factories map[string]Factory
+	keyless   map[string]bool
+	names     []string
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: map[string]Factory{},
+		keyless:   map[string]bool{},
+	}
+}
+
+// DefaultRegistry returns a registry with every built-in provider, all
+// sending their requests with client. The ratesservice source posts to
+// ratesServiceURL.
+func DefaultRegistry(ratesServiceURL string, client *http.Client) *Registry {
+	r := NewRegistry()
+	r.Register("openexchangerates", func(apiKey string) ExchangeRateAdapter {
+		return NewOpenExchangeRates(client, apiKey)
+	}, false)
+	r.Register("fixerio", func(apiKey string) ExchangeRateAdapter {
+		return NewFixerIo(client, apiKey)
+	}, false)
+	r.Register("currencylayer", func(apiKey string) ExchangeRateAdapter {
+		return NewCurrencyLayer(client, apiKey)
+	}, false)
+	r.Register("ratesservice", func(apiKey string) ExchangeRateAdapter {
+		return NewRatesService(client, ratesServiceURL, apiKey)
+	}, false)
+	r.Register("exchangeratehost", func(apiKey string) ExchangeRateAdapter {
+		return NewExchangeRateHost(client, apiKey)
+	}, true)
+	r.Register("ecb", func(apiKey string) ExchangeRateAdapter {
+		return NewECB(client)
+	}, true)
+	r.Register("nbc", func(apiKey string) ExchangeRateAdapter {
+		return NewNBC(client)
+	}, true)
+	r.Register("coingecko", func(apiKey string) ExchangeRateAdapter {
+		return NewCoinGecko(client, apiKey)
+	}, true)
+	return r
+}
+
+// Register adds or replaces the source name. Keyless sources can be used
+// without an API key.
+func (r *Registry) Register(name string, factory Factory, keyless bool) {
+	if _, ok := r.factories[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.factories[name] = factory
+	r.keyless[name] = keyless
+}
+
+// RegisterGeneric adds a source for each declared generic adapter, in name
+// order, sending its requests with client. Adapters without auth are keyless.
+func (r *Registry) RegisterGeneric(configs map[string]GenericAdapterConfig, client *http.Client) {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		config := configs[name]
+		r.Register(name, func(apiKey string) ExchangeRateAdapter {
+			return NewGeneric(client, config, apiKey)
+		}, config.Auth.In == "")
+	}
+}
+
+// RegisterExternal adds a source for each external adapter, keyed by name
+// with its base URL, in name order, sending its requests with client.
+// External adapters are keyless, as they usually hold their provider's
+// credentials themselves.
+func (r *Registry) RegisterExternal(urls map[string]string, client *http.Client) {
+	names := make([]string, 0, len(urls))
+	for name := range urls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		name, url := name, urls[name]
+		r.Register(name, func(apiKey string) ExchangeRateAdapter {
+			return NewExternal(client, name, url, apiKey)
+		}, true)
+	}
+}
+
+// New returns the adapter registered under name, or false when the source is
+// unknown.
+func (r *Registry) New(name, apiKey string) (ExchangeRateAdapter, bool) {
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil, false
+	}
+	return factory(apiKey), true
+}
+
+// Keyless reports whether name can be called without an API key.
+func (r *Registry) Keyless(name string) bool {
+	return r.keyless[name]
+}
+
+// Names lists the registered sources in registration order.
+func (r *Registry) Names() []string {
+	return append([]string{}, r.names...)
+}
@@ -0,0 +1,95 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// providerRequest describes an outbound call to a provider API. Adapters
+// declare the method, headers and an optional body that is sent as JSON.
+type providerRequest struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   interface{}
+}
+
+// fetchProvider sends req with client, or http.DefaultClient when it is nil,
+// and returns the response body. The request is abandoned once ctx is done.
+func fetchProvider(ctx context.Context, client *http.Client, req providerRequest) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	// Encode the request body as JSON
+	var body io.Reader
+	if req.Body != nil {
+		payload, err := json.Marshal(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, req.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range req.Header {
+		for _, value := range values {
+			httpReq.Header.Add(name, value)
+		}
+	}
+	if req.Body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	// Send the request to the API
+	countCall(ctx)
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		// Keep credentials out of errors that end up in logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(urlErr.URL)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	reportQuota(ctx, resp.Header)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		// Keep the start of the body for adapters whose API explains errors
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &UpstreamStatusError{StatusCode: resp.StatusCode, Body: body}
+	}
+
+	// Read the response body
+	return ioutil.ReadAll(resp.Body)
+}
+
+// maxErrorBody caps the error response body kept in an UpstreamStatusError.
+const maxErrorBody = 4 << 10
+
+// UpstreamStatusError reports a provider answering with an error status code.
+// Body is the start of the error response.
+type UpstreamStatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *UpstreamStatusError) Error() string {
+	return fmt.Sprintf("provider responded with status %d", e.StatusCode)
+}
@@ -0,0 +1,156 @@
+package adapters
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cubetiq-samples/exchanger-go/config"
+	"cubetiq-samples/exchanger-go/metrics"
+)
+
+// defaultRetryStatuses are the provider responses worth retrying: rate limits
+// and gateway or overload errors that usually clear up within seconds.
+var defaultRetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy describes how failed provider requests are retried. Backoff
+// doubles after every attempt up to MaxBackoff, and Jitter spreads each wait
+// by up to that fraction so clients don't retry in lockstep.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Jitter      float64
+	Statuses    []int
+}
+
+// RetryPolicyFromEnv reads the retry policy from the EXCHANGER_RETRY_
+// settings. EXCHANGER_RETRY_MAX_ATTEMPTS=1 turns retries off.
+func RetryPolicyFromEnv() RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts: config.Int("EXCHANGER_RETRY_MAX_ATTEMPTS", 3),
+		Backoff:     config.Duration("EXCHANGER_RETRY_BACKOFF", 200*time.Millisecond),
+		MaxBackoff:  config.Duration("EXCHANGER_RETRY_MAX_BACKOFF", 5*time.Second),
+		Jitter:      0.2,
+		Statuses:    defaultRetryStatuses,
+	}
+	if jitter, err := strconv.ParseFloat(os.Getenv("EXCHANGER_RETRY_JITTER"), 64); err == nil && jitter >= 0 && jitter <= 1 {
+		policy.Jitter = jitter
+	}
+	if value := os.Getenv("EXCHANGER_RETRY_STATUS"); value != "" {
+		policy.Statuses = nil
+		for _, status := range strings.Split(value, ",") {
+			if code, err := strconv.Atoi(strings.TrimSpace(status)); err == nil {
+				policy.Statuses = append(policy.Statuses, code)
+			}
+		}
+	}
+	return policy
+}
+
+// retries reports whether a response with status is retried.
+func (p RetryPolicy) retries(status int) bool {
+	for _, code := range p.Statuses {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// wait returns how long to wait before the attempt after attempt, honouring
+// the provider's Retry-After header when resp carries one.
+func (p RetryPolicy) wait(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return minDuration(time.Duration(seconds)*time.Second, p.MaxBackoff)
+		}
+	}
+
+	backoff := p.Backoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = minDuration(backoff, p.MaxBackoff)
+	if p.Jitter > 0 {
+		spread := float64(backoff) * p.Jitter
+		backoff += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+	return backoff
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// retryTransport retries provider requests that failed without a response or
+// with one of the policy's statuses. Every attempt goes through next, so it
+// is metered and traced on its own. Retries stop early once the request's
+// context is done; a wait that would outlast its deadline is not started.
+type retryTransport struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.policy.MaxAttempts || req.Context().Err() != nil {
+			return resp, err
+		}
+		if err == nil && !t.policy.retries(resp.StatusCode) {
+			return resp, nil
+		}
+		// The body has been sent, so a retry needs a fresh copy
+		if req.Body != nil && req.GetBody == nil {
+			return resp, err
+		}
+
+		wait := t.policy.wait(attempt, resp)
+		if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) < wait {
+			return resp, err
+		}
+
+		reason := "error"
+		if err == nil {
+			reason = strconv.Itoa(resp.StatusCode)
+			// Drain the body so the connection can be reused
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		metrics.UpstreamRetries.WithLabelValues(req.URL.Host, reason).Inc()
+		log.Printf("upstream %s %s attempt %d failed (%s), retrying in %s", req.Method, redactURL(req.URL.String()), attempt, reason, wait.Round(time.Millisecond))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package adapters
+
+import (
+	"cubetiq-samples/exchanger-go/config"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// maxLoggedPayload bounds how much of a mismatching payload is logged.
+const maxLoggedPayload = 2048
+
+// jsonSchema is the subset of JSON Schema used by the bundled provider schemas.
+// OneOf lists alternative shapes of a value, such as rates given as an object
+// or as an array; they are expected to be disjoint, so a value matching any
+// of them conforms.
+type jsonSchema struct {
+	OneOf                []*jsonSchema          `json:"oneOf"`
+	Type                 string                 `json:"type"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+}
+
+// SchemaMismatchError reports a provider response that does not match the
+// schema bundled for that provider.
+type SchemaMismatchError struct {
+	Provider string
+	Reason   string
+}
+
+func (e *SchemaMismatchError) Error() string {
+	return fmt.Sprintf("provider_schema_mismatch: %s response %s", e.Provider, e.Reason)
+}
+
+// validateProviderResponse checks body against the provider's bundled schema
+// when validation is enabled. Mismatching payloads are logged with secret
+// redacted.
+func validateProviderResponse(provider string, body []byte, secret string) error {
+	return validateResponse(provider, provider, body, secret)
+}
+
+// validateResponse checks body against the bundled schema name, for
+// providers with several response shapes or sharing a protocol.
+func validateResponse(name, provider string, body []byte, secret string) error {
+	// EXCHANGER_VALIDATE_SCHEMA enables checking provider responses against
+	// the bundled schemas before they are parsed
+	if !config.Bool("EXCHANGER_VALIDATE_SCHEMA") {
+		return nil
+	}
+
+	schema, err := loadSchema(name)
+	if err != nil {
+		return err
+	}
+
+	var document interface{}
+	reason := "is not valid JSON"
+	if err := json.Unmarshal(body, &document); err == nil {
+		reason = schema.validate("$", document)
+	}
+	if reason == "" {
+		return nil
+	}
+
+	payload := redactSecret(string(body), secret)
+	if len(payload) > maxLoggedPayload {
+		payload = payload[:maxLoggedPayload] + "..."
+	}
+	log.Printf("provider %s response %s: %s", provider, reason, payload)
+
+	return &SchemaMismatchError{Provider: provider, Reason: reason}
+}
+
+func loadSchema(name string) (*jsonSchema, error) {
+	data, err := schemaFiles.ReadFile("schemas/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("no schema bundled for provider %s", name)
+	}
+
+	var schema jsonSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema for provider %s: %v", name, err)
+	}
+	return &schema, nil
+}
+
+// validate returns a description of the first mismatch at path, or "" when
+// value conforms to the schema.
+func (s *jsonSchema) validate(path string, value interface{}) string {
+	if len(s.OneOf) > 0 {
+		// Report the mismatch of the shape of value's type, if any
+		reason := ""
+		for _, alternative := range s.OneOf {
+			mismatch := alternative.validate(path, value)
+			if mismatch == "" {
+				return ""
+			}
+			if reason == "" || matchesType(alternative.Type, value) {
+				reason = mismatch
+			}
+		}
+		return reason
+	}
+	if s.Type != "" && !matchesType(s.Type, value) {
+		return fmt.Sprintf("has %s of type %s, expected %s", path, jsonType(value), s.Type)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Sprintf("is missing required field %s.%s", path, name)
+			}
+		}
+
+		// Walk fields in a stable order so the reported mismatch is deterministic
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			field := s.Properties[name]
+			if field == nil {
+				field = s.AdditionalProperties
+			}
+			if field == nil {
+				continue
+			}
+			if reason := field.validate(path+"."+name, v[name]); reason != "" {
+				return reason
+			}
+		}
+	case []interface{}:
+		if s.Items == nil {
+			return ""
+		}
+		for i, item := range v {
+			if reason := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); reason != "" {
+				return reason
+			}
+		}
+	}
+	return ""
+}
+
+func matchesType(expected string, value interface{}) bool {
+	actual := jsonType(value)
+	if expected == "number" && actual == "integer" {
+		return true
+	}
+	return actual == expected
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
@@ -0,0 +1,142 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unvalidatedProviders answer XML or HTML, so they have no JSON schema.
+var unvalidatedProviders = map[string]bool{"ecb": true, "nbc": true}
+
+func TestSchemasBundled(t *testing.T) {
+	for _, name := range DefaultRegistry("", nil).Names() {
+		if unvalidatedProviders[name] {
+			continue
+		}
+		if _, err := loadSchema(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	t.Setenv("EXCHANGER_VALIDATE_SCHEMA", "true")
+
+	tests := []struct {
+		schema     string
+		conforming string
+		mismatches map[string]string
+	}{
+		{"fixerio", "fixerio_latest.json", map[string]string{
+			`{"success":true,"timestamp":1714752000,"base":"EUR"}`:                          "is missing required field $.rates",
+			`{"success":true,"timestamp":"2024-05-03","base":"EUR","rates":{}}`:             "has $.timestamp of type string, expected integer",
+			`{"success":true,"timestamp":1714752000,"base":"EUR","rates":{"USD":"1.0762"}}`: "has $.rates.USD of type string, expected number",
+		}},
+		{"openexchangerates", "openexchangerates_latest.json", map[string]string{
+			`{"timestamp":1714752000,"base":"USD","rates":"0.92"}`:                          "has $.rates of type string, expected object",
+			`{"timestamp":1714752000,"base":"USD","rates":[{"currency":"EUR"}]}`:            "is missing required field $.rates[0].rate",
+			`{"timestamp":1714752000,"base":"USD","rates":[{"currency":"EUR","rate":"x"}]}`: "has $.rates[0].rate of type string, expected number",
+		}},
+		{"currencylayer", "currencylayer_live.json", map[string]string{
+			`{"success":true,"timestamp":1714752000,"source":"USD","rates":{"EUR":0.92}}`: "is missing required field $.quotes",
+		}},
+		{"exchangeratehost", "exchangeratehost_latest.json", map[string]string{
+			`{"base":"USD","rates":{"EUR":0.92}}`: "is missing required field $.date",
+		}},
+		{"coingecko", "coingecko_price.json", map[string]string{
+			`{"bitcoin":{"usd":"63012.5"}}`: "has $.bitcoin.usd of type string, expected number",
+			`[]`:                            "has $ of type array, expected object",
+		}},
+		{"ratesservice", "ratesservice_rates.json", map[string]string{
+			`{"timestamp":1714752000,"rates":{"EUR":0.92},"confidence":"high"}`: "has $.confidence of type string, expected number",
+		}},
+		{"external", "external_rates.json", map[string]string{
+			`{"base":"USD","timestamp":1714752000,"rates":{"EUR":0.92}}`: "has $.timestamp of type integer, expected string",
+			`not json`: "is not valid JSON",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.schema, func(t *testing.T) {
+			conforming, err := os.ReadFile(filepath.Join("testdata", test.conforming))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := validateResponse(test.schema, test.schema, conforming, ""); err != nil {
+				t.Errorf("%s: %v", test.conforming, err)
+			}
+
+			for payload, reason := range test.mismatches {
+				err := validateResponse(test.schema, test.schema, []byte(payload), "")
+				var mismatch *SchemaMismatchError
+				if !errors.As(err, &mismatch) {
+					t.Errorf("%s: error = %v, want a schema mismatch", payload, err)
+					continue
+				}
+				if mismatch.Provider != test.schema || mismatch.Reason != reason {
+					t.Errorf("%s: mismatch = %v, want %q", payload, mismatch, reason)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateResponseArrayRates(t *testing.T) {
+	t.Setenv("EXCHANGER_VALIDATE_SCHEMA", "true")
+	rates := `[{"currency":"EUR","rate":0.92},{"currency":"KHR","rate":4100}]`
+	for schema, payload := range map[string]string{
+		"fixerio":           `{"success":true,"timestamp":1714752000,"base":"USD","rates":` + rates + `}`,
+		"openexchangerates": `{"timestamp":1714752000,"base":"USD","rates":` + rates + `}`,
+		"exchangeratehost":  `{"base":"USD","date":"2024-05-03","rates":` + rates + `}`,
+		"ratesservice":      `{"base":"USD","timestamp":1714752000,"rates":` + rates + `}`,
+		"external":          `{"base":"USD","rates":` + rates + `}`,
+	} {
+		if err := validateResponse(schema, schema, []byte(payload), ""); err != nil {
+			t.Errorf("%s: array rates rejected: %v", schema, err)
+		}
+	}
+}
+
+func TestValidateResponseDisabled(t *testing.T) {
+	t.Setenv("EXCHANGER_VALIDATE_SCHEMA", "")
+	if err := validateProviderResponse("fixerio", []byte(`{"rates":[]}`), ""); err != nil {
+		t.Errorf("validation ran while disabled: %v", err)
+	}
+}
+
+func TestValidateResponseLogsRedactedPayload(t *testing.T) {
+	t.Setenv("EXCHANGER_VALIDATE_SCHEMA", "true")
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	payload := `{"base":"USD","note":"requested with key secret123","rates":{"EUR":0.92}}`
+	if err := validateProviderResponse("exchangeratehost", []byte(payload), "secret123"); err == nil {
+		t.Fatal("a payload without a date validated")
+	}
+	if strings.Contains(logged.String(), "secret123") || !strings.Contains(logged.String(), "requested with key REDACTED") {
+		t.Errorf("log = %q, want the payload with the key redacted", logged.String())
+	}
+}
+
+func TestAdapterSchemaMismatch(t *testing.T) {
+	t.Setenv("EXCHANGER_VALIDATE_SCHEMA", "true")
+	client := replay(t, map[string]string{"/latest": "exchangeratehost_latest.json", "/api/latest": "openexchangerates_latest.json"})
+
+	if _, err := NewExchangeRateHost(client, "").GetRate(context.Background(), "USD", "EUR"); err != nil {
+		t.Errorf("conforming exchangeratehost response: %v", err)
+	}
+
+	// Answering fixerio with another provider's payload is caught as a mismatch
+	_, err := NewFixerIo(client, "key").GetRate(context.Background(), "USD", "EUR")
+	var mismatch *SchemaMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Provider != "fixerio" {
+		t.Errorf("error = %v, want a fixerio schema mismatch", err)
+	}
+}
@@ -0,0 +1,156 @@
+package adapters
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// flights deduplicates lookups across requests; adapters are built per
+// request, so the group is shared by all of them.
+var flights singleflight.Group
+
+// SharedAdapter decorates an adapter so concurrent identical lookups for the
+// same provider and API key share one upstream request; callers with another
+// key, such as their own, don't get its answer. Every waiter gets its own copy of
+// the result and can still give up on its own context; the shared request
+// keeps running for the others, bounded by the upstream timeout.
+type SharedAdapter struct {
+	adapter ExchangeRateAdapter
+	// flight prefixes the keys of the lookups: the provider and a hash of
+	// the API key, so the key itself isn't kept.
+	flight string
+}
+
+// NewShared deduplicates the concurrent lookups adapter makes for provider
+// with apiKey.
+func NewShared(adapter ExchangeRateAdapter, provider, apiKey string) *SharedAdapter {
+	return &SharedAdapter{adapter: adapter, flight: credentialScope(provider, apiKey)}
+}
+
+// credentialScope names provider and a hash of apiKey, so that answers
+// fetched with one key are kept apart from those of another without keeping
+// the key itself.
+func credentialScope(provider, apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return provider + ":" + hex.EncodeToString(sum[:8])
+}
+
+func (s *SharedAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	value, err := s.share(ctx, "rate:"+from+":"+to, func(ctx context.Context) (interface{}, error) {
+		return s.adapter.GetRate(ctx, from, to)
+	})
+	if err != nil {
+		return RateResult{}, err
+	}
+	return value.(RateResult), nil
+}
+
+func (s *SharedAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	key := "rate:" + from + ":" + to + ":" + date.Format("2006-01-02")
+	value, err := s.share(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return s.adapter.GetExchangeRateAt(ctx, from, to, date)
+	})
+	if err != nil {
+		return RateResult{}, err
+	}
+	return value.(RateResult), nil
+}
+
+func (s *SharedAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	// Without a time-series API each day is shared on its own
+	series, ok := s.adapter.(TimeSeriesAdapter)
+	if !ok {
+		return dailySeries(ctx, s, from, to, start, end)
+	}
+	key := "series:" + from + ":" + to + ":" + start.Format("2006-01-02") + ":" + end.Format("2006-01-02")
+	value, err := s.share(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return series.GetTimeSeries(ctx, from, to, start, end)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]RateResult(nil), value.([]RateResult)...), nil
+}
+
+func (s *SharedAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	key := "rates:" + from + ":*"
+	if to != nil {
+		targets := append([]string(nil), to...)
+		sort.Strings(targets)
+		key = "rates:" + from + ":" + strings.Join(targets, ",")
+	}
+	value, err := s.share(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return FetchRates(ctx, s.adapter, from, to)
+	})
+	if err != nil {
+		return nil, err
+	}
+	shared := value.(map[string]RateResult)
+	results := make(map[string]RateResult, len(shared))
+	for target, result := range shared {
+		results[target] = result
+	}
+	return results, nil
+}
+
+func (s *SharedAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	value, err := s.share(ctx, "symbols", func(ctx context.Context) (interface{}, error) {
+		return FetchSymbols(ctx, s.adapter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	shared := value.(map[string]string)
+	symbols := make(map[string]string, len(shared))
+	for code, name := range shared {
+		symbols[code] = name
+	}
+	return symbols, nil
+}
+
+func (s *SharedAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := s.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (s *SharedAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := s.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
+
+// share runs fetch once for all concurrent callers with the same key. The
+// fetch runs detached from the first caller's cancellation, so that caller
+// leaving doesn't fail the others, but keeps its values such as the trace.
+func (s *SharedAdapter) share(ctx context.Context, key string, fetch func(context.Context) (interface{}, error)) (interface{}, error) {
+	flight := flights.DoChan(s.flight+":"+key, func() (interface{}, error) {
+		return fetch(detached{ctx})
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-flight:
+		return result.Val, result.Err
+	}
+}
+
+// detached carries the values of a context but not its deadline or
+// cancellation.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
@@ -0,0 +1,171 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"cubetiq-samples/exchanger-go/config"
+)
+
+// CacheMaxStale returns how long past its TTL a cached rate of provider may
+// still be served while the provider fails, taken from
+// EXCHANGER_CACHE_MAX_STALE_<PROVIDER> or EXCHANGER_CACHE_MAX_STALE. Zero,
+// the default, never serves stale rates.
+func CacheMaxStale(provider string) time.Duration {
+	maxStale := config.Duration("EXCHANGER_CACHE_MAX_STALE", 0)
+	return config.Duration("EXCHANGER_CACHE_MAX_STALE_"+strings.ToUpper(provider), maxStale)
+}
+
+// StaleError is a failed lookup for which the cache still holds rates past
+// their TTL, marked Stale. Result is set for single rates and Results for
+// rate tables. A lookup failing this way can be answered by other providers
+// first; StaleAdapter serves the stale rates when none does.
+type StaleError struct {
+	Result  RateResult
+	Results map[string]RateResult
+	Err     error
+}
+
+func (e *StaleError) Error() string {
+	return e.Err.Error() + " (a stale rate is cached)"
+}
+
+func (e *StaleError) Unwrap() error {
+	return e.Err
+}
+
+// errRevalidating fails lookups of entries being refreshed in the background,
+// so they don't wait on a provider that just failed.
+var errRevalidating = errors.New("provider failed recently, refreshing in the background")
+
+// servesStale reports whether a lookup failing with err may be answered from
+// a stale entry: the provider failed, rather than not knowing the pair or
+// the caller giving up.
+func servesStale(err error) bool {
+	return !errors.Is(err, ErrProviderCurrency) && !errors.Is(err, ErrHistoricalNotSupported) &&
+		!errors.Is(err, ErrRateTableNotSupported) && !errors.Is(err, context.Canceled)
+}
+
+// revalidating holds the cache keys being refreshed in the background.
+var revalidating sync.Map
+
+// revalidate retries refresh in the background until it succeeds or until,
+// when the stale entry of key expires, unless key is already being refreshed.
+// Retries start after staleRetryBackoff and back off to a minute.
+func revalidate(key string, until time.Time, refresh func(ctx context.Context) error) {
+	if _, running := revalidating.LoadOrStore(key, true); running {
+		return
+	}
+	go func() {
+		defer revalidating.Delete(key)
+		backoff := staleRetryBackoff
+		for time.Now().Add(backoff).Before(until) {
+			time.Sleep(backoff)
+			err := refresh(context.Background())
+			if err == nil {
+				return
+			}
+			log.Printf("cache: refreshing %s: %v", key, err)
+			if backoff *= 2; backoff > time.Minute {
+				backoff = time.Minute
+			}
+		}
+	}()
+}
+
+// staleRetryBackoff is the wait before the first background refresh.
+const staleRetryBackoff = 5 * time.Second
+
+func isRevalidating(key string) bool {
+	_, ok := revalidating.Load(key)
+	return ok
+}
+
+// StaleAdapter answers with the stale cached rates of lookups that failed
+// with a StaleError, so an approximate rate beats no rate at all.
+type StaleAdapter struct {
+	adapter ExchangeRateAdapter
+}
+
+// NewStale serves the stale rates adapter's cache still has when it fails.
+func NewStale(adapter ExchangeRateAdapter) *StaleAdapter {
+	return &StaleAdapter{adapter: adapter}
+}
+
+func (s *StaleAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	result, err := s.adapter.GetRate(ctx, from, to)
+	var stale *StaleError
+	if errors.As(err, &stale) && stale.Results == nil {
+		log.Printf("serving a stale %s/%s rate of %s: %v", from, to, stale.Result.Provider, stale.Err)
+		return stale.Result, nil
+	}
+	return result, err
+}
+
+func (s *StaleAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	result, err := s.adapter.GetExchangeRateAt(ctx, from, to, date)
+	var stale *StaleError
+	if errors.As(err, &stale) && stale.Results == nil {
+		return stale.Result, nil
+	}
+	return result, err
+}
+
+func (s *StaleAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	results, err := FetchRates(ctx, s.adapter, from, to)
+	var stale *StaleError
+	if errors.As(err, &stale) && stale.Results != nil {
+		log.Printf("serving stale %s rates: %v", from, stale.Err)
+		return stale.Results, nil
+	}
+	return results, err
+}
+
+func (s *StaleAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	return FetchTimeSeries(ctx, s.adapter, from, to, start, end)
+}
+
+func (s *StaleAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	return FetchSymbols(ctx, s.adapter)
+}
+
+func (s *StaleAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := s.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (s *StaleAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := s.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
+
+// staleFallback keeps the first StaleError of the providers a fallback
+// tried, to be wrapped into its error when all of them failed.
+type staleFallback struct {
+	first *StaleError
+}
+
+func (f *staleFallback) add(err error) {
+	var stale *StaleError
+	if f.first == nil && errors.As(err, &stale) {
+		f.first = stale
+	}
+}
+
+// wrap returns failed, carrying the first stale rates when there are some.
+func (f *staleFallback) wrap(failed error) error {
+	if f.first == nil {
+		return failed
+	}
+	return &StaleError{Result: f.first.Result, Results: f.first.Results, Err: failed}
+}
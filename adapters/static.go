@@ -0,0 +1,294 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StaticRatesFile is the schema of a static rates file, in JSON or YAML:
+//
+//	{
+//	  "base": "USD",
+//	  "timestamp": "2024-05-01T00:00:00Z",
+//	  "rates": {"EUR": 0.92, "KHR": 4100},
+//	  "historical": {"2024-04-30": {"EUR": 0.93, "KHR": 4095}}
+//	}
+//
+// Rates are quoted against base; other pairs are crossed through it.
+// Timestamp may be an RFC 3339 time or a date and defaults to the file's
+// modification time. Historical is optional.
+type StaticRatesFile struct {
+	Base       string           `json:"base" yaml:"base"`
+	Timestamp  string           `json:"timestamp" yaml:"timestamp"`
+	Rates      Rates            `json:"rates" yaml:"rates"`
+	Historical map[string]Rates `json:"historical" yaml:"historical"`
+}
+
+// staticRates is a parsed static rates file.
+type staticRates struct {
+	base       string
+	timestamp  time.Time
+	rates      Rates
+	historical map[string]Rates
+}
+
+// loadStaticRates reads and validates the static rates file at path.
+func loadStaticRates(path string) (staticRates, os.FileInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return staticRates{}, nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return staticRates{}, nil, err
+	}
+
+	var file StaticRatesFile
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(data, &file)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	default:
+		return staticRates{}, nil, fmt.Errorf("%s: unsupported rates file format %q, expected .json, .yaml or .yml", path, ext)
+	}
+	if err != nil {
+		return staticRates{}, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	static := staticRates{
+		base:       strings.ToUpper(file.Base),
+		timestamp:  info.ModTime().UTC(),
+		rates:      normalizeStaticRates(file.Rates, file.Base),
+		historical: map[string]Rates{},
+	}
+	if len(static.base) != 3 {
+		return staticRates{}, nil, fmt.Errorf("%s: base must be a currency code", path)
+	}
+	if len(file.Rates) == 0 {
+		return staticRates{}, nil, fmt.Errorf("%s: no rates", path)
+	}
+	if file.Timestamp != "" {
+		if static.timestamp, err = time.Parse(time.RFC3339, file.Timestamp); err != nil {
+			if static.timestamp, err = time.Parse("2006-01-02", file.Timestamp); err != nil {
+				return staticRates{}, nil, fmt.Errorf("%s: timestamp must be an RFC 3339 time or a YYYY-MM-DD date", path)
+			}
+		}
+	}
+	if err := checkStaticRates(static.rates); err != nil {
+		return staticRates{}, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for date, rates := range file.Historical {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return staticRates{}, nil, fmt.Errorf("%s: historical date %q must be YYYY-MM-DD", path, date)
+		}
+		static.historical[date] = normalizeStaticRates(rates, file.Base)
+		if err := checkStaticRates(static.historical[date]); err != nil {
+			return staticRates{}, nil, fmt.Errorf("%s: %s: %w", path, date, err)
+		}
+	}
+	return static, info, nil
+}
+
+// checkStaticRates rejects rates that can't be crossed.
+func checkStaticRates(rates Rates) error {
+	for code, rate := range rates {
+		if rate <= 0 {
+			return fmt.Errorf("rate for %s must be positive", code)
+		}
+	}
+	return nil
+}
+
+// normalizeStaticRates upper-cases the codes of rates and adds base itself.
+func normalizeStaticRates(rates Rates, base string) Rates {
+	normalized := make(Rates, len(rates)+1)
+	for code, rate := range rates {
+		normalized[strings.ToUpper(code)] = rate
+	}
+	normalized[strings.ToUpper(base)] = 1
+	return normalized
+}
+
+// StaticAdapter answers from a local rates file and never calls a network,
+// for air-gapped deployments and integration tests. Watch reloads the file
+// when it changes.
+type StaticAdapter struct {
+	path string
+
+	mu      sync.RWMutex
+	rates   staticRates
+	modTime time.Time
+	size    int64
+}
+
+// NewStatic loads the static rates file at path.
+func NewStatic(path string) (*StaticAdapter, error) {
+	rates, info, err := loadStaticRates(path)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticAdapter{path: path, rates: rates, modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// Watch checks the file every interval until ctx is done and reloads it when
+// it changed. A file that fails to load is logged and the previous rates are
+// kept.
+func (s *StaticAdapter) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(s.path)
+		if err != nil {
+			log.Printf("static rates: %v", err)
+			continue
+		}
+		s.mu.RLock()
+		changed := !info.ModTime().Equal(s.modTime) || info.Size() != s.size
+		s.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		rates, info, err := loadStaticRates(s.path)
+		if err != nil {
+			log.Printf("static rates: keeping the previous rates: %v", err)
+			// Don't retry the same broken file on every tick
+			if info, statErr := os.Stat(s.path); statErr == nil {
+				s.mu.Lock()
+				s.modTime, s.size = info.ModTime(), info.Size()
+				s.mu.Unlock()
+			}
+			continue
+		}
+		s.mu.Lock()
+		s.rates, s.modTime, s.size = rates, info.ModTime(), info.Size()
+		s.mu.Unlock()
+		log.Printf("static rates: reloaded %s", s.path)
+	}
+}
+
+func (s *StaticAdapter) current() staticRates {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rates
+}
+
+func (s *StaticAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	static := s.current()
+	return crossStaticRate(static.rates, from, to, static.timestamp, "")
+}
+
+func (s *StaticAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	static := s.current()
+	if to == nil {
+		to = tableTargets(static.rates)
+	}
+
+	results := make(map[string]RateResult, len(to))
+	for _, target := range to {
+		result, err := crossStaticRate(static.rates, from, target, static.timestamp, "")
+		if err != nil {
+			return nil, err
+		}
+		results[target] = result
+	}
+	return results, nil
+}
+
+func (s *StaticAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	// The file carries codes only
+	static := s.current()
+	symbols := make(map[string]string, len(static.rates))
+	for code := range static.rates {
+		symbols[code] = ""
+	}
+	return symbols, nil
+}
+
+func (s *StaticAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	static := s.current()
+	if len(static.historical) == 0 {
+		return RateResult{}, ErrHistoricalNotSupported
+	}
+	day := date.Format("2006-01-02")
+	rates, ok := static.historical[day]
+	if !ok {
+		return RateResult{}, fmt.Errorf("static rates file has no rates on %s: %w", day, ErrNoRatesOnDate)
+	}
+	timestamp, _ := time.Parse("2006-01-02", day)
+	return crossStaticRate(rates, from, to, timestamp, day)
+}
+
+func (s *StaticAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	static := s.current()
+	if len(static.historical) == 0 {
+		return nil, ErrHistoricalNotSupported
+	}
+
+	first, last := start.Format("2006-01-02"), end.Format("2006-01-02")
+	daily := map[string]Rates{}
+	for date, rates := range static.historical {
+		if date >= first && date <= last {
+			daily[date] = rates
+		}
+	}
+	return seriesFromDailyRates("static", static.base, from, to, daily)
+}
+
+func (s *StaticAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := s.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (s *StaticAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := s.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
+
+// errStaticCurrency wraps ErrProviderCurrency for currencies the file lacks.
+func errStaticCurrency(code string) error {
+	return fmt.Errorf("static rates file has no rate for %s: %w", code, ErrProviderCurrency)
+}
+
+// crossStaticRate crosses from/to through the base of rates.
+func crossStaticRate(rates Rates, from, to string, timestamp time.Time, date string) (RateResult, error) {
+	fromRate, ok := rates[from]
+	if !ok {
+		return RateResult{}, errStaticCurrency(from)
+	}
+	toRate, ok := rates[to]
+	if !ok {
+		return RateResult{}, errStaticCurrency(to)
+	}
+	return RateResult{
+		Rate:      toRate / fromRate,
+		Base:      from,
+		Target:    to,
+		Timestamp: timestamp,
+		Date:      date,
+		Provider:  "static",
+	}, nil
+}
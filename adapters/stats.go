@@ -0,0 +1,172 @@
+package adapters
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// statsWindow is how many of a provider's latest calls its error rate and
+// latency are computed over.
+const statsWindow = 100
+
+// quotaHeaders are the response headers providers report their remaining
+// quota in, each with the header of the matching limit, in order of
+// preference.
+var quotaHeaders = [][2]string{
+	{"X-RateLimit-Remaining", "X-RateLimit-Limit"},
+	{"X-RateLimit-Remaining-Day", "X-RateLimit-Limit-Day"},
+	{"X-RateLimit-Remaining-Month", "X-RateLimit-Limit-Month"},
+	{"RateLimit-Remaining", "RateLimit-Limit"},
+}
+
+// ProviderStats reports how the latest calls to a provider went.
+type ProviderStats struct {
+	Provider string `json:"provider"`
+	// Calls is how many calls the error rate and latency cover.
+	Calls        int        `json:"calls"`
+	ErrorRate    float64    `json:"errorRate"`
+	P95LatencyMs float64    `json:"p95LatencyMs"`
+	LastSuccess  *time.Time `json:"lastSuccess,omitempty"`
+	LastError    string     `json:"lastError,omitempty"`
+	LastErrorAt  *time.Time `json:"lastErrorAt,omitempty"`
+	// Quota is set once the provider reported its quota in a response.
+	Quota *ProviderQuota `json:"quota,omitempty"`
+}
+
+// ProviderQuota is the quota a provider reported in its latest response.
+type ProviderQuota struct {
+	Remaining  int64     `json:"remaining"`
+	Limit      *int64    `json:"limit,omitempty"`
+	ReportedAt time.Time `json:"reportedAt"`
+}
+
+// CallStats keeps the outcome and latency of a provider's latest calls, and
+// the quota it last reported.
+type CallStats struct {
+	provider string
+
+	mu          sync.Mutex
+	calls       [statsWindow]callOutcome
+	next, count int
+	lastSuccess time.Time
+	lastError   string
+	lastErrorAt time.Time
+	quota       *ProviderQuota
+}
+
+type callOutcome struct {
+	latency time.Duration
+	failed  bool
+}
+
+// record adds a finished call to the window.
+func (s *CallStats) record(latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[s.next] = callOutcome{latency: latency, failed: err != nil}
+	s.next = (s.next + 1) % statsWindow
+	if s.count < statsWindow {
+		s.count++
+	}
+	if err != nil {
+		s.lastError, s.lastErrorAt = err.Error(), time.Now().UTC()
+	} else {
+		s.lastSuccess = time.Now().UTC()
+	}
+}
+
+// reportQuota keeps the quota a provider's response reports, if it does.
+func (s *CallStats) reportQuota(header http.Header) {
+	for _, names := range quotaHeaders {
+		remaining, err := strconv.ParseInt(header.Get(names[0]), 10, 64)
+		if err != nil {
+			continue
+		}
+		quota := &ProviderQuota{Remaining: remaining, ReportedAt: time.Now().UTC()}
+		if limit, err := strconv.ParseInt(header.Get(names[1]), 10, 64); err == nil {
+			quota.Limit = &limit
+		}
+		s.mu.Lock()
+		s.quota = quota
+		s.mu.Unlock()
+		return
+	}
+}
+
+// Snapshot returns the provider's statistics.
+func (s *CallStats) Snapshot() ProviderStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := ProviderStats{Provider: s.provider, Calls: s.count}
+	if s.count > 0 {
+		latencies := make([]time.Duration, s.count)
+		failed := 0
+		for i, call := range s.calls[:s.count] {
+			latencies[i] = call.latency
+			if call.failed {
+				failed++
+			}
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		stats.ErrorRate = float64(failed) / float64(s.count)
+		stats.P95LatencyMs = float64(latencies[(s.count*95+99)/100-1]) / float64(time.Millisecond)
+	}
+	if !s.lastSuccess.IsZero() {
+		lastSuccess := s.lastSuccess
+		stats.LastSuccess = &lastSuccess
+	}
+	if s.lastError != "" {
+		lastErrorAt := s.lastErrorAt
+		stats.LastError, stats.LastErrorAt = s.lastError, &lastErrorAt
+	}
+	if s.quota != nil {
+		quota := *s.quota
+		stats.Quota = &quota
+	}
+	return stats
+}
+
+// Stats holds the call statistics of every provider. Adapters are built per
+// request, so the statistics live as long as the server does.
+type Stats struct {
+	mu    sync.Mutex
+	stats map[string]*CallStats
+}
+
+// NewStats returns empty statistics.
+func NewStats() *Stats {
+	return &Stats{stats: map[string]*CallStats{}}
+}
+
+// Get returns the statistics of provider.
+func (s *Stats) Get(provider string) *CallStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats, ok := s.stats[provider]
+	if !ok {
+		stats = &CallStats{provider: provider}
+		s.stats[provider] = stats
+	}
+	return stats
+}
+
+type callStatsKey struct{}
+
+// withCallStats returns a context under which the quota providers report is
+// kept in stats.
+func withCallStats(ctx context.Context, stats *CallStats) context.Context {
+	return context.WithValue(ctx, callStatsKey{}, stats)
+}
+
+// reportQuota keeps the quota of a provider response in the statistics of
+// ctx, if it has them.
+func reportQuota(ctx context.Context, header http.Header) {
+	if stats, ok := ctx.Value(callStatsKey{}).(*CallStats); ok {
+		stats.reportQuota(header)
+	}
+}
@@ -0,0 +1,17 @@
+package adapters
+
+import "context"
+
+// SymbolsAdapter is implemented by adapters that can list the currencies
+// their provider supports, keyed by code with the provider's name for each.
+type SymbolsAdapter interface {
+	GetSymbols(ctx context.Context) (map[string]string, error)
+}
+
+// FetchSymbols returns the currencies supported by adapter.
+func FetchSymbols(ctx context.Context, adapter ExchangeRateAdapter) (map[string]string, error) {
+	if symbols, ok := adapter.(SymbolsAdapter); ok {
+		return symbols.GetSymbols(ctx)
+	}
+	return nil, ErrSymbolsNotSupported
+}
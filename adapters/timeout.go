@@ -0,0 +1,83 @@
+package adapters
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"cubetiq-samples/exchanger-go/config"
+)
+
+// defaultUpstreamTimeout bounds a provider lookup unless
+// EXCHANGER_UPSTREAM_TIMEOUT says otherwise.
+const defaultUpstreamTimeout = 10 * time.Second
+
+// UpstreamTimeout returns how long a lookup may wait on provider, taken from
+// EXCHANGER_UPSTREAM_TIMEOUT_<PROVIDER> or EXCHANGER_UPSTREAM_TIMEOUT.
+func UpstreamTimeout(provider string) time.Duration {
+	timeout := config.Duration("EXCHANGER_UPSTREAM_TIMEOUT", defaultUpstreamTimeout)
+	return config.Duration("EXCHANGER_UPSTREAM_TIMEOUT_"+strings.ToUpper(provider), timeout)
+}
+
+// TimeoutAdapter decorates an adapter so every lookup gives up after timeout,
+// or earlier when the caller's context is done.
+type TimeoutAdapter struct {
+	adapter ExchangeRateAdapter
+	timeout time.Duration
+}
+
+// NewTimeout bounds every lookup adapter makes by timeout.
+func NewTimeout(adapter ExchangeRateAdapter, timeout time.Duration) *TimeoutAdapter {
+	return &TimeoutAdapter{adapter: adapter, timeout: timeout}
+}
+
+func (t *TimeoutAdapter) GetRate(ctx context.Context, from, to string) (RateResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.adapter.GetRate(ctx, from, to)
+}
+
+func (t *TimeoutAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (RateResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.adapter.GetExchangeRateAt(ctx, from, to, date)
+}
+
+func (t *TimeoutAdapter) GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error) {
+	// Without a time-series API each day gets a timeout of its own
+	series, ok := t.adapter.(TimeSeriesAdapter)
+	if !ok {
+		return dailySeries(ctx, t, from, to, start, end)
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return series.GetTimeSeries(ctx, from, to, start, end)
+}
+
+func (t *TimeoutAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]RateResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return FetchRates(ctx, t.adapter, from, to)
+}
+
+func (t *TimeoutAdapter) GetSymbols(ctx context.Context) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return FetchSymbols(ctx, t.adapter)
+}
+
+func (t *TimeoutAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	result, err := t.GetRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.Rate, nil
+}
+
+func (t *TimeoutAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := t.GetExchangeRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
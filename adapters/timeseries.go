@@ -0,0 +1,103 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// seriesWorkers caps concurrent historical lookups for providers without a
+// time-series API.
+const seriesWorkers = 4
+
+// TimeSeriesAdapter is implemented by adapters whose provider returns a whole
+// date range in one request.
+type TimeSeriesAdapter interface {
+	GetTimeSeries(ctx context.Context, from, to string, start, end time.Time) ([]RateResult, error)
+}
+
+// FetchTimeSeries returns the daily rates between start and end inclusive,
+// using the provider's time-series API when it has one and falling back to
+// one historical lookup per day.
+func FetchTimeSeries(ctx context.Context, adapter ExchangeRateAdapter, from, to string, start, end time.Time) ([]RateResult, error) {
+	if series, ok := adapter.(TimeSeriesAdapter); ok {
+		return series.GetTimeSeries(ctx, from, to, start, end)
+	}
+	return dailySeries(ctx, adapter, from, to, start, end)
+}
+
+// dailySeries looks up each day between start and end with a bounded number
+// of concurrent historical requests.
+func dailySeries(ctx context.Context, adapter ExchangeRateAdapter, from, to string, start, end time.Time) ([]RateResult, error) {
+	var days []time.Time
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+
+	results := make([]RateResult, len(days))
+	errs := make([]error, len(days))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < seriesWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				results[index], errs[index] = adapter.GetExchangeRateAt(ctx, from, to, days[index])
+			}
+		}()
+	}
+	for index := range days {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// seriesFromDailyRates converts a provider's {date: {currency: rate}} map,
+// quoted against base, into a date-ordered series for from/to.
+func seriesFromDailyRates(provider, base, from, to string, daily map[string]Rates) ([]RateResult, error) {
+	dates := make([]string, 0, len(daily))
+	for date := range daily {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	series := make([]RateResult, 0, len(dates))
+	for _, date := range dates {
+		rates := daily[date]
+		if _, ok := rates[base]; !ok {
+			rates[base] = 1
+		}
+
+		fromRate, fromOK := rates[from]
+		toRate, toOK := rates[to]
+		if !fromOK || !toOK || fromRate == 0 {
+			return nil, fmt.Errorf("%s has no rate for %s/%s on %s", provider, from, to, date)
+		}
+
+		day, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, RateResult{
+			Rate:      toRate / fromRate,
+			Base:      from,
+			Target:    to,
+			Timestamp: day,
+			Date:      date,
+			Provider:  provider,
+		})
+	}
+	return series, nil
+}
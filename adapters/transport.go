@@ -0,0 +1,214 @@
+package adapters
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"cubetiq-samples/exchanger-go/config"
+	"cubetiq-samples/exchanger-go/metrics"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv/v1.17.0/httpconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewHTTPClient returns the client the adapters share for provider requests.
+// Its connection pool, proxy and TLS settings come from the EXCHANGER_HTTP_
+// settings; on top of that every request is retried, metered and traced.
+// Sharing one client lets connections to a provider be reused across
+// requests and adapters.
+func NewHTTPClient() (*http.Client, error) {
+	base, err := newBaseTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	var transport http.RoundTripper = &meteredTransport{next: &spanTransport{next: base}}
+	if config.Bool("EXCHANGER_DEBUG") {
+		transport = &tracingTransport{next: transport}
+	}
+	if policy := RetryPolicyFromEnv(); policy.MaxAttempts > 1 {
+		transport = &retryTransport{next: transport, policy: policy}
+	}
+	// Lookups are bounded by their context, see UpstreamTimeout
+	return &http.Client{Transport: transport}, nil
+}
+
+// newBaseTransport builds the pooled transport provider requests go out on.
+func newBaseTransport() (*http.Transport, error) {
+	proxy := http.ProxyFromEnvironment
+	if value := os.Getenv("EXCHANGER_HTTP_PROXY"); value != "" {
+		proxyURL, err := url.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("EXCHANGER_HTTP_PROXY: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if os.Getenv("EXCHANGER_HTTP_TLS_MIN_VERSION") == "1.3" {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+	if path := os.Getenv("EXCHANGER_HTTP_CA_FILE"); path != "" {
+		pem, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("EXCHANGER_HTTP_CA_FILE: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("EXCHANGER_HTTP_CA_FILE: no certificates found in %s", path)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   config.Duration("EXCHANGER_HTTP_DIAL_TIMEOUT", 5*time.Second),
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:               proxy,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: config.Duration("EXCHANGER_HTTP_TLS_HANDSHAKE_TIMEOUT", 10*time.Second),
+		ForceAttemptHTTP2:   true,
+		DisableKeepAlives:   config.Bool("EXCHANGER_HTTP_DISABLE_KEEPALIVES"),
+		MaxIdleConns:        config.Int("EXCHANGER_HTTP_MAX_IDLE_CONNS", 100),
+		MaxIdleConnsPerHost: config.Int("EXCHANGER_HTTP_MAX_IDLE_CONNS_PER_HOST", 10),
+		MaxConnsPerHost:     config.Int("EXCHANGER_HTTP_MAX_CONNS_PER_HOST", 0),
+		IdleConnTimeout:     config.Duration("EXCHANGER_HTTP_IDLE_CONN_TIMEOUT", 90*time.Second),
+	}, nil
+}
+
+// meteredTransport counts every outbound request by host and status.
+type meteredTransport struct {
+	next http.RoundTripper
+}
+
+func (t *meteredTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	metrics.UpstreamHTTPRequests.WithLabelValues(req.URL.Host, status).Inc()
+	return resp, err
+}
+
+// spanTransport records every outbound request as an OpenTelemetry client
+// span, with child spans for DNS, connecting, TLS and waiting on the
+// response. The URL attribute is redacted and headers are left out, as both
+// can carry provider credentials. Trace context is not sent upstream: the
+// providers are third parties.
+type spanTransport struct {
+	next http.RoundTripper
+}
+
+var tracer = otel.Tracer("cubetiq-samples/exchanger-go/adapters")
+
+func (t *spanTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attributes := httpconv.ClientRequest(req)
+	for i, attribute := range attributes {
+		if attribute.Key == "http.url" {
+			attributes[i] = attribute.Key.String(redactURL(req.URL.String()))
+		}
+	}
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...),
+	)
+	ctx = httptrace.WithClientTrace(ctx, otelhttptrace.NewClientTrace(ctx, otelhttptrace.WithoutHeaders()))
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return nil, err
+	}
+
+	span.SetAttributes(httpconv.ClientResponse(resp)...)
+	span.SetStatus(httpconv.ClientStatus(resp.StatusCode))
+	resp.Body = &spanBody{ReadCloser: resp.Body, span: span}
+	return resp, nil
+}
+
+// spanBody ends the request span once the response body is closed, so the
+// span covers reading the response too.
+type spanBody struct {
+	io.ReadCloser
+	span trace.Span
+}
+
+func (b *spanBody) Close() error {
+	b.span.End()
+	return b.ReadCloser.Close()
+}
+
+// tracingTransport logs every outbound request with its status, duration and
+// response size. Credentials in the URL are always redacted.
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	target := redactURL(req.URL.String())
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		// url.Error embeds the raw URL, so only log the underlying cause
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		log.Printf("upstream %s %s failed after %s: %v", req.Method, target, time.Since(start), err)
+		return nil, err
+	}
+
+	resp.Body = &tracedBody{
+		ReadCloser: resp.Body,
+		method:     req.Method,
+		url:        target,
+		status:     resp.StatusCode,
+		start:      start,
+	}
+	return resp, nil
+}
+
+// tracedBody counts the bytes read from a response and logs the trace line
+// once the body is closed.
+type tracedBody struct {
+	io.ReadCloser
+	method string
+	url    string
+	status int
+	start  time.Time
+	size   int64
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	log.Printf("upstream %s %s -> %d in %s (%d bytes)", b.method, b.url, b.status, time.Since(b.start), b.size)
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,262 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: api/exchanger/v1/exchanger.proto
+
+package exchangerv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	ExchangerService_GetRate_FullMethodName        = "/exchanger.v1.ExchangerService/GetRate"
+	ExchangerService_Convert_FullMethodName        = "/exchanger.v1.ExchangerService/Convert"
+	ExchangerService_ListCurrencies_FullMethodName = "/exchanger.v1.ExchangerService/ListCurrencies"
+	ExchangerService_WatchRates_FullMethodName     = "/exchanger.v1.ExchangerService/WatchRates"
+)
+
+// ExchangerServiceClient is the client API for ExchangerService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ExchangerServiceClient interface {
+	// GetRate returns the rate between two currencies.
+	GetRate(ctx context.Context, in *GetRateRequest, opts ...grpc.CallOption) (*GetRateResponse, error)
+	// Convert converts an amount between two currencies, applying the
+	// configured markup.
+	Convert(ctx context.Context, in *ConvertRequest, opts ...grpc.CallOption) (*ConvertResponse, error)
+	// ListCurrencies lists the ISO 4217 currencies, or those a provider
+	// supports when a source is given.
+	ListCurrencies(ctx context.Context, in *ListCurrenciesRequest, opts ...grpc.CallOption) (*ListCurrenciesResponse, error)
+	// WatchRates polls the rates of base against targets and streams every
+	// change, starting with the current rates.
+	WatchRates(ctx context.Context, in *WatchRatesRequest, opts ...grpc.CallOption) (ExchangerService_WatchRatesClient, error)
+}
+
+type exchangerServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewExchangerServiceClient(cc grpc.ClientConnInterface) ExchangerServiceClient {
+	return &exchangerServiceClient{cc}
+}
+
+func (c *exchangerServiceClient) GetRate(ctx context.Context, in *GetRateRequest, opts ...grpc.CallOption) (*GetRateResponse, error) {
+	out := new(GetRateResponse)
+	err := c.cc.Invoke(ctx, ExchangerService_GetRate_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exchangerServiceClient) Convert(ctx context.Context, in *ConvertRequest, opts ...grpc.CallOption) (*ConvertResponse, error) {
+	out := new(ConvertResponse)
+	err := c.cc.Invoke(ctx, ExchangerService_Convert_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exchangerServiceClient) ListCurrencies(ctx context.Context, in *ListCurrenciesRequest, opts ...grpc.CallOption) (*ListCurrenciesResponse, error) {
+	out := new(ListCurrenciesResponse)
+	err := c.cc.Invoke(ctx, ExchangerService_ListCurrencies_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exchangerServiceClient) WatchRates(ctx context.Context, in *WatchRatesRequest, opts ...grpc.CallOption) (ExchangerService_WatchRatesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ExchangerService_ServiceDesc.Streams[0], ExchangerService_WatchRates_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &exchangerServiceWatchRatesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ExchangerService_WatchRatesClient interface {
+	Recv() (*RateUpdate, error)
+	grpc.ClientStream
+}
+
+type exchangerServiceWatchRatesClient struct {
+	grpc.ClientStream
+}
+
+func (x *exchangerServiceWatchRatesClient) Recv() (*RateUpdate, error) {
+	m := new(RateUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ExchangerServiceServer is the server API for ExchangerService service.
+// All implementations must embed UnimplementedExchangerServiceServer
+// for forward compatibility
+type ExchangerServiceServer interface {
+	// GetRate returns the rate between two currencies.
+	GetRate(context.Context, *GetRateRequest) (*GetRateResponse, error)
+	// Convert converts an amount between two currencies, applying the
+	// configured markup.
+	Convert(context.Context, *ConvertRequest) (*ConvertResponse, error)
+	// ListCurrencies lists the ISO 4217 currencies, or those a provider
+	// supports when a source is given.
+	ListCurrencies(context.Context, *ListCurrenciesRequest) (*ListCurrenciesResponse, error)
+	// WatchRates polls the rates of base against targets and streams every
+	// change, starting with the current rates.
+	WatchRates(*WatchRatesRequest, ExchangerService_WatchRatesServer) error
+	mustEmbedUnimplementedExchangerServiceServer()
+}
+
+// UnimplementedExchangerServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedExchangerServiceServer struct {
+}
+
+func (UnimplementedExchangerServiceServer) GetRate(context.Context, *GetRateRequest) (*GetRateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRate not implemented")
+}
+func (UnimplementedExchangerServiceServer) Convert(context.Context, *ConvertRequest) (*ConvertResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Convert not implemented")
+}
+func (UnimplementedExchangerServiceServer) ListCurrencies(context.Context, *ListCurrenciesRequest) (*ListCurrenciesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCurrencies not implemented")
+}
+func (UnimplementedExchangerServiceServer) WatchRates(*WatchRatesRequest, ExchangerService_WatchRatesServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchRates not implemented")
+}
+func (UnimplementedExchangerServiceServer) mustEmbedUnimplementedExchangerServiceServer() {}
+
+// UnsafeExchangerServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ExchangerServiceServer will
+// result in compilation errors.
+type UnsafeExchangerServiceServer interface {
+	mustEmbedUnimplementedExchangerServiceServer()
+}
+
+func RegisterExchangerServiceServer(s grpc.ServiceRegistrar, srv ExchangerServiceServer) {
+	s.RegisterService(&ExchangerService_ServiceDesc, srv)
+}
+
+func _ExchangerService_GetRate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExchangerServiceServer).GetRate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExchangerService_GetRate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExchangerServiceServer).GetRate(ctx, req.(*GetRateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExchangerService_Convert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConvertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExchangerServiceServer).Convert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExchangerService_Convert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExchangerServiceServer).Convert(ctx, req.(*ConvertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExchangerService_ListCurrencies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCurrenciesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExchangerServiceServer).ListCurrencies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExchangerService_ListCurrencies_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExchangerServiceServer).ListCurrencies(ctx, req.(*ListCurrenciesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExchangerService_WatchRates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRatesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ExchangerServiceServer).WatchRates(m, &exchangerServiceWatchRatesServer{stream})
+}
+
+type ExchangerService_WatchRatesServer interface {
+	Send(*RateUpdate) error
+	grpc.ServerStream
+}
+
+type exchangerServiceWatchRatesServer struct {
+	grpc.ServerStream
+}
+
+func (x *exchangerServiceWatchRatesServer) Send(m *RateUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// ExchangerService_ServiceDesc is the grpc.ServiceDesc for ExchangerService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ExchangerService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "exchanger.v1.ExchangerService",
+	HandlerType: (*ExchangerServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetRate",
+			Handler:    _ExchangerService_GetRate_Handler,
+		},
+		{
+			MethodName: "Convert",
+			Handler:    _ExchangerService_Convert_Handler,
+		},
+		{
+			MethodName: "ListCurrencies",
+			Handler:    _ExchangerService_ListCurrencies_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchRates",
+			Handler:       _ExchangerService_WatchRates_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/exchanger/v1/exchanger.proto",
+}
@@ -0,0 +1,97 @@
+// Package cache provides the key/value stores behind the rate cache, the
+// idempotency store and the client rate limit.
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache is a pluggable key/value store with per-entry expiry.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+// Adder is implemented by caches that can store a value only when its key
+// is absent, in one step, so concurrent requests can claim a key.
+type Adder interface {
+	// Add sets key like Set unless it holds an unexpired value, and reports
+	// whether it did.
+	Add(key string, value []byte, ttl time.Duration) bool
+}
+
+// Pinger is implemented by caches that live on a server, so readiness checks
+// can tell whether it is reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// Memory is an in-process Cache. Expired entries are dropped on read and
+// swept periodically on write.
+type Memory struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+// memorySweepInterval is how often Set scans for expired entries.
+const memorySweepInterval = time.Minute
+
+// NewMemory returns an empty in-process cache.
+func NewMemory() *Memory {
+	return &Memory{
+		entries:   make(map[string]memoryEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+func (m *Memory) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(m.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastSweep) > memorySweepInterval {
+		for k, entry := range m.entries {
+			if now.After(entry.expiresAt) {
+				delete(m.entries, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	m.entries[key] = memoryEntry{value: value, expiresAt: now.Add(ttl)}
+}
+
+func (m *Memory) Add(key string, value []byte, ttl time.Duration) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := m.entries[key]; ok && !now.After(entry.expiresAt) {
+		return false
+	}
+	m.entries[key] = memoryEntry{value: value, expiresAt: now.Add(ttl)}
+	return true
+}
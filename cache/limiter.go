@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Bucket describes a token bucket: Burst tokens at most, refilled at Rate
+// tokens per second.
+type Bucket struct {
+	Rate  float64
+	Burst int
+}
+
+// Limiter takes tokens from per-client buckets. Take reports whether the
+// bucket under key had a token left, how many remain, and otherwise how long
+// until the next one.
+type Limiter interface {
+	Take(key string, bucket Bucket) (ok bool, remaining int, retryAfter time.Duration)
+}
+
+type bucketState struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryLimiter keeps token buckets in process, so each instance limits on
+// its own. Buckets that have refilled completely are swept periodically.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucketState
+	lastSweep time.Time
+}
+
+// NewMemoryLimiter returns a limiter without any buckets.
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: map[string]*bucketState{}, lastSweep: time.Now()}
+}
+
+func (m *MemoryLimiter) Take(key string, bucket Bucket) (bool, int, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	full := time.Duration(float64(bucket.Burst) / bucket.Rate * float64(time.Second))
+	if now.Sub(m.lastSweep) > memorySweepInterval {
+		for k, state := range m.buckets {
+			if now.Sub(state.updated) > full {
+				delete(m.buckets, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	state, ok := m.buckets[key]
+	if !ok {
+		state = &bucketState{tokens: float64(bucket.Burst), updated: now}
+		m.buckets[key] = state
+	}
+	state.tokens = math.Min(float64(bucket.Burst), state.tokens+now.Sub(state.updated).Seconds()*bucket.Rate)
+	state.updated = now
+
+	if state.tokens < 1 {
+		return false, 0, time.Duration((1 - state.tokens) / bucket.Rate * float64(time.Second))
+	}
+	state.tokens--
+	return true, int(state.tokens), 0
+}
+
+// takeScript refills and takes from the bucket in one round trip, so
+// instances sharing the Redis server share the limit.
+var takeScript = redis.NewScript(`
+local rate, burst, now = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1]) or burst
+local updated = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - updated) / 1000 * rate)
+
+local ok, wait = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	ok = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {ok, math.floor(tokens), wait}
+`)
+
+// Take implements Limiter on the shared Redis server. When Redis fails the
+// request is let through, like a cache miss.
+func (r *Redis) Take(key string, bucket Bucket) (bool, int, time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	result, err := takeScript.Run(ctx, r.client, []string{r.prefix + key},
+		bucket.Rate, bucket.Burst, time.Now().UnixMilli()).Int64Slice()
+	if err != nil || len(result) != 3 {
+		log.Printf("redis rate limit %s failed: %v", key, err)
+		return true, bucket.Burst, 0
+	}
+	return result[0] == 1, int(result[1]), time.Duration(result[2]) * time.Millisecond
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds every Redis round trip so a slow cache never blocks a
+// conversion for long.
+const redisTimeout = 2 * time.Second
+
+// Redis is a Cache shared by every instance pointing at the same Redis.
+// Errors are logged and treated as cache misses.
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedis connects to the Redis server at url, e.g.
+// redis://:password@localhost:6379/0.
+func NewRedis(url string) (*Redis, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(options)
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, err
+	}
+
+	return &Redis{client: client, prefix: "exchanger:"}, nil
+}
+
+// Ping checks that the Redis server answers.
+func (r *Redis) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *Redis) Get(key string) ([]byte, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	value, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("redis get %s failed: %v", key, err)
+		}
+		return nil, false
+	}
+	return value, true
+}
+
+// Add claims key with SET NX. When Redis fails it reports true, like a miss,
+// so requests go ahead rather than fail.
+func (r *Redis) Add(key string, value []byte, ttl time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	added, err := r.client.SetNX(ctx, r.prefix+key, value, ttl).Result()
+	if err != nil {
+		log.Printf("redis add %s failed: %v", key, err)
+		return true
+	}
+	return added
+}
+
+func (r *Redis) Set(key string, value []byte, ttl time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := r.client.Set(ctx, r.prefix+key, value, ttl).Err(); err != nil {
+		log.Printf("redis set %s failed: %v", key, err)
+	}
+}
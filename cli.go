@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/config"
+	"cubetiq-samples/exchanger-go/server"
+
+	"github.com/shopspring/decimal"
+)
+
+// usage lists the commands of the binary.
+const usage = `Usage: exchanger [command] [flags]
+
+Commands:
+  serve     Run the HTTP server (the default); see -h for its flags
+  convert   Convert an amount, e.g. exchanger convert 100 USD KHR --source=fixerio
+  rates     Print a rate table, e.g. exchanger rates --base=USD
+  help      Print this help
+
+convert and rates call the providers directly, with the same EXCHANGER_
+settings as the server, so no server needs to run.
+`
+
+// cliFlags are the flags convert and rates share.
+type cliFlags struct {
+	*flag.FlagSet
+	source  *string
+	key     *string
+	timeout *time.Duration
+	config  *string
+}
+
+func newCLIFlags(name, usageLine string) *cliFlags {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: exchanger %s\n\n", usageLine)
+		flags.PrintDefaults()
+	}
+	return &cliFlags{
+		FlagSet: flags,
+		source:  flags.String("source", "", "comma-separated providers tried in order (default EXCHANGER_SOURCES, static offline, or "+server.DefaultSource+")"),
+		key:     flags.String("key", "", "provider API key (default EXCHANGER_KEY_<PROVIDER> or the credentials file)"),
+		timeout: flags.Duration("timeout", 30*time.Second, "give up after this long"),
+		config:  flags.String("config", os.Getenv("EXCHANGER_CONFIG"), "YAML or TOML configuration file"),
+	}
+}
+
+// parse parses args, which may mix flags and positional arguments as in
+// "convert 100 USD KHR --source=fixerio", and returns the positional ones.
+func (f *cliFlags) parse(args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := f.Parse(args); err != nil {
+			return nil, err
+		}
+		args = f.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if *f.config != "" {
+		if err := config.LoadFile(*f.config); err != nil {
+			return nil, err
+		}
+	}
+	if err := config.ResolveSecrets(); err != nil {
+		return nil, err
+	}
+	return positional, nil
+}
+
+// adapter builds the adapter chain of the source list like the server does
+// for a request, without its cache or circuit breakers.
+func (f *cliFlags) adapter() (adapters.ExchangeRateAdapter, error) {
+	client, err := adapters.NewHTTPClient()
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP client configuration: %w", err)
+	}
+	registry, _, err := newRegistry(client)
+	if err != nil {
+		return nil, err
+	}
+	var credentials config.Credentials
+	if path := os.Getenv("EXCHANGER_CREDENTIALS_FILE"); path != "" {
+		if credentials, err = config.LoadCredentials(path); err != nil {
+			return nil, fmt.Errorf("loading provider credentials: %w", err)
+		}
+	}
+
+	source := *f.source
+	if source == "" {
+		source = defaultSources()
+	}
+	if source == "" {
+		source = server.DefaultSource
+	}
+
+	var providers []adapters.NamedAdapter
+	for _, name := range strings.Split(source, ",") {
+		name = strings.TrimSpace(name)
+		key := *f.key
+		if key == "" {
+			key = credentials.Key(name)
+		}
+		if key == "" && !registry.Keyless(name) {
+			return nil, fmt.Errorf("%s needs an API key: pass --key or set EXCHANGER_KEY_%s", name, strings.ToUpper(name))
+		}
+		adapter, ok := registry.New(name, key)
+		if !ok {
+			return nil, fmt.Errorf("unknown source %q, expected one of %s", name, strings.Join(registry.Names(), ", "))
+		}
+		adapter = adapters.NewTimeout(adapter, adapters.UpstreamTimeout(name))
+		providers = append(providers, adapters.NamedAdapter{Name: name, Adapter: adapter})
+	}
+	if len(providers) == 1 {
+		return providers[0].Adapter, nil
+	}
+	return adapters.NewFallback(providers), nil
+}
+
+// context returns a context bounded by --timeout and canceled on interrupt.
+func (f *cliFlags) context() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancel := context.WithTimeout(ctx, *f.timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// convertCommand converts an amount given as AMOUNT FROM TO and prints the
+// result, rounded like the API rounds it.
+func convertCommand(args []string) error {
+	flags := newCLIFlags("convert", "convert AMOUNT FROM TO [flags]")
+	positional, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 3 {
+		flags.Usage()
+		return errors.New("convert takes an amount and two currencies")
+	}
+
+	amount, err := decimal.NewFromString(positional[0])
+	if err != nil {
+		return fmt.Errorf("invalid amount %q", positional[0])
+	}
+	from, err := server.NormalizeCurrency(positional[1])
+	if err != nil {
+		return err
+	}
+	to, err := server.NormalizeCurrency(positional[2])
+	if err != nil {
+		return err
+	}
+
+	adapter, err := flags.adapter()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := flags.context()
+	defer cancel()
+
+	result, err := adapter.GetRate(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %s = %s %s\n", amount, from, server.ConvertAmount(amount, result.Rate, to), to)
+	fmt.Printf("rate %v from %s at %s\n", result.Rate, result.Provider, result.Timestamp.Format(time.RFC3339))
+	return nil
+}
+
+// ratesCommand prints the rate table of --base, or only the --symbols rates.
+func ratesCommand(args []string) error {
+	flags := newCLIFlags("rates", "rates --base=USD [flags]")
+	base := flags.String("base", "USD", "currency the rates are quoted against")
+	symbols := flags.String("symbols", "", "comma-separated currencies to list (default all the provider quotes)")
+	positional, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		flags.Usage()
+		return fmt.Errorf("unexpected argument %q", positional[0])
+	}
+
+	from, err := server.NormalizeCurrency(*base)
+	if err != nil {
+		return err
+	}
+	var targets []string
+	for _, symbol := range strings.Split(*symbols, ",") {
+		if strings.TrimSpace(symbol) == "" {
+			continue
+		}
+		target, err := server.NormalizeCurrency(symbol)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, target)
+	}
+
+	adapter, err := flags.adapter()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := flags.context()
+	defer cancel()
+
+	results, err := adapters.FetchRates(ctx, adapter, from, targets)
+	if err != nil {
+		return err
+	}
+
+	codes := make([]string, 0, len(results))
+	var provider string
+	var timestamp time.Time
+	for code, result := range results {
+		codes = append(codes, code)
+		provider = result.Provider
+		if result.Timestamp.After(timestamp) {
+			timestamp = result.Timestamp
+		}
+	}
+	sort.Strings(codes)
+
+	fmt.Printf("Rates against %s from %s at %s\n\n", from, provider, timestamp.Format(time.RFC3339))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CURRENCY\tRATE")
+	for _, code := range codes {
+		fmt.Fprintf(w, "%s\t%v\n", code, results[code].Rate)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,286 @@
+// Package exchanger is a Go client for the exchanger service's REST API.
+//
+//	import exchanger "cubetiq-samples/exchanger-go/client"
+//
+//	c := exchanger.NewClient("https://rates.example.com", apiKey)
+//	conversion, err := c.Convert(ctx, decimal.NewFromInt(100), "USD", "KHR")
+package exchanger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Client calls the exchanger API. Its fields may be changed before the first
+// call.
+type Client struct {
+	// BaseURL is the service's address, e.g. https://rates.example.com.
+	BaseURL string
+	// APIKey is sent as X-API-Key when set.
+	APIKey string
+	// Source selects the providers, as the source parameter; empty uses the
+	// service's default.
+	Source string
+	// ProviderKey is the providers' API key, as the key parameter; empty
+	// uses the service's.
+	ProviderKey string
+	// HTTPClient sends the requests; defaults to one with a 30s timeout.
+	HTTPClient *http.Client
+	// MaxAttempts is how often a request is tried in all when it fails with
+	// a retriable error (default 3).
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubling after each one
+	// (default 200ms). A Retry-After from the service takes precedence.
+	Backoff time.Duration
+}
+
+// NewClient returns a client of the service at baseURL authenticating with
+// apiKey, which may be empty for services without API keys.
+func NewClient(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		APIKey:      apiKey,
+		HTTPClient:  &http.Client{Timeout: 30 * time.Second},
+		MaxAttempts: 3,
+		Backoff:     200 * time.Millisecond,
+	}
+}
+
+// Conversion is the result of Convert.
+type Conversion struct {
+	Source    string          `json:"source"`
+	Provider  string          `json:"provider"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Amount    decimal.Decimal `json:"amount"`
+	Rate      float64         `json:"rate"`
+	Converted decimal.Decimal `json:"converted"`
+	// MidRate, Fee and Total are set when a markup applies to the API key;
+	// Rate is then the customer rate.
+	MidRate   float64          `json:"midRate,omitempty"`
+	Fee       *decimal.Decimal `json:"fee,omitempty"`
+	Total     *decimal.Decimal `json:"total,omitempty"`
+	Timestamp time.Time        `json:"timestamp"`
+	Cached    bool             `json:"cached"`
+}
+
+// Rate is the result of GetRate and Historical.
+type Rate struct {
+	Source    string    `json:"source"`
+	Provider  string    `json:"provider"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Rate      float64   `json:"rate"`
+	Date      string    `json:"date,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Cached    bool      `json:"cached"`
+}
+
+// BatchItem is one conversion of a Batch call.
+type BatchItem struct {
+	Amount decimal.Decimal `json:"amount"`
+	From   string          `json:"from"`
+	To     string          `json:"to"`
+}
+
+// BatchResult is the outcome of the BatchItem at the same index: a
+// conversion, or the Error it failed with.
+type BatchResult struct {
+	Amount    decimal.Decimal `json:"amount"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Rate      float64         `json:"rate"`
+	Converted decimal.Decimal `json:"converted"`
+	Provider  string          `json:"provider"`
+	Error     *Error          `json:"-"`
+}
+
+// Error is an error the service answered with. Code is one of the stable
+// codes of the service's error envelope, e.g. INVALID_CURRENCY or
+// PROVIDER_UNAVAILABLE.
+type Error struct {
+	Status    int    `json:"-"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Field     string `json:"field"`
+	Provider  string `json:"provider"`
+	Retriable bool   `json:"retriable"`
+}
+
+func (e *Error) Error() string {
+	if e.Status == 0 {
+		return fmt.Sprintf("exchanger: %s: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("exchanger: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// Convert converts amount from one currency into another.
+func (c *Client) Convert(ctx context.Context, amount decimal.Decimal, from, to string) (*Conversion, error) {
+	query := url.Values{"amount": {amount.String()}, "from": {from}, "to": {to}}
+	var conversion Conversion
+	if err := c.do(ctx, http.MethodGet, "/exchange", query, nil, &conversion); err != nil {
+		return nil, err
+	}
+	return &conversion, nil
+}
+
+// GetRate returns the latest rate from one currency to another.
+func (c *Client) GetRate(ctx context.Context, from, to string) (*Rate, error) {
+	query := url.Values{"amount": {"1"}, "from": {from}, "to": {to}}
+	var rate Rate
+	if err := c.do(ctx, http.MethodGet, "/exchange", query, nil, &rate); err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// Historical returns the rate from one currency to another on a past day.
+func (c *Client) Historical(ctx context.Context, from, to string, date time.Time) (*Rate, error) {
+	query := url.Values{"from": {from}, "to": {to}, "date": {date.Format("2006-01-02")}}
+	var rate Rate
+	if err := c.do(ctx, http.MethodGet, "/exchange/historical", query, nil, &rate); err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// Batch converts every item in one request. The items fail on their own:
+// the results carry the Error of each failed item, in input order.
+func (c *Client) Batch(ctx context.Context, items []BatchItem) ([]BatchResult, error) {
+	var response struct {
+		Results []json.RawMessage `json:"results"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/exchange/batch", url.Values{}, items, &response); err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, len(response.Results))
+	for i, raw := range response.Results {
+		if err := json.Unmarshal(raw, &results[i]); err != nil {
+			return nil, err
+		}
+		var failure Error
+		if err := json.Unmarshal(raw, &failure); err == nil && failure.Code != "" {
+			results[i].Error = &failure
+		}
+	}
+	return results, nil
+}
+
+// do sends a request to path under /api/v1 and decodes the JSON response into
+// out, retrying retriable failures.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	if c.Source != "" {
+		query.Set("source", c.Source)
+	}
+	if c.ProviderKey != "" {
+		query.Set("key", c.ProviderKey)
+	}
+	endpoint := c.BaseURL + "/api/v1" + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	attempts := c.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := c.Backoff
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := c.send(ctx, method, endpoint, payload, out)
+		if err == nil || attempt >= attempts || !retriable(err) {
+			return err
+		}
+
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		// Spread retries by ±20% so clients don't retry in lockstep
+		wait += time.Duration((rand.Float64()*0.4 - 0.2) * float64(wait))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// send makes one attempt; it returns the Retry-After of a failed response.
+func (c *Client) send(ctx context.Context, method, endpoint string, payload []byte, out interface{}) (time.Duration, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode >= 400 {
+		failure := &Error{Status: resp.StatusCode}
+		if err := json.Unmarshal(data, failure); err != nil || failure.Code == "" {
+			failure.Code = "HTTP_ERROR"
+			failure.Message = http.StatusText(resp.StatusCode)
+			failure.Retriable = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		}
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return time.Duration(seconds) * time.Second, failure
+	}
+	return 0, json.Unmarshal(data, out)
+}
+
+// retriable reports whether err may go away on another attempt: the service
+// says so, or it couldn't be reached.
+func retriable(err error) bool {
+	var failure *Error
+	if errors.As(err, &failure) {
+		return failure.Retriable
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"cubetiq-samples/exchanger-go/secrets"
+)
+
+// Credentials holds server-side API keys keyed by provider name, as loaded
+// from EXCHANGER_CREDENTIALS_FILE.
+type Credentials map[string]string
+
+// LoadCredentials reads a JSON object mapping provider names to API keys,
+// e.g. {"openexchangerates": "...", "fixerio": "..."}. Keys may reference
+// secrets, e.g. "awssm://exchanger#fixerio", which are fetched.
+func LoadCredentials(path string) (Credentials, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := Credentials{}
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return nil, err
+	}
+	resolver := secrets.NewResolver()
+	for provider, key := range credentials {
+		if credentials[provider], err = resolver.Resolve(context.Background(), key); err != nil {
+			return nil, fmt.Errorf("%s: %w", provider, err)
+		}
+	}
+	return credentials, nil
+}
+
+// Key returns the server-side API key for provider. The
+// EXCHANGER_KEY_<PROVIDER> environment variable takes precedence over the
+// credentials file.
+func (c Credentials) Key(provider string) string {
+	if key := os.Getenv("EXCHANGER_KEY_" + strings.ToUpper(provider)); key != "" {
+		return key
+	}
+	return c[provider]
+}
@@ -0,0 +1,35 @@
+// Package config reads the service configuration from the environment and
+// configuration files.
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Bool reports whether the named environment variable is set to a true value.
+func Bool(name string) bool {
+	value, err := strconv.ParseBool(os.Getenv(name))
+	return err == nil && value
+}
+
+// Int returns the named environment variable as an int, or def when it is
+// unset or invalid.
+func Int(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// Duration returns the named environment variable parsed as a
+// time.Duration (e.g. "90s", "1h"), or def when it is unset or invalid.
+func Duration(name string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return value
+}
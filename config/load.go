@@ -0,0 +1,377 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cubetiq-samples/exchanger-go/secrets"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// flagSettings maps the command-line flags to the settings they set.
+var flagSettings = []struct {
+	flag, setting, usage string
+}{
+	{"port", "EXCHANGER_PORT", "HTTP port (default 8080, or $PORT)"},
+	{"bind", "EXCHANGER_BIND", "address to bind, e.g. 127.0.0.1 (default all interfaces)"},
+	{"tls-cert", "EXCHANGER_TLS_CERT", "TLS certificate file; serves HTTPS together with -tls-key"},
+	{"tls-key", "EXCHANGER_TLS_KEY", "TLS private key file"},
+	{"sources", "EXCHANGER_SOURCES", "default providers in priority order, e.g. ratesservice,nbc"},
+	{"static-rates", "EXCHANGER_STATIC_RATES_FILE", "JSON or YAML rates file served as the static source"},
+	{"offline", "EXCHANGER_OFFLINE", "true to answer only from -static-rates and never call a provider"},
+	{"log-level", "EXCHANGER_LOG_LEVEL", "minimum log level"},
+}
+
+// loaded tracks the configuration file so Reload can re-read it: its path,
+// and the settings whose value this process took from it.
+var loaded struct {
+	mu       sync.Mutex
+	path     string
+	settings map[string]bool
+	secrets  map[string]secretSetting
+}
+
+// setFlag collects repeated -set NAME=VALUE flags.
+type setFlag []string
+
+func (s *setFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *setFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// Load reads the configuration from a YAML or TOML file, the environment and
+// the command-line args, in increasing order of precedence. Every source ends
+// up in the EXCHANGER_ environment variables the service reads, which are
+// then validated. The file is named by -config or EXCHANGER_CONFIG. Settings
+// whose value references a secret, such as vault://secret/data/exchanger#fixerio,
+// are set to the secret.
+func Load(args []string) error {
+	flags := flag.NewFlagSet("exchanger", flag.ContinueOnError)
+	path := flags.String("config", os.Getenv("EXCHANGER_CONFIG"), "YAML or TOML configuration file")
+	values := map[string]*string{}
+	for _, f := range flagSettings {
+		values[f.setting] = flags.String(f.flag, "", f.usage)
+	}
+	var sets setFlag
+	flags.Var(&sets, "set", "any setting as NAME=VALUE, e.g. -set cache_ttl=10m; repeatable")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *path != "" {
+		if err := LoadFile(*path); err != nil {
+			return err
+		}
+	}
+
+	overrides := map[string]string{}
+	flags.Visit(func(f *flag.Flag) {
+		for _, setting := range flagSettings {
+			if setting.flag == f.Name {
+				overrides[setting.setting] = *values[setting.setting]
+			}
+		}
+	})
+	for _, set := range sets {
+		name, value, ok := strings.Cut(set, "=")
+		if !ok {
+			return fmt.Errorf("-set %s: expected NAME=VALUE", set)
+		}
+		overrides[settingName(name)] = value
+	}
+	for name, value := range overrides {
+		if _, ok := lookupSetting(name); !ok {
+			return fmt.Errorf("unknown setting %s", name)
+		}
+		os.Setenv(name, value)
+		// A flag outranks the file, on reload too
+		loaded.mu.Lock()
+		delete(loaded.settings, name)
+		loaded.mu.Unlock()
+	}
+
+	if err := ResolveSecrets(); err != nil {
+		return err
+	}
+	return Validate()
+}
+
+// LoadFile sets the environment variables named by the settings in the YAML
+// (.yaml, .yml) or TOML (.toml) file at path, unless they are already set.
+// Nested keys are joined with underscores, so
+//
+//	cache:
+//	  ttl: 10m
+//
+// sets EXCHANGER_CACHE_TTL, and lists are joined with commas.
+func LoadFile(path string) error {
+	file, err := readFile(path)
+	if err != nil {
+		return err
+	}
+
+	loaded.mu.Lock()
+	defer loaded.mu.Unlock()
+	loaded.path, loaded.settings = path, map[string]bool{}
+	for name, value := range file {
+		if _, set := os.LookupEnv(name); !set {
+			os.Setenv(name, value)
+			loaded.settings[name] = true
+		}
+	}
+	return nil
+}
+
+// File returns the path of the configuration file, or "" without one.
+func File() string {
+	loaded.mu.Lock()
+	defer loaded.mu.Unlock()
+	return loaded.path
+}
+
+// Reload re-reads the configuration file. The settings taken from it change
+// to their new values, or are unset when the file no longer has them, while
+// those set in the environment or by flags keep precedence. Every secret
+// reference is fetched again, so rotated secrets take effect. A file that
+// fails to parse, holds an invalid value or references a secret that can't
+// be fetched changes nothing.
+func Reload() error {
+	loaded.mu.Lock()
+	defer loaded.mu.Unlock()
+
+	changes := map[string]*string{}
+	if loaded.path != "" {
+		file, err := readFile(loaded.path)
+		if err != nil {
+			return err
+		}
+		var problems []string
+		for name, value := range file {
+			k, _ := lookupSetting(name)
+			if value == "" || secrets.IsReference(value) {
+				continue
+			}
+			if err := checkKind(k, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+		if len(problems) > 0 {
+			sort.Strings(problems)
+			return fmt.Errorf("%s: %s", loaded.path, strings.Join(problems, "; "))
+		}
+
+		for name := range loaded.settings {
+			if _, ok := file[name]; !ok {
+				changes[name] = nil
+			}
+		}
+		for name, value := range file {
+			value := value
+			if _, set := os.LookupEnv(name); !set || loaded.settings[name] {
+				changes[name] = &value
+			}
+		}
+	}
+
+	if err := applySettings(changes); err != nil {
+		return err
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(loaded.settings, name)
+		} else {
+			loaded.settings[name] = true
+		}
+	}
+	return nil
+}
+
+// ResolveSecrets sets the settings that reference a secret to the secret, as
+// Load does.
+func ResolveSecrets() error {
+	loaded.mu.Lock()
+	defer loaded.mu.Unlock()
+	return applySettings(nil)
+}
+
+// secretSetting is a setting that references a secret, which the
+// environment holds resolved.
+type secretSetting struct {
+	reference, value string
+}
+
+// applySettings sets the environment variables of changes, unsetting those
+// without a value, and resolves the settings that reference secrets: those
+// whose new value is a reference, and those resolved before that still hold
+// their secret. When a secret can't be fetched nothing changes, and every
+// problem is reported at once. loaded.mu must be held.
+func applySettings(changes map[string]*string) error {
+	values := map[string]string{}
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if _, ok := lookupSetting(name); ok {
+			values[name] = value
+		}
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(values, name)
+		} else {
+			values[name] = *value
+		}
+	}
+
+	references := map[string]string{}
+	for name, value := range values {
+		_, changed := changes[name]
+		if secrets.IsReference(value) {
+			references[name] = value
+		} else if secret, ok := loaded.secrets[name]; ok && !changed && value == secret.value {
+			references[name] = secret.reference
+		}
+	}
+	resolver := secrets.NewResolver()
+	resolved := map[string]secretSetting{}
+	var problems []string
+	for name, reference := range references {
+		value, err := resolver.Resolve(context.Background(), reference)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		resolved[name] = secretSetting{reference: reference, value: value}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	for name, value := range changes {
+		if value == nil {
+			os.Unsetenv(name)
+		} else {
+			os.Setenv(name, *value)
+		}
+	}
+	for name, secret := range resolved {
+		os.Setenv(name, secret.value)
+	}
+	loaded.secrets = resolved
+	return nil
+}
+
+// readFile returns the settings of the YAML or TOML file at path by name.
+func readFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("%s: unsupported configuration format %q, expected .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	file := map[string]string{}
+	if err := flatten(file, "", document); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var unknown []string
+	for name := range file {
+		if _, ok := lookupSetting(name); !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("%s: unknown settings %s", path, strings.Join(unknown, ", "))
+	}
+	return file, nil
+}
+
+// flatten stores the scalar values of document in settings under their
+// setting names.
+func flatten(settings map[string]string, prefix string, document map[string]interface{}) error {
+	for key, value := range document {
+		name := key
+		if prefix != "" {
+			name = prefix + "_" + key
+		}
+
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if err := flatten(settings, name, value); err != nil {
+				return err
+			}
+			continue
+		case []interface{}:
+			items := make([]string, len(value))
+			for i, item := range value {
+				s, err := scalar(item)
+				if err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				items[i] = s
+			}
+			settings[settingName(name)] = strings.Join(items, ",")
+		default:
+			s, err := scalar(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			settings[settingName(name)] = s
+		}
+	}
+	return nil
+}
+
+func scalar(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case int:
+		return strconv.Itoa(value), nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// settingName turns a file key or -set name such as cache_ttl or
+// tls-cert into its variable name, EXCHANGER_CACHE_TTL.
+func settingName(key string) string {
+	name := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+	if !strings.HasPrefix(name, "EXCHANGER_") {
+		name = "EXCHANGER_" + name
+	}
+	return name
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kind is the type a setting's value must parse as.
+type kind int
+
+const (
+	kindString kind = iota
+	kindBool
+	kindInt
+	kindFloat
+	kindDuration
+)
+
+// settings lists every EXCHANGER_ variable the service reads, so settings from
+// a config file or flag can be checked for typos and every value for its
+// type before the server starts.
+var settings = map[string]kind{
+	"EXCHANGER_CONFIG":                       kindString,
+	"EXCHANGER_RELOAD_INTERVAL":              kindDuration,
+	"EXCHANGER_PORT":                         kindInt,
+	"EXCHANGER_BIND":                         kindString,
+	"EXCHANGER_TLS_CERT":                     kindString,
+	"EXCHANGER_TLS_KEY":                      kindString,
+	"EXCHANGER_READ_TIMEOUT":                 kindDuration,
+	"EXCHANGER_WRITE_TIMEOUT":                kindDuration,
+	"EXCHANGER_IDLE_TIMEOUT":                 kindDuration,
+	"EXCHANGER_SOURCES":                      kindString,
+	"EXCHANGER_RATESSERVICE_URL":             kindString,
+	"EXCHANGER_CREDENTIALS_FILE":             kindString,
+	"EXCHANGER_ADAPTERS_FILE":                kindString,
+	"EXCHANGER_EXTERNAL_ADAPTERS":            kindString,
+	"EXCHANGER_STATIC_RATES_FILE":            kindString,
+	"EXCHANGER_STATIC_RELOAD_INTERVAL":       kindDuration,
+	"EXCHANGER_OFFLINE":                      kindBool,
+	"EXCHANGER_MOCK":                         kindBool,
+	"EXCHANGER_MOCK_SEED":                    kindInt,
+	"EXCHANGER_MOCK_LATENCY":                 kindDuration,
+	"EXCHANGER_MOCK_ERROR":                   kindString,
+	"EXCHANGER_MOCK_ERROR_RATE":              kindFloat,
+	"EXCHANGER_UPSTREAM_TIMEOUT":             kindDuration,
+	"EXCHANGER_RETRY_MAX_ATTEMPTS":           kindInt,
+	"EXCHANGER_RETRY_BACKOFF":                kindDuration,
+	"EXCHANGER_RETRY_MAX_BACKOFF":            kindDuration,
+	"EXCHANGER_RETRY_JITTER":                 kindFloat,
+	"EXCHANGER_RETRY_STATUS":                 kindString,
+	"EXCHANGER_BREAKER_FAILURES":             kindInt,
+	"EXCHANGER_BREAKER_COOLDOWN":             kindDuration,
+	"EXCHANGER_DEBUG":                        kindBool,
+	"EXCHANGER_HTTP_PROXY":                   kindString,
+	"EXCHANGER_HTTP_CA_FILE":                 kindString,
+	"EXCHANGER_HTTP_TLS_MIN_VERSION":         kindString,
+	"EXCHANGER_HTTP_MAX_IDLE_CONNS":          kindInt,
+	"EXCHANGER_HTTP_MAX_IDLE_CONNS_PER_HOST": kindInt,
+	"EXCHANGER_HTTP_MAX_CONNS_PER_HOST":      kindInt,
+	"EXCHANGER_HTTP_IDLE_CONN_TIMEOUT":       kindDuration,
+	"EXCHANGER_HTTP_DIAL_TIMEOUT":            kindDuration,
+	"EXCHANGER_HTTP_TLS_HANDSHAKE_TIMEOUT":   kindDuration,
+	"EXCHANGER_HTTP_DISABLE_KEEPALIVES":      kindBool,
+	"EXCHANGER_VALIDATE_SCHEMA":              kindBool,
+	"EXCHANGER_CACHE_TTL":                    kindDuration,
+	"EXCHANGER_CACHE_MAX_STALE":              kindDuration,
+	"EXCHANGER_REDIS_URL":                    kindString,
+	"EXCHANGER_IDEMPOTENCY_TTL":              kindDuration,
+	"EXCHANGER_MARKUP_FILE":                  kindString,
+	"EXCHANGER_MARKUP_PERCENT":               kindFloat,
+	"EXCHANGER_MARKUP_FEE":                   kindFloat,
+	"EXCHANGER_ROUNDING":                     kindString,
+	"EXCHANGER_MAX_AMOUNT":                   kindFloat,
+	"EXCHANGER_PRECISION":                    kindInt,
+	"EXCHANGER_EXTRA_CURRENCIES":             kindString,
+	"EXCHANGER_CROSS_CURRENCIES":             kindString,
+	"EXCHANGER_CROSS_MAX_HOPS":               kindInt,
+	"EXCHANGER_CONSENSUS_SOURCES":            kindString,
+	"EXCHANGER_CONSENSUS_METHOD":             kindString,
+	"EXCHANGER_CONSENSUS_WEIGHTS":            kindString,
+	"EXCHANGER_CONSENSUS_TOLERANCE":          kindFloat,
+	"EXCHANGER_CONSENSUS_MIN_PROVIDERS":      kindInt,
+	"EXCHANGER_BATCH_MAX_ITEMS":              kindInt,
+	"EXCHANGER_BATCH_WORKERS":                kindInt,
+	"EXCHANGER_HOLIDAYS":                     kindString,
+	"EXCHANGER_ROLLBACK_DAYS":                kindInt,
+	"EXCHANGER_MAINTENANCE":                  kindBool,
+	"EXCHANGER_MAINTENANCE_RETRY_AFTER":      kindInt,
+	"EXCHANGER_GRPC_ADDR":                    kindString,
+	"EXCHANGER_STREAM_INTERVAL":              kindDuration,
+	"EXCHANGER_STREAM_MAX_PAIRS":             kindInt,
+	"EXCHANGER_WS_MAX_PAIRS":                 kindInt,
+	"EXCHANGER_REFRESH_PAIRS":                kindString,
+	"EXCHANGER_REFRESH_INTERVAL":             kindDuration,
+	"EXCHANGER_ALERT_ATTEMPTS":               kindInt,
+	"EXCHANGER_ALERT_BACKOFF":                kindDuration,
+	"EXCHANGER_ALERT_ALLOWED_NETWORKS":       kindString,
+	"EXCHANGER_SLACK_WEBHOOK_URL":            kindString,
+	"EXCHANGER_TELEGRAM_BOT_TOKEN":           kindString,
+	"EXCHANGER_TELEGRAM_CHAT_ID":             kindString,
+	"EXCHANGER_TELEGRAM_API_URL":             kindString,
+	"EXCHANGER_OUTAGE_NOTIFY":                kindString,
+	"EXCHANGER_ADMIN_TOKEN":                  kindString,
+	"EXCHANGER_PPROF":                        kindBool,
+	"EXCHANGER_RATE_LIMIT":                   kindInt,
+	"EXCHANGER_RATE_LIMIT_BURST":             kindInt,
+	"EXCHANGER_RATE_LIMIT_BY":                kindString,
+	"EXCHANGER_TRUSTED_PROXIES":              kindString,
+	"EXCHANGER_CORS_ORIGINS":                 kindString,
+	"EXCHANGER_CORS_METHODS":                 kindString,
+	"EXCHANGER_CORS_HEADERS":                 kindString,
+	"EXCHANGER_CORS_MAX_AGE":                 kindDuration,
+	"EXCHANGER_CORS_CREDENTIALS":             kindBool,
+	"EXCHANGER_HISTORY_DSN":                  kindString,
+	"EXCHANGER_KEYS_DSN":                     kindString,
+	"EXCHANGER_EVENTS_URL":                   kindString,
+	"EXCHANGER_EVENTS_TOPIC":                 kindString,
+	"EXCHANGER_EVENTS_FORMAT":                kindString,
+	"EXCHANGER_QUOTA_DAILY":                  kindInt,
+	"EXCHANGER_QUOTA_MONTHLY":                kindInt,
+	"EXCHANGER_JWT_JWKS_URL":                 kindString,
+	"EXCHANGER_JWT_ISSUER":                   kindString,
+	"EXCHANGER_JWT_AUDIENCE":                 kindString,
+	"EXCHANGER_JWT_SCOPES_CLAIM":             kindString,
+	"EXCHANGER_JWT_TENANTS_FILE":             kindString,
+	"EXCHANGER_TRACING_EXPORTER":             kindString,
+	"EXCHANGER_READY_CHECK_PROVIDERS":        kindBool,
+	"EXCHANGER_PROBE_PAIR":                   kindString,
+	"EXCHANGER_PROBE_TTL":                    kindDuration,
+	"EXCHANGER_SHUTDOWN_DELAY":               kindDuration,
+	"EXCHANGER_SHUTDOWN_TIMEOUT":             kindDuration,
+	"EXCHANGER_LOG_LEVEL":                    kindString,
+	"EXCHANGER_LOG_FORMAT":                   kindString,
+}
+
+// providerSettings are the prefixes of per-provider settings, e.g.
+// EXCHANGER_KEY_FIXERIO.
+var providerSettings = map[string]kind{
+	"EXCHANGER_KEY_":              kindString,
+	"EXCHANGER_CACHE_TTL_":        kindDuration,
+	"EXCHANGER_CACHE_MAX_STALE_":  kindDuration,
+	"EXCHANGER_UPSTREAM_TIMEOUT_": kindDuration,
+	"EXCHANGER_REFRESH_INTERVAL_": kindDuration,
+	"EXCHANGER_PROBE_PAIR_":       kindString,
+	"EXCHANGER_BREAKER_FAILURES_": kindInt,
+	"EXCHANGER_BREAKER_COOLDOWN_": kindDuration,
+}
+
+// lookupSetting returns the kind of the named setting, if it is one.
+func lookupSetting(name string) (kind, bool) {
+	if k, ok := settings[name]; ok {
+		return k, true
+	}
+	for prefix, k := range providerSettings {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return k, true
+		}
+	}
+	return kindString, false
+}
+
+// Validate checks that every EXCHANGER_ variable that is set parses as its
+// type and that the listener settings are consistent, reporting every
+// problem at once.
+func Validate() error {
+	var problems []string
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		k, ok := lookupSetting(name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := checkKind(k, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	if port := os.Getenv("EXCHANGER_PORT"); port != "" {
+		if n, err := strconv.Atoi(port); err == nil && (n < 1 || n > 65535) {
+			problems = append(problems, "EXCHANGER_PORT: must be between 1 and 65535")
+		}
+	}
+	if workers := os.Getenv("EXCHANGER_BATCH_WORKERS"); workers != "" {
+		if n, err := strconv.Atoi(workers); err == nil && n < 1 {
+			problems = append(problems, "EXCHANGER_BATCH_WORKERS: must be at least 1")
+		}
+	}
+	for _, day := range strings.Split(os.Getenv("EXCHANGER_HOLIDAYS"), ",") {
+		if day = strings.TrimSpace(day); day != "" {
+			if _, err := time.Parse("2006-01-02", day); err != nil {
+				problems = append(problems, fmt.Sprintf("EXCHANGER_HOLIDAYS: %q is not a YYYY-MM-DD date", day))
+			}
+		}
+	}
+	if (os.Getenv("EXCHANGER_TLS_CERT") == "") != (os.Getenv("EXCHANGER_TLS_KEY") == "") {
+		problems = append(problems, "EXCHANGER_TLS_CERT and EXCHANGER_TLS_KEY must be set together")
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func checkKind(k kind, value string) error {
+	switch k {
+	case kindBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+	case kindInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+	case kindFloat:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+	case kindDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 5m", value)
+		}
+	}
+	return nil
+}
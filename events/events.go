@@ -0,0 +1,90 @@
+// Package events publishes refreshed rates to a message bus, Kafka or NATS,
+// so other services can follow them without polling the HTTP API.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// Rate is the event published for each refreshed rate.
+type Rate struct {
+	Provider  string    `json:"provider"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Rate      float64   `json:"rate"`
+	Timestamp time.Time `json:"timestamp"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// AvroSchema is the schema of Avro encoded events, whose timestamps are
+// milliseconds since the epoch.
+const AvroSchema = `{
+  "type": "record",
+  "name": "Rate",
+  "namespace": "exchanger",
+  "fields": [
+    {"name": "provider", "type": "string"},
+    {"name": "from", "type": "string"},
+    {"name": "to", "type": "string"},
+    {"name": "rate", "type": "double"},
+    {"name": "timestamp", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "fetchedAt", "type": {"type": "long", "logicalType": "timestamp-millis"}}
+  ]
+}`
+
+// Publisher sends rate events to a topic of a message bus.
+type Publisher interface {
+	Publish(ctx context.Context, rates []Rate) error
+	Close() error
+}
+
+// Open connects to the bus at url: kafka://broker1:9092,broker2:9092 for
+// Kafka, or nats://host:4222 for NATS. Events go to topic, as JSON or, with
+// format "avro", Avro binary of AvroSchema.
+func Open(url, topic, format string) (Publisher, error) {
+	encode, err := encoder(format)
+	if err != nil {
+		return nil, err
+	}
+	scheme, address, ok := strings.Cut(url, "://")
+	if !ok {
+		return nil, fmt.Errorf("event bus URL %q must start with kafka:// or nats://", url)
+	}
+	switch scheme {
+	case "kafka":
+		return newKafka(strings.Split(address, ","), topic, encode)
+	case "nats", "tls":
+		return newNATS(url, topic, encode)
+	}
+	return nil, fmt.Errorf("unknown event bus %q, expected kafka or nats", scheme)
+}
+
+// encoder returns the payload encoding of format.
+func encoder(format string) (func(Rate) ([]byte, error), error) {
+	switch format {
+	case "", "json":
+		return func(rate Rate) ([]byte, error) { return json.Marshal(rate) }, nil
+	case "avro":
+		codec, err := goavro.NewCodec(AvroSchema)
+		if err != nil {
+			return nil, err
+		}
+		return func(rate Rate) ([]byte, error) {
+			return codec.BinaryFromNative(nil, map[string]interface{}{
+				"provider":  rate.Provider,
+				"from":      rate.From,
+				"to":        rate.To,
+				"rate":      rate.Rate,
+				"timestamp": rate.Timestamp,
+				"fetchedAt": rate.FetchedAt,
+			})
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown event format %q, expected json or avro", format)
+}
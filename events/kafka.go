@@ -0,0 +1,40 @@
+package events
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka publishes events to a Kafka topic, keyed by pair so the events of a
+// pair stay in order on one partition.
+type Kafka struct {
+	writer *kafka.Writer
+	encode func(Rate) ([]byte, error)
+}
+
+func newKafka(brokers []string, topic string, encode func(Rate) ([]byte, error)) (*Kafka, error) {
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireOne,
+	}
+	return &Kafka{writer: writer, encode: encode}, nil
+}
+
+func (k *Kafka) Publish(ctx context.Context, rates []Rate) error {
+	messages := make([]kafka.Message, len(rates))
+	for i, rate := range rates {
+		value, err := k.encode(rate)
+		if err != nil {
+			return err
+		}
+		messages[i] = kafka.Message{Key: []byte(rate.From + "/" + rate.To), Value: value}
+	}
+	return k.writer.WriteMessages(ctx, messages...)
+}
+
+func (k *Kafka) Close() error {
+	return k.writer.Close()
+}
@@ -0,0 +1,41 @@
+package events
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATS publishes events under a subject per pair, the configured subject
+// followed by the currencies, e.g. exchanger.rates.USD.KHR, so subscribers
+// can pick pairs with wildcards.
+type NATS struct {
+	conn    *nats.Conn
+	subject string
+	encode  func(Rate) ([]byte, error)
+}
+
+func newNATS(url, subject string, encode func(Rate) ([]byte, error)) (*NATS, error) {
+	conn, err := nats.Connect(url, nats.Name("exchanger"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	return &NATS{conn: conn, subject: subject, encode: encode}, nil
+}
+
+func (n *NATS) Publish(ctx context.Context, rates []Rate) error {
+	for _, rate := range rates {
+		data, err := n.encode(rate)
+		if err != nil {
+			return err
+		}
+		if err := n.conn.Publish(n.subject+"."+rate.From+"."+rate.To, data); err != nil {
+			return err
+		}
+	}
+	return n.conn.FlushWithContext(ctx)
+}
+
+func (n *NATS) Close() error {
+	return n.conn.Drain()
+}
@@ -0,0 +1,132 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Conversion is one conversion made for a client, kept to prove which rate
+// was applied to it.
+type Conversion struct {
+	ID        int64     `json:"id"`
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestId"`
+	// KeyID is the service key the client authenticated with, if any.
+	KeyID    string          `json:"keyId"`
+	Route    string          `json:"route"`
+	Provider string          `json:"provider"`
+	From     string          `json:"from"`
+	To       string          `json:"to"`
+	Amount   decimal.Decimal `json:"amount"`
+	// Rate is the rate applied, the customer rate when a markup raised it
+	// from MidRate.
+	Rate      float64         `json:"rate"`
+	MidRate   float64         `json:"midRate"`
+	Fee       decimal.Decimal `json:"fee"`
+	Converted decimal.Decimal `json:"converted"`
+	// Date is the day of historical conversions.
+	Date          string    `json:"date"`
+	RateTimestamp time.Time `json:"rateTimestamp"`
+}
+
+// ConversionFilter selects conversions; empty fields match everything.
+// Before pages through them, keeping those with a lower ID.
+type ConversionFilter struct {
+	From   string
+	To     string
+	KeyID  string
+	Since  time.Time
+	Until  time.Time
+	Before int64
+	Limit  int
+}
+
+// RecordConversions adds conversions to the audit log.
+func (s *Store) RecordConversions(conversions []Conversion) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement, err := tx.Prepare(s.query(`INSERT INTO conversions (converted_at, request_id, key_id, route, provider, base, target,
+		amount, rate, mid_rate, fee, converted, day, rate_timestamp) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for _, conversion := range conversions {
+		_, err := statement.Exec(formatTime(conversion.Time), conversion.RequestID, conversion.KeyID, conversion.Route,
+			conversion.Provider, conversion.From, conversion.To, conversion.Amount.String(), conversion.Rate,
+			conversion.MidRate, conversion.Fee.String(), conversion.Converted.String(), conversion.Date,
+			formatTime(conversion.RateTimestamp))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Conversions returns the conversions matching filter, newest first.
+func (s *Store) Conversions(filter ConversionFilter) ([]Conversion, error) {
+	var conditions []string
+	var args []interface{}
+	for column, value := range map[string]string{"base": filter.From, "target": filter.To, "key_id": filter.KeyID} {
+		if value != "" {
+			conditions = append(conditions, column+" = ?")
+			args = append(args, value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "converted_at >= ?")
+		args = append(args, formatTime(filter.Since))
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "converted_at <= ?")
+		args = append(args, formatTime(filter.Until))
+	}
+	if filter.Before > 0 {
+		conditions = append(conditions, "id < ?")
+		args = append(args, filter.Before)
+	}
+
+	query := `SELECT id, converted_at, request_id, key_id, route, provider, base, target, amount, rate, mid_rate, fee,
+		converted, day, rate_timestamp FROM conversions`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := s.db.Query(s.query(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversions := []Conversion{}
+	for rows.Next() {
+		var conversion Conversion
+		var t, amount, fee, converted, rateTimestamp string
+		err := rows.Scan(&conversion.ID, &t, &conversion.RequestID, &conversion.KeyID, &conversion.Route,
+			&conversion.Provider, &conversion.From, &conversion.To, &amount, &conversion.Rate, &conversion.MidRate,
+			&fee, &converted, &conversion.Date, &rateTimestamp)
+		if err != nil {
+			return nil, err
+		}
+		conversion.Time = parseTime(t)
+		conversion.RateTimestamp = parseTime(rateTimestamp)
+		// Amounts are stored as decimal text, so they read back exactly
+		conversion.Amount, _ = decimal.NewFromString(amount)
+		conversion.Fee, _ = decimal.NewFromString(fee)
+		conversion.Converted, _ = decimal.NewFromString(converted)
+		conversions = append(conversions, conversion)
+	}
+	return conversions, rows.Err()
+}
@@ -0,0 +1,328 @@
+// Package history records every fetched rate in SQLite or Postgres, so past
+// rates can be served locally and audited later, along with the conversions
+// made at them.
+package history
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// timeLayout stores timestamps as fixed-width UTC text, which sorts and
+// compares the same in both databases.
+const timeLayout = "2006-01-02T15:04:05.000Z"
+
+// Store is an adapters.History backed by a SQL database.
+type Store struct {
+	db       *sql.DB
+	postgres bool
+}
+
+// Record is one fetched rate.
+type Record struct {
+	Provider  string    `json:"provider"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Rate      float64   `json:"rate"`
+	Day       string    `json:"day"`
+	Timestamp time.Time `json:"timestamp"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// Filter selects records; empty fields match everything.
+type Filter struct {
+	Provider string
+	From     string
+	To       string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+// Open connects to the database at dsn and creates the rates and
+// conversions tables if needed. A postgres:// or postgresql:// DSN selects Postgres; anything else
+// is a SQLite file path.
+func Open(dsn string) (*Store, error) {
+	driver, postgres := "sqlite", false
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		driver, postgres = "postgres", true
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if !postgres {
+		// SQLite allows one writer at a time
+		db.SetMaxOpenConns(1)
+	}
+
+	id := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	if postgres {
+		id = "BIGSERIAL PRIMARY KEY"
+	}
+	schema := []string{
+		`CREATE TABLE IF NOT EXISTS rates (
+			id ` + id + `,
+			provider TEXT NOT NULL,
+			base TEXT NOT NULL,
+			target TEXT NOT NULL,
+			rate DOUBLE PRECISION NOT NULL,
+			day TEXT NOT NULL,
+			timestamp TEXT NOT NULL,
+			fetched_at TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS rates_pair_day ON rates (provider, base, target, day)`,
+		`CREATE INDEX IF NOT EXISTS rates_fetched_at ON rates (fetched_at)`,
+		`CREATE TABLE IF NOT EXISTS conversions (
+			id ` + id + `,
+			converted_at TEXT NOT NULL,
+			request_id TEXT NOT NULL,
+			key_id TEXT NOT NULL,
+			route TEXT NOT NULL,
+			provider TEXT NOT NULL,
+			base TEXT NOT NULL,
+			target TEXT NOT NULL,
+			amount TEXT NOT NULL,
+			rate DOUBLE PRECISION NOT NULL,
+			mid_rate DOUBLE PRECISION NOT NULL,
+			fee TEXT NOT NULL,
+			converted TEXT NOT NULL,
+			day TEXT NOT NULL,
+			rate_timestamp TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS conversions_converted_at ON conversions (converted_at)`,
+		`CREATE INDEX IF NOT EXISTS conversions_key ON conversions (key_id, id)`,
+		`CREATE INDEX IF NOT EXISTS conversions_pair ON conversions (base, target, id)`,
+	}
+	for _, statement := range schema {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("creating history schema: %w", err)
+		}
+	}
+
+	return &Store{db: db, postgres: postgres}, nil
+}
+
+// Ping checks that the database answers.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Record(provider, day string, results []adapters.RateResult) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement, err := tx.Prepare(s.query(`INSERT INTO rates (provider, base, target, rate, day, timestamp, fetched_at) VALUES (?, ?, ?, ?, ?, ?, ?)`))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for _, result := range results {
+		fetchedAt := result.FetchedAt
+		if fetchedAt.IsZero() {
+			fetchedAt = time.Now()
+		}
+		_, err := statement.Exec(provider, result.Base, result.Target, result.Rate, day,
+			formatTime(result.Timestamp), formatTime(fetchedAt))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Store) RateOn(provider, from, to, day string) (adapters.RateResult, bool, error) {
+	row := s.db.QueryRow(s.query(`SELECT rate, timestamp, fetched_at FROM rates
+		WHERE provider = ? AND base = ? AND target = ? AND day = ?
+		ORDER BY id DESC LIMIT 1`), provider, from, to, day)
+
+	var rate float64
+	var timestamp, fetchedAt string
+	if err := row.Scan(&rate, &timestamp, &fetchedAt); err == sql.ErrNoRows {
+		return adapters.RateResult{}, false, nil
+	} else if err != nil {
+		return adapters.RateResult{}, false, err
+	}
+	return rateResult(provider, from, to, day, rate, timestamp, fetchedAt), true, nil
+}
+
+func (s *Store) Series(provider, from, to, start, end string) ([]adapters.RateResult, error) {
+	rows, err := s.db.Query(s.query(`SELECT day, rate, timestamp, fetched_at FROM rates
+		WHERE provider = ? AND base = ? AND target = ? AND day >= ? AND day <= ?
+		ORDER BY day, id`), provider, from, to, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Rows come oldest first within a day, so the last one of each day wins
+	var series []adapters.RateResult
+	for rows.Next() {
+		var day, timestamp, fetchedAt string
+		var rate float64
+		if err := rows.Scan(&day, &rate, &timestamp, &fetchedAt); err != nil {
+			return nil, err
+		}
+		result := rateResult(provider, from, to, day, rate, timestamp, fetchedAt)
+		if n := len(series); n > 0 && series[n-1].Date == day {
+			series[n-1] = result
+		} else {
+			series = append(series, result)
+		}
+	}
+	return series, rows.Err()
+}
+
+// Candle is the open, high, low and close of the rates recorded in an
+// interval starting at Start.
+type Candle struct {
+	Start time.Time `json:"start"`
+	Open  float64   `json:"open"`
+	High  float64   `json:"high"`
+	Low   float64   `json:"low"`
+	Close float64   `json:"close"`
+	// Count is how many rates were recorded in the interval.
+	Count int `json:"count"`
+}
+
+// Candles aggregates the rates of the pair recorded for provider with a
+// timestamp from start up to end into candles of interval, which start on
+// whole hours or at midnight UTC for hourly and daily candles. Intervals
+// without rates are left out.
+func (s *Store) Candles(provider, from, to string, start, end time.Time, interval time.Duration) ([]Candle, error) {
+	rows, err := s.db.Query(s.query(`SELECT rate, timestamp FROM rates
+		WHERE provider = ? AND base = ? AND target = ? AND timestamp >= ? AND timestamp < ?
+		ORDER BY timestamp, id`), provider, from, to, formatTime(start), formatTime(end))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candles := []Candle{}
+	for rows.Next() {
+		var rate float64
+		var timestamp string
+		if err := rows.Scan(&rate, &timestamp); err != nil {
+			return nil, err
+		}
+		bucket := parseTime(timestamp).Truncate(interval)
+		n := len(candles)
+		if n == 0 || !candles[n-1].Start.Equal(bucket) {
+			candles = append(candles, Candle{Start: bucket, Open: rate, High: rate, Low: rate, Close: rate, Count: 1})
+			continue
+		}
+		candle := &candles[n-1]
+		if rate > candle.High {
+			candle.High = rate
+		}
+		if rate < candle.Low {
+			candle.Low = rate
+		}
+		candle.Close = rate
+		candle.Count++
+	}
+	return candles, rows.Err()
+}
+
+// Records returns the records matching filter, newest first.
+func (s *Store) Records(filter Filter) ([]Record, error) {
+	var conditions []string
+	var args []interface{}
+	for column, value := range map[string]string{"provider": filter.Provider, "base": filter.From, "target": filter.To} {
+		if value != "" {
+			conditions = append(conditions, column+" = ?")
+			args = append(args, value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "fetched_at >= ?")
+		args = append(args, formatTime(filter.Since))
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "fetched_at <= ?")
+		args = append(args, formatTime(filter.Until))
+	}
+
+	query := `SELECT provider, base, target, rate, day, timestamp, fetched_at FROM rates`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT %d", filter.Limit)
+
+	rows, err := s.db.Query(s.query(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		var record Record
+		var timestamp, fetchedAt string
+		if err := rows.Scan(&record.Provider, &record.From, &record.To, &record.Rate, &record.Day, &timestamp, &fetchedAt); err != nil {
+			return nil, err
+		}
+		record.Timestamp = parseTime(timestamp)
+		record.FetchedAt = parseTime(fetchedAt)
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// query rewrites ? placeholders as $1, $2, ... for Postgres.
+func (s *Store) query(query string) string {
+	if !s.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func rateResult(provider, from, to, day string, rate float64, timestamp, fetchedAt string) adapters.RateResult {
+	return adapters.RateResult{
+		Rate:      rate,
+		Base:      from,
+		Target:    to,
+		Timestamp: parseTime(timestamp),
+		Provider:  provider,
+		Date:      day,
+		FetchedAt: parseTime(fetchedAt),
+	}
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(timeLayout, s)
+	return t
+}
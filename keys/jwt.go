@@ -0,0 +1,175 @@
+package keys
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MicahParks/keyfunc"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Tenant is an identity provider whose tokens are accepted, as configured in
+// EXCHANGER_JWT_TENANTS_FILE.
+type Tenant struct {
+	// Name identifies the tenant; it is the Name of the keys its tokens
+	// authenticate as.
+	Name string `json:"name"`
+	// Issuer must match the iss claim, and selects the tenant of a token.
+	Issuer string `json:"issuer"`
+	// JWKSURL serves the keys the tenant's tokens are signed with.
+	JWKSURL string `json:"jwksUrl"`
+	// Audience, when set, must be among the aud claim.
+	Audience string `json:"audience"`
+	// ScopesClaim names the claim granting scopes, as a space-separated
+	// string or a list; defaults to "scope".
+	ScopesClaim string `json:"scopesClaim"`
+	// Scopes maps the claim's values to service scopes. Values that name a
+	// service scope grant it without a mapping.
+	Scopes map[string]string `json:"scopes"`
+}
+
+// LoadTenants reads a JSON list of tenants.
+func LoadTenants(path string) ([]Tenant, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tenants []Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+// EnvTenant returns the tenant set by EXCHANGER_JWT_ISSUER,
+// EXCHANGER_JWT_JWKS_URL, EXCHANGER_JWT_AUDIENCE and
+// EXCHANGER_JWT_SCOPES_CLAIM, or nil when no JWKS URL is set.
+func EnvTenant() *Tenant {
+	jwksURL := os.Getenv("EXCHANGER_JWT_JWKS_URL")
+	if jwksURL == "" {
+		return nil
+	}
+	return &Tenant{
+		Name:        "default",
+		Issuer:      os.Getenv("EXCHANGER_JWT_ISSUER"),
+		JWKSURL:     jwksURL,
+		Audience:    os.Getenv("EXCHANGER_JWT_AUDIENCE"),
+		ScopesClaim: os.Getenv("EXCHANGER_JWT_SCOPES_CLAIM"),
+	}
+}
+
+// Signing methods accepted from identity providers. Symmetric ones are left
+// out, since the keys come from a public JWKS.
+var jwtMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// JWTVerifier authenticates bearer tokens issued by the tenants' identity
+// providers, as an alternative to stored keys.
+type JWTVerifier struct {
+	tenants map[string]*tenantKeys
+}
+
+type tenantKeys struct {
+	Tenant
+	jwks *keyfunc.JWKS
+}
+
+// NewJWTVerifier fetches the JWKS of every tenant with client and keeps it
+// fresh in the background: hourly, and when a token names an unknown key.
+func NewJWTVerifier(tenants []Tenant, client *http.Client) (*JWTVerifier, error) {
+	v := &JWTVerifier{tenants: map[string]*tenantKeys{}}
+	for _, tenant := range tenants {
+		if tenant.Issuer == "" || tenant.JWKSURL == "" {
+			v.Close()
+			return nil, fmt.Errorf("tenant %q needs an issuer and a JWKS URL", tenant.Name)
+		}
+		if tenant.ScopesClaim == "" {
+			tenant.ScopesClaim = "scope"
+		}
+		name := tenant.Name
+		jwks, err := keyfunc.Get(tenant.JWKSURL, keyfunc.Options{
+			Client:            client,
+			RefreshInterval:   time.Hour,
+			RefreshRateLimit:  5 * time.Minute,
+			RefreshTimeout:    10 * time.Second,
+			RefreshUnknownKID: true,
+			RefreshErrorHandler: func(err error) {
+				log.Printf("refreshing JWKS of tenant %s failed: %v", name, err)
+			},
+		})
+		if err != nil {
+			v.Close()
+			return nil, fmt.Errorf("fetching JWKS of tenant %q: %w", tenant.Name, err)
+		}
+		v.tenants[tenant.Issuer] = &tenantKeys{Tenant: tenant, jwks: jwks}
+	}
+	return v, nil
+}
+
+// Close stops refreshing the tenants' JWKS.
+func (v *JWTVerifier) Close() {
+	for _, tenant := range v.tenants {
+		tenant.jwks.EndBackground()
+	}
+}
+
+var errUnknownIssuer = errors.New("unknown issuer")
+
+// Authenticate returns the key a valid token authenticates as: its Name is
+// the tenant, its ID the subject and its Scopes those the token grants. A
+// token that is malformed, expired, signed by an unknown key or meant for
+// another audience authenticates as nothing.
+func (v *JWTVerifier) Authenticate(token string) (Key, bool, error) {
+	var tenant *tenantKeys
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		issuer, _ := claims["iss"].(string)
+		if tenant = v.tenants[issuer]; tenant == nil {
+			return nil, errUnknownIssuer
+		}
+		return tenant.jwks.Keyfunc(token)
+	}, jwt.WithValidMethods(jwtMethods))
+	if err != nil {
+		return Key{}, false, nil
+	}
+	if tenant.Audience != "" && !claims.VerifyAudience(tenant.Audience, true) {
+		return Key{}, false, nil
+	}
+
+	subject, _ := claims["sub"].(string)
+	var scopes []string
+	for _, value := range claimValues(claims[tenant.ScopesClaim]) {
+		if scope, ok := tenant.Scopes[value]; ok {
+			value = scope
+		}
+		if ValidScope(value) {
+			scopes = append(scopes, value)
+		}
+	}
+	return Key{ID: subject, Name: tenant.Name, Scopes: scopes}, true, nil
+}
+
+// claimValues reads a claim given as a space-separated string, like the OAuth
+// scope claim, or as a list of strings.
+func claimValues(claim interface{}) []string {
+	switch claim := claim.(type) {
+	case string:
+		return strings.Fields(claim)
+	case []interface{}:
+		var values []string
+		for _, value := range claim {
+			if value, ok := value.(string); ok {
+				values = append(values, value)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+// Package keys authenticates clients of the service: by API keys stored in
+// SQLite or Postgres, or by JWTs of an identity provider. Only a hash of each
+// API key is kept; the key itself is shown once, when it is created.
+package keys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// Scopes a key can be granted. Each route requires one of them.
+const (
+	ScopeConvert    = "convert"
+	ScopeHistorical = "historical"
+	ScopeAdmin      = "admin"
+)
+
+// Scopes lists every scope in the order they are documented.
+var Scopes = []string{ScopeConvert, ScopeHistorical, ScopeAdmin}
+
+// secretPrefix starts every key, so leaked keys are easy to search for.
+const secretPrefix = "exk_"
+
+// timeLayout matches the history package, so timestamps sort as text.
+const timeLayout = "2006-01-02T15:04:05.000Z"
+
+// Key is a stored API key, without its secret.
+type Key struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	Scopes    []string  `json:"scopes"`
+	Quota     Quota     `json:"quota"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Quota caps the conversions a key may make per UTC day and calendar month;
+// 0 leaves the server's default in place.
+type Quota struct {
+	Daily   int `json:"daily,omitempty"`
+	Monthly int `json:"monthly,omitempty"`
+}
+
+// Usage is what a key used on one UTC day.
+type Usage struct {
+	KeyID         string `json:"keyId"`
+	Day           string `json:"day"`
+	Conversions   int    `json:"conversions"`
+	UpstreamCalls int    `json:"upstreamCalls"`
+}
+
+// UsageFilter selects usage; an empty KeyID matches every key and Since and
+// Until are inclusive days written 2006-01-02.
+type UsageFilter struct {
+	KeyID string
+	Since string
+	Until string
+}
+
+// Allows reports whether the key was granted scope.
+func (k Key) Allows(scope string) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidScope reports whether scope is one of Scopes.
+func ValidScope(scope string) bool {
+	for _, known := range Scopes {
+		if known == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Store is a SQL database of API keys.
+type Store struct {
+	db       *sql.DB
+	postgres bool
+}
+
+// Open connects to the database at dsn and creates the keys table if needed.
+// A postgres:// or postgresql:// DSN selects Postgres; anything else is a
+// SQLite file path.
+func Open(dsn string) (*Store, error) {
+	driver, postgres := "sqlite", false
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		driver, postgres = "postgres", true
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if !postgres {
+		// SQLite allows one writer at a time
+		db.SetMaxOpenConns(1)
+	}
+
+	schema := []string{
+		`CREATE TABLE IF NOT EXISTS api_keys (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			prefix TEXT NOT NULL,
+			hash TEXT NOT NULL UNIQUE,
+			scopes TEXT NOT NULL,
+			daily_quota INTEGER NOT NULL DEFAULT 0,
+			monthly_quota INTEGER NOT NULL DEFAULT 0,
+			created_at TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS api_usage (
+			key_id TEXT NOT NULL,
+			day TEXT NOT NULL,
+			conversions INTEGER NOT NULL,
+			upstream_calls INTEGER NOT NULL,
+			PRIMARY KEY (key_id, day)
+		)`,
+	}
+	for _, statement := range schema {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("creating keys schema: %w", err)
+		}
+	}
+
+	return &Store{db: db, postgres: postgres}, nil
+}
+
+// Ping checks that the database answers.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Create stores a new key named name with scopes and quota and returns it
+// with its secret, which is not stored and can't be recovered later.
+func (s *Store) Create(name string, scopes []string, quota Quota) (Key, string, error) {
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return Key{}, "", err
+	}
+	secret := secretPrefix + hex.EncodeToString(random)
+
+	scopes = append([]string(nil), scopes...)
+	sort.Strings(scopes)
+	key := Key{
+		ID:        uuid.NewString(),
+		Name:      name,
+		Prefix:    secret[:len(secretPrefix)+8],
+		Scopes:    scopes,
+		Quota:     quota,
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	_, err := s.db.Exec(s.query(`INSERT INTO api_keys (id, name, prefix, hash, scopes, daily_quota, monthly_quota, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		key.ID, key.Name, key.Prefix, hash(secret), strings.Join(key.Scopes, ","), quota.Daily, quota.Monthly, key.CreatedAt.Format(timeLayout))
+	if err != nil {
+		return Key{}, "", err
+	}
+	return key, secret, nil
+}
+
+// List returns every key, oldest first.
+func (s *Store) List() ([]Key, error) {
+	rows, err := s.db.Query(`SELECT ` + keyColumns + ` FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []Key{}
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Delete revokes the key with id and reports whether it existed.
+func (s *Store) Delete(id string) (bool, error) {
+	result, err := s.db.Exec(s.query(`DELETE FROM api_keys WHERE id = ?`), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Authenticate returns the key whose secret is secret, if there is one.
+func (s *Store) Authenticate(secret string) (Key, bool, error) {
+	row := s.db.QueryRow(s.query(`SELECT `+keyColumns+` FROM api_keys WHERE hash = ?`), hash(secret))
+	key, err := scanKey(row)
+	if err == sql.ErrNoRows {
+		return Key{}, false, nil
+	} else if err != nil {
+		return Key{}, false, err
+	}
+	return key, true, nil
+}
+
+// Record adds conversions and upstream calls to the usage of the key with id
+// on day.
+func (s *Store) Record(id, day string, conversions, upstreamCalls int) error {
+	_, err := s.db.Exec(s.query(`INSERT INTO api_usage (key_id, day, conversions, upstream_calls) VALUES (?, ?, ?, ?)
+		ON CONFLICT (key_id, day) DO UPDATE SET
+			conversions = api_usage.conversions + excluded.conversions,
+			upstream_calls = api_usage.upstream_calls + excluded.upstream_calls`),
+		id, day, conversions, upstreamCalls)
+	return err
+}
+
+// Used returns the conversions the key with id made on day and in the
+// calendar month of day.
+func (s *Store) Used(id, day string) (daily, monthly int, err error) {
+	err = s.db.QueryRow(s.query(`SELECT
+			COALESCE(SUM(CASE WHEN day = ? THEN conversions ELSE 0 END), 0),
+			COALESCE(SUM(conversions), 0)
+		FROM api_usage WHERE key_id = ? AND day >= ? AND day <= ?`),
+		day, id, day[:len("2006-01")]+"-01", day).Scan(&daily, &monthly)
+	return daily, monthly, err
+}
+
+// Usage returns the daily usage matching filter, by day and then key.
+func (s *Store) Usage(filter UsageFilter) ([]Usage, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.KeyID != "" {
+		conditions = append(conditions, "key_id = ?")
+		args = append(args, filter.KeyID)
+	}
+	if filter.Since != "" {
+		conditions = append(conditions, "day >= ?")
+		args = append(args, filter.Since)
+	}
+	if filter.Until != "" {
+		conditions = append(conditions, "day <= ?")
+		args = append(args, filter.Until)
+	}
+
+	query := `SELECT key_id, day, conversions, upstream_calls FROM api_usage`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := s.db.Query(s.query(query+" ORDER BY day, key_id"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []Usage{}
+	for rows.Next() {
+		var u Usage
+		if err := rows.Scan(&u.KeyID, &u.Day, &u.Conversions, &u.UpstreamCalls); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// keyColumns are the columns scanKey reads.
+const keyColumns = `id, name, prefix, scopes, daily_quota, monthly_quota, created_at`
+
+func scanKey(row interface{ Scan(...interface{}) error }) (Key, error) {
+	var key Key
+	var scopes, createdAt string
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &scopes, &key.Quota.Daily, &key.Quota.Monthly, &createdAt); err != nil {
+		return Key{}, err
+	}
+	key.Scopes = strings.Split(scopes, ",")
+	key.CreatedAt, _ = time.Parse(timeLayout, createdAt)
+	return key, nil
+}
+
+// hash is what a key is looked up by. Keys are long and random, so a plain
+// SHA-256 is enough; there is nothing to brute-force.
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// query rewrites ? placeholders as $1, $2, ... for Postgres.
+func (s *Store) query(query string) string {
+	if !s.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+// Package logging sets up the service's structured JSON logs.
+package logging
+
+import (
+	stdlog "log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Setup makes the global zerolog logger write one JSON object per line to
+// stderr at level and above, or readable console lines when format is
+// "console". Messages logged with the standard log package and gin's own
+// output go through it as well, so every line the service writes has the
+// same shape.
+func Setup(level, format string) error {
+	parsed := zerolog.InfoLevel
+	if level != "" {
+		var err error
+		if parsed, err = zerolog.ParseLevel(level); err != nil {
+			return err
+		}
+	}
+	zerolog.SetGlobalLevel(parsed)
+	zerolog.TimeFieldFormat = time.RFC3339Nano
+	zerolog.DurationFieldUnit = time.Millisecond
+
+	logger := zerolog.New(os.Stderr)
+	if format == "console" {
+		logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339})
+	}
+	log.Logger = logger.With().Timestamp().Logger()
+
+	// The standard logger has no levels; its messages are informational
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(levelWriter{zerolog.InfoLevel})
+	gin.DefaultWriter = levelWriter{zerolog.InfoLevel}
+	gin.DefaultErrorWriter = levelWriter{zerolog.ErrorLevel}
+	return nil
+}
+
+// levelWriter logs every line written to it as a message at level.
+type levelWriter struct {
+	level zerolog.Level
+}
+
+func (w levelWriter) Write(p []byte) (int, error) {
+	log.WithLevel(w.level).Msg(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
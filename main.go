@@ -1,163 +1,421 @@
 package main
 
 import (
-	"log"
-
-	"encoding/json"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"log"
+	"net"
 	"net/http"
-	"strconv"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/gin-gonic/gin"
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/cache"
+	"cubetiq-samples/exchanger-go/config"
+	"cubetiq-samples/exchanger-go/events"
+	"cubetiq-samples/exchanger-go/history"
+	"cubetiq-samples/exchanger-go/keys"
+	"cubetiq-samples/exchanger-go/logging"
+	"cubetiq-samples/exchanger-go/server"
+	"cubetiq-samples/exchanger-go/tracing"
+
+	"google.golang.org/grpc"
 )
 
-type ExchangeRateAdapter interface {
-	GetExchangeRate(from, to string) (float64, error)
-	ConvertCurrency(amount float64, from, to string) (float64, error)
-}
+func main() {
+	// Without a command, or with flags first, the binary serves as it did
+	// before it had commands
+	args := os.Args[1:]
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
 
-type OpenExchangeRatesAdapter struct {
-	apiKey string
+	var err error
+	switch command {
+	case "serve":
+		serve(args)
+		return
+	case "convert":
+		err = convertCommand(args)
+	case "rates":
+		err = ratesCommand(args)
+	case "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "exchanger: unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(os.Stderr, "exchanger: %v\n", err)
+		os.Exit(1)
+	}
 }
 
-func (o *OpenExchangeRatesAdapter) GetExchangeRate(from, to string) (float64, error) {
-	// Build the API URL
-	url := fmt.Sprintf("https://openexchangerates.org/api/latest.json?app_id=%s&symbols=%s,%s", o.apiKey, from, to)
+// serve runs the HTTP server, and the gRPC one when configured, until the
+// process is interrupted.
+func serve(args []string) {
+	if err := config.Load(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	if err := logging.Setup(os.Getenv("EXCHANGER_LOG_LEVEL"), os.Getenv("EXCHANGER_LOG_FORMAT")); err != nil {
+		log.Fatalf("Invalid log level: %v", err)
+	}
+
+	if err := server.CheckRounding(); err != nil {
+		log.Fatalf("Invalid rounding configuration: %v", err)
+	}
 
-	// Send a GET request to the API
-	resp, err := http.Get(url)
+	client, err := adapters.NewHTTPClient()
 	if err != nil {
-		return 0, err
+		log.Fatalf("Invalid HTTP client configuration: %v", err)
 	}
-	defer resp.Body.Close()
 
-	// Read the response body
-	body, err := ioutil.ReadAll(resp.Body)
+	registry, static, err := newRegistry(client)
 	if err != nil {
-		return 0, err
+		log.Fatalf("Failed to set up sources: %v", err)
 	}
 
-	// Unmarshal the JSON response
-	var data struct {
-		Rates map[string]float64 `json:"rates"`
+	opts := server.Options{
+		Registry:              registry,
+		Sources:               defaultSources(),
+		Maintenance:           config.Bool("EXCHANGER_MAINTENANCE"),
+		MaintenanceRetryAfter: config.Int("EXCHANGER_MAINTENANCE_RETRY_AFTER", 300),
+		Pprof:                 config.Bool("EXCHANGER_PPROF"),
+		IdempotencyTTL:        config.Duration("EXCHANGER_IDEMPOTENCY_TTL", time.Hour),
+		RefreshPairs:          os.Getenv("EXCHANGER_REFRESH_PAIRS"),
+		RateLimit:             config.Int("EXCHANGER_RATE_LIMIT", 0),
+		RateLimitBurst:        config.Int("EXCHANGER_RATE_LIMIT_BURST", 10),
+		RateLimitBy:           os.Getenv("EXCHANGER_RATE_LIMIT_BY"),
+		Quota: keys.Quota{
+			Daily:   config.Int("EXCHANGER_QUOTA_DAILY", 0),
+			Monthly: config.Int("EXCHANGER_QUOTA_MONTHLY", 0),
+		},
 	}
-	if err := json.Unmarshal(body, &data); err != nil {
-		return 0, err
+	if proxies := os.Getenv("EXCHANGER_TRUSTED_PROXIES"); proxies != "" {
+		opts.TrustedProxies = strings.Split(proxies, ",")
+	}
+	if origins := os.Getenv("EXCHANGER_CORS_ORIGINS"); origins != "" {
+		opts.CORS = server.CORSOptions{
+			Origins:     strings.Split(origins, ","),
+			MaxAge:      config.Duration("EXCHANGER_CORS_MAX_AGE", 10*time.Minute),
+			Credentials: config.Bool("EXCHANGER_CORS_CREDENTIALS"),
+		}
+		if methods := os.Getenv("EXCHANGER_CORS_METHODS"); methods != "" {
+			opts.CORS.Methods = strings.Split(methods, ",")
+		}
+		if headers := os.Getenv("EXCHANGER_CORS_HEADERS"); headers != "" {
+			opts.CORS.Headers = strings.Split(headers, ",")
+		}
 	}
 
-	// Return the exchange rate
-	return data.Rates[to] / data.Rates[from], nil
-}
+	if err := loadSettings(&opts); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if opts.Pprof && opts.AdminToken == "" {
+		log.Fatalf("Invalid configuration: EXCHANGER_PPROF requires EXCHANGER_ADMIN_TOKEN")
+	}
+	opts.Reload = func(opts *server.Options) error {
+		if err := config.Reload(); err != nil {
+			return err
+		}
+		return loadSettings(opts)
+	}
 
-func (o *OpenExchangeRatesAdapter) ConvertCurrency(amount float64, from, to string) (float64, error) {
-	rate, err := o.GetExchangeRate(from, to)
-	if err != nil {
-		return 0, err
+	if redisURL := os.Getenv("EXCHANGER_REDIS_URL"); redisURL != "" {
+		store, err := cache.NewRedis(redisURL)
+		if err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		opts.Cache = store
 	}
-	return amount * rate, nil
-}
 
-type FixerIoAdapter struct {
-	apiKey string
-}
+	if dsn := os.Getenv("EXCHANGER_HISTORY_DSN"); dsn != "" {
+		store, err := history.Open(dsn)
+		if err != nil {
+			log.Fatalf("Failed to open rate history: %v", err)
+		}
+		opts.History = store
+	}
 
-func (f *FixerIoAdapter) GetExchangeRate(from, to string) (float64, error) {
-	// Build the API URL
-	url := fmt.Sprintf("http://data.fixer.io/api/latest?access_key=%s&symbols=%s,%s", f.apiKey, from, to)
+	if url := os.Getenv("EXCHANGER_EVENTS_URL"); url != "" {
+		topic := os.Getenv("EXCHANGER_EVENTS_TOPIC")
+		if topic == "" {
+			topic = "exchanger.rates"
+		}
+		publisher, err := events.Open(url, topic, os.Getenv("EXCHANGER_EVENTS_FORMAT"))
+		if err != nil {
+			log.Fatalf("Failed to connect to the event bus: %v", err)
+		}
+		defer publisher.Close()
+		opts.Events = publisher
+	}
 
-	// Send a GET request to the API
-	resp, err := http.Get(url)
-	if err != nil {
-		return 0, err
+	if dsn := os.Getenv("EXCHANGER_KEYS_DSN"); dsn != "" {
+		store, err := keys.Open(dsn)
+		if err != nil {
+			log.Fatalf("Failed to open API keys: %v", err)
+		}
+		opts.Keys = store
+		if opts.AdminToken == "" {
+			log.Printf("EXCHANGER_KEYS_DSN is set without EXCHANGER_ADMIN_TOKEN, only admin API keys open /admin")
+		}
 	}
-	defer resp.Body.Close()
 
-	// Read the response body
-	body, err := ioutil.ReadAll(resp.Body)
+	var tenants []keys.Tenant
+	if path := os.Getenv("EXCHANGER_JWT_TENANTS_FILE"); path != "" {
+		tenants, err = keys.LoadTenants(path)
+		if err != nil {
+			log.Fatalf("Failed to load JWT tenants: %v", err)
+		}
+	} else if tenant := keys.EnvTenant(); tenant != nil {
+		tenants = []keys.Tenant{*tenant}
+	}
+	if len(tenants) > 0 {
+		verifier, err := keys.NewJWTVerifier(tenants, client)
+		if err != nil {
+			log.Fatalf("Failed to set up JWT authentication: %v", err)
+		}
+		opts.JWT = verifier
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), os.Getenv("EXCHANGER_TRACING_EXPORTER"))
 	if err != nil {
-		return 0, err
+		log.Fatalf("Failed to set up tracing: %v", err)
 	}
+	defer shutdownTracing(context.Background())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Unmarshal the JSON response
-	var data struct {
-		Rates map[string]float64 `json:"rates"`
-		Base  string             `json:"base"`
+	if interval := config.Duration("EXCHANGER_STATIC_RELOAD_INTERVAL", 2*time.Second); static != nil && interval > 0 {
+		go static.Watch(ctx, interval)
 	}
-	if err := json.Unmarshal(body, &data); err != nil {
-		return 0, err
+
+	srv := server.New(opts)
+	go reloadOnHangup(ctx, srv)
+	if interval := config.Duration("EXCHANGER_RELOAD_INTERVAL", 0); interval > 0 {
+		go watchSettings(ctx, srv, interval)
 	}
+	refresherDone := make(chan struct{})
+	go func() {
+		srv.RunRefresher(ctx)
+		close(refresherDone)
+	}()
 
-	// Return the exchange rate
-	return data.Rates[to] / data.Rates[from], nil
-}
+	var grpcServer *grpc.Server
+	if addr := os.Getenv("EXCHANGER_GRPC_ADDR"); addr != "" {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC: %v", err)
+		}
+		grpcServer = srv.GRPC()
+		go func() {
+			log.Printf("gRPC server is listening on %s", addr)
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatalf("Failed to serve gRPC: %v", err)
+			}
+		}()
+	}
 
-func (f *FixerIoAdapter) ConvertCurrency(amount float64, from, to string) (float64, error) {
-	rate, err := f.GetExchangeRate(from, to)
-	if err != nil {
-		return 0, err
+	httpServer := &http.Server{
+		Addr:         listenAddr(),
+		Handler:      srv.Router(),
+		ReadTimeout:  config.Duration("EXCHANGER_READ_TIMEOUT", 30*time.Second),
+		WriteTimeout: config.Duration("EXCHANGER_WRITE_TIMEOUT", 0),
+		IdleTimeout:  config.Duration("EXCHANGER_IDLE_TIMEOUT", 2*time.Minute),
+	}
+	cert, key := os.Getenv("EXCHANGER_TLS_CERT"), os.Getenv("EXCHANGER_TLS_KEY")
+	if cert != "" {
+		// Load the pair up front so a bad certificate fails at startup
+		if _, err := tls.LoadX509KeyPair(cert, key); err != nil {
+			log.Fatalf("Failed to load TLS certificate: %v", err)
+		}
+	}
+	go func() {
+		log.Printf("Exchanger server is started on %s!", httpServer.Addr)
+		var err error
+		if cert != "" {
+			err = httpServer.ListenAndServeTLS(cert, key)
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	shutdown(srv, httpServer, grpcServer, refresherDone)
+	if opts.History != nil {
+		opts.History.Close()
+	}
+	if opts.Keys != nil {
+		opts.Keys.Close()
+	}
+	if opts.JWT != nil {
+		opts.JWT.Close()
 	}
-	return amount * rate, nil
 }
 
-func MoneyExchangeHandler(c *gin.Context) {
-	var adapter ExchangeRateAdapter
-	source := c.Query("source")
-	apiKey := c.Query("key")
+// loadSettings reads the settings the server can reload into opts: the
+// admin token, the provider credentials, the consensus settings and the
+// markups.
+func loadSettings(opts *server.Options) error {
+	opts.AdminToken = os.Getenv("EXCHANGER_ADMIN_TOKEN")
+	if path := os.Getenv("EXCHANGER_CREDENTIALS_FILE"); path != "" {
+		credentials, err := config.LoadCredentials(path)
+		if err != nil {
+			return fmt.Errorf("provider credentials: %w", err)
+		}
+		opts.Credentials = credentials
+	}
 
-	if apiKey == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key is required!", "name": "key"})
-		return
+	consensus, err := adapters.ConsensusFromEnv()
+	if err != nil {
+		return fmt.Errorf("consensus: %w", err)
 	}
+	opts.Consensus = consensus
 
-	switch source {
-	case "openexchangerates":
-		adapter = &OpenExchangeRatesAdapter{
-			apiKey: apiKey,
+	if path := os.Getenv("EXCHANGER_MARKUP_FILE"); path != "" {
+		markups, err := server.LoadMarkups(path)
+		if err != nil {
+			return fmt.Errorf("markups: %w", err)
 		}
-	case "fixerio":
-		adapter = &FixerIoAdapter{
-			apiKey: apiKey,
+		opts.Markups = markups
+	} else {
+		markup, err := server.EnvMarkup()
+		if err != nil {
+			return fmt.Errorf("markups: %w", err)
 		}
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exchange rate source", "name": "source"})
-		return
+		opts.Markups.Default = markup
 	}
+	return nil
+}
 
-	// Perform currency conversion using the selected adapter
-	amountStr := c.Query("amount")
-	from := c.Query("from")
-	to := c.Query("to")
+// reloadOnHangup reloads the configuration of srv on every SIGHUP.
+func reloadOnHangup(ctx context.Context, srv *server.Server) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+		}
+		if err := srv.Reload(); err != nil {
+			log.Printf("reload: keeping the current configuration: %v", err)
+		}
+	}
+}
 
-	amount, err := strconv.ParseFloat(amountStr, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount", "name": "amount"})
-		return
+// watchSettings reloads the configuration of srv whenever the configuration,
+// credentials or markup file changes, checking every interval.
+func watchSettings(ctx context.Context, srv *server.Server, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	seen := settingFiles()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		files := settingFiles()
+		changed := len(files) != len(seen)
+		for path, stamp := range files {
+			changed = changed || seen[path] != stamp
+		}
+		seen = files
+		if !changed {
+			continue
+		}
+		if err := srv.Reload(); err != nil {
+			log.Printf("reload: keeping the current configuration: %v", err)
+		}
 	}
+}
 
-	convertedAmount, err := adapter.ConvertCurrency(amount, from, to)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// settingFiles stamps the files watchSettings watches with their size and
+// modification time.
+func settingFiles() map[string]string {
+	stamps := map[string]string{}
+	for _, path := range []string{config.File(), os.Getenv("EXCHANGER_CREDENTIALS_FILE"), os.Getenv("EXCHANGER_MARKUP_FILE")} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fmt.Sprintf("%d %s", info.Size(), info.ModTime())
+		}
 	}
+	return stamps
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"source":    source,
-		"from":      from,
-		"to":        to,
-		"amount":    amount,
-		"converted": convertedAmount,
-	})
+// listenAddr is the HTTP address from EXCHANGER_BIND and EXCHANGER_PORT. The
+// port falls back to PORT, then 8080 like gin.
+func listenAddr() string {
+	port := os.Getenv("EXCHANGER_PORT")
+	if port == "" {
+		port = os.Getenv("PORT")
+	}
+	if port == "" {
+		port = "8080"
+	}
+	return net.JoinHostPort(os.Getenv("EXCHANGER_BIND"), port)
 }
 
-func main() {
-	r := gin.Default()
+// shutdown drains the servers: readiness fails first so load balancers stop
+// sending traffic, then after EXCHANGER_SHUTDOWN_DELAY the listeners close
+// and in-flight requests get up to EXCHANGER_SHUTDOWN_TIMEOUT to finish,
+// along with gRPC calls and the refresher's current run. A second signal ends
+// the process right away.
+func shutdown(srv *server.Server, httpServer *http.Server, grpcServer *grpc.Server, refresherDone <-chan struct{}) {
+	log.Println("Shutting down, draining in-flight requests")
+	srv.Drain()
+	time.Sleep(config.Duration("EXCHANGER_SHUTDOWN_DELAY", 0))
 
-	r.GET("/exchange", MoneyExchangeHandler)
+	ctx, cancel := context.WithTimeout(context.Background(), config.Duration("EXCHANGER_SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
 
-	log.Println("Exchanger server is started!")
-	err := r.Run()
-	if err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	grpcStopped := make(chan struct{})
+	go func() {
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		close(grpcStopped)
+	}()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("HTTP server did not drain in time: %v", err)
+	}
+
+	if grpcServer != nil {
+		select {
+		case <-grpcStopped:
+		case <-ctx.Done():
+			log.Println("gRPC server did not drain in time")
+			grpcServer.Stop()
+		}
+	}
+
+	select {
+	case <-refresherDone:
+	case <-ctx.Done():
+		log.Println("Refresher did not stop in time")
 	}
+	log.Println("Exchanger server is stopped")
 }
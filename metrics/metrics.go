@@ -0,0 +1,87 @@
+// Package metrics defines the Prometheus metrics of the service.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// HTTPRequests and HTTPDuration cover the requests the service answers,
+	// labelled by route pattern rather than raw path to bound cardinality.
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "exchanger_http_requests_total",
+		Help: "HTTP requests answered, by route, method and status.",
+	}, []string{"route", "method", "status"})
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "exchanger_http_request_duration_seconds",
+		Help:    "Time to answer HTTP requests, by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// ProviderRequests and ProviderDuration cover the lookups that reach a
+	// provider, i.e. cache misses.
+	ProviderRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "exchanger_provider_requests_total",
+		Help: "Lookups sent to providers, by provider, operation and outcome (ok, error, unsupported).",
+	}, []string{"provider", "operation", "outcome"})
+	ProviderDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "exchanger_provider_request_duration_seconds",
+		Help:    "Upstream latency of provider lookups, by provider and operation.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"provider", "operation"})
+
+	// UpstreamHTTPRequests counts the HTTP calls made to each provider host,
+	// which is what provider quotas are metered in.
+	UpstreamHTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "exchanger_upstream_http_requests_total",
+		Help: "HTTP requests sent to provider APIs, by host and status; \"error\" when no response arrived.",
+	}, []string{"host", "status"})
+
+	// UpstreamRetries counts the provider calls retried after a transient
+	// failure; each retry shows up in UpstreamHTTPRequests as well.
+	UpstreamRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "exchanger_upstream_retries_total",
+		Help: "Provider HTTP requests retried, by host and the status that caused the retry; \"error\" when no response arrived.",
+	}, []string{"host", "reason"})
+
+	// CircuitOpen is 1 while a provider's circuit breaker is open.
+	CircuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "exchanger_circuit_open",
+		Help: "Whether the provider's circuit breaker is open (1) or not (0).",
+	}, []string{"provider"})
+
+	// CacheLookups gives the cache hit ratio as hit / (hit + miss).
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "exchanger_cache_lookups_total",
+		Help: "Rate cache lookups, by provider and result (hit or miss).",
+	}, []string{"provider", "result"})
+
+	// RateLimited counts the requests rejected for exceeding the client
+	// rate limit.
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "exchanger_rate_limited_total",
+		Help: "Requests rejected with 429 by the client rate limit, by route.",
+	}, []string{"route"})
+
+	// QuotaExceeded counts the requests rejected because their API key used
+	// up its quota.
+	QuotaExceeded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "exchanger_quota_exceeded_total",
+		Help: "Requests rejected for an API key over its quota, by period (daily or monthly).",
+	}, []string{"period"})
+
+	// ConversionErrors counts failed rate lookups answered to clients.
+	ConversionErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "exchanger_conversion_errors_total",
+		Help: "Conversions that failed, by reason.",
+	}, []string{"reason"})
+)
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
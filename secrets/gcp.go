@@ -0,0 +1,82 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// gcpSecretManager reads secrets from GCP Secret Manager. Paths are
+// resource names, such as projects/my-project/secrets/exchanger, whose
+// latest version is read unless the path names one. Requests carry
+// GOOGLE_OAUTH_ACCESS_TOKEN when set, or else the token of the instance's
+// service account from the metadata server at GCE_METADATA_HOST (default
+// metadata.google.internal).
+type gcpSecretManager struct {
+	client *http.Client
+}
+
+func (g gcpSecretManager) Secret(ctx context.Context, path string) ([]byte, error) {
+	path = strings.Trim(path, "/")
+	if !strings.HasPrefix(path, "projects/") || !strings.Contains(path, "/secrets/") {
+		return nil, errors.New("gcpsm paths look like projects/<project>/secrets/<secret>")
+	}
+	if !strings.Contains(path, "/versions/") {
+		path += "/versions/latest"
+	}
+	token, err := g.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://secretmanager.googleapis.com/v1/"+path+":access", nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	var answer struct {
+		Payload struct {
+			Data []byte `json:"data"`
+		} `json:"payload"`
+	}
+	if err := fetch(g.client, request, &answer, gcpMessage); err != nil {
+		return nil, err
+	}
+	return answer.Payload.Data, nil
+}
+
+// token returns the access token requests are authorized with.
+func (g gcpSecretManager) token(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Metadata-Flavor", "Google")
+	var answer struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := fetch(g.client, request, &answer, gcpMessage); err != nil {
+		return "", errors.New("gcpsm needs GOOGLE_OAUTH_ACCESS_TOKEN outside GCP: " + err.Error())
+	}
+	return answer.AccessToken, nil
+}
+
+func gcpMessage(body []byte) string {
+	var answer struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.Unmarshal(body, &answer)
+	return answer.Error.Message
+}
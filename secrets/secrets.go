@@ -0,0 +1,181 @@
+// Package secrets resolves references to secrets kept in a secret manager,
+// such as vault://secret/data/exchanger#fixerio, so API keys and tokens
+// needn't sit in plain text in the configuration.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeout bounds fetching one secret.
+const timeout = 10 * time.Second
+
+// Backend fetches secrets from a secret manager.
+type Backend interface {
+	// Secret returns the secret at path, the part of a reference between
+	// its scheme and its #field.
+	Secret(ctx context.Context, path string) ([]byte, error)
+}
+
+var registry = struct {
+	mu       sync.RWMutex
+	backends map[string]Backend
+}{backends: map[string]Backend{}}
+
+// Register has the references of scheme, scheme://path#field, fetched from
+// backend. Registering a scheme again replaces its backend.
+func Register(scheme string, backend Backend) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.backends[scheme] = backend
+}
+
+func init() {
+	client := &http.Client{Timeout: timeout}
+	Register("vault", vault{client: client})
+	Register("awssm", awsSecretsManager{client: client})
+	Register("gcpsm", gcpSecretManager{client: client})
+}
+
+// Schemes lists the registered schemes.
+func Schemes() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	schemes := make([]string, 0, len(registry.backends))
+	for scheme := range registry.backends {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// reference is a parsed scheme://path#field.
+type reference struct {
+	backend      Backend
+	scheme, path string
+	field        string
+}
+
+// parse splits value into a reference, reporting false when it doesn't
+// start with a registered scheme.
+func parse(value string) (reference, bool) {
+	scheme, rest, ok := strings.Cut(value, "://")
+	if !ok {
+		return reference{}, false
+	}
+	registry.mu.RLock()
+	backend, ok := registry.backends[scheme]
+	registry.mu.RUnlock()
+	if !ok {
+		return reference{}, false
+	}
+	ref := reference{backend: backend, scheme: scheme, path: rest}
+	if i := strings.LastIndex(rest, "#"); i >= 0 {
+		ref.path, ref.field = rest[:i], rest[i+1:]
+	}
+	return ref, true
+}
+
+// IsReference reports whether value references a secret.
+func IsReference(value string) bool {
+	_, ok := parse(value)
+	return ok
+}
+
+// Resolver resolves references, fetching each secret once, so a reference
+// to several fields of one secret costs one call.
+type Resolver struct {
+	secrets map[string][]byte
+}
+
+// NewResolver returns a Resolver that hasn't fetched anything yet.
+func NewResolver() *Resolver {
+	return &Resolver{secrets: map[string][]byte{}}
+}
+
+// Resolve returns the secret value references, or value itself when it
+// isn't a reference. A reference with a #field takes that field of a secret
+// holding a JSON object.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	ref, ok := parse(value)
+	if !ok {
+		return value, nil
+	}
+	key := ref.scheme + "://" + ref.path
+	secret, ok := r.secrets[key]
+	if !ok {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		var err error
+		if secret, err = ref.backend.Secret(ctx, ref.path); err != nil {
+			return "", fmt.Errorf("%s: %w", key, err)
+		}
+		r.secrets[key] = secret
+	}
+	if ref.field == "" {
+		return strings.TrimSpace(string(secret)), nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(secret, &fields); err != nil {
+		return "", fmt.Errorf("%s: #%s needs a secret holding a JSON object", key, ref.field)
+	}
+	field, ok := fields[ref.field]
+	if !ok {
+		return "", fmt.Errorf("%s has no field %s", key, ref.field)
+	}
+	if s, ok := field.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(field)
+	return string(data), err
+}
+
+// Resolve resolves value on its own; see Resolver.Resolve.
+func Resolve(ctx context.Context, value string) (string, error) {
+	return NewResolver().Resolve(ctx, value)
+}
+
+// responseError is a secret manager answering with a status other than 2xx.
+type responseError struct {
+	status  int
+	message string
+}
+
+func (e *responseError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("secret manager answered %d", e.status)
+	}
+	return fmt.Sprintf("secret manager answered %d: %s", e.status, e.message)
+}
+
+// fetch sends request and decodes the JSON answer into v. message extracts
+// the error message of a failed answer.
+func fetch(client *http.Client, request *http.Request, v interface{}, message func([]byte) string) error {
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var body json.RawMessage
+	decodeErr := json.NewDecoder(response.Body).Decode(&body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		text := ""
+		if decodeErr == nil {
+			text = message(body)
+		}
+		return &responseError{status: response.StatusCode, message: text}
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("decoding the secret manager's answer: %w", decodeErr)
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,55 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// vault reads secrets from HashiCorp Vault at VAULT_ADDR with VAULT_TOKEN,
+// in VAULT_NAMESPACE when set. Paths are API paths, such as
+// secret/data/exchanger for the exchanger secret of a KV version 2 engine
+// mounted at secret/. The secret is the JSON object of its key/value pairs.
+type vault struct {
+	client *http.Client
+}
+
+func (v vault) Secret(ctx context.Context, path string) ([]byte, error) {
+	addr, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return nil, errors.New("vault needs VAULT_ADDR and VAULT_TOKEN")
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		request.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	var answer struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := fetch(v.client, request, &answer, vaultMessage); err != nil {
+		return nil, err
+	}
+	// KV version 2 nests the pairs under data.data, next to their metadata
+	if data, ok := answer.Data["data"]; ok {
+		if _, ok := answer.Data["metadata"]; ok {
+			return data, nil
+		}
+	}
+	return json.Marshal(answer.Data)
+}
+
+func vaultMessage(body []byte) string {
+	var answer struct {
+		Errors []string `json:"errors"`
+	}
+	json.Unmarshal(body, &answer)
+	return strings.Join(answer.Errors, "; ")
+}
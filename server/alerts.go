@@ -0,0 +1,354 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/cache"
+	"cubetiq-samples/exchanger-go/keys"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Alert calls URL and notifies the Notify channels when the rate of its pair
+// moves above Above, below Below, or by ChangePercent since it last fired.
+// Secret signs the calls; it is shown once, when the alert is created.
+type Alert struct {
+	ID            string    `json:"id"`
+	From          string    `json:"from"`
+	To            string    `json:"to"`
+	Above         *float64  `json:"above,omitempty"`
+	Below         *float64  `json:"below,omitempty"`
+	ChangePercent *float64  `json:"changePercent,omitempty"`
+	URL           string    `json:"url,omitempty"`
+	Notify        []string  `json:"notify,omitempty"`
+	Secret        string    `json:"secret,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	// LastRate is the rate last checked and Baseline the one ChangePercent
+	// is measured from.
+	LastRate      float64    `json:"lastRate"`
+	Baseline      float64    `json:"baseline"`
+	LastTriggered *time.Time `json:"lastTriggered,omitempty"`
+}
+
+// storedAlert is an alert with the key ID it belongs to, which responses
+// leave out.
+type storedAlert struct {
+	Alert
+	Owner string `json:"owner,omitempty"`
+}
+
+// alertEvent is what an alert reports when it fires.
+type alertEvent struct {
+	Alert        Alert   `json:"alert"`
+	Event        string  `json:"event"`
+	Rate         float64 `json:"rate"`
+	PreviousRate float64 `json:"previousRate"`
+	// Baseline is the rate a change event is measured from.
+	Baseline    float64   `json:"baseline,omitempty"`
+	Provider    string    `json:"provider"`
+	TriggeredAt time.Time `json:"triggeredAt"`
+}
+
+const (
+	// alertsKey holds every alert as one JSON document, like the overrides.
+	alertsKey = "alerts"
+	// alertsTTL keeps the document as long as the cache keeps anything.
+	alertsTTL = 100 * 365 * 24 * time.Hour
+	// alertsReload is how often alerts created on other instances are
+	// picked up.
+	alertsReload = 5 * time.Second
+)
+
+// alerts stores the alerts in a cache.
+type alerts struct {
+	store cache.Cache
+
+	mu       sync.Mutex
+	entries  map[string]storedAlert
+	loadedAt time.Time
+}
+
+func newAlerts(store cache.Cache) *alerts {
+	return &alerts{store: store}
+}
+
+// current returns the alerts, reloading them when the local copy is older
+// than alertsReload. The caller holds a.mu.
+func (a *alerts) current() map[string]storedAlert {
+	if a.entries != nil && time.Since(a.loadedAt) < alertsReload {
+		return a.entries
+	}
+	entries := map[string]storedAlert{}
+	if data, ok := a.store.Get(alertsKey); ok {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			entries = map[string]storedAlert{}
+		}
+	}
+	a.entries, a.loadedAt = entries, time.Now()
+	return entries
+}
+
+// save writes entries back to the store. The caller holds a.mu.
+func (a *alerts) save(entries map[string]storedAlert) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	a.store.Set(alertsKey, data, alertsTTL)
+	a.entries, a.loadedAt = entries, time.Now()
+	return nil
+}
+
+// add stores a new alert of owner.
+func (a *alerts) add(alert Alert, owner string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries = nil
+	entries := a.current()
+	entries[alert.ID] = storedAlert{Alert: alert, Owner: owner}
+	return a.save(entries)
+}
+
+// list returns the alerts of owner, or every alert for an empty owner,
+// ordered by creation and without their secrets.
+func (a *alerts) list(owner string) []Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	list := []Alert{}
+	for _, stored := range a.current() {
+		if owner == "" || stored.Owner == owner {
+			alert := stored.Alert
+			alert.Secret = ""
+			list = append(list, alert)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+// remove deletes the alert id of owner, or of anyone for an empty owner,
+// reporting whether there was one.
+func (a *alerts) remove(id, owner string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries = nil
+	entries := a.current()
+	stored, ok := entries[id]
+	if !ok || (owner != "" && stored.Owner != owner) {
+		return false, nil
+	}
+	delete(entries, id)
+	return true, a.save(entries)
+}
+
+// pairs lists the pairs alerts watch.
+func (a *alerts) pairs() []adapters.Pair {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	seen := map[adapters.Pair]bool{}
+	var pairs []adapters.Pair
+	for _, stored := range a.current() {
+		pair := adapters.Pair{From: stored.From, To: stored.To}
+		if !seen[pair] {
+			seen[pair] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+// check compares a refreshed rate with the alerts of its pair and returns
+// the events of those that fire. Thresholds fire when the rate crosses
+// them, and a change when it moved ChangePercent from the baseline, which
+// then starts over.
+func (a *alerts) check(pair adapters.Pair, result adapters.RateResult) ([]alertEvent, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries = nil
+	entries := a.current()
+	now := time.Now().UTC()
+	rate := result.Rate
+	var events []alertEvent
+	changed := false
+	for id, stored := range entries {
+		if stored.From != pair.From || stored.To != pair.To || stored.LastRate == rate {
+			continue
+		}
+		previous := stored.LastRate
+		var event string
+		switch {
+		case stored.Above != nil && previous < *stored.Above && rate >= *stored.Above:
+			event = "above"
+		case stored.Below != nil && previous > *stored.Below && rate <= *stored.Below:
+			event = "below"
+		case stored.ChangePercent != nil && stored.Baseline > 0 &&
+			math.Abs(rate-stored.Baseline)/stored.Baseline*100 >= *stored.ChangePercent:
+			event = "change"
+		}
+
+		stored.LastRate = rate
+		if event != "" {
+			fired := alertEvent{Event: event, Rate: rate, PreviousRate: previous, Provider: result.Provider, TriggeredAt: now}
+			if event == "change" {
+				fired.Baseline = stored.Baseline
+			}
+			stored.Baseline = rate
+			stored.LastTriggered = &now
+			fired.Alert = stored.Alert
+			events = append(events, fired)
+		}
+		entries[id] = stored
+		changed = true
+	}
+	if !changed {
+		return nil, nil
+	}
+	return events, a.save(entries)
+}
+
+// alertOwner is the key ID alerts created by the request belong to, "" when
+// the server has no keys and every alert is shared.
+func alertOwner(c *gin.Context) string {
+	key, _ := c.Request.Context().Value(serviceKeyContextKey{}).(keys.Key)
+	return key.ID
+}
+
+// CreateAlertHandler registers an alert, e.g. POST /alerts
+// {"pair": "USD/KHR", "above": 4200, "url": "https://example.com/hook"}. The
+// pair's rate is fetched right away, as the baseline the alert is checked
+// against. The response carries the secret that signs the alert's calls.
+func (s *Server) CreateAlertHandler(c *gin.Context) {
+	countConversions(c, 0)
+	var request struct {
+		Pair          string   `json:"pair"`
+		From          string   `json:"from"`
+		To            string   `json:"to"`
+		Above         *float64 `json:"above"`
+		Below         *float64 `json:"below"`
+		ChangePercent *float64 `json:"changePercent"`
+		URL           string   `json:"url"`
+		Notify        []string `json:"notify"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid alert, expected {\"pair\", \"above\", \"below\", \"changePercent\", \"url\", \"notify\"}", "")
+		return
+	}
+
+	from, to := request.From, request.To
+	if request.Pair != "" {
+		pair, err := parsePair(request.Pair)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidCurrency, err.Error(), "pair")
+			return
+		}
+		from, to = pair.From, pair.To
+	}
+	from, to, err := normalizePair(from, to)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidCurrency, err.Error(), "pair")
+		return
+	}
+	if request.Above == nil && request.Below == nil && request.ChangePercent == nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Set above, below or changePercent", "")
+		return
+	}
+	for name, value := range map[string]*float64{"above": request.Above, "below": request.Below, "changePercent": request.ChangePercent} {
+		if value != nil && *value <= 0 {
+			respondError(c, http.StatusBadRequest, CodeInvalidRequest, name+" must be positive", name)
+			return
+		}
+	}
+	if request.URL == "" && len(request.Notify) == 0 {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Set url or notify", "url")
+		return
+	}
+	if callback, err := url.Parse(request.URL); request.URL != "" && (err != nil || (callback.Scheme != "http" && callback.Scheme != "https") || callback.Host == "") {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "url must be an http or https URL", "url")
+		return
+	} else if request.URL != "" && s.webhooks.blockedHost(callback.Hostname()) {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "url must not point to a loopback, link-local or private address", "url")
+		return
+	}
+	notifiers := s.current().notifiers
+	for _, channel := range request.Notify {
+		if _, ok := notifiers[channel]; !ok {
+			respondError(c, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Unknown channel %q, configured: %s", channel, strings.Join(s.channelNames(), ", ")), "notify")
+			return
+		}
+	}
+
+	adapter, _, ok := s.requestAdapter(c)
+	if !ok {
+		return
+	}
+	result, err := adapter.GetRate(c.Request.Context(), from, to)
+	if err != nil {
+		respondRateError(c, err)
+		return
+	}
+
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+		return
+	}
+	alert := Alert{
+		ID:            uuid.NewString(),
+		From:          from,
+		To:            to,
+		Above:         request.Above,
+		Below:         request.Below,
+		ChangePercent: request.ChangePercent,
+		URL:           request.URL,
+		Notify:        request.Notify,
+		Secret:        "whsec_" + hex.EncodeToString(random),
+		CreatedAt:     time.Now().UTC(),
+		LastRate:      result.Rate,
+		Baseline:      result.Rate,
+	}
+	if err := s.alerts.add(alert, alertOwner(c)); err != nil {
+		respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"alert": alert})
+}
+
+// ListAlertsHandler lists the alerts of the caller's key, or every alert
+// when the server has no keys.
+func (s *Server) ListAlertsHandler(c *gin.Context) {
+	countConversions(c, 0)
+	c.JSON(http.StatusOK, gin.H{"alerts": s.alerts.list(alertOwner(c))})
+}
+
+// DeleteAlertHandler removes an alert of the caller's key.
+func (s *Server) DeleteAlertHandler(c *gin.Context) {
+	countConversions(c, 0)
+	deleted, err := s.alerts.remove(c.Param("id"), alertOwner(c))
+	switch {
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+	case !deleted:
+		respondError(c, http.StatusNotFound, CodeNotFound, "No alert "+strings.TrimSpace(c.Param("id")), "id")
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,290 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	exchangerv1 "cubetiq-samples/exchanger-go/api/exchanger/v1"
+	"cubetiq-samples/exchanger-go/keys"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type serviceKeyContextKey struct{}
+
+// authRequired reports whether clients must authenticate, with a stored key
+// or a bearer token.
+func (s *Server) authRequired() bool {
+	return s.keys != nil || s.jwt != nil
+}
+
+// authenticate looks up the service key sent as apiKey, or else the one the
+// bearer JWT authenticates as, and checks that it grants scope. It returns
+// the HTTP status, message and credential name to reject the request with,
+// or a status of 0 when the key may proceed.
+func (s *Server) authenticate(apiKey, bearer, scope string) (keys.Key, int, string, string) {
+	var key keys.Key
+	var ok bool
+	var err error
+	name := "X-API-Key"
+	switch {
+	case apiKey != "" && s.keys != nil:
+		key, ok, err = s.keys.Authenticate(apiKey)
+	case bearer != "" && s.jwt != nil:
+		name = "Authorization"
+		key, ok, err = s.jwt.Authenticate(bearer)
+	case s.jwt != nil && s.keys == nil:
+		return keys.Key{}, http.StatusUnauthorized, "Bearer token is required", "Authorization"
+	case s.jwt != nil:
+		return keys.Key{}, http.StatusUnauthorized, "API key or bearer token is required", name
+	default:
+		return keys.Key{}, http.StatusUnauthorized, "API key is required", name
+	}
+
+	switch {
+	case err != nil:
+		return keys.Key{}, http.StatusServiceUnavailable, "API keys can't be checked right now", name
+	case !ok && name == "Authorization":
+		return keys.Key{}, http.StatusUnauthorized, "Invalid bearer token", name
+	case !ok:
+		return keys.Key{}, http.StatusUnauthorized, "Invalid API key", name
+	case !key.Allows(scope):
+		return keys.Key{}, http.StatusForbidden, "Credentials lack the " + scope + " scope", name
+	}
+	return key, 0, "", ""
+}
+
+// authErrorCodes are the error codes of the statuses authenticate rejects
+// with.
+var authErrorCodes = map[int]string{
+	http.StatusUnauthorized:       CodeUnauthenticated,
+	http.StatusForbidden:          CodeForbidden,
+	http.StatusServiceUnavailable: CodeServiceUnavailable,
+}
+
+// bearerToken returns the token of an Authorization: Bearer header.
+func bearerToken(header string) string {
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return header[7:]
+	}
+	return ""
+}
+
+// requireScope rejects requests whose X-API-Key or bearer token doesn't grant
+// scope. Without a key store or JWT verifier every request passes, as before
+// keys existed.
+func (s *Server) requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.authRequired() {
+			c.Next()
+			return
+		}
+		key, status, message, name := s.authenticate(c.GetHeader("X-API-Key"), bearerToken(c.GetHeader("Authorization")), scope)
+		if status != 0 {
+			abortError(c, status, authErrorCodes[status], message, name)
+			return
+		}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), serviceKeyContextKey{}, key))
+		if s.keys != nil && scope != keys.ScopeAdmin {
+			s.meter(c, key)
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorize checks a scope beyond the one the route required, for GraphQL
+// fields that read historical rates.
+func (s *Server) authorize(ctx context.Context, scope string) error {
+	if !s.authRequired() {
+		return nil
+	}
+	key, _ := ctx.Value(serviceKeyContextKey{}).(keys.Key)
+	if !key.Allows(scope) {
+		return &keyScopeError{scope: scope}
+	}
+	return nil
+}
+
+type keyScopeError struct {
+	scope string
+}
+
+func (e *keyScopeError) Error() string {
+	return "Credentials lack the " + e.scope + " scope"
+}
+
+// adminAuth guards the /admin routes. Besides the admin token they accept a
+// service key or bearer token with the admin scope; with either enabled but
+// no admin token, those are the only way in.
+func (s *Server) adminAuth() gin.HandlerFunc {
+	if !s.authRequired() {
+		return func(c *gin.Context) { AdminAuth(s.current().adminToken)(c) }
+	}
+	requireAdmin := s.requireScope(keys.ScopeAdmin)
+	return func(c *gin.Context) {
+		given := []byte(c.GetHeader("Authorization"))
+		token := s.current().adminToken
+		if token != "" && subtle.ConstantTimeCompare(given, []byte("Bearer "+token)) == 1 {
+			c.Next()
+			return
+		}
+		if c.GetHeader("X-API-Key") != "" || (s.jwt != nil && len(given) > 0) {
+			requireAdmin(c)
+			return
+		}
+		abortError(c, http.StatusUnauthorized, CodeUnauthenticated, "Admin token or admin API key is required", "Authorization")
+	}
+}
+
+// grpcKey checks the x-api-key or authorization metadata of a gRPC call for
+// the convert scope.
+func (s *Server) grpcKey(ctx context.Context) (keys.Key, error) {
+	var apiKey, bearer string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-api-key"); len(values) > 0 {
+			apiKey = values[0]
+		}
+		if values := md.Get("authorization"); len(values) > 0 {
+			bearer = bearerToken(values[0])
+		}
+	}
+	key, httpStatus, message, _ := s.authenticate(apiKey, bearer, keys.ScopeConvert)
+	switch httpStatus {
+	case 0:
+		return key, nil
+	case http.StatusUnauthorized:
+		return keys.Key{}, status.Error(codes.Unauthenticated, message)
+	case http.StatusForbidden:
+		return keys.Key{}, status.Error(codes.PermissionDenied, message)
+	default:
+		return keys.Key{}, status.Error(codes.Unavailable, message)
+	}
+}
+
+func (s *Server) grpcKeyUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	key, err := s.grpcKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, serviceKeyContextKey{}, key)
+	if s.keys == nil {
+		return handler(ctx, req)
+	}
+	ctx, record, err := s.grpcMeter(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := handler(ctx, req)
+	conversions := 0
+	if err == nil && info.FullMethod == exchangerv1.ExchangerService_Convert_FullMethodName {
+		conversions = 1
+	}
+	record(conversions)
+	return resp, err
+}
+
+func (s *Server) grpcKeyStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	key, err := s.grpcKey(ss.Context())
+	if err != nil {
+		return err
+	}
+	if s.keys == nil {
+		return handler(srv, ss)
+	}
+	ctx, record, err := s.grpcMeter(ss.Context(), key)
+	if err != nil {
+		return err
+	}
+	err = handler(srv, &meteredStream{ServerStream: ss, ctx: ctx})
+	record(0)
+	return err
+}
+
+// meteredStream hands the stream handler the context that counts upstream
+// calls.
+type meteredStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (m *meteredStream) Context() context.Context {
+	return m.ctx
+}
+
+// CreateKeyHandler creates a service key from {"name", "scopes", "quota"}
+// and answers it with its secret, which is shown only this once.
+func (s *Server) CreateKeyHandler(c *gin.Context) {
+	if s.keys == nil {
+		respondError(c, http.StatusNotImplemented, CodeFeatureDisabled, "API keys are disabled", "")
+		return
+	}
+
+	var request struct {
+		Name   string     `json:"name"`
+		Scopes []string   `json:"scopes"`
+		Quota  keys.Quota `json:"quota"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || strings.TrimSpace(request.Name) == "" {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid key request, expected {\"name\", \"scopes\"}", "name")
+		return
+	}
+	if len(request.Scopes) == 0 {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "At least one scope is required: "+strings.Join(keys.Scopes, ", "), "scopes")
+		return
+	}
+	if request.Quota.Daily < 0 || request.Quota.Monthly < 0 {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Quotas can't be negative", "quota")
+		return
+	}
+	for _, scope := range request.Scopes {
+		if !keys.ValidScope(scope) {
+			respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Unknown scope "+scope+", expected "+strings.Join(keys.Scopes, ", "), "scopes")
+			return
+		}
+	}
+
+	key, secret, err := s.keys.Create(strings.TrimSpace(request.Name), request.Scopes, request.Quota)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"key": key, "secret": secret})
+}
+
+// ListKeysHandler lists the service keys, without their secrets.
+func (s *Server) ListKeysHandler(c *gin.Context) {
+	if s.keys == nil {
+		respondError(c, http.StatusNotImplemented, CodeFeatureDisabled, "API keys are disabled", "")
+		return
+	}
+	list, err := s.keys.List()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": list})
+}
+
+// DeleteKeyHandler revokes the service key with the id in the path.
+func (s *Server) DeleteKeyHandler(c *gin.Context) {
+	if s.keys == nil {
+		respondError(c, http.StatusNotImplemented, CodeFeatureDisabled, "API keys are disabled", "")
+		return
+	}
+	deleted, err := s.keys.Delete(c.Param("id"))
+	switch {
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+	case !deleted:
+		respondError(c, http.StatusNotFound, CodeNotFound, "Unknown API key", "id")
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,275 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/history"
+	"cubetiq-samples/exchanger-go/keys"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+// HistoryHandler lists recorded rates newest first, filtered by provider,
+// from, to and the since/until fetch times (RFC 3339), e.g.
+// /admin/history?from=USD&to=KHR&since=2024-05-01T00:00:00Z. It answers
+// which rate a conversion at a given time used.
+func (s *Server) HistoryHandler(c *gin.Context) {
+	if s.history == nil {
+		respondError(c, http.StatusNotImplemented, CodeFeatureDisabled, "Rate history is disabled", "")
+		return
+	}
+
+	query, ok := parseHistoryQuery(c)
+	if !ok {
+		return
+	}
+	filter := history.Filter{
+		Provider: c.Query("provider"),
+		From:     query.from,
+		To:       query.to,
+		Since:    query.since,
+		Until:    query.until,
+		Limit:    query.limit,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultHistoryLimit
+	}
+
+	records, err := s.history.Records(filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"records": records})
+}
+
+// historyQuery holds the filters the admin history routes share. A zero
+// limit was left to the route's default.
+type historyQuery struct {
+	from, to     string
+	since, until time.Time
+	limit        int
+}
+
+// parseHistoryQuery reads the from and to currencies, the since and until
+// times (RFC 3339) and the limit. On failure it writes the error response and
+// returns false.
+func parseHistoryQuery(c *gin.Context) (historyQuery, bool) {
+	var query historyQuery
+	for name, code := range map[string]*string{"from": &query.from, "to": &query.to} {
+		if c.Query(name) == "" {
+			continue
+		}
+		normalized, ok := requestCurrency(c, name)
+		if !ok {
+			return query, false
+		}
+		*code = normalized
+	}
+	for name, t := range map[string]*time.Time{"since": &query.since, "until": &query.until} {
+		if value := c.Query(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, CodeInvalidDate, "Invalid time, expected RFC 3339", name)
+				return query, false
+			}
+			*t = parsed
+		}
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxHistoryLimit {
+			respondError(c, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Limit must be between 1 and %d", maxHistoryLimit), "limit")
+			return query, false
+		}
+		query.limit = limit
+	}
+	return query, true
+}
+
+// AuditHandler lists the conversions in the audit log newest first, filtered
+// by from, to, the key ID of the service key that made them and the
+// since/until conversion times (RFC 3339), e.g.
+// /admin/audit?key=k_123&since=2024-05-01T00:00:00Z. before=<id> pages on
+// from the last conversion listed. With format=csv every matching conversion
+// is exported unless limit caps them.
+func (s *Server) AuditHandler(c *gin.Context) {
+	if s.history == nil {
+		respondError(c, http.StatusNotImplemented, CodeFeatureDisabled, "Rate history is disabled", "")
+		return
+	}
+
+	query, ok := parseHistoryQuery(c)
+	if !ok {
+		return
+	}
+	filter := history.ConversionFilter{
+		From:  query.from,
+		To:    query.to,
+		KeyID: c.Query("key"),
+		Since: query.since,
+		Until: query.until,
+		Limit: query.limit,
+	}
+	if beforeStr := c.Query("before"); beforeStr != "" {
+		before, err := strconv.ParseInt(beforeStr, 10, 64)
+		if err != nil || before < 1 {
+			respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Before must be a conversion ID", "before")
+			return
+		}
+		filter.Before = before
+	}
+
+	if responseFormat(c) == formatCSV {
+		s.exportConversions(c, filter)
+		return
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultHistoryLimit
+	}
+	conversions, err := s.history.Conversions(filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"conversions": conversions})
+}
+
+// auditColumns are the columns of an audit log export.
+var auditColumns = []string{"id", "time", "requestId", "keyId", "route", "provider", "from", "to",
+	"amount", "rate", "midRate", "fee", "converted", "date", "rateTimestamp"}
+
+// auditExportPage is how many conversions an export reads at a time, so
+// recording new ones isn't held up by a long export.
+const auditExportPage = 1000
+
+// exportConversions streams the conversions filter matches as CSV, a page at
+// a time. Once rows went out, a failure ends the file with an error row.
+func (s *Server) exportConversions(c *gin.Context, filter history.ConversionFilter) {
+	limit := filter.Limit
+	page := func() ([]history.Conversion, error) {
+		filter.Limit = auditExportPage
+		if limit > 0 && limit < filter.Limit {
+			filter.Limit = limit
+		}
+		return s.history.Conversions(filter)
+	}
+	conversions, err := page()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="audit.csv"`)
+	c.Status(http.StatusOK)
+	writer := csv.NewWriter(c.Writer)
+	writer.Write(auditColumns)
+	for len(conversions) > 0 && c.Request.Context().Err() == nil {
+		for _, conversion := range conversions {
+			writer.Write(auditRecord(conversion))
+		}
+		writer.Flush()
+		c.Writer.Flush()
+
+		if limit > 0 {
+			if limit -= len(conversions); limit == 0 {
+				break
+			}
+		}
+		filter.Before = conversions[len(conversions)-1].ID
+		if conversions, err = page(); err != nil {
+			writer.Write([]string{"error: " + err.Error()})
+			break
+		}
+	}
+	writer.Flush()
+}
+
+// auditRecord is the CSV row of conversion, in the order of auditColumns.
+func auditRecord(conversion history.Conversion) []string {
+	return []string{
+		strconv.FormatInt(conversion.ID, 10),
+		conversion.Time.Format(time.RFC3339Nano),
+		conversion.RequestID,
+		conversion.KeyID,
+		conversion.Route,
+		conversion.Provider,
+		conversion.From,
+		conversion.To,
+		conversion.Amount.String(),
+		strconv.FormatFloat(conversion.Rate, 'f', -1, 64),
+		strconv.FormatFloat(conversion.MidRate, 'f', -1, 64),
+		conversion.Fee.String(),
+		conversion.Converted.String(),
+		conversion.Date,
+		conversion.RateTimestamp.Format(time.RFC3339Nano),
+	}
+}
+
+// auditSource is where recorded conversions were asked for: the route, or
+// gRPC method, and the request ID.
+type auditSource struct {
+	route     string
+	requestID string
+}
+
+// auditSourceContextKey holds the auditSource of GraphQL requests, whose
+// resolvers don't see the gin context.
+type auditSourceContextKey struct{}
+
+// requestSource is the auditSource of an HTTP request.
+func requestSource(c *gin.Context) auditSource {
+	return auditSource{route: c.FullPath(), requestID: c.GetString(requestIDKey)}
+}
+
+// auditedConversion describes converting amount into converted at rate, with
+// fee charged on top. result is the rate looked up, before any markup.
+func auditedConversion(result adapters.RateResult, rate float64, amount, converted, fee decimal.Decimal) history.Conversion {
+	return history.Conversion{
+		Provider:      result.Provider,
+		From:          result.Base,
+		To:            result.Target,
+		Amount:        amount,
+		Rate:          rate,
+		MidRate:       result.Rate,
+		Fee:           fee,
+		Converted:     converted,
+		Date:          result.Date,
+		RateTimestamp: result.Timestamp,
+	}
+}
+
+// audit records conversions in the audit log, stamped with the time, source
+// and the service key ctx authenticated with. Without rate history it does
+// nothing. A failure is logged rather than failing the request, as the
+// conversions were made by then.
+func (s *Server) audit(ctx context.Context, source auditSource, conversions ...history.Conversion) {
+	if s.history == nil || len(conversions) == 0 {
+		return
+	}
+	key, _ := ctx.Value(serviceKeyContextKey{}).(keys.Key)
+	now := time.Now()
+	for i := range conversions {
+		conversions[i].Time = now
+		conversions[i].RequestID = source.requestID
+		conversions[i].KeyID = key.ID
+		conversions[i].Route = source.route
+	}
+	if err := s.history.RecordConversions(conversions); err != nil {
+		log.Printf("audit: recording %d conversions of %s request %s: %v", len(conversions), source.route, source.requestID, err)
+	}
+}
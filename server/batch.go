@@ -0,0 +1,246 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/config"
+	"cubetiq-samples/exchanger-go/history"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// batchItem is one conversion in a POST /exchange/batch request. Amount is
+// kept raw so that an invalid amount fails its item rather than the request.
+type batchItem struct {
+	Amount json.RawMessage `json:"amount"`
+	From   string          `json:"from"`
+	To     string          `json:"to"`
+}
+
+// batchAmount reads the amount of an item, a JSON number or decimal string.
+// A missing or null amount makes the item a rate lookup for an amount of 1,
+// reported by rateOnly.
+func batchAmount(raw json.RawMessage) (amount decimal.Decimal, rateOnly bool, err error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return decimal.NewFromInt(1), true, nil
+	}
+	text := string(raw)
+	if raw[0] == '"' {
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return decimal.Decimal{}, false, err
+		}
+	}
+	amount, err = parseAmount(text)
+	return amount, false, err
+}
+
+// totalCheck is the total a client expects the conversions of a batch to
+// add up to.
+type totalCheck struct {
+	expected  decimal.Decimal
+	currency  string
+	tolerance decimal.Decimal
+}
+
+// requestTotalCheck reads the expected_total, expected_currency and tolerance
+// parameters of a batch, returning nil without expected_total. On failure it
+// writes the error response and returns false.
+func requestTotalCheck(c *gin.Context) (*totalCheck, bool) {
+	expectedStr := c.Query("expected_total")
+	if expectedStr == "" {
+		return nil, true
+	}
+	expected, err := parseAmount(expectedStr)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Expected total "+err.Error(), "expected_total")
+		return nil, false
+	}
+	currency, ok := requestCurrency(c, "expected_currency")
+	if !ok {
+		return nil, false
+	}
+
+	// By default the total may be a minor unit off, for the rounding of
+	// each conversion
+	tolerance := decimal.New(1, -amountPlaces(currency))
+	if toleranceStr := c.Query("tolerance"); toleranceStr != "" {
+		tolerance, err = decimal.NewFromString(toleranceStr)
+		if err != nil || tolerance.IsNegative() {
+			respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Tolerance must be a decimal number of at least 0", "tolerance")
+			return nil, false
+		}
+	}
+	return &totalCheck{expected: expected, currency: currency, tolerance: tolerance}, true
+}
+
+// check adds up the converted amounts of conversions, converting those into
+// other currencies at the mid-market rate first, and reports whether the sum
+// is within the tolerance of the expected total. A rate that can't be
+// fetched fails the check with its error.
+func (t *totalCheck) check(c *gin.Context, adapter adapters.ExchangeRateAdapter, conversions []gin.H, raw bool) gin.H {
+	var pairs []adapters.Pair
+	seen := map[adapters.Pair]bool{}
+	for _, conversion := range conversions {
+		pair := adapters.Pair{From: conversion["to"].(string), To: t.currency}
+		if pair.From != pair.To && !seen[pair] {
+			seen[pair] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	rates := adapters.FetchPairs(c.Request.Context(), adapter, pairs, config.Int("EXCHANGER_BATCH_WORKERS", 8))
+
+	actual := decimal.Zero
+	for _, conversion := range conversions {
+		converted := conversion["converted"].(decimal.Decimal)
+		if from := conversion["to"].(string); from != t.currency {
+			rate := rates[adapters.Pair{From: from, To: t.currency}]
+			if rate.Err != nil {
+				_, body := rateErrorBody(c, rate.Err)
+				body["currency"], body["expected"] = t.currency, t.expected
+				return body
+			}
+			converted = convertAmount(converted, rate.Result.Rate, t.currency, raw)
+		}
+		actual = actual.Add(converted)
+	}
+
+	difference := actual.Sub(t.expected)
+	return gin.H{
+		"currency":   t.currency,
+		"expected":   t.expected,
+		"actual":     actual,
+		"difference": difference,
+		"tolerance":  t.tolerance,
+		"matches":    difference.Abs().LessThanOrEqual(t.tolerance),
+	}
+}
+
+// BatchExchangeHandler converts a JSON array of {amount, from, to} items in
+// one request. Each distinct pair is fetched once, concurrently, and every
+// item gets its own result or error in input order. Items without an amount
+// are rate lookups, converted for 1 but neither audited nor counted. With
+// expected_total the sum of the conversions is checked against it.
+func (s *Server) BatchExchangeHandler(c *gin.Context) {
+	adapter, source, ok := s.requestAdapter(c)
+	if !ok {
+		return
+	}
+
+	var items []batchItem
+	if err := c.ShouldBindJSON(&items); err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Request body must be a JSON array of {amount, from, to}", "body")
+		return
+	}
+	if maxItems := config.Int("EXCHANGER_BATCH_MAX_ITEMS", 1000); len(items) > maxItems {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("A batch may contain at most %d items", maxItems), "body")
+		return
+	}
+	expectedTotal, ok := requestTotalCheck(c)
+	if !ok {
+		return
+	}
+
+	// Normalize the currencies and collect the distinct pairs in first-seen order
+	var pairs []adapters.Pair
+	seen := map[adapters.Pair]bool{}
+	invalid := make([]error, len(items))
+	amounts := make([]decimal.Decimal, len(items))
+	rateOnly := make([]bool, len(items))
+	amountErrs := make([]error, len(items))
+	conversions := 0
+	for i, item := range items {
+		amounts[i], rateOnly[i], amountErrs[i] = batchAmount(item.Amount)
+		from, err := normalizeCurrency(item.From)
+		if err != nil {
+			invalid[i] = err
+			continue
+		}
+		to, err := normalizeCurrency(item.To)
+		if err != nil {
+			invalid[i] = err
+			continue
+		}
+		items[i].From, items[i].To = from, to
+
+		pair := adapters.Pair{From: from, To: to}
+		if amountErrs[i] == nil && !seen[pair] {
+			seen[pair] = true
+			pairs = append(pairs, pair)
+		}
+		if amountErrs[i] == nil && !rateOnly[i] {
+			conversions++
+		}
+	}
+	if !s.allowConversions(c, conversions) {
+		return
+	}
+
+	rates := adapters.FetchPairs(c.Request.Context(), adapter, pairs, config.Int("EXCHANGER_BATCH_WORKERS", 8))
+	raw := rawAmounts(c)
+	markupOf := s.requestMarkups(c)
+
+	results := make([]gin.H, len(items))
+	var audited []history.Conversion
+	var converted []gin.H
+	for i, item := range items {
+		if invalid[i] != nil {
+			results[i] = errorBody(pairErrorCode(invalid[i]), invalid[i].Error(), "", "")
+			results[i]["from"], results[i]["to"] = item.From, item.To
+			continue
+		}
+		if amountErrs[i] != nil {
+			results[i] = errorBody(CodeInvalidAmount, "Amount "+amountErrs[i].Error(), "amount", "")
+			results[i]["amount"], results[i]["from"], results[i]["to"] = item.Amount, item.From, item.To
+			continue
+		}
+		amount := amounts[i]
+
+		rate := rates[adapters.Pair{From: item.From, To: item.To}]
+		if rate.Err != nil {
+			_, results[i] = rateErrorBody(c, rate.Err)
+			results[i]["amount"], results[i]["from"], results[i]["to"] = amount, item.From, item.To
+			continue
+		}
+
+		results[i] = gin.H{
+			"amount":    amount,
+			"from":      rate.Result.Base,
+			"to":        rate.Result.Target,
+			"rate":      rate.Result.Rate,
+			"converted": convertAmount(amount, rate.Result.Rate, rate.Result.Target, raw),
+			"provider":  rate.Result.Provider,
+		}
+		if markup, ok := markupOf(rate.Result.Base, rate.Result.Target); ok {
+			effective := markup.effectiveRate(rate.Result.Rate)
+			fee := markup.fee(rate.Result.Base)
+			results[i]["midRate"] = rate.Result.Rate
+			results[i]["rate"] = effective
+			results[i]["converted"] = convertAmount(amount, effective, rate.Result.Target, raw)
+			results[i]["fee"] = fee
+			results[i]["total"] = amount.Add(fee)
+		}
+		if rateOnly[i] {
+			results[i]["rateOnly"] = true
+			continue
+		}
+		converted = append(converted, results[i])
+		fee, _ := results[i]["fee"].(decimal.Decimal)
+		audited = append(audited, auditedConversion(rate.Result, results[i]["rate"].(float64), amount,
+			results[i]["converted"].(decimal.Decimal), fee))
+	}
+
+	s.audit(c.Request.Context(), requestSource(c), audited...)
+	countConversions(c, len(audited))
+	response := gin.H{
+		"source":  source,
+		"results": results,
+	}
+	if expectedTotal != nil {
+		response["totalCheck"] = expectedTotal.check(c, adapter, converted, raw)
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+)
+
+// slowAdapter quotes USD to each of targets at its position plus one, taking
+// longer for the earlier ones, and tracks how many lookups are in flight.
+type slowAdapter struct {
+	stubAdapter
+	targets     []string
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	completed   []string
+}
+
+func (a *slowAdapter) GetRate(ctx context.Context, from, to string) (adapters.RateResult, error) {
+	a.mu.Lock()
+	a.inFlight++
+	if a.inFlight > a.maxInFlight {
+		a.maxInFlight = a.inFlight
+	}
+	a.mu.Unlock()
+
+	i := 0
+	for i < len(a.targets) && a.targets[i] != to {
+		i++
+	}
+	time.Sleep(time.Duration(len(a.targets)-i) * 5 * time.Millisecond)
+
+	a.mu.Lock()
+	a.inFlight--
+	a.completed = append(a.completed, to)
+	a.mu.Unlock()
+	return adapters.RateResult{Rate: float64(i + 1), Base: from, Target: to, Provider: "stub", Timestamp: time.Now()}, nil
+}
+
+func TestBatchMissingAmounts(t *testing.T) {
+	stub := &stubAdapter{rate: 4100}
+	router := newTestServer(t, stub, Options{})
+
+	response := serve(router, http.MethodPost, "/api/v1/exchange/batch?raw=true", `[
+		{"amount": 100, "from": "USD", "to": "KHR"},
+		{"from": "USD", "to": "KHR"},
+		{"amount": null, "from": "EUR", "to": "KHR"},
+		{"amount": "12.50", "from": "USD", "to": "KHR"},
+		{"amount": "abc", "from": "USD", "to": "KHR"},
+		{"amount": -5, "from": "USD", "to": "KHR"},
+		{"amount": true, "from": "USD", "to": "KHR"}
+	]`, nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", response.Code, response.Body)
+	}
+	results := decode(t, response)["results"].([]interface{})
+	if len(results) != 7 {
+		t.Fatalf("got %d results, want 7", len(results))
+	}
+
+	tests := []struct {
+		amount    float64
+		converted float64
+		rateOnly  bool
+	}{
+		{100, 410000, false},
+		{1, 4100, true},
+		{1, 4100, true},
+		{12.5, 51250, false},
+	}
+	for i, test := range tests {
+		result := results[i].(map[string]interface{})
+		if result["error"] != nil {
+			t.Errorf("item %d failed: %v", i, result["error"])
+			continue
+		}
+		if result["amount"] != test.amount || result["converted"] != test.converted || result["rate"] != 4100.0 {
+			t.Errorf("item %d = %v, want amount %v converted %v at 4100", i, result, test.amount, test.converted)
+		}
+		if rateOnly, _ := result["rateOnly"].(bool); rateOnly != test.rateOnly {
+			t.Errorf("item %d rateOnly = %v, want %v", i, rateOnly, test.rateOnly)
+		}
+	}
+	for i := 4; i < 7; i++ {
+		result := results[i].(map[string]interface{})
+		if result["code"] != CodeInvalidAmount || result["field"] != "amount" {
+			t.Errorf("item %d = %v, want an %s error on amount", i, result, CodeInvalidAmount)
+		}
+	}
+}
+
+func TestBatchWorkers(t *testing.T) {
+	t.Setenv("EXCHANGER_BATCH_WORKERS", "3")
+	stub := &slowAdapter{targets: []string{"EUR", "KHR", "JPY", "GBP", "AUD", "CAD", "CHF", "CNY", "THB", "SGD"}}
+	router := newTestServer(t, stub, Options{})
+
+	items := make([]string, len(stub.targets))
+	for i, target := range stub.targets {
+		items[i] = fmt.Sprintf(`{"amount": 10, "from": "USD", "to": %q}`, target)
+	}
+	response := serve(router, http.MethodPost, "/api/v1/exchange/batch?raw=true", "["+strings.Join(items, ",")+"]", nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", response.Code, response.Body)
+	}
+
+	if stub.maxInFlight != 3 {
+		t.Errorf("%d lookups were in flight at once, want the 3 workers", stub.maxInFlight)
+	}
+	if stub.completed[0] == stub.targets[0] {
+		t.Errorf("lookups completed in order %v, want the earlier pairs to finish last", stub.completed)
+	}
+	results := decode(t, response)["results"].([]interface{})
+	for i, target := range stub.targets {
+		result := results[i].(map[string]interface{})
+		if result["to"] != target || result["rate"] != float64(i+1) {
+			t.Errorf("item %d = %v, want %s at %d", i, result, target, i+1)
+		}
+	}
+}
+
+func TestBatchExpectedTotal(t *testing.T) {
+	router := newTestServer(t, &stubAdapter{rate: 4100}, Options{})
+	// 41000 KHR, plus 41000 USD converted at 4100 into 168100000 KHR; the
+	// rate lookup and the invalid item don't count
+	body := `[
+		{"amount": 10, "from": "USD", "to": "KHR"},
+		{"amount": 10, "from": "EUR", "to": "USD"},
+		{"from": "USD", "to": "KHR"},
+		{"amount": "abc", "from": "USD", "to": "KHR"}
+	]`
+
+	tests := []struct {
+		query      string
+		difference float64
+		matches    bool
+	}{
+		{"expected_total=168141000&expected_currency=KHR", 0, true},
+		{"expected_total=168141000.01&expected_currency=khr", -0.01, true},
+		{"expected_total=168140000&expected_currency=KHR", 1000, false},
+		{"expected_total=168140000&expected_currency=KHR&tolerance=1000", 1000, true},
+	}
+	for _, test := range tests {
+		response := serve(router, http.MethodPost, "/api/v1/exchange/batch?"+test.query, body, nil)
+		if response.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200: %s", test.query, response.Code, response.Body)
+			continue
+		}
+		check, _ := decode(t, response)["totalCheck"].(map[string]interface{})
+		if check["currency"] != "KHR" || check["actual"] != 168141000.0 {
+			t.Errorf("%s: totalCheck = %v, want 168141000 KHR", test.query, check)
+		}
+		if check["difference"] != test.difference || check["matches"] != test.matches {
+			t.Errorf("%s: difference %v matches %v, want %v %v", test.query, check["difference"], check["matches"], test.difference, test.matches)
+		}
+	}
+
+	if body := decode(t, serve(router, http.MethodPost, "/api/v1/exchange/batch", body, nil)); body["totalCheck"] != nil {
+		t.Errorf("totalCheck = %v without expected_total", body["totalCheck"])
+	}
+
+	rejected := []struct {
+		query string
+		code  string
+		field string
+	}{
+		{"expected_total=abc&expected_currency=KHR", CodeInvalidAmount, "expected_total"},
+		{"expected_total=-5&expected_currency=KHR", CodeInvalidAmount, "expected_total"},
+		{"expected_total=100", CodeInvalidCurrency, "expected_currency"},
+		{"expected_total=100&expected_currency=XYZ", CodeInvalidCurrency, "expected_currency"},
+		{"expected_total=100&expected_currency=KHR&tolerance=-1", CodeInvalidAmount, "tolerance"},
+	}
+	for _, test := range rejected {
+		response := serve(router, http.MethodPost, "/api/v1/exchange/batch?"+test.query, body, nil)
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400: %s", test.query, response.Code, response.Body)
+			continue
+		}
+		if body := decode(t, response); body["code"] != test.code || body["field"] != test.field {
+			t.Errorf("%s: error = %v, want %s on %s", test.query, body, test.code, test.field)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/history"
+
+	"github.com/gin-gonic/gin"
+)
+
+// changePeriods are the periods /rates/change compares against.
+var changePeriods = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// RateChangeHandler compares the latest rate of a pair with its rate a
+// period ago, e.g. /rates/change?from=USD&to=EUR&period=7d.
+func (s *Server) RateChangeHandler(c *gin.Context) {
+	countConversions(c, 0)
+	adapter, source, ok := s.requestAdapter(c)
+	if !ok {
+		return
+	}
+
+	from, ok := requestCurrency(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := requestCurrency(c, "to")
+	if !ok {
+		return
+	}
+	period := c.DefaultQuery("period", "24h")
+	length, ok := changePeriods[period]
+	if !ok {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid period, expected 24h, 7d or 30d", "period")
+		return
+	}
+
+	result, err := adapter.GetRate(c.Request.Context(), from, to)
+	if err != nil {
+		respondRateError(c, err)
+		return
+	}
+	logProvider(c, result.Provider)
+
+	start, err := s.rateAt(c, adapter, result.Provider, from, to, time.Now().UTC().Add(-length))
+	if err != nil {
+		respondRateError(c, err)
+		return
+	}
+
+	change := result.Rate - start.Rate
+	direction := "unchanged"
+	switch {
+	case change > 0:
+		direction = "up"
+	case change < 0:
+		direction = "down"
+	}
+	response := gin.H{
+		"source":         source,
+		"provider":       result.Provider,
+		"from":           from,
+		"to":             to,
+		"period":         period,
+		"rate":           result.Rate,
+		"timestamp":      result.Timestamp,
+		"startRate":      start.Rate,
+		"startTimestamp": start.Timestamp,
+		"change":         change,
+		"changePercent":  change / start.Rate * 100,
+		"direction":      direction,
+		"cached":         result.Cached,
+	}
+	if result.Stale {
+		response["stale"] = true
+		response["cacheAge"] = time.Since(result.FetchedAt).Seconds()
+	}
+
+	if notModified(c, rateMaxAge(result), result.Provider, from, to, period, result.Timestamp, result.Rate, start.Rate) {
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// rateAt returns the rate of from/to at at: the last one provider's history
+// recorded in the day before it, or else the provider's rate of that day.
+func (s *Server) rateAt(c *gin.Context, adapter adapters.ExchangeRateAdapter, provider, from, to string, at time.Time) (adapters.RateResult, error) {
+	if s.history != nil {
+		records, err := s.history.Records(history.Filter{
+			Provider: provider,
+			From:     from,
+			To:       to,
+			Since:    at.Add(-24 * time.Hour),
+			Until:    at,
+			Limit:    1,
+		})
+		if err != nil {
+			log.Printf("history: %s %s/%s at %s: %v", provider, from, to, at.Format(time.RFC3339), err)
+		}
+		if len(records) > 0 {
+			record := records[0]
+			return adapters.RateResult{Rate: record.Rate, Base: from, Target: to, Timestamp: record.Timestamp, Provider: provider}, nil
+		}
+	}
+	return adapter.GetExchangeRateAt(c.Request.Context(), from, to, at)
+}
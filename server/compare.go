@@ -0,0 +1,135 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// comparableSources lists every source the request can use: the keyless ones
+// and those with a key in the request or on the server.
+func (s *Server) comparableSources(c *gin.Context) string {
+	keys := c.QueryMap("key")
+	var usable []string
+	for _, name := range s.registry.Names() {
+		if s.registry.Keyless(name) || keys[name] != "" || c.Query("key") != "" || s.current().credentials.Key(name) != "" {
+			usable = append(usable, name)
+		}
+	}
+	return strings.Join(usable, ",")
+}
+
+// CompareExchangeHandler queries every configured provider in parallel for
+// the same pair and reports each quote with its latency, plus the best one:
+// the highest rate, which gives the most of the target currency.
+func (s *Server) CompareExchangeHandler(c *gin.Context) {
+	countConversions(c, 0)
+	source := c.Query("source")
+	if source == "" {
+		source = s.sources
+	}
+	if source == "" {
+		source = s.comparableSources(c)
+	}
+
+	providers, ok := s.requestProviders(c, source)
+	if !ok {
+		return
+	}
+
+	from, ok := requestCurrency(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := requestCurrency(c, "to")
+	if !ok {
+		return
+	}
+	amount := decimal.NewFromInt(1)
+	if amountStr := c.Query("amount"); amountStr != "" {
+		var err error
+		amount, err = parseAmount(amountStr)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Amount "+err.Error(), "amount")
+			return
+		}
+	}
+
+	raw := rawAmounts(c)
+
+	// Ask every provider at once; quotes keep the source order
+	quotes := make([]gin.H, len(providers))
+	results := make([]*adapters.RateResult, len(providers))
+	var wg sync.WaitGroup
+	for i, provider := range providers {
+		wg.Add(1)
+		go func(i int, provider adapters.NamedAdapter) {
+			defer wg.Done()
+
+			started := time.Now()
+			result, err := adapters.NewStale(provider.Adapter).GetRate(c.Request.Context(), from, to)
+			quote := gin.H{
+				"provider":  provider.Name,
+				"latencyMs": time.Since(started).Milliseconds(),
+			}
+			if err != nil {
+				_, body := rateErrorBody(c, err)
+				for name, value := range body {
+					quote[name] = value
+				}
+				quote["provider"] = provider.Name
+			} else {
+				quote["rate"] = result.Rate
+				quote["converted"] = convertAmount(amount, result.Rate, to, raw)
+				quote["timestamp"] = result.Timestamp
+				quote["cached"] = result.Cached
+				if result.Stale {
+					quote["stale"] = true
+				}
+				results[i] = &result
+			}
+			quotes[i] = quote
+		}(i, provider)
+	}
+	wg.Wait()
+
+	best := -1
+	for i, result := range results {
+		if result != nil && (best < 0 || result.Rate > results[best].Rate) {
+			best = i
+		}
+	}
+
+	response := gin.H{
+		"source": source,
+		"from":   from,
+		"to":     to,
+		"amount": amount,
+		"quotes": quotes,
+	}
+	if best < 0 {
+		for name, value := range errorBody(CodeProviderUnavailable, "all providers failed", "", "") {
+			response[name] = value
+		}
+		c.JSON(http.StatusBadGateway, response)
+		return
+	}
+
+	response["best"] = gin.H{
+		"provider":  quotes[best]["provider"],
+		"rate":      results[best].Rate,
+		"converted": convertAmount(amount, results[best].Rate, to, raw),
+	}
+	if c.Query("amount") != "" {
+		// The quotes compare one conversion; without an amount they are
+		// rates
+		countConversions(c, 1)
+	}
+	c.JSON(http.StatusOK, response)
+}
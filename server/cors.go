@@ -0,0 +1,119 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSOptions configures the CORS headers for browsers calling the API from
+// another origin.
+type CORSOptions struct {
+	// Origins lists the allowed origins, such as https://app.example.com.
+	// "*" allows any origin and https://*.example.com any subdomain. Empty
+	// disables CORS.
+	Origins []string
+	// Methods allowed in cross-origin requests; defaults to GET, POST and
+	// DELETE.
+	Methods []string
+	// Headers browsers may send; defaults to the ones the API reads.
+	Headers []string
+	// MaxAge is how long browsers may cache a preflight; defaults to ten
+	// minutes.
+	MaxAge time.Duration
+	// Credentials lets browsers send cookies and HTTP authentication. With
+	// "*" the requesting origin is echoed, as browsers require.
+	Credentials bool
+}
+
+// Defaults of CORSOptions.
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodDelete}
+	defaultCORSHeaders = []string{"Content-Type", "Authorization", "X-API-Key", "Idempotency-Key", "X-Idempotency-Key"}
+)
+
+// corsExposedHeaders are the response headers scripts may read besides the
+// ones browsers always expose.
+var corsExposedHeaders = strings.Join([]string{
+	"X-RateLimit-Limit", "X-RateLimit-Remaining", "Retry-After", "Deprecation", "Link", "Idempotent-Replayed", RequestIDHeader,
+}, ", ")
+
+// CORS answers preflight requests from allowed origins with 204 and adds the
+// CORS headers to their other requests. Requests from other origins pass
+// through without the headers, so browsers block their responses.
+func CORS(opts CORSOptions) gin.HandlerFunc {
+	if len(opts.Methods) == 0 {
+		opts.Methods = defaultCORSMethods
+	}
+	if len(opts.Headers) == 0 {
+		opts.Headers = defaultCORSHeaders
+	}
+	if opts.MaxAge == 0 {
+		opts.MaxAge = 10 * time.Minute
+	}
+	origins := make([]string, len(opts.Origins))
+	for i, origin := range opts.Origins {
+		origins[i] = strings.TrimSpace(origin)
+	}
+	methods := strings.Join(opts.Methods, ", ")
+	headers := strings.Join(opts.Headers, ", ")
+	maxAge := strconv.Itoa(int(opts.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Origin")
+		allowed, wildcard := corsAllowed(origins, origin)
+		if !allowed {
+			c.Next()
+			return
+		}
+
+		if wildcard && !opts.Credentials {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if opts.Credentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", headers)
+			c.Header("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Header("Access-Control-Expose-Headers", corsExposedHeaders)
+		c.Next()
+	}
+}
+
+// corsAllowed reports whether origin matches one of origins, and whether it
+// did so through "*".
+func corsAllowed(origins []string, origin string) (allowed, wildcard bool) {
+	for _, pattern := range origins {
+		switch {
+		case pattern == "*":
+			return true, true
+		case strings.EqualFold(pattern, origin):
+			return true, false
+		case strings.Contains(pattern, "://*."):
+			star := strings.Index(pattern, "*")
+			scheme, domain := pattern[:star], pattern[star+1:]
+			if strings.HasPrefix(origin, scheme) && strings.HasSuffix(origin, domain) && len(origin) > len(scheme)+len(domain) {
+				return true, false
+			}
+		}
+	}
+	return false, false
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+
+	"cubetiq-samples/exchanger-go/adapters"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Currency is the ISO 4217 metadata of a currency. Exponent is the number of
+// minor unit digits, e.g. 2 for USD cents and 0 for JPY.
+type Currency struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Exponent int    `json:"exponent"`
+}
+
+// CurrenciesHandler lists the ISO 4217 currencies with their names, symbols
+// and exponents. With a source, only the currencies that provider supports
+// are listed, and codes outside ISO 4217 (e.g. crypto or metals) are reported
+// under other with the provider's name.
+func (s *Server) CurrenciesHandler(c *gin.Context) {
+	countConversions(c, 0)
+	if c.Query("source") == "" {
+		c.JSON(http.StatusOK, gin.H{"currencies": allCurrencies()})
+		return
+	}
+
+	adapter, source, ok := s.requestAdapter(c)
+	if !ok {
+		return
+	}
+
+	symbols, err := adapters.FetchSymbols(c.Request.Context(), adapter)
+	if err != nil {
+		respondRateError(c, err)
+		return
+	}
+
+	currencies := []Currency{}
+	other := []gin.H{}
+	for code, name := range symbols {
+		if currency, ok := isoCurrencies[code]; ok {
+			currencies = append(currencies, currency)
+		} else {
+			other = append(other, gin.H{"code": code, "name": name})
+		}
+	}
+	sortCurrencies(currencies)
+	sort.Slice(other, func(i, j int) bool {
+		return other[i]["code"].(string) < other[j]["code"].(string)
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"source":     source,
+		"currencies": currencies,
+		"other":      other,
+	})
+}
+
+// allCurrencies returns the ISO 4217 currencies sorted by code.
+func allCurrencies() []Currency {
+	currencies := make([]Currency, 0, len(isoCurrencies))
+	for _, currency := range isoCurrencies {
+		currencies = append(currencies, currency)
+	}
+	sortCurrencies(currencies)
+	return currencies
+}
+
+// splitSymbols splits a provider's symbols into ISO 4217 currencies and the
+// other codes, both sorted by code. Other codes carry only the provider's
+// name.
+func splitSymbols(symbols map[string]string) (currencies, other []Currency) {
+	for code, name := range symbols {
+		if currency, ok := isoCurrencies[code]; ok {
+			currencies = append(currencies, currency)
+		} else {
+			other = append(other, Currency{Code: code, Name: name})
+		}
+	}
+	sortCurrencies(currencies)
+	sortCurrencies(other)
+	return currencies, other
+}
+
+func sortCurrencies(currencies []Currency) {
+	sort.Slice(currencies, func(i, j int) bool {
+		return currencies[i].Code < currencies[j].Code
+	})
+}
+
+// isoCurrencies holds the active ISO 4217 currencies keyed by code.
+var isoCurrencies = map[string]Currency{
+	"AED": {Code: "AED", Name: "UAE Dirham", Symbol: "د.إ", Exponent: 2},
+	"AFN": {Code: "AFN", Name: "Afghani", Symbol: "؋", Exponent: 2},
+	"ALL": {Code: "ALL", Name: "Lek", Symbol: "L", Exponent: 2},
+	"AMD": {Code: "AMD", Name: "Armenian Dram", Symbol: "֏", Exponent: 2},
+	"ANG": {Code: "ANG", Name: "Netherlands Antillean Guilder", Symbol: "ƒ", Exponent: 2},
+	"AOA": {Code: "AOA", Name: "Kwanza", Symbol: "Kz", Exponent: 2},
+	"ARS": {Code: "ARS", Name: "Argentine Peso", Symbol: "$", Exponent: 2},
+	"AUD": {Code: "AUD", Name: "Australian Dollar", Symbol: "A$", Exponent: 2},
+	"AWG": {Code: "AWG", Name: "Aruban Florin", Symbol: "ƒ", Exponent: 2},
+	"AZN": {Code: "AZN", Name: "Azerbaijan Manat", Symbol: "₼", Exponent: 2},
+	"BAM": {Code: "BAM", Name: "Convertible Mark", Symbol: "KM", Exponent: 2},
+	"BBD": {Code: "BBD", Name: "Barbados Dollar", Symbol: "$", Exponent: 2},
+	"BDT": {Code: "BDT", Name: "Taka", Symbol: "৳", Exponent: 2},
+	"BGN": {Code: "BGN", Name: "Bulgarian Lev", Symbol: "лв", Exponent: 2},
+	"BHD": {Code: "BHD", Name: "Bahraini Dinar", Symbol: ".د.ب", Exponent: 3},
+	"BIF": {Code: "BIF", Name: "Burundi Franc", Symbol: "FBu", Exponent: 0},
+	"BMD": {Code: "BMD", Name: "Bermudian Dollar", Symbol: "$", Exponent: 2},
+	"BND": {Code: "BND", Name: "Brunei Dollar", Symbol: "$", Exponent: 2},
+	"BOB": {Code: "BOB", Name: "Boliviano", Symbol: "Bs.", Exponent: 2},
+	"BRL": {Code: "BRL", Name: "Brazilian Real", Symbol: "R$", Exponent: 2},
+	"BSD": {Code: "BSD", Name: "Bahamian Dollar", Symbol: "$", Exponent: 2},
+	"BTN": {Code: "BTN", Name: "Ngultrum", Symbol: "Nu.", Exponent: 2},
+	"BWP": {Code: "BWP", Name: "Pula", Symbol: "P", Exponent: 2},
+	"BYN": {Code: "BYN", Name: "Belarusian Ruble", Symbol: "Br", Exponent: 2},
+	"BZD": {Code: "BZD", Name: "Belize Dollar", Symbol: "$", Exponent: 2},
+	"CAD": {Code: "CAD", Name: "Canadian Dollar", Symbol: "CA$", Exponent: 2},
+	"CDF": {Code: "CDF", Name: "Congolese Franc", Symbol: "FC", Exponent: 2},
+	"CHF": {Code: "CHF", Name: "Swiss Franc", Symbol: "CHF", Exponent: 2},
+	"CLF": {Code: "CLF", Name: "Unidad de Fomento", Symbol: "UF", Exponent: 4},
+	"CLP": {Code: "CLP", Name: "Chilean Peso", Symbol: "$", Exponent: 0},
+	"CNY": {Code: "CNY", Name: "Yuan Renminbi", Symbol: "¥", Exponent: 2},
+	"COP": {Code: "COP", Name: "Colombian Peso", Symbol: "$", Exponent: 2},
+	"CRC": {Code: "CRC", Name: "Costa Rican Colon", Symbol: "₡", Exponent: 2},
+	"CUP": {Code: "CUP", Name: "Cuban Peso", Symbol: "$", Exponent: 2},
+	"CVE": {Code: "CVE", Name: "Cabo Verde Escudo", Symbol: "$", Exponent: 2},
+	"CZK": {Code: "CZK", Name: "Czech Koruna", Symbol: "Kč", Exponent: 2},
+	"DJF": {Code: "DJF", Name: "Djibouti Franc", Symbol: "Fdj", Exponent: 0},
+	"DKK": {Code: "DKK", Name: "Danish Krone", Symbol: "kr", Exponent: 2},
+	"DOP": {Code: "DOP", Name: "Dominican Peso", Symbol: "$", Exponent: 2},
+	"DZD": {Code: "DZD", Name: "Algerian Dinar", Symbol: "د.ج", Exponent: 2},
+	"EGP": {Code: "EGP", Name: "Egyptian Pound", Symbol: "E£", Exponent: 2},
+	"ERN": {Code: "ERN", Name: "Nakfa", Symbol: "Nfk", Exponent: 2},
+	"ETB": {Code: "ETB", Name: "Ethiopian Birr", Symbol: "Br", Exponent: 2},
+	"EUR": {Code: "EUR", Name: "Euro", Symbol: "€", Exponent: 2},
+	"FJD": {Code: "FJD", Name: "Fiji Dollar", Symbol: "$", Exponent: 2},
+	"FKP": {Code: "FKP", Name: "Falkland Islands Pound", Symbol: "£", Exponent: 2},
+	"GBP": {Code: "GBP", Name: "Pound Sterling", Symbol: "£", Exponent: 2},
+	"GEL": {Code: "GEL", Name: "Lari", Symbol: "₾", Exponent: 2},
+	"GHS": {Code: "GHS", Name: "Ghana Cedi", Symbol: "GH₵", Exponent: 2},
+	"GIP": {Code: "GIP", Name: "Gibraltar Pound", Symbol: "£", Exponent: 2},
+	"GMD": {Code: "GMD", Name: "Dalasi", Symbol: "D", Exponent: 2},
+	"GNF": {Code: "GNF", Name: "Guinean Franc", Symbol: "FG", Exponent: 0},
+	"GTQ": {Code: "GTQ", Name: "Quetzal", Symbol: "Q", Exponent: 2},
+	"GYD": {Code: "GYD", Name: "Guyana Dollar", Symbol: "$", Exponent: 2},
+	"HKD": {Code: "HKD", Name: "Hong Kong Dollar", Symbol: "HK$", Exponent: 2},
+	"HNL": {Code: "HNL", Name: "Lempira", Symbol: "L", Exponent: 2},
+	"HTG": {Code: "HTG", Name: "Gourde", Symbol: "G", Exponent: 2},
+	"HUF": {Code: "HUF", Name: "Forint", Symbol: "Ft", Exponent: 2},
+	"IDR": {Code: "IDR", Name: "Rupiah", Symbol: "Rp", Exponent: 2},
+	"ILS": {Code: "ILS", Name: "New Israeli Sheqel", Symbol: "₪", Exponent: 2},
+	"INR": {Code: "INR", Name: "Indian Rupee", Symbol: "₹", Exponent: 2},
+	"IQD": {Code: "IQD", Name: "Iraqi Dinar", Symbol: "ع.د", Exponent: 3},
+	"IRR": {Code: "IRR", Name: "Iranian Rial", Symbol: "﷼", Exponent: 2},
+	"ISK": {Code: "ISK", Name: "Iceland Krona", Symbol: "kr", Exponent: 0},
+	"JMD": {Code: "JMD", Name: "Jamaican Dollar", Symbol: "$", Exponent: 2},
+	"JOD": {Code: "JOD", Name: "Jordanian Dinar", Symbol: "د.ا", Exponent: 3},
+	"JPY": {Code: "JPY", Name: "Yen", Symbol: "¥", Exponent: 0},
+	"KES": {Code: "KES", Name: "Kenyan Shilling", Symbol: "KSh", Exponent: 2},
+	"KGS": {Code: "KGS", Name: "Som", Symbol: "с", Exponent: 2},
+	"KHR": {Code: "KHR", Name: "Riel", Symbol: "៛", Exponent: 2},
+	"KMF": {Code: "KMF", Name: "Comorian Franc", Symbol: "CF", Exponent: 0},
+	"KPW": {Code: "KPW", Name: "North Korean Won", Symbol: "₩", Exponent: 2},
+	"KRW": {Code: "KRW", Name: "Won", Symbol: "₩", Exponent: 0},
+	"KWD": {Code: "KWD", Name: "Kuwaiti Dinar", Symbol: "د.ك", Exponent: 3},
+	"KYD": {Code: "KYD", Name: "Cayman Islands Dollar", Symbol: "$", Exponent: 2},
+	"KZT": {Code: "KZT", Name: "Tenge", Symbol: "₸", Exponent: 2},
+	"LAK": {Code: "LAK", Name: "Lao Kip", Symbol: "₭", Exponent: 2},
+	"LBP": {Code: "LBP", Name: "Lebanese Pound", Symbol: "ل.ل", Exponent: 2},
+	"LKR": {Code: "LKR", Name: "Sri Lanka Rupee", Symbol: "Rs", Exponent: 2},
+	"LRD": {Code: "LRD", Name: "Liberian Dollar", Symbol: "$", Exponent: 2},
+	"LSL": {Code: "LSL", Name: "Loti", Symbol: "L", Exponent: 2},
+	"LYD": {Code: "LYD", Name: "Libyan Dinar", Symbol: "ل.د", Exponent: 3},
+	"MAD": {Code: "MAD", Name: "Moroccan Dirham", Symbol: "د.م.", Exponent: 2},
+	"MDL": {Code: "MDL", Name: "Moldovan Leu", Symbol: "L", Exponent: 2},
+	"MGA": {Code: "MGA", Name: "Malagasy Ariary", Symbol: "Ar", Exponent: 2},
+	"MKD": {Code: "MKD", Name: "Denar", Symbol: "ден", Exponent: 2},
+	"MMK": {Code: "MMK", Name: "Kyat", Symbol: "K", Exponent: 2},
+	"MNT": {Code: "MNT", Name: "Tugrik", Symbol: "₮", Exponent: 2},
+	"MOP": {Code: "MOP", Name: "Pataca", Symbol: "MOP$", Exponent: 2},
+	"MRU": {Code: "MRU", Name: "Ouguiya", Symbol: "UM", Exponent: 2},
+	"MUR": {Code: "MUR", Name: "Mauritius Rupee", Symbol: "₨", Exponent: 2},
+	"MVR": {Code: "MVR", Name: "Rufiyaa", Symbol: "Rf", Exponent: 2},
+	"MWK": {Code: "MWK", Name: "Malawi Kwacha", Symbol: "MK", Exponent: 2},
+	"MXN": {Code: "MXN", Name: "Mexican Peso", Symbol: "MX$", Exponent: 2},
+	"MYR": {Code: "MYR", Name: "Malaysian Ringgit", Symbol: "RM", Exponent: 2},
+	"MZN": {Code: "MZN", Name: "Mozambique Metical", Symbol: "MT", Exponent: 2},
+	"NAD": {Code: "NAD", Name: "Namibia Dollar", Symbol: "$", Exponent: 2},
+	"NGN": {Code: "NGN", Name: "Naira", Symbol: "₦", Exponent: 2},
+	"NIO": {Code: "NIO", Name: "Cordoba Oro", Symbol: "C$", Exponent: 2},
+	"NOK": {Code: "NOK", Name: "Norwegian Krone", Symbol: "kr", Exponent: 2},
+	"NPR": {Code: "NPR", Name: "Nepalese Rupee", Symbol: "₨", Exponent: 2},
+	"NZD": {Code: "NZD", Name: "New Zealand Dollar", Symbol: "NZ$", Exponent: 2},
+	"OMR": {Code: "OMR", Name: "Rial Omani", Symbol: "ر.ع.", Exponent: 3},
+	"PAB": {Code: "PAB", Name: "Balboa", Symbol: "B/.", Exponent: 2},
+	"PEN": {Code: "PEN", Name: "Sol", Symbol: "S/", Exponent: 2},
+	"PGK": {Code: "PGK", Name: "Kina", Symbol: "K", Exponent: 2},
+	"PHP": {Code: "PHP", Name: "Philippine Peso", Symbol: "₱", Exponent: 2},
+	"PKR": {Code: "PKR", Name: "Pakistan Rupee", Symbol: "₨", Exponent: 2},
+	"PLN": {Code: "PLN", Name: "Zloty", Symbol: "zł", Exponent: 2},
+	"PYG": {Code: "PYG", Name: "Guarani", Symbol: "₲", Exponent: 0},
+	"QAR": {Code: "QAR", Name: "Qatari Rial", Symbol: "ر.ق", Exponent: 2},
+	"RON": {Code: "RON", Name: "Romanian Leu", Symbol: "lei", Exponent: 2},
+	"RSD": {Code: "RSD", Name: "Serbian Dinar", Symbol: "дин.", Exponent: 2},
+	"RUB": {Code: "RUB", Name: "Russian Ruble", Symbol: "₽", Exponent: 2},
+	"RWF": {Code: "RWF", Name: "Rwanda Franc", Symbol: "FRw", Exponent: 0},
+	"SAR": {Code: "SAR", Name: "Saudi Riyal", Symbol: "ر.س", Exponent: 2},
+	"SBD": {Code: "SBD", Name: "Solomon Islands Dollar", Symbol: "$", Exponent: 2},
+	"SCR": {Code: "SCR", Name: "Seychelles Rupee", Symbol: "₨", Exponent: 2},
+	"SDG": {Code: "SDG", Name: "Sudanese Pound", Symbol: "ج.س.", Exponent: 2},
+	"SEK": {Code: "SEK", Name: "Swedish Krona", Symbol: "kr", Exponent: 2},
+	"SGD": {Code: "SGD", Name: "Singapore Dollar", Symbol: "S$", Exponent: 2},
+	"SHP": {Code: "SHP", Name: "Saint Helena Pound", Symbol: "£", Exponent: 2},
+	"SLE": {Code: "SLE", Name: "Leone", Symbol: "Le", Exponent: 2},
+	"SOS": {Code: "SOS", Name: "Somali Shilling", Symbol: "Sh", Exponent: 2},
+	"SRD": {Code: "SRD", Name: "Surinam Dollar", Symbol: "$", Exponent: 2},
+	"SSP": {Code: "SSP", Name: "South Sudanese Pound", Symbol: "£", Exponent: 2},
+	"STN": {Code: "STN", Name: "Dobra", Symbol: "Db", Exponent: 2},
+	"SVC": {Code: "SVC", Name: "El Salvador Colon", Symbol: "₡", Exponent: 2},
+	"SYP": {Code: "SYP", Name: "Syrian Pound", Symbol: "£", Exponent: 2},
+	"SZL": {Code: "SZL", Name: "Lilangeni", Symbol: "L", Exponent: 2},
+	"THB": {Code: "THB", Name: "Baht", Symbol: "฿", Exponent: 2},
+	"TJS": {Code: "TJS", Name: "Somoni", Symbol: "SM", Exponent: 2},
+	"TMT": {Code: "TMT", Name: "Turkmenistan New Manat", Symbol: "m", Exponent: 2},
+	"TND": {Code: "TND", Name: "Tunisian Dinar", Symbol: "د.ت", Exponent: 3},
+	"TOP": {Code: "TOP", Name: "Pa’anga", Symbol: "T$", Exponent: 2},
+	"TRY": {Code: "TRY", Name: "Turkish Lira", Symbol: "₺", Exponent: 2},
+	"TTD": {Code: "TTD", Name: "Trinidad and Tobago Dollar", Symbol: "$", Exponent: 2},
+	"TWD": {Code: "TWD", Name: "New Taiwan Dollar", Symbol: "NT$", Exponent: 2},
+	"TZS": {Code: "TZS", Name: "Tanzanian Shilling", Symbol: "TSh", Exponent: 2},
+	"UAH": {Code: "UAH", Name: "Hryvnia", Symbol: "₴", Exponent: 2},
+	"UGX": {Code: "UGX", Name: "Uganda Shilling", Symbol: "USh", Exponent: 0},
+	"USD": {Code: "USD", Name: "US Dollar", Symbol: "$", Exponent: 2},
+	"UYU": {Code: "UYU", Name: "Peso Uruguayo", Symbol: "$U", Exponent: 2},
+	"UZS": {Code: "UZS", Name: "Uzbekistan Sum", Symbol: "soʻm", Exponent: 2},
+	"VES": {Code: "VES", Name: "Bolívar Soberano", Symbol: "Bs.S", Exponent: 2},
+	"VND": {Code: "VND", Name: "Dong", Symbol: "₫", Exponent: 0},
+	"VUV": {Code: "VUV", Name: "Vatu", Symbol: "VT", Exponent: 0},
+	"WST": {Code: "WST", Name: "Tala", Symbol: "WS$", Exponent: 2},
+	"XAF": {Code: "XAF", Name: "CFA Franc BEAC", Symbol: "FCFA", Exponent: 0},
+	"XCD": {Code: "XCD", Name: "East Caribbean Dollar", Symbol: "EC$", Exponent: 2},
+	"XOF": {Code: "XOF", Name: "CFA Franc BCEAO", Symbol: "CFA", Exponent: 0},
+	"XPF": {Code: "XPF", Name: "CFP Franc", Symbol: "₣", Exponent: 0},
+	"YER": {Code: "YER", Name: "Yemeni Rial", Symbol: "﷼", Exponent: 2},
+	"ZAR": {Code: "ZAR", Name: "Rand", Symbol: "R", Exponent: 2},
+	"ZMW": {Code: "ZMW", Name: "Zambian Kwacha", Symbol: "ZK", Exponent: 2},
+	"ZWL": {Code: "ZWL", Name: "Zimbabwe Dollar", Symbol: "$", Exponent: 2},
+}
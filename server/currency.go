@@ -0,0 +1,244 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currencyAliases maps common informal codes to the ISO 4217 code of the
+// same currency.
+var currencyAliases = map[string]string{
+	"RMB": "CNY",
+	"NTD": "TWD",
+	"NIS": "ILS",
+}
+
+// withdrawnCurrencies maps withdrawn codes to the code of the currency that
+// replaced them. They were redenominations, so an amount in the old code is
+// not worth the same in the new one and they are rejected, not aliased.
+var withdrawnCurrencies = map[string]string{
+	"BYR": "BYN",
+	"GHC": "GHS",
+	"MRO": "MRU",
+	"SLL": "SLE",
+	"STD": "STN",
+	"VEF": "VES",
+	"ZMK": "ZMW",
+}
+
+// metalCodes are the ISO 4217 precious metal codes, which have no minor unit.
+var metalCodes = []string{"XAU", "XAG", "XPT", "XPD"}
+
+// UnknownCurrencyError reports a currency the registry doesn't know, with the
+// closest known codes.
+type UnknownCurrencyError struct {
+	Input       string
+	Suggestions []string
+	// Replacement is the code that replaced a withdrawn currency.
+	Replacement string
+}
+
+func (e *UnknownCurrencyError) Error() string {
+	if e.Replacement != "" {
+		return fmt.Sprintf("Currency %q was withdrawn and replaced by %s", e.Input, e.Replacement)
+	}
+	if len(e.Suggestions) == 0 {
+		return fmt.Sprintf("Unknown currency %q", e.Input)
+	}
+	return fmt.Sprintf("Unknown currency %q, did you mean %s?", e.Input, strings.Join(e.Suggestions, ", "))
+}
+
+// knownCurrency reports whether code is an ISO 4217 currency, a metal, a
+// supported cryptocurrency or listed in EXCHANGER_EXTRA_CURRENCIES.
+func knownCurrency(code string) bool {
+	if _, ok := isoCurrencies[code]; ok {
+		return true
+	}
+	if _, ok := adapters.CanonicalCrypto(code); ok {
+		return true
+	}
+	for _, metal := range metalCodes {
+		if code == metal {
+			return true
+		}
+	}
+	for _, extra := range strings.Split(os.Getenv("EXCHANGER_EXTRA_CURRENCIES"), ",") {
+		if strings.EqualFold(strings.TrimSpace(extra), code) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeCurrency turns user input such as "usd", "RMB", "XBT" or
+// "US Dollar" into a known currency code.
+func normalizeCurrency(input string) (string, error) {
+	code := strings.ToUpper(strings.TrimSpace(input))
+	if alias, ok := currencyAliases[code]; ok {
+		code = alias
+	}
+	if replacement, ok := withdrawnCurrencies[code]; ok {
+		return "", &UnknownCurrencyError{Input: input, Suggestions: []string{replacement}, Replacement: replacement}
+	}
+	if crypto, ok := adapters.CanonicalCrypto(code); ok {
+		code = crypto
+	}
+	if knownCurrency(code) {
+		return code, nil
+	}
+
+	// Accept the ISO name of a currency in place of its code
+	for _, currency := range isoCurrencies {
+		if strings.EqualFold(currency.Name, strings.TrimSpace(input)) {
+			return currency.Code, nil
+		}
+	}
+
+	return "", &UnknownCurrencyError{Input: input, Suggestions: closeCurrencies(code)}
+}
+
+// NormalizeCurrency is normalizeCurrency for programs that take currencies
+// without the HTTP layer; unknown ones fail with an *UnknownCurrencyError.
+func NormalizeCurrency(input string) (string, error) {
+	return normalizeCurrency(input)
+}
+
+// normalizePair normalizes the currencies of a conversion.
+func normalizePair(from, to string) (string, string, error) {
+	from, err := normalizeCurrency(from)
+	if err != nil {
+		return "", "", err
+	}
+	to, err = normalizeCurrency(to)
+	if err != nil {
+		return "", "", err
+	}
+	return from, to, nil
+}
+
+// closeCurrencies returns up to five codes within one edit of code, or whose
+// name contains it, nearest first.
+func closeCurrencies(code string) []string {
+	type match struct {
+		code     string
+		distance int
+	}
+	var matches []match
+	for _, currency := range isoCurrencies {
+		if distance := editDistance(code, currency.Code); distance <= 1 {
+			matches = append(matches, match{currency.Code, distance})
+		} else if len(code) > 3 && strings.Contains(strings.ToUpper(currency.Name), code) {
+			matches = append(matches, match{currency.Code, 2})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].code < matches[j].code
+	})
+
+	suggestions := []string{}
+	for i := 0; i < len(matches) && i < 5; i++ {
+		suggestions = append(suggestions, matches[i].code)
+	}
+	return suggestions
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// requestCurrency reads and normalizes the currency in query parameter name.
+// On failure it writes the error response and returns false.
+func requestCurrency(c *gin.Context, name string) (string, bool) {
+	input := c.Query(name)
+	if strings.TrimSpace(input) == "" {
+		respondError(c, http.StatusBadRequest, CodeInvalidCurrency, "Currency is required", name)
+		return "", false
+	}
+
+	code, err := normalizeCurrency(input)
+	if err != nil {
+		respondCurrencyError(c, name, err)
+		return "", false
+	}
+	return code, true
+}
+
+// requestCurrencies reads and normalizes a comma-separated currency list in
+// query parameter name. On failure it writes the error response and returns
+// false.
+func requestCurrencies(c *gin.Context, name string) ([]string, bool) {
+	inputs := splitCurrencies(c.Query(name))
+	if len(inputs) == 0 {
+		respondError(c, http.StatusBadRequest, CodeInvalidCurrency, "Currency is required", name)
+		return nil, false
+	}
+
+	var codes []string
+	seen := map[string]bool{}
+	for _, input := range inputs {
+		code, err := normalizeCurrency(input)
+		if err != nil {
+			respondCurrencyError(c, name, err)
+			return nil, false
+		}
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return codes, true
+}
+
+// respondCurrencyError writes the 400 response for an unknown currency.
+func respondCurrencyError(c *gin.Context, name string, err error) {
+	c.Error(err)
+	metrics.ConversionErrors.WithLabelValues("invalid_currency").Inc()
+	response := errorBody(CodeInvalidCurrency, err.Error(), name, "")
+	var unknown *UnknownCurrencyError
+	if errors.As(err, &unknown) {
+		response["suggestions"] = unknown.Suggestions
+		if unknown.Replacement != "" {
+			response["replacement"] = unknown.Replacement
+		}
+	}
+	c.JSON(http.StatusBadRequest, response)
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"cubetiq-samples/exchanger-go/adapters"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes of the error envelope. They are part of the API: clients
+// branch on them, so they never change once documented.
+const (
+	CodeInvalidRequest         = "INVALID_REQUEST"
+	CodeInvalidCurrency        = "INVALID_CURRENCY"
+	CodeInvalidAmount          = "INVALID_AMOUNT"
+	CodeInvalidDate            = "INVALID_DATE"
+	CodeInvalidSource          = "INVALID_SOURCE"
+	CodeProviderKeyRequired    = "PROVIDER_KEY_REQUIRED"
+	CodeUnauthenticated        = "UNAUTHENTICATED"
+	CodeForbidden              = "FORBIDDEN"
+	CodeNotFound               = "NOT_FOUND"
+	CodeConflict               = "CONFLICT"
+	CodeRequestInProgress      = "REQUEST_IN_PROGRESS"
+	CodeQuotaExceeded          = "QUOTA_EXCEEDED"
+	CodeRateLimited            = "RATE_LIMITED"
+	CodeNotSupported           = "NOT_SUPPORTED"
+	CodeFeatureDisabled        = "FEATURE_DISABLED"
+	CodeProviderAuthFailed     = "PROVIDER_AUTH_FAILED"
+	CodeProviderQuotaExceeded  = "PROVIDER_QUOTA_EXCEEDED"
+	CodeProviderError          = "PROVIDER_ERROR"
+	CodeProviderUnavailable    = "PROVIDER_UNAVAILABLE"
+	CodeProviderSchemaMismatch = "PROVIDER_SCHEMA_MISMATCH"
+	CodeUpstreamTimeout        = "UPSTREAM_TIMEOUT"
+	CodeMaintenance            = "MAINTENANCE"
+	CodeServiceUnavailable     = "SERVICE_UNAVAILABLE"
+	CodeInternal               = "INTERNAL"
+)
+
+// retriableCodes are the codes of errors that may go away when the same
+// request is sent again later.
+var retriableCodes = map[string]bool{
+	CodeRequestInProgress:     true,
+	CodeRateLimited:           true,
+	CodeProviderQuotaExceeded: true,
+	CodeProviderError:         true,
+	CodeProviderUnavailable:   true,
+	CodeUpstreamTimeout:       true,
+	CodeMaintenance:           true,
+	CodeServiceUnavailable:    true,
+}
+
+// errorBody builds the error envelope: code, message, the field it is about
+// and the provider that failed, when there is one, and whether retrying may
+// help. "error" and "name" repeat the message and field for clients written
+// before the envelope.
+func errorBody(code, message, field, provider string) gin.H {
+	body := gin.H{"code": code, "message": message, "retriable": retriableCodes[code], "error": message}
+	if field != "" {
+		body["field"] = field
+		body["name"] = field
+	}
+	if provider != "" {
+		body["provider"] = provider
+	}
+	return body
+}
+
+// respondError writes an error envelope.
+func respondError(c *gin.Context, status int, code, message, field string) {
+	c.JSON(status, errorBody(code, message, field, ""))
+}
+
+// abortError writes an error envelope and stops the handler chain.
+func abortError(c *gin.Context, status int, code, message, field string) {
+	c.AbortWithStatusJSON(status, errorBody(code, message, field, ""))
+}
+
+// sourceKey holds the source of a request that asked a single provider, to
+// name it in errors that don't.
+const sourceKey = "source"
+
+// rateError classifies a failed rate lookup into the status and code to
+// answer it with. A provider rejecting the key is the client's fault when the
+// client sent the key, and the server's otherwise.
+func rateError(c *gin.Context, err error) (int, string) {
+	var schemaErr *adapters.SchemaMismatchError
+	var statusErr *adapters.UpstreamStatusError
+	switch {
+	case errors.Is(err, adapters.ErrProviderCurrency):
+		return http.StatusBadRequest, CodeInvalidCurrency
+	case errors.Is(err, adapters.ErrNoRatesOnDate):
+		return http.StatusNotFound, CodeNotFound
+	case errors.Is(err, adapters.ErrCircuitOpen):
+		return http.StatusServiceUnavailable, CodeProviderUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, CodeUpstreamTimeout
+	case errors.As(err, &schemaErr):
+		return http.StatusBadGateway, CodeProviderSchemaMismatch
+	case errors.Is(err, adapters.ErrHistoricalNotSupported), errors.Is(err, adapters.ErrRateTableNotSupported),
+		errors.Is(err, adapters.ErrSymbolsNotSupported), errors.Is(err, adapters.ErrProviderPlan):
+		return http.StatusNotImplemented, CodeNotSupported
+	case errors.Is(err, adapters.ErrProviderQuota):
+		return http.StatusServiceUnavailable, CodeProviderQuotaExceeded
+	case errors.Is(err, adapters.ErrProviderAuth):
+		return providerAuthStatus(c), CodeProviderAuthFailed
+	case errors.As(err, &statusErr):
+		switch {
+		case statusErr.StatusCode == http.StatusUnauthorized, statusErr.StatusCode == http.StatusForbidden:
+			return providerAuthStatus(c), CodeProviderAuthFailed
+		case statusErr.StatusCode == http.StatusTooManyRequests:
+			return http.StatusServiceUnavailable, CodeProviderQuotaExceeded
+		case statusErr.StatusCode >= 500:
+			return http.StatusServiceUnavailable, CodeProviderUnavailable
+		}
+	}
+	return http.StatusBadGateway, CodeProviderError
+}
+
+func providerAuthStatus(c *gin.Context) int {
+	if c.Query("key") != "" || len(c.QueryMap("key")) > 0 {
+		return http.StatusUnauthorized
+	}
+	return http.StatusBadGateway
+}
+
+// rateErrorBody is the envelope of a failed rate lookup, naming the provider
+// the error came from, or else the request's single source.
+func rateErrorBody(c *gin.Context, err error) (int, gin.H) {
+	status, code := rateError(c, err)
+	field := ""
+	switch code {
+	case CodeInvalidCurrency:
+		field = "currency"
+	case CodeNotSupported:
+		field = "source"
+	case CodeProviderAuthFailed:
+		if status == http.StatusUnauthorized {
+			field = "key"
+		}
+	}
+	return status, errorBody(code, err.Error(), field, errorProvider(c, err))
+}
+
+func errorProvider(c *gin.Context, err error) string {
+	var providerErr *adapters.ProviderError
+	var schemaErr *adapters.SchemaMismatchError
+	switch {
+	case errors.As(err, &providerErr):
+		return providerErr.Provider
+	case errors.As(err, &schemaErr):
+		return schemaErr.Provider
+	}
+	return c.GetString(sourceKey)
+}
+
+// pairErrorCode is the code of an invalid pair: INVALID_CURRENCY when it
+// names an unknown currency, INVALID_REQUEST when it is malformed.
+func pairErrorCode(err error) string {
+	var unknown *UnknownCurrencyError
+	if errors.As(err, &unknown) {
+		return CodeInvalidCurrency
+	}
+	return CodeInvalidRequest
+}
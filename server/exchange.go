@@ -0,0 +1,258 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// MoneyExchangeHandler converts an amount between currencies, e.g.
+// /api/v1/exchange?amount=100&from=USD&to=EUR.
+func (s *Server) MoneyExchangeHandler(c *gin.Context) {
+	adapter, source, ok := s.requestAdapter(c)
+	if !ok {
+		return
+	}
+
+	// Perform currency conversion using the selected adapter
+	amountStr := c.Query("amount")
+	from, ok := requestCurrency(c, "from")
+	if !ok {
+		return
+	}
+	targets, ok := requestCurrencies(c, "to")
+	if !ok {
+		return
+	}
+	format, ok := requestFormatter(c)
+	if !ok {
+		return
+	}
+
+	var amount, targetAmount, percent, reference decimal.Decimal
+	var err error
+	// A target_amount asks for the amount of from that converts into it
+	targetAmountStr := c.Query("target_amount")
+	isReverse := targetAmountStr != ""
+	isPercentage := strings.HasSuffix(amountStr, "%")
+	// An amount_minor gives the amount in from's minor units, e.g. cents,
+	// and asks for the converted amounts in those of to
+	amountMinorStr := c.Query("amount_minor")
+	isMinor := amountMinorStr != ""
+	if isMinor && (amountStr != "" || isReverse) {
+		respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Pass only one of amount, amount_minor and target_amount", "amount_minor")
+		return
+	}
+	if isMinor && rawAmounts(c) {
+		respondError(c, http.StatusBadRequest, CodeInvalidAmount, "amount_minor answers rounded minor units and can't be combined with raw", "raw")
+		return
+	}
+	if isReverse {
+		if amountStr != "" {
+			respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Pass either amount or target_amount, not both", "target_amount")
+			return
+		}
+		if len(targets) > 1 {
+			respondError(c, http.StatusBadRequest, CodeInvalidCurrency, "target_amount takes a single to currency", "to")
+			return
+		}
+		targetAmount, err = parseAmount(targetAmountStr)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Target amount "+err.Error(), "target_amount")
+			return
+		}
+	} else if isMinor {
+		amount, err = parseMinorAmount(amountMinorStr, from)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Amount "+err.Error(), "amount_minor")
+			return
+		}
+	} else if isPercentage {
+		// A percentage amount is taken of the reference amount
+		percent, err = parseAmount(strings.TrimSuffix(amountStr, "%"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Percentage "+err.Error(), "amount")
+			return
+		}
+
+		referenceStr := c.Query("reference")
+		if referenceStr == "" {
+			respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Reference amount is required for percentage amounts", "reference")
+			return
+		}
+
+		reference, err = parseAmount(referenceStr)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Reference amount "+err.Error(), "reference")
+			return
+		}
+
+		amount = reference.Mul(percent).Div(decimal.NewFromInt(100))
+		if err := checkAmount(amount); err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Amount "+err.Error(), "amount")
+			return
+		}
+	} else {
+		if amountStr == "" {
+			respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Amount is required", "amount")
+			return
+		}
+		amount, err = parseAmount(amountStr)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Amount "+err.Error(), "amount")
+			return
+		}
+	}
+
+	// A comma-separated to converts into every listed currency at once
+	if len(targets) > 1 {
+		if !s.allowConversions(c, len(targets)) {
+			return
+		}
+		response, results, failed, err := multiExchange(c.Request.Context(), adapter, amount, from, targets, rawAmounts(c), s.requestMarkups(c))
+		if err != nil {
+			respondRateError(c, err)
+			return
+		}
+		response["source"] = source
+		// Targets that failed carry their error envelope, and the others
+		// still convert
+		response["partial"] = len(failed) > 0
+		if len(failed) > 0 {
+			errs := make(map[string]gin.H, len(failed))
+			for target, err := range failed {
+				_, errs[target] = rateErrorBody(c, err)
+			}
+			response["errors"] = errs
+		}
+		logProvider(c, response["provider"].(string))
+		if isPercentage {
+			response["percentage"] = percent
+			response["reference"] = reference
+		}
+		if isMinor {
+			response["amountMinor"] = minorUnits(amount, from)
+			response["convertedMinor"] = minorAmounts(response["converted"].(map[string]decimal.Decimal))
+		}
+		if format != nil {
+			response["formatted"] = formatAmounts(format, response["converted"].(map[string]decimal.Decimal))
+		}
+		if verboseAmounts(c) {
+			fees, _ := response["fees"].(map[string]decimal.Decimal)
+			effective := map[string]float64{}
+			for target, converted := range response["converted"].(map[string]decimal.Decimal) {
+				effective[target] = effectiveRate(amount, fees[target], converted)
+			}
+			response["effectiveRates"] = effective
+		}
+		s.audit(c.Request.Context(), requestSource(c), multiConversions(response, results)...)
+		countConversions(c, len(results))
+		if notModified(c, rateMaxAge(rateValues(results)...), response["provider"], from, response["timestamp"], response["rates"], response["fees"], response["formatted"]) {
+			return
+		}
+		respond(c, http.StatusOK, response)
+		return
+	}
+
+	result, err := adapter.GetRate(c.Request.Context(), from, targets[0])
+	if err != nil {
+		respondRateError(c, err)
+		return
+	}
+	logProvider(c, result.Provider)
+
+	markup, hasMarkup := s.requestMarkups(c)(result.Base, result.Target)
+	if isReverse {
+		// Solve at the rate the conversion is made at, the customer rate when
+		// marked up
+		rate := result.Rate
+		if hasMarkup {
+			rate = markup.effectiveRate(rate)
+		}
+		// A rate that isn't positive can't be solved for, and isn't a quote
+		if rate <= 0 {
+			respondRateError(c, fmt.Errorf("%s answered a rate of %v for %s/%s", result.Provider, rate, result.Base, result.Target))
+			return
+		}
+		amount = sourceAmount(targetAmount, rate, result.Base, result.Target, rawAmounts(c))
+	}
+
+	response := gin.H{
+		"source":    source,
+		"provider":  result.Provider,
+		"from":      result.Base,
+		"to":        result.Target,
+		"amount":    amount,
+		"rate":      result.Rate,
+		"converted": convertAmount(amount, result.Rate, result.Target, rawAmounts(c)),
+		"timestamp": result.Timestamp,
+		"cached":    result.Cached,
+	}
+	if hasMarkup {
+		// Quote the customer rate and keep the mid-market one for reference
+		rate := markup.effectiveRate(result.Rate)
+		fee := markup.fee(result.Base)
+		response["midRate"] = result.Rate
+		response["rate"] = rate
+		response["converted"] = convertAmount(amount, rate, result.Target, rawAmounts(c))
+		response["markupPercent"] = markup.Percent
+		response["fee"] = fee
+		response["total"] = amount.Add(fee)
+	}
+	if verboseAmounts(c) {
+		fee, _ := response["fee"].(decimal.Decimal)
+		response["effectiveRate"] = effectiveRate(amount, fee, response["converted"].(decimal.Decimal))
+	}
+	if isPercentage {
+		response["percentage"] = percent
+		response["reference"] = reference
+	}
+	if isReverse {
+		response["targetAmount"] = targetAmount
+	}
+	if isMinor {
+		response["amountMinor"] = minorUnits(amount, result.Base)
+		response["convertedMinor"] = minorUnits(response["converted"].(decimal.Decimal), result.Target)
+		if hasMarkup {
+			response["feeMinor"] = minorUnits(response["fee"].(decimal.Decimal), result.Base)
+			response["totalMinor"] = minorUnits(response["total"].(decimal.Decimal), result.Base)
+		}
+	}
+	if format != nil {
+		response["formatted"] = format(response["converted"].(decimal.Decimal), result.Target)
+	}
+	if result.Date != "" {
+		response["date"] = result.Date
+	}
+	if result.Cached {
+		response["cacheAge"] = time.Since(result.FetchedAt).Seconds()
+	}
+	if result.Stale {
+		response["stale"] = true
+	}
+	if result.Quality != "" {
+		response["quality"] = result.Quality
+	}
+	if result.Confidence != nil {
+		response["confidence"] = *result.Confidence
+	}
+	if result.Path != nil {
+		response["path"] = result.Path
+	}
+	if result.Quotes != nil {
+		response["quotes"] = result.Quotes
+	}
+
+	fee, _ := response["fee"].(decimal.Decimal)
+	s.audit(c.Request.Context(), requestSource(c),
+		auditedConversion(result, response["rate"].(float64), amount, response["converted"].(decimal.Decimal), fee))
+	countConversions(c, 1)
+	if notModified(c, rateMaxAge(result), result.Provider, result.Base, result.Target, result.Timestamp, response["rate"], response["fee"], response["formatted"]) {
+		return
+	}
+	respond(c, http.StatusOK, response)
+}
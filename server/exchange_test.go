@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"cubetiq-samples/exchanger-go/adapters"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestExchangePercentage(t *testing.T) {
+	stub := &stubAdapter{rate: 4100}
+	router := newTestServer(t, stub, Options{})
+
+	tests := []struct {
+		query     string
+		amount    float64
+		converted float64
+		percent   float64
+		reference float64
+	}{
+		{"amount=50%25&reference=200", 100, 410000, 50, 200},
+		{"amount=12.5%25&reference=80", 10, 41000, 12.5, 80},
+		{"amount=150%25&reference=10", 15, 61500, 150, 10},
+		{"amount=0.5%25&reference=1000.50", 5.0025, 20510.25, 0.5, 1000.5},
+	}
+	for _, test := range tests {
+		response := serve(router, http.MethodGet, "/api/v1/exchange?from=USD&to=KHR&raw=true&"+test.query, "", nil)
+		if response.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200: %s", test.query, response.Code, response.Body)
+			continue
+		}
+		body := decode(t, response)
+		if body["amount"] != test.amount || body["converted"] != test.converted {
+			t.Errorf("%s: converted %v into %v, want %v into %v", test.query, body["amount"], body["converted"], test.amount, test.converted)
+		}
+		if body["percentage"] != test.percent || body["reference"] != test.reference {
+			t.Errorf("%s: percentage %v of %v, want %v of %v", test.query, body["percentage"], body["reference"], test.percent, test.reference)
+		}
+	}
+}
+
+func TestExchangePercentageRejected(t *testing.T) {
+	stub := &stubAdapter{rate: 4100}
+	router := newTestServer(t, stub, Options{})
+
+	tests := []struct {
+		query string
+		field string
+	}{
+		{"amount=50%25", "reference"},
+		{"amount=50%25&reference=", "reference"},
+		{"amount=50%25&reference=abc", "reference"},
+		{"amount=50%25&reference=-10", "reference"},
+		{"amount=abc%25&reference=100", "amount"},
+		{"amount=%25&reference=100", "amount"},
+		{"amount=-5%25&reference=100", "amount"},
+	}
+	for _, test := range tests {
+		response := serve(router, http.MethodGet, "/api/v1/exchange?from=USD&to=KHR&"+test.query, "", nil)
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400: %s", test.query, response.Code, response.Body)
+			continue
+		}
+		if body := decode(t, response); body["code"] != CodeInvalidAmount || body["field"] != test.field {
+			t.Errorf("%s: error = %v, want %s on %s", test.query, body, CodeInvalidAmount, test.field)
+		}
+	}
+	if stub.calls != 0 {
+		t.Errorf("the provider was called %d times for rejected amounts", stub.calls)
+	}
+}
+
+func TestExchangeQuality(t *testing.T) {
+	tests := []struct {
+		name       string
+		answer     string
+		quality    interface{}
+		confidence interface{}
+	}{
+		{"annotated", `{"base":"USD","timestamp":1714752000,"rates":{"EUR":0.9293},"quality":"firm","confidence":0.98}`, "firm", 0.98},
+		{"unannotated", `{"base":"USD","timestamp":1714752000,"rates":{"EUR":0.9293}}`, nil, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hits := 0
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits++
+				fmt.Fprint(w, test.answer)
+			}))
+			defer upstream.Close()
+			router := newTestServer(t, adapters.NewRatesService(upstream.Client(), upstream.URL, "key"), Options{})
+
+			// The second request is answered from the rate cache, which keeps the annotations
+			for i := 0; i < 2; i++ {
+				response := serve(router, http.MethodGet, "/api/v1/exchange?from=USD&to=EUR&amount=10", "", nil)
+				if response.Code != http.StatusOK {
+					t.Fatalf("status = %d, want 200: %s", response.Code, response.Body)
+				}
+				body := decode(t, response)
+				if body["quality"] != test.quality || body["confidence"] != test.confidence {
+					t.Errorf("request %d: quality %v confidence %v, want %v %v", i+1, body["quality"], body["confidence"], test.quality, test.confidence)
+				}
+			}
+			if hits != 1 {
+				t.Errorf("the provider was called %d times, want 1", hits)
+			}
+		})
+	}
+}
+
+func TestExchangeTargetAmountNonPositiveRate(t *testing.T) {
+	for _, rate := range []float64{0, -4100} {
+		router := newTestServer(t, &stubAdapter{rate: rate}, Options{})
+		response := serve(router, http.MethodGet, "/api/v1/exchange?from=USD&to=KHR&target_amount=4100", "", nil)
+		if response.Code != http.StatusBadGateway {
+			t.Errorf("rate %v: status = %d, want 502: %s", rate, response.Code, response.Body)
+			continue
+		}
+		if body := decode(t, response); body["code"] != CodeProviderError {
+			t.Errorf("rate %v: error = %v, want %s", rate, body, CodeProviderError)
+		}
+	}
+}
+
+func TestExchangeEffectiveRate(t *testing.T) {
+	markups := MarkupConfig{Pairs: map[string]Markup{
+		"USD/KHR": {Percent: decimal.NewFromInt(2), Fee: decimal.RequireFromString("1.25")},
+		"USD/EUR": {Percent: decimal.NewFromInt(1)},
+	}}
+	router := newTestServer(t, &stubAdapter{rate: 4100}, Options{Markups: markups})
+
+	tests := []struct {
+		query     string
+		rate      float64
+		effective float64
+	}{
+		// 100 USD converts at 4018 into 401800 KHR, for 101.25 USD with the fee
+		{"to=KHR&amount=100", 4018, 401800 / 101.25},
+		// Without a fee only the rate's markup is left
+		{"to=EUR&amount=100", 4059, 4059},
+		// Without a markup it is the rate, up to rounding
+		{"to=JPY&amount=0.001&raw=true", 4100, 4100},
+		{"to=JPY&amount=0.001", 4100, 4000},
+	}
+	for _, test := range tests {
+		response := serve(router, http.MethodGet, "/api/v1/exchange?from=USD&verbose=true&"+test.query, "", nil)
+		if response.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200: %s", test.query, response.Code, response.Body)
+			continue
+		}
+		body := decode(t, response)
+		if body["rate"] != test.rate || body["effectiveRate"] != test.effective {
+			t.Errorf("%s: rate %v effectiveRate %v, want %v %v", test.query, body["rate"], body["effectiveRate"], test.rate, test.effective)
+		}
+		if midRate, ok := body["midRate"]; ok && body["effectiveRate"].(float64) >= midRate.(float64) {
+			t.Errorf("%s: effectiveRate %v isn't below the mid rate %v", test.query, body["effectiveRate"], midRate)
+		}
+	}
+
+	body := decode(t, serve(router, http.MethodGet, "/api/v1/exchange?from=USD&to=KHR,EUR&amount=100&verbose=true", "", nil))
+	want := map[string]interface{}{"KHR": 401800 / 101.25, "EUR": 4059.0}
+	if effective, _ := body["effectiveRates"].(map[string]interface{}); !reflect.DeepEqual(effective, want) {
+		t.Errorf("effectiveRates = %v, want %v", body["effectiveRates"], want)
+	}
+
+	if body := decode(t, serve(router, http.MethodGet, "/api/v1/exchange?from=USD&to=KHR&amount=100", "", nil)); body["effectiveRate"] != nil {
+		t.Errorf("effectiveRate = %v without verbose", body["effectiveRate"])
+	}
+}
+
+// refusingAdapter quotes every pair like stubAdapter except those into the
+// currencies it refuses.
+type refusingAdapter struct {
+	stubAdapter
+	refused map[string]bool
+}
+
+func (a *refusingAdapter) GetRate(ctx context.Context, from, to string) (adapters.RateResult, error) {
+	if a.refused[to] {
+		return adapters.RateResult{}, fmt.Errorf("stub doesn't quote %s: %w", to, adapters.ErrProviderCurrency)
+	}
+	return a.stubAdapter.GetRate(ctx, from, to)
+}
+
+func TestExchangeMultiPartial(t *testing.T) {
+	router := newTestServer(t, &refusingAdapter{stubAdapter: stubAdapter{rate: 4100}, refused: map[string]bool{"XAU": true, "XAG": true}}, Options{})
+
+	tests := []struct {
+		to        string
+		status    int
+		partial   interface{}
+		converted []string
+		failed    []string
+	}{
+		{"EUR,KHR", http.StatusOK, false, []string{"EUR", "KHR"}, nil},
+		{"EUR,XAU,KHR", http.StatusOK, true, []string{"EUR", "KHR"}, []string{"XAU"}},
+		{"XAU,XAG", http.StatusBadRequest, nil, nil, nil},
+	}
+	for _, test := range tests {
+		response := serve(router, http.MethodGet, "/api/v1/exchange?from=USD&amount=10&to="+test.to, "", nil)
+		if response.Code != test.status {
+			t.Errorf("%s: status = %d, want %d: %s", test.to, response.Code, test.status, response.Body)
+			continue
+		}
+		body := decode(t, response)
+		if test.status != http.StatusOK {
+			if body["code"] != CodeInvalidCurrency {
+				t.Errorf("%s: error = %v, want %s", test.to, body, CodeInvalidCurrency)
+			}
+			continue
+		}
+		if body["partial"] != test.partial {
+			t.Errorf("%s: partial = %v, want %v", test.to, body["partial"], test.partial)
+		}
+		converted := body["converted"].(map[string]interface{})
+		for _, target := range test.converted {
+			if converted[target] != 41000.0 {
+				t.Errorf("%s: converted %v into %s, want 41000", test.to, converted[target], target)
+			}
+		}
+		if len(converted) != len(test.converted) {
+			t.Errorf("%s: converted = %v, want only %v", test.to, converted, test.converted)
+		}
+		errs, _ := body["errors"].(map[string]interface{})
+		if len(errs) != len(test.failed) {
+			t.Errorf("%s: errors = %v, want %v", test.to, errs, test.failed)
+		}
+		for _, target := range test.failed {
+			if err, _ := errs[target].(map[string]interface{}); err["code"] != CodeInvalidCurrency {
+				t.Errorf("%s: error of %s = %v, want %s", test.to, target, errs[target], CodeInvalidCurrency)
+			}
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/config"
+	"cubetiq-samples/exchanger-go/history"
+	"cubetiq-samples/exchanger-go/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// maxFileBytes caps the size of an uploaded ledger.
+	maxFileBytes = 512 << 20
+	// fileChunkRows is how many rows are read, converted and flushed at a
+	// time, so large files stream while each chunk's pairs are fetched
+	// together.
+	fileChunkRows = 500
+)
+
+// fileColumns are the columns FileExchangeHandler appends to every row.
+var fileColumns = []string{"rate", "converted", "fee", "total", "provider", "error"}
+
+// FileExchangeHandler converts a CSV ledger and answers the same CSV with
+// rate, converted, fee, total, provider and error columns appended; fee and
+// total are left empty without a markup. The header names the
+// amount, from and to columns; the from and to query parameters stand in for
+// missing currency columns, e.g. a ledger of KHR amounts converted with
+// ?from=KHR&to=USD. Rows fail on their own, with the reason in the error
+// column. The response streams a chunk of rows at a time, so its status is
+// 200 once the header is accepted.
+func (s *Server) FileExchangeHandler(c *gin.Context) {
+	adapter, _, ok := s.requestAdapter(c)
+	if !ok {
+		return
+	}
+
+	body, name, err := uploadBody(c, maxFileBytes)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), "file")
+		return
+	}
+	defer body.Close()
+	// HTTP/1 can't read the request once the response streams, so the upload
+	// is spooled to disk first
+	spooled, err := spool(body)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), "file")
+		return
+	}
+	defer spooled.Close()
+
+	reader := csv.NewReader(spooled)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "The file must be a CSV with a header row", "file")
+		return
+	}
+	columns := csvColumns(header)
+	amountColumn, ok := columns["amount"]
+	if !ok {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "The CSV header must name the amount column", "file")
+		return
+	}
+
+	// Each currency comes from its column, or else the query parameter
+	currencies := map[string]func([]string) string{}
+	for _, name := range []string{"from", "to"} {
+		if i, ok := columns[name]; ok {
+			currencies[name] = func(record []string) string { return csvField(record, i) }
+			continue
+		}
+		code, ok := requestCurrency(c, name)
+		if !ok {
+			return
+		}
+		currencies[name] = func([]string) string { return code }
+	}
+
+	if name == "" {
+		name = "ledger.csv"
+	}
+	converted := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)) + "-converted.csv"
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", converted))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write(append(header, fileColumns...))
+
+	raw := rawAmounts(c)
+	markupOf := s.requestMarkups(c)
+	workers := config.Int("EXCHANGER_BATCH_WORKERS", 8)
+	rates := map[adapters.Pair]adapters.PairResult{}
+	conversions := 0
+	source := requestSource(c)
+	for done := false; !done; {
+		// Read a chunk and fetch the pairs it adds
+		var chunk [][]string
+		var pairs []adapters.Pair
+		for len(chunk) < fileChunkRows {
+			record, err := reader.Read()
+			if err == io.EOF {
+				done = true
+				break
+			}
+			if err != nil {
+				// The rows so far went out already, so the error ends the file
+				writer.Write([]string{"error: " + err.Error()})
+				done = true
+				break
+			}
+			chunk = append(chunk, record)
+
+			from, to, err := normalizePair(currencies["from"](record), currencies["to"](record))
+			pair := adapters.Pair{From: from, To: to}
+			if _, seen := rates[pair]; err == nil && !seen {
+				rates[pair] = adapters.PairResult{}
+				pairs = append(pairs, pair)
+			}
+		}
+		if err := s.conversionQuota(c, conversions+len(chunk)); err != nil {
+			metrics.QuotaExceeded.WithLabelValues(err.period).Inc()
+			writer.Write([]string{"error: " + err.Error()})
+			writer.Flush()
+			break
+		}
+		for pair, result := range adapters.FetchPairs(c.Request.Context(), adapter, pairs, workers) {
+			rates[pair] = result
+		}
+
+		var audited []history.Conversion
+		for _, record := range chunk {
+			appended, conversion, ok := s.convertRecord(c, record, amountColumn, currencies, rates, markupOf, raw)
+			if ok {
+				audited = append(audited, conversion)
+			}
+			writer.Write(append(record, appended...))
+		}
+		writer.Flush()
+		c.Writer.Flush()
+		s.audit(c.Request.Context(), source, audited...)
+		conversions += len(audited)
+		if c.Request.Context().Err() != nil {
+			break
+		}
+	}
+	countConversions(c, conversions)
+}
+
+// convertRecord returns the columns appended to record and, when it was
+// converted, the conversion made.
+func (s *Server) convertRecord(c *gin.Context, record []string, amountColumn int, currencies map[string]func([]string) string,
+	rates map[adapters.Pair]adapters.PairResult, markupOf markupLookup, raw bool) ([]string, history.Conversion, bool) {
+	failed := func(message string) ([]string, history.Conversion, bool) {
+		return []string{"", "", "", "", "", message}, history.Conversion{}, false
+	}
+
+	amount, err := parseAmount(csvField(record, amountColumn))
+	if err != nil {
+		return failed("Amount " + err.Error())
+	}
+	from, to, err := normalizePair(currencies["from"](record), currencies["to"](record))
+	if err != nil {
+		return failed(err.Error())
+	}
+	rate := rates[adapters.Pair{From: from, To: to}]
+	if rate.Err != nil {
+		_, body := rateErrorBody(c, rate.Err)
+		return failed(body["message"].(string))
+	}
+
+	effective := rate.Result.Rate
+	fee, feeColumn, totalColumn := decimal.Zero, "", ""
+	if markup, ok := markupOf(from, to); ok {
+		effective = markup.effectiveRate(effective)
+		fee = markup.fee(from)
+		feeColumn, totalColumn = fee.String(), amount.Add(fee).String()
+	}
+	converted := convertAmount(amount, effective, to, raw)
+	return []string{
+		strconv.FormatFloat(effective, 'f', -1, 64),
+		converted.String(),
+		feeColumn,
+		totalColumn,
+		rate.Result.Provider,
+		"",
+	}, auditedConversion(rate.Result, effective, amount, converted, fee), true
+}
+
+// spooledFile is an upload copied to a temporary file, removed on Close.
+type spooledFile struct {
+	*os.File
+}
+
+func (f spooledFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+// spool copies r to a temporary file and returns it rewound.
+func spool(r io.Reader) (spooledFile, error) {
+	file, err := ioutil.TempFile("", "exchanger-upload-*")
+	if err != nil {
+		return spooledFile{}, err
+	}
+	spooled := spooledFile{file}
+	if _, err := io.Copy(file, r); err != nil {
+		spooled.Close()
+		return spooledFile{}, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		spooled.Close()
+		return spooledFile{}, err
+	}
+	return spooled, nil
+}
+
+// csvField returns the trimmed field i of record, or "" for short records.
+func csvField(record []string, i int) string {
+	if i < len(record) {
+		return strings.TrimSpace(record[i])
+	}
+	return ""
+}
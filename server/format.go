@@ -0,0 +1,288 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response formats besides JSON picked by Negotiate.
+const (
+	formatJSON = "json"
+	formatXML  = "xml"
+	formatCSV  = "csv"
+)
+
+// formatKey and formatRootKey hold the negotiated format and the XML root
+// element in the gin context.
+const (
+	formatKey     = "responseFormat"
+	formatRootKey = "responseFormatRoot"
+)
+
+// formatTypes are the media types of each format, the first being the one
+// answered.
+var formatTypes = map[string][]string{
+	formatJSON: {"application/json"},
+	formatXML:  {"application/xml", "text/xml"},
+	formatCSV:  {"text/csv", "application/csv"},
+}
+
+// Negotiate picks the format respond writes, from the format query parameter
+// or else the Accept header, falling back to JSON. root names the document
+// element of XML responses. Errors stay JSON.
+func Negotiate(root string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept")
+		format := strings.ToLower(c.Query("format"))
+		if format == "" {
+			format = negotiateFormat(c.GetHeader("Accept"))
+		}
+		if _, ok := formatTypes[format]; !ok {
+			abortError(c, http.StatusBadRequest, CodeInvalidRequest, "Format must be json, xml or csv", "format")
+			return
+		}
+		c.Set(formatKey, format)
+		c.Set(formatRootKey, root)
+		c.Next()
+	}
+}
+
+// negotiateFormat picks the format an Accept header prefers, honoring
+// q-values and preferring JSON on ties.
+func negotiateFormat(header string) string {
+	best, bestQ := formatJSON, 0.0
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		for _, format := range []string{formatJSON, formatXML, formatCSV} {
+			for _, accepted := range formatTypes[format] {
+				if mediaType == accepted && q > bestQ {
+					best, bestQ = format, q
+				}
+			}
+		}
+	}
+	return best
+}
+
+// responseFormat is the format Negotiate picked, JSON on routes without it.
+func responseFormat(c *gin.Context) string {
+	if format := c.GetString(formatKey); format != "" {
+		return format
+	}
+	return formatJSON
+}
+
+// respond writes body in the negotiated format. XML and CSV are built from
+// the JSON encoding, so every format carries the same fields.
+func respond(c *gin.Context, status int, body gin.H) {
+	format := responseFormat(c)
+	if format == formatJSON {
+		c.JSON(status, body)
+		return
+	}
+
+	data, err := json.Marshal(body)
+	var value map[string]interface{}
+	if err == nil {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&value)
+	}
+	var out bytes.Buffer
+	if err == nil {
+		if format == formatXML {
+			err = writeXML(&out, c.GetString(formatRootKey), value)
+		} else {
+			err = writeCSV(&out, value)
+		}
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+		return
+	}
+	c.Data(status, formatTypes[format][0]+"; charset=utf-8", out.Bytes())
+}
+
+// writeXML writes value as the root element: object fields become child
+// elements in name order and arrays repeat their element, as encoding/xml
+// does for slices.
+func writeXML(out *bytes.Buffer, root string, value map[string]interface{}) error {
+	out.WriteString(xml.Header)
+	encoder := xml.NewEncoder(out)
+	if err := encodeXML(encoder, root, value); err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func encodeXML(encoder *xml.Encoder, name string, value interface{}) error {
+	if items, ok := value.([]interface{}); ok {
+		for _, item := range items {
+			if err := encodeXML(encoder, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(value) {
+			if err := encodeXML(encoder, key, value[key]); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := encoder.EncodeToken(xml.CharData(csvValue(value))); err != nil {
+			return err
+		}
+	}
+	return encoder.EncodeToken(start.End())
+}
+
+// writeCSV writes value as a table with a row per entry of its collections:
+// objects keyed by currency, such as rates and converted, share a row per
+// currency, and arrays of objects, such as a time series, give a row per
+// item. Every other field is a column repeated on each row, with lists
+// joined by spaces.
+func writeCSV(out *bytes.Buffer, value map[string]interface{}) error {
+	var keyed, listed, scalars []string
+	for _, name := range sortedKeys(value) {
+		switch field := value[name].(type) {
+		case map[string]interface{}:
+			keyed = append(keyed, name)
+		case []interface{}:
+			if len(field) > 0 && isObject(field[0]) {
+				listed = append(listed, name)
+			} else {
+				scalars = append(scalars, name)
+			}
+		default:
+			scalars = append(scalars, name)
+		}
+	}
+
+	var header []string
+	var rows []map[string]interface{}
+	switch {
+	case len(keyed) > 0:
+		// Currencies in order, with a column per keyed field
+		header = append([]string{"currency"}, keyed...)
+		currencies := map[string]interface{}{}
+		for _, name := range keyed {
+			for currency := range value[name].(map[string]interface{}) {
+				currencies[currency] = nil
+			}
+		}
+		for _, currency := range sortedKeys(currencies) {
+			row := map[string]interface{}{"currency": currency}
+			for _, name := range keyed {
+				row[name] = value[name].(map[string]interface{})[currency]
+			}
+			rows = append(rows, row)
+		}
+	case len(listed) > 0:
+		columns := map[string]interface{}{}
+		for _, item := range value[listed[0]].([]interface{}) {
+			if item, ok := item.(map[string]interface{}); ok {
+				for name := range item {
+					columns[name] = nil
+				}
+				rows = append(rows, item)
+			}
+		}
+		header = sortedKeys(columns)
+	default:
+		rows = []map[string]interface{}{{}}
+	}
+	for _, name := range scalars {
+		if !contains(header, name) {
+			header = append(header, name)
+		}
+	}
+
+	writer := csv.NewWriter(out)
+	writer.Write(header)
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for i, name := range header {
+			field, ok := row[name]
+			if !ok {
+				field = value[name]
+			}
+			record[i] = csvValue(field)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvValue formats a decoded JSON value as text.
+func csvValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []interface{}:
+		parts := make([]string, len(value))
+		for i, item := range value {
+			parts[i] = csvValue(item)
+		}
+		return strings.Join(parts, " ")
+	case map[string]interface{}:
+		data, _ := json.Marshal(value)
+		return string(data)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func isObject(value interface{}) bool {
+	_, ok := value.(map[string]interface{})
+	return ok
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,360 @@
+package server
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/keys"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/shopspring/decimal"
+)
+
+//go:embed schema.graphql
+var graphqlSchema string
+
+// newGraphQLSchema parses the GraphQL schema against the resolvers of s.
+func newGraphQLSchema(s *Server) *graphql.Schema {
+	return graphql.MustParseSchema(graphqlSchema, &graphqlResolver{server: s}, graphql.UseFieldResolvers())
+}
+
+// GraphQLHandler executes a GraphQL query posted as
+// {"query", "operationName", "variables"}. Errors of single fields are
+// reported in the response's errors next to the fields that resolved.
+func (s *Server) GraphQLHandler(c *gin.Context) {
+	var request struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.Query == "" {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid GraphQL request", "query")
+		return
+	}
+
+	// Markups per API key apply as they do for the REST routes
+	ctx := context.WithValue(c.Request.Context(), apiKeyContextKey{}, c.GetHeader("X-API-Key"))
+	ctx = context.WithValue(ctx, auditSourceContextKey{}, requestSource(c))
+	var conversions int64
+	ctx = context.WithValue(ctx, graphqlConversionsContextKey{}, &conversions)
+	response := s.graphql.Exec(ctx, request.Query, request.OperationName, request.Variables)
+	countConversions(c, int(atomic.LoadInt64(&conversions)))
+	c.JSON(http.StatusOK, response)
+}
+
+type apiKeyContextKey struct{}
+
+// graphqlConversionsContextKey holds the *int64 counting the convert fields
+// of a query that resolved, which run concurrently.
+type graphqlConversionsContextKey struct{}
+
+type graphqlResolver struct {
+	server *Server
+}
+
+type graphqlProvider struct {
+	Source *string
+	Key    *string
+	Keys   *[]struct {
+		Source string
+		Key    string
+	}
+}
+
+type graphqlRate struct {
+	From      string
+	To        string
+	Rate      float64
+	MidRate   float64
+	Provider  string
+	Timestamp string
+	Cached    bool
+	Date      *string
+}
+
+type graphqlConversion struct {
+	Rate          *graphqlRate
+	Amount        string
+	Converted     string
+	MarkupPercent *string
+	Fee           *string
+	Total         string
+}
+
+type graphqlDailyRate struct {
+	Date string
+	Rate float64
+}
+
+type graphqlCurrencies struct {
+	Currencies []graphqlCurrency
+	Other      []graphqlCurrency
+}
+
+type graphqlCurrency struct {
+	Code     string
+	Name     string
+	Symbol   string
+	Exponent int32
+}
+
+func (r *graphqlResolver) Rate(ctx context.Context, args struct {
+	From, To string
+	Provider *graphqlProvider
+}) (*graphqlRate, error) {
+	adapter, err := r.adapter(args.Provider)
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := normalizePair(args.From, args.To)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := adapter.GetRate(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	rate, _, _ := graphqlRateOf(result, r.markups(ctx))
+	return rate, nil
+}
+
+func (r *graphqlResolver) Convert(ctx context.Context, args struct {
+	Amount, From, To string
+	Raw              *bool
+	Provider         *graphqlProvider
+}) (*graphqlConversion, error) {
+	adapter, err := r.adapter(args.Provider)
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := normalizePair(args.From, args.To)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := parseAmount(args.Amount)
+	if err != nil {
+		return nil, errors.New("Amount " + err.Error())
+	}
+
+	result, err := adapter.GetRate(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	rate, markup, ok := graphqlRateOf(result, r.markups(ctx))
+	raw := args.Raw != nil && *args.Raw
+	converted := convertAmount(amount, rate.Rate, to, raw)
+	conversion := &graphqlConversion{
+		Rate:      rate,
+		Amount:    amount.String(),
+		Converted: converted.String(),
+		Total:     amount.String(),
+	}
+	fee := decimal.Zero
+	if ok {
+		fee = markup.fee(from)
+		percent, feeStr := markup.Percent.String(), fee.String()
+		conversion.MarkupPercent = &percent
+		conversion.Fee = &feeStr
+		conversion.Total = amount.Add(fee).String()
+	}
+	source, _ := ctx.Value(auditSourceContextKey{}).(auditSource)
+	r.server.audit(ctx, source, auditedConversion(result, rate.Rate, amount, converted, fee))
+	if counter, ok := ctx.Value(graphqlConversionsContextKey{}).(*int64); ok {
+		atomic.AddInt64(counter, 1)
+	}
+	return conversion, nil
+}
+
+func (r *graphqlResolver) Rates(ctx context.Context, args struct {
+	Base     string
+	Symbols  *[]string
+	Provider *graphqlProvider
+}) (*[]*graphqlRate, error) {
+	adapter, err := r.adapter(args.Provider)
+	if err != nil {
+		return nil, err
+	}
+	base, err := normalizeCurrency(args.Base)
+	if err != nil {
+		return nil, err
+	}
+
+	// No symbols asks for the provider's whole table
+	var symbols []string
+	if args.Symbols != nil {
+		for _, input := range *args.Symbols {
+			symbol, err := normalizeCurrency(input)
+			if err != nil {
+				return nil, err
+			}
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	results, err := adapters.FetchRates(ctx, adapter, base, symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	markupOf := r.markups(ctx)
+	rates := make([]*graphqlRate, 0, len(results))
+	for _, result := range results {
+		rate, _, _ := graphqlRateOf(result, markupOf)
+		rates = append(rates, rate)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		return rates[i].To < rates[j].To
+	})
+	return &rates, nil
+}
+
+func (r *graphqlResolver) Timeseries(ctx context.Context, args struct {
+	From, To, Start, End string
+	Provider             *graphqlProvider
+}) (*[]*graphqlDailyRate, error) {
+	if err := r.server.authorize(ctx, keys.ScopeHistorical); err != nil {
+		return nil, err
+	}
+	adapter, err := r.adapter(args.Provider)
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := normalizePair(args.From, args.To)
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := time.Parse("2006-01-02", args.Start)
+	if err != nil {
+		return nil, errors.New("Invalid start date, expected YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", args.End)
+	if err != nil {
+		return nil, errors.New("Invalid end date, expected YYYY-MM-DD")
+	}
+	switch {
+	case end.Before(start):
+		return nil, errors.New("End date must not be before start date")
+	case end.After(time.Now().UTC()):
+		return nil, errors.New("End date must not be in the future")
+	case end.Sub(start) >= maxSeriesLimit*24*time.Hour:
+		// Unlike the REST route there are no pages, so cap the range instead
+		return nil, fmt.Errorf("Range must not exceed %d days", maxSeriesLimit)
+	}
+
+	series, err := adapters.FetchTimeSeries(ctx, adapter, from, to, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := make([]*graphqlDailyRate, 0, len(series))
+	for _, result := range series {
+		rates = append(rates, &graphqlDailyRate{
+			Date: result.Timestamp.Format("2006-01-02"),
+			Rate: result.Rate,
+		})
+	}
+	return &rates, nil
+}
+
+func (r *graphqlResolver) Currencies(ctx context.Context, args struct {
+	Provider *graphqlProvider
+}) (*graphqlCurrencies, error) {
+	if args.Provider == nil || args.Provider.Source == nil || *args.Provider.Source == "" {
+		return &graphqlCurrencies{Currencies: graphqlCurrencyList(allCurrencies()), Other: []graphqlCurrency{}}, nil
+	}
+
+	adapter, err := r.adapter(args.Provider)
+	if err != nil {
+		return nil, err
+	}
+	symbols, err := adapters.FetchSymbols(ctx, adapter)
+	if err != nil {
+		return nil, err
+	}
+
+	currencies, other := splitSymbols(symbols)
+	return &graphqlCurrencies{Currencies: graphqlCurrencyList(currencies), Other: graphqlCurrencyList(other)}, nil
+}
+
+// adapter builds the adapter chain selected by provider, like the source and
+// key query parameters do for REST requests.
+func (r *graphqlResolver) adapter(provider *graphqlProvider) (adapters.ExchangeRateAdapter, error) {
+	var source, key string
+	keys := map[string]string{}
+	if provider != nil {
+		if provider.Source != nil {
+			source = *provider.Source
+		}
+		if provider.Key != nil {
+			key = *provider.Key
+		}
+		if provider.Keys != nil {
+			for _, entry := range *provider.Keys {
+				keys[entry.Source] = entry.Key
+			}
+		}
+	}
+
+	providers, err := r.server.providers(r.server.source(source), key, keys)
+	if err != nil {
+		return nil, err
+	}
+	return r.server.chain(providers), nil
+}
+
+// markups returns the markup lookup for the API key of the request.
+func (r *graphqlResolver) markups(ctx context.Context) markupLookup {
+	apiKey, _ := ctx.Value(apiKeyContextKey{}).(string)
+	markups := r.server.current().markups
+	return func(from, to string) (Markup, bool) {
+		return markups.markupFor(apiKey, from, to)
+	}
+}
+
+// graphqlRateOf converts result into a Rate, quoting the customer rate when a
+// markup applies to the pair.
+func graphqlRateOf(result adapters.RateResult, markupOf markupLookup) (*graphqlRate, Markup, bool) {
+	rate := &graphqlRate{
+		From:      result.Base,
+		To:        result.Target,
+		Rate:      result.Rate,
+		MidRate:   result.Rate,
+		Provider:  result.Provider,
+		Timestamp: result.Timestamp.Format(time.RFC3339),
+		Cached:    result.Cached,
+	}
+	if result.Date != "" {
+		date := result.Date
+		rate.Date = &date
+	}
+	markup, ok := markupOf(result.Base, result.Target)
+	if ok {
+		rate.Rate = markup.effectiveRate(result.Rate)
+	}
+	return rate, markup, ok
+}
+
+func graphqlCurrencyList(currencies []Currency) []graphqlCurrency {
+	list := make([]graphqlCurrency, 0, len(currencies))
+	for _, currency := range currencies {
+		list = append(list, graphqlCurrency{
+			Code:     currency.Code,
+			Name:     currency.Name,
+			Symbol:   currency.Symbol,
+			Exponent: int32(currency.Exponent),
+		})
+	}
+	return list
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	exchangerv1 "cubetiq-samples/exchanger-go/api/exchanger/v1"
+
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Bounds of the WatchRates poll interval.
+const (
+	defaultWatchInterval = time.Minute
+	minWatchInterval     = time.Second
+)
+
+// GRPC returns a gRPC server for ExchangerService. It shares the providers,
+// cache and markups of the HTTP routes; with API keys or JWTs enabled, calls
+// need x-api-key or authorization metadata granting the convert scope.
+func (s *Server) GRPC() *grpc.Server {
+	var opts []grpc.ServerOption
+	if s.maintenance {
+		opts = append(opts,
+			grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				return nil, errMaintenance
+			}),
+			grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				return errMaintenance
+			}),
+		)
+	} else if s.authRequired() {
+		opts = append(opts, grpc.UnaryInterceptor(s.grpcKeyUnary), grpc.StreamInterceptor(s.grpcKeyStream))
+	}
+
+	g := grpc.NewServer(opts...)
+	exchangerv1.RegisterExchangerServiceServer(g, &grpcService{server: s})
+	return g
+}
+
+var errMaintenance = status.Error(codes.Unavailable, "Service is under maintenance")
+
+type grpcService struct {
+	exchangerv1.UnimplementedExchangerServiceServer
+	server *Server
+}
+
+func (g *grpcService) GetRate(ctx context.Context, req *exchangerv1.GetRateRequest) (*exchangerv1.GetRateResponse, error) {
+	adapter, err := g.adapter(req.GetProvider())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	from, to, err := normalizePair(req.GetFrom(), req.GetTo())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	result, err := adapter.GetRate(ctx, from, to)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	rate, _, _ := grpcRate(result, g.markups(ctx))
+	return &exchangerv1.GetRateResponse{Rate: rate}, nil
+}
+
+func (g *grpcService) Convert(ctx context.Context, req *exchangerv1.ConvertRequest) (*exchangerv1.ConvertResponse, error) {
+	adapter, err := g.adapter(req.GetProvider())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	from, to, err := normalizePair(req.GetFrom(), req.GetTo())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	amount, err := parseAmount(req.GetAmount())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Amount "+err.Error())
+	}
+
+	result, err := adapter.GetRate(ctx, from, to)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	rate, markup, ok := grpcRate(result, g.markups(ctx))
+	converted := convertAmount(amount, rate.Rate, to, req.GetRaw())
+	response := &exchangerv1.ConvertResponse{
+		Rate:      rate,
+		Amount:    amount.String(),
+		Converted: converted.String(),
+		Total:     amount.String(),
+	}
+	fee := decimal.Zero
+	if ok {
+		fee = markup.fee(from)
+		response.MarkupPercent = markup.Percent.String()
+		response.Fee = fee.String()
+		response.Total = amount.Add(fee).String()
+	}
+	g.server.audit(ctx, grpcSource(ctx, exchangerv1.ExchangerService_Convert_FullMethodName),
+		auditedConversion(result, rate.Rate, amount, converted, fee))
+	return response, nil
+}
+
+func (g *grpcService) ListCurrencies(ctx context.Context, req *exchangerv1.ListCurrenciesRequest) (*exchangerv1.ListCurrenciesResponse, error) {
+	response := &exchangerv1.ListCurrenciesResponse{}
+	if req.GetProvider().GetSource() == "" {
+		for _, currency := range allCurrencies() {
+			response.Currencies = append(response.Currencies, grpcCurrency(currency))
+		}
+		return response, nil
+	}
+
+	adapter, err := g.adapter(req.GetProvider())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	symbols, err := adapters.FetchSymbols(ctx, adapter)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	currencies, other := splitSymbols(symbols)
+	for _, currency := range currencies {
+		response.Currencies = append(response.Currencies, grpcCurrency(currency))
+	}
+	for _, currency := range other {
+		response.Other = append(response.Other, grpcCurrency(currency))
+	}
+	return response, nil
+}
+
+// WatchRates polls the rates every interval, which the rate cache keeps from
+// reaching the provider more often than its TTL, and sends the rates that
+// changed since the last update. Failures of the first poll end the stream;
+// later ones are logged and retried on the next tick.
+func (g *grpcService) WatchRates(req *exchangerv1.WatchRatesRequest, stream exchangerv1.ExchangerService_WatchRatesServer) error {
+	adapter, err := g.adapter(req.GetProvider())
+	if err != nil {
+		return grpcError(err)
+	}
+	base, err := normalizeCurrency(req.GetBase())
+	if err != nil {
+		return grpcError(err)
+	}
+
+	// No targets watches the provider's whole rate table
+	var targets []string
+	for _, input := range req.GetTargets() {
+		target, err := normalizeCurrency(input)
+		if err != nil {
+			return grpcError(err)
+		}
+		targets = append(targets, target)
+	}
+
+	interval := defaultWatchInterval
+	if req.GetInterval() != nil {
+		interval = req.GetInterval().AsDuration()
+	}
+	if interval < minWatchInterval {
+		interval = minWatchInterval
+	}
+
+	markupOf := g.markups(stream.Context())
+	sent := map[string]float64{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for first := true; ; first = false {
+		results, err := adapters.FetchRates(stream.Context(), adapter, base, targets)
+		if err != nil && first {
+			return grpcError(err)
+		}
+		if err != nil {
+			log.Printf("WatchRates %s: %v", base, err)
+		}
+
+		update := &exchangerv1.RateUpdate{}
+		for target, result := range results {
+			if rate, ok := sent[target]; ok && rate == result.Rate {
+				continue
+			}
+			sent[target] = result.Rate
+			rate, _, _ := grpcRate(result, markupOf)
+			update.Rates = append(update.Rates, rate)
+		}
+		if len(update.Rates) > 0 {
+			if err := stream.Send(update); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-g.server.draining:
+			return status.Error(codes.Unavailable, "Server is shutting down")
+		case <-ticker.C:
+		}
+	}
+}
+
+// adapter builds the adapter chain selected by provider, like the source and
+// key query parameters do for HTTP requests.
+func (g *grpcService) adapter(provider *exchangerv1.Provider) (adapters.ExchangeRateAdapter, error) {
+	providers, err := g.server.providers(g.server.source(provider.GetSource()), provider.GetKey(), provider.GetKeys())
+	if err != nil {
+		return nil, err
+	}
+	return g.server.chain(providers), nil
+}
+
+// markups returns the markup lookup for the caller's x-api-key metadata.
+func (g *grpcService) markups(ctx context.Context) markupLookup {
+	var apiKey string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-api-key"); len(values) > 0 {
+			apiKey = values[0]
+		}
+	}
+	markups := g.server.current().markups
+	return func(from, to string) (Markup, bool) {
+		return markups.markupFor(apiKey, from, to)
+	}
+}
+
+// grpcSource is the auditSource of a call to method, with the request ID of
+// its x-request-id metadata.
+func grpcSource(ctx context.Context, method string) auditSource {
+	source := auditSource{route: method}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-request-id"); len(values) > 0 && validRequestID.MatchString(values[0]) {
+			source.requestID = values[0]
+		}
+	}
+	return source
+}
+
+// grpcRate converts result into a Rate, quoting the customer rate when a
+// markup applies to the pair.
+func grpcRate(result adapters.RateResult, markupOf markupLookup) (*exchangerv1.Rate, Markup, bool) {
+	rate := &exchangerv1.Rate{
+		From:      result.Base,
+		To:        result.Target,
+		Rate:      result.Rate,
+		MidRate:   result.Rate,
+		Provider:  result.Provider,
+		Timestamp: timestamppb.New(result.Timestamp),
+		Cached:    result.Cached,
+		Date:      result.Date,
+	}
+	markup, ok := markupOf(result.Base, result.Target)
+	if ok {
+		rate.Rate = markup.effectiveRate(result.Rate)
+	}
+	return rate, markup, ok
+}
+
+func grpcCurrency(currency Currency) *exchangerv1.Currency {
+	return &exchangerv1.Currency{
+		Code:     currency.Code,
+		Name:     currency.Name,
+		Symbol:   currency.Symbol,
+		Exponent: int32(currency.Exponent),
+	}
+}
+
+// grpcError maps err to the gRPC status matching the HTTP one.
+func grpcError(err error) error {
+	var unknown *UnknownCurrencyError
+	switch {
+	case errors.As(err, &unknown), errors.Is(err, errInvalidSource), errors.Is(err, adapters.ErrProviderCurrency):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, errKeyRequired):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, adapters.ErrHistoricalNotSupported), errors.Is(err, adapters.ErrRateTableNotSupported),
+		errors.Is(err, adapters.ErrSymbolsNotSupported), errors.Is(err, adapters.ErrProviderPlan):
+		return status.Error(codes.Unimplemented, err.Error())
+	default:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+}
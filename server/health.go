@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/cache"
+	"cubetiq-samples/exchanger-go/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// readyTimeout bounds each readiness check.
+	readyTimeout = 5 * time.Second
+	// defaultProbePair is the rate fetched to tell whether a provider is
+	// reachable, unless EXCHANGER_PROBE_PAIR names another.
+	defaultProbePair = "USD/EUR"
+)
+
+// CheckStatus is the outcome of one dependency check.
+type CheckStatus struct {
+	OK        bool    `json:"ok"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// ProviderStatus reports whether a provider answered a rate lookup.
+type ProviderStatus struct {
+	Provider  string    `json:"provider"`
+	Pair      string    `json:"pair"`
+	Reachable bool      `json:"reachable"`
+	LatencyMs float64   `json:"latencyMs"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// prober remembers the last provider probe, so frequent readiness checks
+// don't spend provider quota on every call.
+type prober struct {
+	mu      sync.Mutex
+	checked time.Time
+	results []ProviderStatus
+}
+
+// probePair returns the pair fetched to probe provider, taken from
+// EXCHANGER_PROBE_PAIR_<PROVIDER> or EXCHANGER_PROBE_PAIR.
+func probePair(provider string) string {
+	pair := defaultProbePair
+	if value := os.Getenv("EXCHANGER_PROBE_PAIR"); value != "" {
+		pair = value
+	}
+	if value := os.Getenv("EXCHANGER_PROBE_PAIR_" + strings.ToUpper(provider)); value != "" {
+		pair = value
+	}
+	return pair
+}
+
+// probeProviders fetches the probe pair from every default provider,
+// bypassing the cache. Results are reused for EXCHANGER_PROBE_TTL.
+func (s *Server) probeProviders() []ProviderStatus {
+	s.prober.mu.Lock()
+	defer s.prober.mu.Unlock()
+	if time.Since(s.prober.checked) < config.Duration("EXCHANGER_PROBE_TTL", 30*time.Second) {
+		return s.prober.results
+	}
+
+	names := strings.Split(s.source(""), ",")
+	results := make([]ProviderStatus, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = s.probe(name)
+		}(i, strings.TrimSpace(name))
+	}
+	wg.Wait()
+
+	s.prober.checked = time.Now()
+	s.prober.results = results
+	return results
+}
+
+func (s *Server) probe(name string) ProviderStatus {
+	status := ProviderStatus{Provider: name, Pair: probePair(name), CheckedAt: time.Now()}
+	pair, err := parsePair(status.Pair)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	adapter, err := s.provider(name, "", nil)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+
+	// Probe results are shared between requests, so no single caller's
+	// context bounds them; the upstream timeout does
+	start := time.Now()
+	_, err = adapter.GetRate(context.Background(), pair.From, pair.To)
+	status.LatencyMs = milliseconds(time.Since(start))
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Reachable = true
+	return status
+}
+
+// checkDependencies pings the cache, history and key backends that live on a
+// server; in-process ones are left out.
+func (s *Server) checkDependencies(ctx context.Context) map[string]CheckStatus {
+	checks := map[string]CheckStatus{}
+	if pinger, ok := s.cache.(cache.Pinger); ok {
+		checks["cache"] = check(ctx, pinger.Ping)
+	}
+	if s.history != nil {
+		checks["history"] = check(ctx, s.history.Ping)
+	}
+	if s.keys != nil {
+		checks["keys"] = check(ctx, s.keys.Ping)
+	}
+	return checks
+}
+
+func check(ctx context.Context, ping func(context.Context) error) CheckStatus {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ping(ctx)
+	status := CheckStatus{OK: err == nil, LatencyMs: milliseconds(time.Since(start))}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// ReadyHandler answers Kubernetes readiness probes with 200 while the cache,
+// history and key backends answer, and 503 otherwise. With
+// EXCHANGER_READY_CHECK_PROVIDERS set, at least one default provider must be
+// reachable as well. Once the server drains for shutdown it always answers
+// 503.
+func (s *Server) ReadyHandler(c *gin.Context) {
+	select {
+	case <-s.draining:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting down"})
+		return
+	default:
+	}
+
+	checks := s.checkDependencies(c.Request.Context())
+	ready := true
+	for _, check := range checks {
+		ready = ready && check.OK
+	}
+
+	response := gin.H{"checks": checks}
+	if config.Bool("EXCHANGER_READY_CHECK_PROVIDERS") {
+		providers := s.probeProviders()
+		ready = ready && anyReachable(providers)
+		response["providers"] = providers
+	}
+
+	if !ready {
+		response["status"] = "not ready"
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+	response["status"] = "ready"
+	c.JSON(http.StatusOK, response)
+}
+
+// StatusHandler reports the reachability of every default provider along
+// with the backend checks and the circuit breaker of every provider used so
+// far. It always answers 200; status is "ok" when everything is reachable
+// and no circuit is open, and "degraded" otherwise.
+func (s *Server) StatusHandler(c *gin.Context) {
+	checks := s.checkDependencies(c.Request.Context())
+	providers := s.probeProviders()
+	circuits := s.breakers.Statuses()
+
+	status := "ok"
+	for _, provider := range providers {
+		if !provider.Reachable {
+			status = "degraded"
+		}
+	}
+	for _, circuit := range circuits {
+		if circuit.State != adapters.CircuitClosed {
+			status = "degraded"
+		}
+	}
+	for _, check := range checks {
+		if !check.OK {
+			status = "degraded"
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"status": status, "checks": checks, "providers": providers, "circuits": circuits})
+}
+
+func anyReachable(providers []ProviderStatus) bool {
+	for _, provider := range providers {
+		if provider.Reachable {
+			return true
+		}
+	}
+	return false
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
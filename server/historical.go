@@ -0,0 +1,133 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// HistoricalExchangeHandler serves the rate for a pair on a past date, e.g.
+// /exchange/historical?date=2023-05-01&from=USD&to=KHR. An optional amount is
+// converted at that rate. With rollback=true a weekend, holiday or other day
+// without rates takes the rate of the latest business day before it, and
+// date in the response is that day.
+func (s *Server) HistoricalExchangeHandler(c *gin.Context) {
+	countConversions(c, 0)
+	adapter, source, ok := s.requestAdapter(c)
+	if !ok {
+		return
+	}
+
+	from, ok := requestCurrency(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := requestCurrency(c, "to")
+	if !ok {
+		return
+	}
+	format, ok := requestFormatter(c)
+	if !ok {
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidDate, "Invalid date, expected YYYY-MM-DD", "date")
+		return
+	}
+	if date.After(time.Now().UTC()) {
+		respondError(c, http.StatusBadRequest, CodeInvalidDate, "Date must not be in the future", "date")
+		return
+	}
+	rollback := false
+	if value := c.Query("rollback"); value != "" {
+		if rollback, err = strconv.ParseBool(value); err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidRequest, "rollback must be true or false", "rollback")
+			return
+		}
+	}
+
+	var amount decimal.Decimal
+	amountStr, amountMinorStr := c.Query("amount"), c.Query("amount_minor")
+	switch {
+	case amountStr != "" && amountMinorStr != "":
+		respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Pass either amount or amount_minor, not both", "amount_minor")
+		return
+	case amountMinorStr != "" && rawAmounts(c):
+		respondError(c, http.StatusBadRequest, CodeInvalidAmount, "amount_minor answers rounded minor units and can't be combined with raw", "raw")
+		return
+	case amountStr != "":
+		amount, err = parseAmount(amountStr)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Amount "+err.Error(), "amount")
+			return
+		}
+	case amountMinorStr != "":
+		amount, err = parseMinorAmount(amountMinorStr, from)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Amount "+err.Error(), "amount_minor")
+			return
+		}
+	}
+
+	requested := date
+	var result adapters.RateResult
+	if rollback {
+		result, date, err = rolledBackRate(c.Request.Context(), adapter, from, to, date)
+	} else {
+		result, err = adapter.GetExchangeRateAt(c.Request.Context(), from, to, date)
+	}
+	if err != nil {
+		respondRateError(c, err)
+		return
+	}
+	logProvider(c, result.Provider)
+
+	response := gin.H{
+		"source":    source,
+		"provider":  result.Provider,
+		"from":      result.Base,
+		"to":        result.Target,
+		"date":      date.Format("2006-01-02"),
+		"rate":      result.Rate,
+		"timestamp": result.Timestamp,
+		"cached":    result.Cached,
+	}
+	if rollback {
+		response["requestedDate"] = requested.Format("2006-01-02")
+	}
+	if result.Quotes != nil {
+		response["quotes"] = result.Quotes
+	}
+	if result.Stale {
+		response["stale"] = true
+		response["cacheAge"] = time.Since(result.FetchedAt).Seconds()
+	}
+	if amountStr != "" || amountMinorStr != "" {
+		response["amount"] = amount
+		response["converted"] = convertAmount(amount, result.Rate, to, rawAmounts(c))
+		if amountMinorStr != "" {
+			response["amountMinor"] = minorUnits(amount, from)
+			response["convertedMinor"] = minorUnits(response["converted"].(decimal.Decimal), to)
+		}
+		if format != nil {
+			response["formatted"] = format(response["converted"].(decimal.Decimal), to)
+		}
+
+		conversion := auditedConversion(result, result.Rate, amount, response["converted"].(decimal.Decimal), decimal.Zero)
+		conversion.Date = response["date"].(string)
+		s.audit(c.Request.Context(), requestSource(c), conversion)
+		countConversions(c, 1)
+	}
+
+	if notModified(c, dayMaxAge(date, result), result.Provider, result.Base, result.Target, result.Timestamp, result.Rate, response["formatted"], response["requestedDate"]) {
+		return
+	}
+	respond(c, http.StatusOK, response)
+}
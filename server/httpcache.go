@@ -0,0 +1,87 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateMaxAge is how long clients may reuse a response built from results:
+// what is left of the cache TTL of the stalest one.
+func rateMaxAge(results ...adapters.RateResult) time.Duration {
+	maxAge := time.Duration(-1)
+	for _, result := range results {
+		ttl := adapters.CacheTTL(result.Provider)
+		if result.Cached {
+			ttl -= time.Since(result.FetchedAt)
+		}
+		if maxAge < 0 || ttl < maxAge {
+			maxAge = ttl
+		}
+	}
+	if maxAge < 0 {
+		return 0
+	}
+	return maxAge
+}
+
+// dayMaxAge is how long a response with the rates of day stays fresh: past
+// days no longer change, today's rates follow results.
+func dayMaxAge(day time.Time, results ...adapters.RateResult) time.Duration {
+	if day.Before(time.Now().UTC().Truncate(24 * time.Hour)) {
+		return adapters.HistoricalCacheTTL
+	}
+	return rateMaxAge(results...)
+}
+
+// notModified sets the caching headers of a rate response: Cache-Control
+// for maxAge and a weak ETag over parts, which identify the rates served.
+// When the client's If-None-Match already holds the ETag it answers 304 and
+// returns true. Responses to authenticated clients can carry their markups,
+// so only those clients may cache them.
+func notModified(c *gin.Context, maxAge time.Duration, parts ...interface{}) bool {
+	visibility := "public"
+	if c.GetHeader("X-API-Key") != "" || c.GetHeader("Authorization") != "" {
+		visibility = "private"
+	}
+	if seconds := int(maxAge.Seconds()); seconds > 0 {
+		c.Header("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, seconds))
+	} else {
+		c.Header("Cache-Control", visibility+", no-cache")
+	}
+
+	if format := responseFormat(c); format != formatJSON {
+		// Each format is a representation of its own
+		parts = append(parts, format)
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintln(parts...)))
+	etag := `W/"` + hex.EncodeToString(sum[:12]) + `"`
+	c.Header("ETag", etag)
+	if !etagMatches(c.GetHeader("If-None-Match"), etag) {
+		return false
+	}
+	// The client already has the conversions, so none are made
+	countConversions(c, 0)
+	c.Status(http.StatusNotModified)
+	c.Writer.WriteHeaderNow()
+	return true
+}
+
+// etagMatches compares an If-None-Match list against etag the weak way, as
+// RFC 9110 asks for If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
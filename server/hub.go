@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/config"
+)
+
+// parsePair reads a pair written as USD/KHR or USD-KHR and normalizes both
+// currencies.
+func parsePair(input string) (adapters.Pair, error) {
+	parts := strings.FieldsFunc(input, func(r rune) bool { return r == '/' || r == '-' })
+	if len(parts) != 2 {
+		return adapters.Pair{}, fmt.Errorf("Invalid pair %q, expected FROM/TO", input)
+	}
+	from, to, err := normalizePair(parts[0], parts[1])
+	if err != nil {
+		return adapters.Pair{}, err
+	}
+	return adapters.Pair{From: from, To: to}, nil
+}
+
+// rateHub polls the pairs that streaming clients subscribed to and pushes
+// every rate change to them. Polls go through the rate cache, so upstream
+// traffic stays bounded by the cache TTL however many clients subscribe.
+type rateHub struct {
+	server   *Server
+	interval time.Duration
+	start    sync.Once
+
+	mu     sync.Mutex
+	subs   map[adapters.Pair]map[*subscriber]bool
+	latest map[adapters.Pair]adapters.RateResult
+}
+
+// subscriber receives the updates of the pairs it subscribed to.
+type subscriber struct {
+	updates chan adapters.RateResult
+}
+
+// subscriberBuffer is how many updates a subscriber may fall behind before
+// further updates are dropped for it.
+const subscriberBuffer = 64
+
+func newSubscriber() *subscriber {
+	return &subscriber{updates: make(chan adapters.RateResult, subscriberBuffer)}
+}
+
+func newRateHub(s *Server) *rateHub {
+	return &rateHub{
+		server:   s,
+		interval: config.Duration("EXCHANGER_STREAM_INTERVAL", time.Minute),
+		subs:     map[adapters.Pair]map[*subscriber]bool{},
+		latest:   map[adapters.Pair]adapters.RateResult{},
+	}
+}
+
+// subscribe adds sub to pair and sends it the latest known rate. A pair
+// without subscribers so far is fetched right away.
+func (h *rateHub) subscribe(sub *subscriber, pair adapters.Pair) {
+	h.start.Do(func() { go h.run() })
+
+	h.mu.Lock()
+	subs, watched := h.subs[pair]
+	if !watched {
+		subs = map[*subscriber]bool{}
+		h.subs[pair] = subs
+	}
+	subs[sub] = true
+	latest, known := h.latest[pair]
+	h.mu.Unlock()
+
+	if known {
+		h.deliver(sub, latest)
+	}
+	if !watched {
+		go h.poll([]adapters.Pair{pair})
+	}
+}
+
+// unsubscribe removes sub from pair. Pairs without subscribers stop being
+// polled.
+func (h *rateHub) unsubscribe(sub *subscriber, pair adapters.Pair) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs[pair], sub)
+	if len(h.subs[pair]) == 0 {
+		delete(h.subs, pair)
+		delete(h.latest, pair)
+	}
+}
+
+func (h *rateHub) run() {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.server.draining:
+			return
+		case <-ticker.C:
+		}
+
+		h.mu.Lock()
+		pairs := make([]adapters.Pair, 0, len(h.subs))
+		for pair := range h.subs {
+			pairs = append(pairs, pair)
+		}
+		h.mu.Unlock()
+
+		h.poll(pairs)
+	}
+}
+
+// poll fetches pairs from the server's default providers, one request per
+// base currency, and publishes the rates that changed.
+func (h *rateHub) poll(pairs []adapters.Pair) {
+	if len(pairs) == 0 {
+		return
+	}
+	providers, err := h.server.providers(h.server.source(""), "", nil)
+	if err != nil {
+		log.Printf("rate stream: %v", err)
+		return
+	}
+	adapter := h.server.chain(providers)
+
+	targets := map[string][]string{}
+	for _, pair := range pairs {
+		targets[pair.From] = append(targets[pair.From], pair.To)
+	}
+	for base, to := range targets {
+		results, err := adapters.FetchRates(context.Background(), adapter, base, to)
+		if err != nil {
+			log.Printf("rate stream %s: %v", base, err)
+			continue
+		}
+		for target, result := range results {
+			h.publish(adapters.Pair{From: base, To: target}, result)
+		}
+	}
+}
+
+// publish sends result to the subscribers of pair if the rate changed.
+func (h *rateHub) publish(pair adapters.Pair, result adapters.RateResult) {
+	h.mu.Lock()
+	subs, watched := h.subs[pair]
+	if !watched {
+		h.mu.Unlock()
+		return
+	}
+	if previous, ok := h.latest[pair]; ok && previous.Rate == result.Rate {
+		h.mu.Unlock()
+		return
+	}
+	h.latest[pair] = result
+	recipients := make([]*subscriber, 0, len(subs))
+	for sub := range subs {
+		recipients = append(recipients, sub)
+	}
+	h.mu.Unlock()
+
+	for _, sub := range recipients {
+		h.deliver(sub, result)
+	}
+}
+
+// deliver hands result to sub without blocking the hub on a slow client.
+func (h *rateHub) deliver(sub *subscriber, result adapters.RateResult) {
+	select {
+	case sub.updates <- result:
+	default:
+		log.Printf("rate stream: dropped %s/%s update for a slow subscriber", result.Base, result.Target)
+	}
+}
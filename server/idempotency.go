@@ -0,0 +1,242 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"cubetiq-samples/exchanger-go/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// idempotencyPendingTTL bounds how long a key stays claimed by a request
+	// that never completes, e.g. because its instance died.
+	idempotencyPendingTTL = 10 * time.Minute
+	// maxReplayBytes caps the responses kept for replay; larger ones, such
+	// as big file conversions, are served again in full on a retry.
+	maxReplayBytes = 16 << 20
+	// maxIdempotencyKeyLength bounds the keys clients choose.
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotencyHeaders are the request headers carrying an idempotency key,
+// the standard one first.
+var idempotencyHeaders = []string{"Idempotency-Key", "X-Idempotency-Key"}
+
+// storedResponse is the replayable response kept for an idempotency key.
+// Pending marks a key claimed by a request still being processed.
+type storedResponse struct {
+	Fingerprint string `json:"fingerprint"`
+	Pending     bool   `json:"pending,omitempty"`
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
+	Body        []byte `json:"body"`
+}
+
+// IdempotencyMiddleware replays the stored response when a request repeats an
+// Idempotency-Key (or X-Idempotency-Key) seen within ttl. This is
+// request-level deduplication and independent of any rate caching: the prior
+// response is returned verbatim. The key is claimed while the first request
+// is processed, so a retry racing it gets 409 instead of processing it twice.
+// Keys are scoped to the client's API key or bearer token.
+func IdempotencyMiddleware(store cache.Cache, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, header := idempotencyKey(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			abortError(c, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Idempotency key must not exceed %d characters", maxIdempotencyKeyLength), header)
+			return
+		}
+
+		fingerprint, cleanup, err := requestFingerprint(c)
+		if err != nil {
+			abortError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), "body")
+			return
+		}
+		defer cleanup()
+
+		cacheKey := "idempotency:" + credentialScope(c) + key
+		pending, _ := json.Marshal(storedResponse{Fingerprint: fingerprint, Pending: true})
+		if !claimKey(store, cacheKey, pending) {
+			var stored storedResponse
+			if data, ok := store.Get(cacheKey); ok && json.Unmarshal(data, &stored) == nil {
+				switch {
+				case stored.Fingerprint != fingerprint:
+					abortError(c, http.StatusConflict, CodeConflict, "Idempotency key was already used for a different request", header)
+				case stored.Pending:
+					c.Header("Retry-After", "1")
+					abortError(c, http.StatusConflict, CodeRequestInProgress, "A request with this idempotency key is still being processed", header)
+				default:
+					c.Header("Idempotent-Replayed", "true")
+					countConversions(c, 0)
+					c.Data(stored.Status, stored.ContentType, stored.Body)
+					c.Abort()
+				}
+				return
+			}
+			// The entry expired or was released meanwhile; go ahead
+		}
+
+		recorder := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Only successful responses are replayed; failures may be retried
+		if recorder.Status() < 200 || recorder.Status() >= 300 || recorder.overflow {
+			releaseKey(store, cacheKey)
+			return
+		}
+
+		data, err := json.Marshal(storedResponse{
+			Fingerprint: fingerprint,
+			Status:      recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err == nil {
+			store.Set(cacheKey, data, ttl)
+		}
+	}
+}
+
+// idempotencyKey returns the request's idempotency key and the header it came
+// in.
+func idempotencyKey(c *gin.Context) (string, string) {
+	for _, header := range idempotencyHeaders {
+		if key := c.GetHeader(header); key != "" {
+			return key, header
+		}
+	}
+	return "", ""
+}
+
+// claimKey stores the pending marker under key unless the key is taken, in
+// one step when store is a cache.Adder.
+func claimKey(store cache.Cache, key string, pending []byte) bool {
+	if adder, ok := store.(cache.Adder); ok {
+		return adder.Add(key, pending, idempotencyPendingTTL)
+	}
+	if _, ok := store.Get(key); ok {
+		return false
+	}
+	store.Set(key, pending, idempotencyPendingTTL)
+	return true
+}
+
+// releaseKey frees a claimed key for retries. Cache has no delete, so the
+// key is overwritten with an entry that expires at once.
+func releaseKey(store cache.Cache, key string) {
+	store.Set(key, nil, time.Millisecond)
+}
+
+// credentialScope keeps the keys of different clients apart, by a hash of
+// their API key or bearer token.
+func credentialScope(c *gin.Context) string {
+	apiKey, authorization := c.GetHeader("X-API-Key"), c.GetHeader("Authorization")
+	if apiKey == "" && authorization == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey + "\n" + authorization))
+	return hex.EncodeToString(sum[:8]) + ":"
+}
+
+// requestFingerprint identifies a request by method, full URI and body,
+// hashed so query-string credentials are never stored. A body is spooled to
+// disk to be hashed and then handed on; cleanup removes it.
+func requestFingerprint(c *gin.Context) (string, func(), error) {
+	fingerprint := sha256.New()
+	io.WriteString(fingerprint, c.Request.Method+" "+c.Request.URL.RequestURI()+"\n")
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return hex.EncodeToString(fingerprint.Sum(nil)), func() {}, nil
+	}
+
+	spooled, err := spool(http.MaxBytesReader(c.Writer, c.Request.Body, maxFileBytes))
+	if err != nil {
+		return "", nil, err
+	}
+	if err := hashBody(fingerprint, spooled, c.GetHeader("Content-Type")); err != nil {
+		spooled.Close()
+		return "", nil, err
+	}
+	c.Request.Body = spooled
+	return hex.EncodeToString(fingerprint.Sum(nil)), func() { spooled.Close() }, nil
+}
+
+// hashBody adds body to fingerprint and rewinds it. Multipart bodies are
+// hashed part by part, since clients pick a new boundary on every retry.
+func hashBody(fingerprint hash.Hash, body io.ReadSeeker, contentType string) error {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		hashed := hashParts(fingerprint, multipart.NewReader(body, params["boundary"]))
+		if _, err := body.Seek(0, io.SeekStart); err != nil || hashed {
+			return err
+		}
+	}
+	if _, err := io.Copy(fingerprint, body); err != nil {
+		return err
+	}
+	_, err := body.Seek(0, io.SeekStart)
+	return err
+}
+
+// hashParts adds the names and contents of every part to fingerprint,
+// reporting false for malformed multipart bodies.
+func hashParts(fingerprint hash.Hash, reader *multipart.Reader) bool {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+		fmt.Fprintf(fingerprint, "%q %q\n", part.FormName(), part.FileName())
+		if _, err := io.Copy(fingerprint, part); err != nil {
+			return false
+		}
+	}
+}
+
+// recordingWriter captures the response body while passing it through, up to
+// maxReplayBytes.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *recordingWriter) record(n int, write func()) {
+	if w.overflow {
+		return
+	}
+	if w.body.Len()+n > maxReplayBytes {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	write()
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.record(len(data), func() { w.body.Write(data) })
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.record(len(s), func() { w.body.WriteString(s) })
+	return w.ResponseWriter.WriteString(s)
+}
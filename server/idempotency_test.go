@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	stub := &stubAdapter{rate: 4100}
+	router := newTestServer(t, stub, Options{})
+	const target = "/api/v1/exchange?from=USD&to=KHR&amount=10"
+
+	first := serve(router, http.MethodGet, target, "", http.Header{"Idempotency-Key": {"a"}})
+	if first.Code != http.StatusOK || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first request = %d, replayed %q: %s", first.Code, first.Header().Get("Idempotent-Replayed"), first.Body)
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		header   http.Header
+		status   int
+		replayed bool
+	}{
+		{"repeated key", target, http.Header{"Idempotency-Key": {"a"}}, http.StatusOK, true},
+		{"repeated legacy header", target, http.Header{"X-Idempotency-Key": {"a"}}, http.StatusOK, true},
+		{"distinct key", target, http.Header{"Idempotency-Key": {"b"}}, http.StatusOK, false},
+		{"no key", target, nil, http.StatusOK, false},
+		{"other client", target, http.Header{"Idempotency-Key": {"a"}, "X-Api-Key": {"other"}}, http.StatusOK, false},
+		{"key reused for another request", "/api/v1/exchange?from=USD&to=KHR&amount=20", http.Header{"Idempotency-Key": {"a"}}, http.StatusConflict, false},
+	}
+	for _, test := range tests {
+		response := serve(router, http.MethodGet, test.target, "", test.header)
+		if response.Code != test.status {
+			t.Errorf("%s: status = %d, want %d: %s", test.name, response.Code, test.status, response.Body)
+			continue
+		}
+		replayed := response.Header().Get("Idempotent-Replayed") == "true"
+		if replayed != test.replayed {
+			t.Errorf("%s: replayed = %v, want %v", test.name, replayed, test.replayed)
+		}
+		if replayed && response.Body.String() != first.Body.String() {
+			t.Errorf("%s: replayed %s, want the first response %s", test.name, response.Body, first.Body)
+		}
+	}
+}
+
+func TestIdempotencyFailuresNotReplayed(t *testing.T) {
+	stub := &stubAdapter{rate: 4100}
+	router := newTestServer(t, stub, Options{})
+	header := http.Header{"Idempotency-Key": {"failed"}}
+
+	for i := 0; i < 2; i++ {
+		response := serve(router, http.MethodGet, "/api/v1/exchange?from=USD&to=KHR&amount=abc", "", header)
+		if response.Code != http.StatusBadRequest || response.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("attempt %d = %d, replayed %q, want a fresh 400", i+1, response.Code, response.Header().Get("Idempotent-Replayed"))
+		}
+	}
+}
+
+func TestIdempotencyBatch(t *testing.T) {
+	stub := &stubAdapter{rate: 4100}
+	router := newTestServer(t, stub, Options{})
+	header := http.Header{"Idempotency-Key": {"batch"}}
+	body := `[{"amount": 10, "from": "USD", "to": "KHR"}]`
+
+	first := serve(router, http.MethodPost, "/api/v1/exchange/batch", body, header)
+	second := serve(router, http.MethodPost, "/api/v1/exchange/batch", body, header)
+	if first.Code != http.StatusOK || second.Header().Get("Idempotent-Replayed") != "true" || second.Body.String() != first.Body.String() {
+		t.Errorf("repeated batch = %d, replayed %q, want the first response replayed", second.Code, second.Header().Get("Idempotent-Replayed"))
+	}
+
+	other := serve(router, http.MethodPost, "/api/v1/exchange/batch", `[{"amount": 20, "from": "USD", "to": "KHR"}]`, header)
+	if other.Code != http.StatusConflict {
+		t.Errorf("batch with a reused key = %d, want 409", other.Code)
+	}
+}
@@ -0,0 +1,288 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxImportBytes caps the size of an uploaded snapshot.
+	maxImportBytes = 64 << 20
+	// maxImportErrors is how many invalid rows a rejected import reports.
+	maxImportErrors = 20
+)
+
+// importRow is one rate of an imported snapshot. Pair, e.g. "USD/KHR", may
+// stand in for from and to.
+type importRow struct {
+	Pair     string  `json:"pair"`
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Rate     float64 `json:"rate"`
+	Date     string  `json:"date"`
+	Provider string  `json:"provider"`
+}
+
+// importError reports an invalid row by its 1-based number, counting a CSV
+// header as row 1.
+type importError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// ImportRatesHandler backfills the rate history from a CSV or JSON snapshot,
+// sent as the body or as the file field of a multipart form. CSV has a
+// header naming the from, to (or pair), rate, date and provider columns;
+// JSON is an array of the same fields. Rows lacking a provider take the
+// provider query parameter. Every row is checked first, and nothing is
+// imported unless all are valid.
+func (s *Server) ImportRatesHandler(c *gin.Context) {
+	if s.history == nil {
+		respondError(c, http.StatusNotImplemented, CodeFeatureDisabled, "Rate history is disabled", "")
+		return
+	}
+
+	body, name, err := uploadBody(c, maxImportBytes)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), "file")
+		return
+	}
+	defer body.Close()
+
+	var rows []importRow
+	var firstRow int
+	switch format := importFormat(c, name); format {
+	case "csv":
+		rows, err = readImportCSV(body)
+		firstRow = 2
+	case "json":
+		rows, err = readImportJSON(body)
+		firstRow = 1
+	default:
+		respondError(c, http.StatusUnsupportedMediaType, CodeInvalidRequest, "Snapshots must be CSV or JSON", "Content-Type")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, err.Error(), "file")
+		return
+	}
+	if len(rows) == 0 {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "The snapshot has no rates", "file")
+		return
+	}
+
+	// Check every row before recording any, grouped by provider and day
+	type group struct{ provider, day string }
+	groups := map[group][]adapters.RateResult{}
+	var invalid []importError
+	today := time.Now().UTC().Format("2006-01-02")
+	fetchedAt := time.Now()
+	for i, row := range rows {
+		result, day, err := s.importRate(row, c.Query("provider"), today)
+		if err != nil {
+			if len(invalid) < maxImportErrors {
+				invalid = append(invalid, importError{Row: firstRow + i, Message: err.Error()})
+			}
+			continue
+		}
+		result.FetchedAt = fetchedAt
+		key := group{result.Provider, day}
+		groups[key] = append(groups[key], result)
+	}
+	if len(invalid) > 0 {
+		response := errorBody(CodeInvalidRequest, fmt.Sprintf("The snapshot has invalid rows, starting at row %d", invalid[0].Row), "file", "")
+		response["rows"] = invalid
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	days := map[string]bool{}
+	providers := map[string]bool{}
+	for key, results := range groups {
+		if err := s.history.Record(key.provider, key.day, results); err != nil {
+			respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+			return
+		}
+		days[key.day] = true
+		providers[key.provider] = true
+	}
+
+	names := make([]string, 0, len(providers))
+	for provider := range providers {
+		names = append(names, provider)
+	}
+	sort.Strings(names)
+	c.JSON(http.StatusOK, gin.H{"imported": len(rows), "days": len(days), "providers": names})
+}
+
+// importRate validates row, returning it as a rate result and its day.
+func (s *Server) importRate(row importRow, defaultProvider, today string) (adapters.RateResult, string, error) {
+	from, to := row.From, row.To
+	if row.Pair != "" {
+		var ok bool
+		if from, to, ok = strings.Cut(row.Pair, "/"); !ok {
+			return adapters.RateResult{}, "", fmt.Errorf("pair %q must be FROM/TO", row.Pair)
+		}
+	}
+	from, to, err := normalizePair(from, to)
+	if err != nil {
+		return adapters.RateResult{}, "", err
+	}
+	if row.Rate <= 0 {
+		return adapters.RateResult{}, "", errors.New("rate must be positive")
+	}
+	date, err := time.Parse("2006-01-02", strings.TrimSpace(row.Date))
+	if err != nil {
+		return adapters.RateResult{}, "", fmt.Errorf("date %q must be YYYY-MM-DD", row.Date)
+	}
+	day := date.Format("2006-01-02")
+	if day > today {
+		return adapters.RateResult{}, "", fmt.Errorf("date %s is in the future", day)
+	}
+
+	// Rates are served by the source they are recorded under, so it must exist
+	provider := strings.TrimSpace(row.Provider)
+	if provider == "" {
+		provider = defaultProvider
+	}
+	if provider == "" {
+		return adapters.RateResult{}, "", errors.New("provider is required")
+	}
+	if _, ok := s.registry.New(provider, ""); !ok {
+		return adapters.RateResult{}, "", fmt.Errorf("unknown provider %q, expected one of %s", provider, strings.Join(s.registry.Names(), ", "))
+	}
+
+	return adapters.RateResult{
+		Rate:      row.Rate,
+		Base:      from,
+		Target:    to,
+		Timestamp: date,
+		Date:      day,
+		Provider:  provider,
+	}, day, nil
+}
+
+// uploadBody returns an uploaded file of at most limit bytes: the file field
+// of a multipart form, or else the request body. name is the uploaded file's
+// name.
+func uploadBody(c *gin.Context, limit int64) (io.ReadCloser, string, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return c.Request.Body, "", nil
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, "", errors.New("Upload the file as the file field")
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	return file, header.Filename, nil
+}
+
+// importFormat tells CSV from JSON by the uploaded file's extension, or else
+// the Content-Type.
+func importFormat(c *gin.Context, name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return "csv"
+	case ".json":
+		return "json"
+	}
+	switch mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type")); mediaType {
+	case "text/csv", "application/csv":
+		return "csv"
+	case "application/json":
+		return "json"
+	}
+	return ""
+}
+
+// csvColumns maps the lower-cased column names of a CSV header to their
+// indexes.
+func csvColumns(header []string) map[string]int {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	return columns
+}
+
+// readImportCSV reads rows by the column names of the header.
+func readImportCSV(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := csvColumns(header)
+	_, hasPair := columns["pair"]
+	_, hasFrom := columns["from"]
+	_, hasTo := columns["to"]
+	if !hasPair && !(hasFrom && hasTo) {
+		return nil, errors.New("The CSV header must name the pair or the from and to columns")
+	}
+	for _, name := range []string{"rate", "date"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("The CSV header must name the %s column", name)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// An unparsable rate stays 0 and is reported with its row
+		rate, _ := strconv.ParseFloat(field(record, "rate"), 64)
+		rows = append(rows, importRow{
+			Pair:     field(record, "pair"),
+			From:     field(record, "from"),
+			To:       field(record, "to"),
+			Rate:     rate,
+			Date:     field(record, "date"),
+			Provider: field(record, "provider"),
+		})
+	}
+}
+
+// readImportJSON reads an array of rows.
+func readImportJSON(r io.Reader) ([]importRow, error) {
+	var rows []importRow
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, errors.New("The JSON snapshot must be an array of {from, to, rate, date, provider}")
+	}
+	return rows, nil
+}
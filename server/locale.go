@@ -0,0 +1,194 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// amountFormatter renders an amount of a currency for display, e.g.
+// "$1,234.56" or "1.234,56 €".
+type amountFormatter func(amount decimal.Decimal, code string) string
+
+// requestFormatter returns the formatter of the locale the request asks for
+// with locale, e.g. locale=de-DE, or else its Accept-Language header, and nil
+// when it names neither. On failure it writes the error response and returns
+// false.
+func requestFormatter(c *gin.Context) (amountFormatter, bool) {
+	if input := c.Query("locale"); input != "" {
+		tag, err := language.Parse(input)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid locale, expected a BCP 47 tag such as en-US", "locale")
+			return nil, false
+		}
+		return localeFormatter(tag), true
+	}
+
+	header := c.GetHeader("Accept-Language")
+	if header == "" {
+		return nil, true
+	}
+	c.Writer.Header().Add("Vary", "Accept-Language")
+	// A malformed header is ignored, like browsers sending it unasked
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil {
+		return nil, true
+	}
+	for _, tag := range tags {
+		if tag != language.Und && tag.String() != "mul" {
+			return localeFormatter(tag), true
+		}
+	}
+	return nil, true
+}
+
+// localeFormatter formats amounts with tag's digits, separators and currency
+// symbols, to the decimal places the API rounds the currency to.
+func localeFormatter(tag language.Tag) amountFormatter {
+	printer := message.NewPrinter(tag)
+	placement := symbolPlacementOf(tag)
+	numerals := newLocaleNumerals(printer)
+	return func(amount decimal.Decimal, code string) string {
+		symbol := code
+		if unit, err := currency.ParseISO(code); err == nil {
+			symbol = printer.Sprint(currency.Symbol(unit))
+		}
+		digits := numerals.format(amount.Abs(), amountPlaces(code))
+
+		var formatted string
+		switch placement {
+		case symbolTrailing:
+			formatted = digits + nbsp + symbol
+		case symbolTrailingUnspaced:
+			formatted = digits + symbol
+		default:
+			// Symbols ending in a letter, such as KHR, are kept off the digits
+			last, _ := utf8.DecodeLastRuneInString(symbol)
+			if placement == symbolLeadingSpaced || unicode.IsLetter(last) {
+				symbol += nbsp
+			}
+			formatted = symbol + digits
+		}
+		if amount.IsNegative() {
+			formatted = "-" + formatted
+		}
+		return formatted
+	}
+}
+
+// localeNumerals writes decimals in the digits and separators of a locale.
+// x/text only formats machine numbers, so amounts are written from their
+// decimal digits instead, which a float64 would round past 15 or so.
+type localeNumerals struct {
+	printer *message.Printer
+	// digits are the locale's digits for 0 to 9.
+	digits  [10]string
+	decimal string
+	group   string
+}
+
+func newLocaleNumerals(printer *message.Printer) *localeNumerals {
+	numerals := &localeNumerals{printer: printer}
+	for i := range numerals.digits {
+		numerals.digits[i] = printer.Sprint(number.Decimal(i))
+	}
+	// The separators are what the locale writes between the digits of
+	// 1.5 and 1000
+	numerals.decimal = strings.TrimSuffix(strings.TrimPrefix(printer.Sprint(number.Decimal(1.5, number.Scale(1))), numerals.digits[1]), numerals.digits[5])
+	numerals.group = strings.TrimSuffix(strings.TrimPrefix(printer.Sprint(number.Decimal(1000)), numerals.digits[1]), strings.Repeat(numerals.digits[0], 3))
+	return numerals
+}
+
+// format writes amount, which isn't negative, rounded to places decimals.
+func (n *localeNumerals) format(amount decimal.Decimal, places int32) string {
+	whole, fraction, _ := strings.Cut(amount.StringFixed(places), ".")
+	var formatted string
+	if value, err := strconv.ParseInt(whole, 10, 64); err == nil {
+		// Integers format exactly, with the locale's own grouping
+		formatted = n.printer.Sprint(number.Decimal(value))
+	} else {
+		// Beyond int64, group by thousands
+		var grouped strings.Builder
+		for i, digit := range whole {
+			if i > 0 && (len(whole)-i)%3 == 0 {
+				grouped.WriteString(n.group)
+			}
+			grouped.WriteString(n.digits[digit-'0'])
+		}
+		formatted = grouped.String()
+	}
+	if fraction != "" {
+		formatted += n.decimal
+		for _, digit := range fraction {
+			formatted += n.digits[digit-'0']
+		}
+	}
+	return formatted
+}
+
+// nbsp keeps symbols and digits on one line, as CLDR patterns do.
+const nbsp = "\u00a0"
+
+// symbolPlacement is where a locale writes the currency symbol.
+type symbolPlacement int
+
+const (
+	symbolLeading symbolPlacement = iota
+	symbolLeadingSpaced
+	symbolTrailing
+	symbolTrailingUnspaced
+)
+
+// symbolPlacements follows the CLDR currency patterns of the locales that
+// don't lead with the symbol like en does. Locales not listed take the
+// placement of their parent, e.g. es-MX that of es-419.
+var symbolPlacements = map[string]symbolPlacement{
+	"de": symbolTrailing, "de-AT": symbolLeadingSpaced, "de-CH": symbolLeadingSpaced, "de-LI": symbolLeadingSpaced,
+	"es": symbolTrailing, "es-419": symbolLeading,
+	"it": symbolTrailing, "it-CH": symbolLeadingSpaced,
+	"pt": symbolLeadingSpaced, "pt-PT": symbolTrailing,
+	"nl": symbolLeadingSpaced,
+	"fr": symbolTrailing, "ru": symbolTrailing, "uk": symbolTrailing, "be": symbolTrailing,
+	"pl": symbolTrailing, "cs": symbolTrailing, "sk": symbolTrailing, "sl": symbolTrailing,
+	"hr": symbolTrailing, "sr": symbolTrailing, "bs": symbolTrailing, "bg": symbolTrailing,
+	"mk": symbolTrailing, "ro": symbolTrailing, "hu": symbolTrailing, "lt": symbolTrailing,
+	"lv": symbolTrailing, "et": symbolTrailing, "fi": symbolTrailing, "sv": symbolTrailing,
+	"nb": symbolTrailing, "nn": symbolTrailing, "no": symbolTrailing, "da": symbolTrailing,
+	"is": symbolTrailing, "el": symbolTrailing, "ca": symbolTrailing, "gl": symbolTrailing,
+	"eu": symbolTrailing, "vi": symbolTrailing, "hy": symbolTrailing, "ka": symbolTrailing,
+	"kk": symbolTrailing, "az": symbolTrailing,
+	"km": symbolTrailingUnspaced,
+}
+
+func symbolPlacementOf(tag language.Tag) symbolPlacement {
+	// Extensions, such as -u-nu-latn, don't move the symbol
+	base, script, region := tag.Raw()
+	tag, _ = language.Compose(base, script, region)
+	for {
+		if placement, ok := symbolPlacements[tag.String()]; ok {
+			return placement
+		}
+		if tag.IsRoot() {
+			return symbolLeading
+		}
+		tag = tag.Parent()
+	}
+}
+
+// formatAmounts formats each converted amount, keyed by currency.
+func formatAmounts(format amountFormatter, amounts map[string]decimal.Decimal) map[string]string {
+	formatted := make(map[string]string, len(amounts))
+	for code, amount := range amounts {
+		formatted[code] = format(amount, code)
+	}
+	return formatted
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID, both from callers that already
+// have one and back to the client.
+const RequestIDHeader = "X-Request-ID"
+
+const (
+	requestIDKey   = "requestID"
+	logProviderKey = "logProvider"
+)
+
+// validRequestID bounds propagated request IDs to characters that are safe to
+// log and echo.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:\-]{1,128}$`)
+
+// RequestLogger writes one structured log line per request with its request
+// ID, route, currency pair, provider, status, duration and outcome. A valid
+// X-Request-ID header is reused, otherwise a new ID is generated; either way
+// it is echoed in the response. The query string is left out, as it may hold
+// API keys.
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+
+		span := trace.SpanFromContext(c.Request.Context())
+		span.SetAttributes(attribute.String("http.request_id", id))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level, outcome := zerolog.InfoLevel, "ok"
+		switch {
+		case status >= http.StatusInternalServerError:
+			level, outcome = zerolog.ErrorLevel, "failed"
+		case status >= http.StatusBadRequest:
+			level, outcome = zerolog.WarnLevel, "rejected"
+		}
+
+		event := log.WithLevel(level).
+			Str("request_id", id).
+			Str("method", c.Request.Method).
+			Str("path", c.Request.URL.Path).
+			Str("route", c.FullPath()).
+			Int("status", status).
+			Dur("duration_ms", time.Since(start)).
+			Str("outcome", outcome).
+			Str("client_ip", c.ClientIP())
+		if from := c.Query("from"); from != "" {
+			event = event.Str("from", from)
+		}
+		if to := c.Query("to"); to != "" {
+			event = event.Str("to", to)
+		}
+		if provider := c.GetString(logProviderKey); provider != "" {
+			event = event.Str("provider", provider)
+		}
+		if err := c.Errors.Last(); err != nil {
+			event = event.Str("error", err.Error())
+		}
+		if span.SpanContext().HasTraceID() {
+			event = event.Str("trace_id", span.SpanContext().TraceID().String())
+		}
+		event.Msg("request")
+	}
+}
+
+// logProvider notes the provider that answered the request for its log line.
+func logProvider(c *gin.Context, provider string) {
+	c.Set(logProviderKey, provider)
+}
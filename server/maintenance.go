@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maintenanceExempt lists the routes that keep serving during maintenance.
+var maintenanceExempt = map[string]bool{
+	"/health":        true,
+	"/api/v1/health": true,
+	"/metrics":       true,
+	"/healthz":       true,
+	"/readyz":        true,
+	"/status":        true,
+}
+
+// MaintenanceMiddleware rejects every non-exempt request with 503 and a
+// Retry-After header while the service is under maintenance.
+func MaintenanceMiddleware(retryAfter int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maintenanceExempt[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		body := errorBody(CodeMaintenance, "Service is under maintenance, please retry later", "", "")
+		body["retryAfter"] = retryAfter
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, body)
+	}
+}
+
+// HealthHandler reports that the service is up.
+func HealthHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
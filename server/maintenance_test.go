@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	stub := &stubAdapter{rate: 4100}
+	router := newTestServer(t, stub, Options{Maintenance: true, MaintenanceRetryAfter: 120})
+
+	tests := []struct {
+		method, target, body string
+		status               int
+	}{
+		{http.MethodGet, "/api/v1/exchange?from=USD&to=KHR&amount=10", "", http.StatusServiceUnavailable},
+		{http.MethodPost, "/api/v1/exchange/batch", `[{"amount": 10, "from": "USD", "to": "KHR"}]`, http.StatusServiceUnavailable},
+		{http.MethodGet, "/api/v1/rates?base=USD", "", http.StatusServiceUnavailable},
+		{http.MethodGet, "/health", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/health", "", http.StatusOK},
+		{http.MethodGet, "/healthz", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		response := serve(router, test.method, test.target, test.body, nil)
+		if response.Code != test.status {
+			t.Errorf("%s %s = %d, want %d: %s", test.method, test.target, response.Code, test.status, response.Body)
+			continue
+		}
+		if test.status != http.StatusServiceUnavailable {
+			continue
+		}
+		if retryAfter := response.Header().Get("Retry-After"); retryAfter != "120" {
+			t.Errorf("%s %s Retry-After = %q, want 120", test.method, test.target, retryAfter)
+		}
+		if body := decode(t, response); body["code"] != CodeMaintenance || body["retryAfter"] != 120.0 {
+			t.Errorf("%s %s body = %v, want a %s error", test.method, test.target, body, CodeMaintenance)
+		}
+	}
+	if stub.calls != 0 {
+		t.Errorf("the provider was called %d times during maintenance", stub.calls)
+	}
+
+	// Outside maintenance the same conversion goes through
+	router = newTestServer(t, stub, Options{})
+	if response := serve(router, http.MethodGet, "/api/v1/exchange?from=USD&to=KHR&amount=10", "", nil); response.Code != http.StatusOK {
+		t.Errorf("GET /api/v1/exchange outside maintenance = %d, want 200: %s", response.Code, response.Body)
+	}
+}
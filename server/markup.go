@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// Markup is the margin applied on top of the mid-market rate: Percent lowers
+// the customer rate by that percentage and Fee is a fixed charge in the
+// source currency.
+type Markup struct {
+	Percent decimal.Decimal `json:"percent"`
+	Fee     decimal.Decimal `json:"fee"`
+}
+
+// MarkupConfig holds the configured markups, in the layout of
+// EXCHANGER_MARKUP_FILE. Keys are matched
+// against the caller's X-API-Key header and pairs are written "USD/KHR".
+type MarkupConfig struct {
+	Default *Markup           `json:"default"`
+	Pairs   map[string]Markup `json:"pairs"`
+	Keys    map[string]Markup `json:"keys"`
+}
+
+// markupLookup returns the markup for a pair, or false when none applies.
+type markupLookup func(from, to string) (Markup, bool)
+
+// LoadMarkups reads the markup file at path and checks every markup in it.
+func LoadMarkups(path string) (MarkupConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return MarkupConfig{}, err
+	}
+
+	var config MarkupConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return MarkupConfig{}, err
+	}
+	if err := config.validate(); err != nil {
+		return MarkupConfig{}, err
+	}
+	return config, nil
+}
+
+// validate checks the default markup and those of the pairs and keys, in a
+// stable order so the reported one is the same on every load.
+func (m MarkupConfig) validate() error {
+	if m.Default != nil {
+		if err := m.Default.validate(); err != nil {
+			return fmt.Errorf("default: %w", err)
+		}
+	}
+	for _, group := range []struct {
+		name    string
+		markups map[string]Markup
+	}{{"pair", m.Pairs}, {"key", m.Keys}} {
+		names := make([]string, 0, len(group.markups))
+		for name := range group.markups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := group.markups[name].validate(); err != nil {
+				return fmt.Errorf("%s %s: %w", group.name, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate rejects markups that would quote a customer rate of zero or below,
+// or credit the customer a fee.
+func (m Markup) validate() error {
+	if m.Percent.IsNegative() || m.Percent.GreaterThanOrEqual(decimal.NewFromInt(100)) {
+		return fmt.Errorf("percent %s must be at least 0 and below 100", m.Percent)
+	}
+	if m.Fee.IsNegative() {
+		return fmt.Errorf("fee %s must not be negative", m.Fee)
+	}
+	return nil
+}
+
+// EnvMarkup returns the global markup set by EXCHANGER_MARKUP_PERCENT and
+// EXCHANGER_MARKUP_FEE, or nil when neither is set.
+func EnvMarkup() (*Markup, error) {
+	percent, fee := os.Getenv("EXCHANGER_MARKUP_PERCENT"), os.Getenv("EXCHANGER_MARKUP_FEE")
+	if percent == "" && fee == "" {
+		return nil, nil
+	}
+
+	var markup Markup
+	var err error
+	if percent != "" {
+		if markup.Percent, err = decimal.NewFromString(percent); err != nil {
+			return nil, err
+		}
+	}
+	if fee != "" {
+		if markup.Fee, err = decimal.NewFromString(fee); err != nil {
+			return nil, err
+		}
+	}
+	if err := markup.validate(); err != nil {
+		return nil, err
+	}
+	return &markup, nil
+}
+
+// markupFor picks the most specific markup for the caller and pair: the API
+// key's, then the pair's, then the default.
+func (m MarkupConfig) markupFor(apiKey, from, to string) (Markup, bool) {
+	if markup, ok := m.Keys[apiKey]; ok && apiKey != "" {
+		return markup, true
+	}
+	if markup, ok := m.Pairs[from+"/"+to]; ok {
+		return markup, true
+	}
+	if m.Default != nil {
+		return *m.Default, true
+	}
+	return Markup{}, false
+}
+
+// requestMarkups returns the markup lookup for the request's caller.
+func (s *Server) requestMarkups(c *gin.Context) markupLookup {
+	apiKey := c.GetHeader("X-API-Key")
+	markups := s.current().markups
+	return func(from, to string) (Markup, bool) {
+		return markups.markupFor(apiKey, from, to)
+	}
+}
+
+// effectiveRate is the customer rate for the mid-market rate mid.
+func (m Markup) effectiveRate(mid float64) float64 {
+	hundred := decimal.NewFromInt(100)
+	factor := hundred.Sub(m.Percent).Div(hundred)
+	return decimal.NewFromFloat(mid).Mul(factor).InexactFloat64()
+}
+
+// fee returns the fixed fee rounded to currency's minor unit.
+func (m Markup) fee(currency string) decimal.Decimal {
+	return roundAmount(m.Fee, currency)
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestEnvMarkupRejected(t *testing.T) {
+	tests := []struct {
+		percent, fee string
+		reason       string
+	}{
+		{"100", "", "percent 100 must be at least 0 and below 100"},
+		{"150", "", "percent 150 must be at least 0 and below 100"},
+		{"-1", "", "percent -1 must be at least 0 and below 100"},
+		{"1", "-0.5", "fee -0.5 must not be negative"},
+	}
+	for _, test := range tests {
+		t.Setenv("EXCHANGER_MARKUP_PERCENT", test.percent)
+		t.Setenv("EXCHANGER_MARKUP_FEE", test.fee)
+		if _, err := EnvMarkup(); err == nil || err.Error() != test.reason {
+			t.Errorf("percent %q fee %q: error = %v, want %q", test.percent, test.fee, err, test.reason)
+		}
+	}
+
+	t.Setenv("EXCHANGER_MARKUP_PERCENT", "99.5")
+	t.Setenv("EXCHANGER_MARKUP_FEE", "0")
+	if markup, err := EnvMarkup(); err != nil || markup.Percent.String() != "99.5" {
+		t.Errorf("EnvMarkup() = %v, %v, want a 99.5%% markup", markup, err)
+	}
+}
+
+func TestLoadMarkupsRejected(t *testing.T) {
+	tests := []struct {
+		file   string
+		reason string
+	}{
+		{`{"default": {"percent": 100}}`, "default: percent 100"},
+		{`{"pairs": {"USD/KHR": {"percent": 2}, "USD/EUR": {"fee": -1}}}`, "pair USD/EUR: fee -1"},
+		{`{"keys": {"partner": {"percent": -0.5}}}`, "key partner: percent -0.5"},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "markups.json")
+		if err := os.WriteFile(path, []byte(test.file), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadMarkups(path); err == nil || !strings.HasPrefix(err.Error(), test.reason) {
+			t.Errorf("%s: error = %v, want %q", test.file, err, test.reason)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "markups.json")
+	os.WriteFile(path, []byte(`{"default": {"percent": 1}, "pairs": {"USD/KHR": {"percent": 0, "fee": 1.25}}}`), 0o600)
+	if _, err := LoadMarkups(path); err != nil {
+		t.Errorf("valid markups rejected: %v", err)
+	}
+}
+
+func TestReloadRejectsMarkup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "markups.json")
+	os.WriteFile(path, []byte(`{"default": {"percent": 1}}`), 0o600)
+	load := func(opts *Options) error {
+		markups, err := LoadMarkups(path)
+		opts.Markups = markups
+		return err
+	}
+	var opts Options
+	if err := load(&opts); err != nil {
+		t.Fatal(err)
+	}
+	opts.Reload = load
+	srv := New(opts)
+
+	os.WriteFile(path, []byte(`{"default": {"percent": 120}}`), 0o600)
+	if err := srv.Reload(); err == nil {
+		t.Fatal("reload accepted a 120% markup")
+	}
+	if markup, ok := srv.current().markups.markupFor("", "USD", "KHR"); !ok || markup.Percent.String() != "1" {
+		t.Errorf("markup after the failed reload = %v, want the 1%% one kept", markup)
+	}
+}
+
+func TestMultiTargetMarkupTotals(t *testing.T) {
+	markups := MarkupConfig{Pairs: map[string]Markup{
+		"USD/KHR": {Percent: decimal.NewFromInt(2), Fee: decimal.RequireFromString("1.25")},
+		"USD/EUR": {Percent: decimal.NewFromInt(1)},
+	}}
+	router := newTestServer(t, &stubAdapter{rate: 4100}, Options{Markups: markups})
+
+	body := decode(t, serve(router, http.MethodGet, "/api/v1/exchange?from=USD&to=KHR,EUR,JPY&amount=100", "", nil))
+	want := map[string]interface{}{"KHR": 101.25, "EUR": 100.0}
+	if totals, _ := body["totals"].(map[string]interface{}); !reflect.DeepEqual(totals, want) {
+		t.Errorf("totals = %v, want %v", body["totals"], want)
+	}
+
+	// Each total matches the single-target response for its pair
+	for target, total := range want {
+		single := decode(t, serve(router, http.MethodGet, "/api/v1/exchange?from=USD&amount=100&to="+target, "", nil))
+		if single["total"] != total {
+			t.Errorf("%s: single-target total = %v, want %v", target, single["total"], total)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"cubetiq-samples/exchanger-go/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsMiddleware records the count and duration of every request by
+// route pattern.
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequests.WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(route, c.Request.Method).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"cubetiq-samples/exchanger-go/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+func init() {
+	// Keep amounts as JSON numbers, as they were before switching to decimals
+	decimal.MarshalJSONWithoutQuotes = true
+}
+
+// Rounding modes accepted by EXCHANGER_ROUNDING.
+const (
+	roundHalfUp   = "half-up"
+	roundHalfEven = "bankers"
+)
+
+// defaultPrecision is the number of decimal places kept for currencies
+// without an ISO 4217 exponent, such as crypto, unless EXCHANGER_PRECISION
+// says otherwise.
+const defaultPrecision = 8
+
+func roundingMode() string {
+	return os.Getenv("EXCHANGER_ROUNDING")
+}
+
+func roundingPrecision() int32 {
+	return int32(config.Int("EXCHANGER_PRECISION", defaultPrecision))
+}
+
+// CheckRounding validates the configured rounding mode.
+func CheckRounding() error {
+	switch mode := roundingMode(); mode {
+	case "", roundHalfUp, roundHalfEven, "half-even":
+		return nil
+	default:
+		return fmt.Errorf("unknown rounding mode %q, expected %s or %s", mode, roundHalfUp, roundHalfEven)
+	}
+}
+
+// defaultMaxAmount caps amounts unless EXCHANGER_MAX_AMOUNT says otherwise.
+var defaultMaxAmount = decimal.New(1, 15)
+
+// maxAmountPlaces bounds the decimal places of amounts, so an amount such as
+// 1e-1000000 isn't carried around as a million digits.
+const maxAmountPlaces = 18
+
+func maxAmount() decimal.Decimal {
+	if max, err := decimal.NewFromString(os.Getenv("EXCHANGER_MAX_AMOUNT")); err == nil && max.IsPositive() {
+		return max
+	}
+	return defaultMaxAmount
+}
+
+// parseAmount reads a decimal amount without going through float64 and
+// checks it with checkAmount.
+func parseAmount(s string) (decimal.Decimal, error) {
+	amount, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Decimal{}, errors.New("must be a decimal number such as 100 or 12.50")
+	}
+	return amount, checkAmount(amount)
+}
+
+// parseMinorAmount reads an amount of currency given as an integer number of
+// its minor units, e.g. 12345 cents for 123.45 USD or 12345 yen for
+// 12345 JPY, and checks it with checkAmount.
+func parseMinorAmount(s, currency string) (decimal.Decimal, error) {
+	units, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return decimal.Decimal{}, fmt.Errorf("must be an integer number of %s minor units", currency)
+	}
+	amount := decimal.New(units, -amountPlaces(currency))
+	return amount, checkAmount(amount)
+}
+
+// checkAmount accepts the amounts the API converts: greater than zero, no
+// larger than EXCHANGER_MAX_AMOUNT and with at most maxAmountPlaces decimal
+// places. Its errors complete a sentence about the amount.
+func checkAmount(amount decimal.Decimal) error {
+	if !amount.IsPositive() {
+		return errors.New("must be greater than zero")
+	}
+	if max := maxAmount(); amount.GreaterThan(max) {
+		return fmt.Errorf("must not exceed %s", max)
+	}
+	if !amount.Round(maxAmountPlaces).Equal(amount) {
+		return fmt.Errorf("must not have more than %d decimal places", maxAmountPlaces)
+	}
+	return nil
+}
+
+// minorUnits expresses amount of currency as an integer number of its minor
+// units, rounding it like converted amounts are.
+func minorUnits(amount decimal.Decimal, currency string) decimal.Decimal {
+	return roundAmount(amount, currency).Shift(amountPlaces(currency))
+}
+
+// minorAmounts expresses each amount in the minor units of its currency.
+func minorAmounts(amounts map[string]decimal.Decimal) map[string]decimal.Decimal {
+	minor := make(map[string]decimal.Decimal, len(amounts))
+	for currency, amount := range amounts {
+		minor[currency] = minorUnits(amount, currency)
+	}
+	return minor
+}
+
+// convertAmount converts amount at rate into currency and rounds the result
+// to the currency's minor unit, unless raw asks for full precision.
+// decimal.NewFromFloat recovers the shortest decimal form of the rate, i.e.
+// the digits the provider sent.
+func convertAmount(amount decimal.Decimal, rate float64, currency string, raw bool) decimal.Decimal {
+	converted := amount.Mul(decimal.NewFromFloat(rate))
+	if raw {
+		return converted
+	}
+	return roundAmount(converted, currency)
+}
+
+// effectiveRate is the single rate a conversion comes to once its markup, fee
+// and rounding are applied: converted per unit paid, i.e. amount plus fee.
+// Nothing paid has a rate of 0 rather than a division by zero.
+func effectiveRate(amount, fee, converted decimal.Decimal) float64 {
+	paid := amount.Add(fee)
+	if paid.IsZero() {
+		return 0
+	}
+	return converted.Div(paid).InexactFloat64()
+}
+
+// ConvertAmount converts amount at rate into currency and rounds the result
+// like the API does, for programs that convert without the HTTP layer.
+func ConvertAmount(amount decimal.Decimal, rate float64, currency string) decimal.Decimal {
+	return convertAmount(amount, rate, currency, false)
+}
+
+// roundAmount rounds amount to the ISO 4217 exponent of currency, e.g. 0
+// places for JPY and 3 for KWD, or to the configured precision for other
+// currencies, using the configured rounding mode.
+func roundAmount(amount decimal.Decimal, currency string) decimal.Decimal {
+	places := amountPlaces(currency)
+	if mode := roundingMode(); mode == roundHalfEven || mode == "half-even" {
+		return amount.RoundBank(places)
+	}
+	return amount.Round(places)
+}
+
+// amountPlaces is the number of decimal places amounts of currency are
+// rounded to.
+func amountPlaces(currency string) int32 {
+	if iso, ok := isoCurrencies[currency]; ok {
+		return int32(iso.Exponent)
+	}
+	return roundingPrecision()
+}
+
+// sourceAmount solves a conversion backwards: it returns the least amount of
+// from, in from's minor unit, that converts at rate into at least target of
+// to once rounded, so converting it forward gives target whenever from's
+// minor unit allows. With raw it is the exact quotient.
+func sourceAmount(target decimal.Decimal, rate float64, from, to string, raw bool) decimal.Decimal {
+	if raw {
+		return target.Div(decimal.NewFromFloat(rate))
+	}
+	// Rounding half up reaches target from half a minor unit of to below it
+	half := decimal.New(5, -amountPlaces(to)-1)
+	places := amountPlaces(from)
+	amount := target.Sub(half).Div(decimal.NewFromFloat(rate)).RoundCeil(places)
+	if convertAmount(amount, rate, to, false).LessThan(target) {
+		// Bankers' rounding can round that half down
+		amount = amount.Add(decimal.New(1, -places))
+	}
+	return amount
+}
+
+// rawAmounts reports whether the request opted out of rounding with raw=true.
+func rawAmounts(c *gin.Context) bool {
+	return c.Query("raw") == "true"
+}
+
+// verboseAmounts reports whether the request asked for the effective rate
+// with verbose=true.
+func verboseAmounts(c *gin.Context) bool {
+	return c.Query("verbose") == "true"
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/config"
+	"cubetiq-samples/exchanger-go/history"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// multiExchange converts amount into each target currency and builds the
+// response body for a multi-target /exchange request, along with the rates
+// it used and the errors of the targets that failed. It only fails when
+// every target does.
+func multiExchange(ctx context.Context, adapter adapters.ExchangeRateAdapter, amount decimal.Decimal, from string, to []string, raw bool, markupOf markupLookup) (gin.H, map[string]adapters.RateResult, map[string]error, error) {
+	results, err := adapters.FetchRates(ctx, adapter, from, to)
+	var failed map[string]error
+	if err != nil {
+		// Look the targets up one by one to tell which failed, so that the
+		// others still convert
+		results, failed = fetchTargets(ctx, adapter, from, to)
+		if len(results) == 0 {
+			return nil, nil, nil, err
+		}
+	}
+
+	// Marked up targets quote the customer rate, with the mid-market rate,
+	// fee and total charged alongside
+	midRates := map[string]float64{}
+	fees := map[string]decimal.Decimal{}
+	totals := map[string]decimal.Decimal{}
+	for target, result := range results {
+		if markup, ok := markupOf(from, target); ok {
+			midRates[target] = result.Rate
+			fees[target] = markup.fee(from)
+			totals[target] = amount.Add(fees[target])
+			result.Rate = markup.effectiveRate(result.Rate)
+			results[target] = result
+		}
+	}
+
+	converted := make(map[string]decimal.Decimal, len(results))
+	for target, result := range results {
+		converted[target] = convertAmount(amount, result.Rate, target, raw)
+	}
+
+	response := rateSummary(results)
+	response["from"] = from
+	response["amount"] = amount
+	response["converted"] = converted
+	if len(midRates) > 0 {
+		response["midRates"] = midRates
+		response["fees"] = fees
+		response["totals"] = totals
+	}
+	return response, results, failed, nil
+}
+
+// fetchTargets looks up the rate from from to each currency in to on its
+// own, returning the results of those that succeeded and the errors of the
+// others.
+func fetchTargets(ctx context.Context, adapter adapters.ExchangeRateAdapter, from string, to []string) (map[string]adapters.RateResult, map[string]error) {
+	pairs := make([]adapters.Pair, len(to))
+	for i, target := range to {
+		pairs[i] = adapters.Pair{From: from, To: target}
+	}
+
+	results := map[string]adapters.RateResult{}
+	failed := map[string]error{}
+	for pair, rate := range adapters.FetchPairs(ctx, adapter, pairs, config.Int("EXCHANGER_BATCH_WORKERS", 8)) {
+		if rate.Err != nil {
+			failed[pair.To] = rate.Err
+			continue
+		}
+		results[pair.To] = rate.Result
+	}
+	return results, failed
+}
+
+// multiConversions lists the conversions of a response multiExchange built
+// from results, for the audit log.
+func multiConversions(response gin.H, results map[string]adapters.RateResult) []history.Conversion {
+	amount := response["amount"].(decimal.Decimal)
+	converted := response["converted"].(map[string]decimal.Decimal)
+	midRates, _ := response["midRates"].(map[string]float64)
+	fees, _ := response["fees"].(map[string]decimal.Decimal)
+
+	targets := make([]string, 0, len(results))
+	for target := range results {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	conversions := make([]history.Conversion, 0, len(results))
+	for _, target := range targets {
+		result := results[target]
+		rate := result.Rate
+		if midRate, ok := midRates[target]; ok {
+			result.Rate = midRate
+		}
+		conversions = append(conversions, auditedConversion(result, rate, amount, converted[target], fees[target]))
+	}
+	return conversions
+}
+
+// rateValues lists results for rateMaxAge.
+func rateValues(results map[string]adapters.RateResult) []adapters.RateResult {
+	values := make([]adapters.RateResult, 0, len(results))
+	for _, result := range results {
+		values = append(values, result)
+	}
+	return values
+}
+
+// rateSummary flattens per-target results into the rates map, answering
+// provider, latest timestamp and whether every rate came from the cache.
+// Targets answered by a rate override are listed as overridden, and name the
+// provider only when all are. Crossed targets have their path in paths, and
+// consensus rates their providers' quotes in quotes. When stale cached rates
+// stood in for failing providers, stale is set with the age of the oldest.
+func rateSummary(results map[string]adapters.RateResult) gin.H {
+	rates := make(map[string]float64, len(results))
+	paths := map[string][]string{}
+	quotes := map[string][]adapters.ConsensusQuote{}
+	var provider string
+	var overridden []string
+	var timestamp time.Time
+	var staleAge time.Duration
+	cached := len(results) > 0
+	for target, result := range results {
+		rates[target] = result.Rate
+		if result.Provider == "override" {
+			overridden = append(overridden, target)
+		}
+		if result.Provider != "override" || provider == "" {
+			provider = result.Provider
+		}
+		if result.Timestamp.After(timestamp) {
+			timestamp = result.Timestamp
+		}
+		cached = cached && result.Cached
+		if age := time.Since(result.FetchedAt); result.Stale && age > staleAge {
+			staleAge = age
+		}
+		if result.Path != nil {
+			paths[target] = result.Path
+		}
+		if result.Quotes != nil {
+			quotes[target] = result.Quotes
+		}
+	}
+
+	summary := gin.H{
+		"provider":  provider,
+		"rates":     rates,
+		"timestamp": timestamp,
+		"cached":    cached,
+	}
+	if len(overridden) > 0 {
+		sort.Strings(overridden)
+		summary["overridden"] = overridden
+	}
+	if len(paths) > 0 {
+		summary["paths"] = paths
+	}
+	if len(quotes) > 0 {
+		summary["quotes"] = quotes
+	}
+	if staleAge > 0 {
+		summary["stale"] = true
+		summary["cacheAge"] = staleAge.Seconds()
+	}
+	return summary
+}
+
+// splitCurrencies splits a comma-separated currency list, dropping blanks and
+// duplicates.
+func splitCurrencies(list string) []string {
+	var currencies []string
+	seen := map[string]bool{}
+	for _, currency := range strings.Split(list, ",") {
+		currency = strings.TrimSpace(currency)
+		if currency != "" && !seen[currency] {
+			seen[currency] = true
+			currencies = append(currencies, currency)
+		}
+	}
+	return currencies
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// notifyTimeout bounds posting one notification.
+const notifyTimeout = 10 * time.Second
+
+// notifier posts human-readable notices to a chat channel.
+type notifier interface {
+	notify(ctx context.Context, text string) error
+}
+
+// slackNotifier posts to a Slack incoming webhook.
+type slackNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func (n slackNotifier) notify(ctx context.Context, text string) error {
+	return postJSON(ctx, n.client, n.url, map[string]string{"text": text})
+}
+
+// telegramNotifier sends messages to a chat as a Telegram bot.
+type telegramNotifier struct {
+	client *http.Client
+	api    string
+	token  string
+	chatID string
+}
+
+func (n telegramNotifier) notify(ctx context.Context, text string) error {
+	url := strings.TrimSuffix(n.api, "/") + "/bot" + n.token + "/sendMessage"
+	err := postJSON(ctx, n.client, url, map[string]string{"chat_id": n.chatID, "text": text})
+	if err != nil {
+		// The URL carries the bot token, so it stays out of the error
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return err
+	}
+	return nil
+}
+
+// postJSON POSTs body as JSON and fails on answers other than 2xx.
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return &webhookError{status: response.StatusCode}
+	}
+	return nil
+}
+
+// newNotifiers returns the channels configured in the environment: slack
+// with EXCHANGER_SLACK_WEBHOOK_URL, and telegram with
+// EXCHANGER_TELEGRAM_BOT_TOKEN and EXCHANGER_TELEGRAM_CHAT_ID.
+func newNotifiers() map[string]notifier {
+	client := &http.Client{Timeout: notifyTimeout}
+	notifiers := map[string]notifier{}
+	if url := os.Getenv("EXCHANGER_SLACK_WEBHOOK_URL"); url != "" {
+		notifiers["slack"] = slackNotifier{client: client, url: url}
+	}
+	token, chatID := os.Getenv("EXCHANGER_TELEGRAM_BOT_TOKEN"), os.Getenv("EXCHANGER_TELEGRAM_CHAT_ID")
+	if token != "" && chatID != "" {
+		api := os.Getenv("EXCHANGER_TELEGRAM_API_URL")
+		if api == "" {
+			api = "https://api.telegram.org"
+		}
+		notifiers["telegram"] = telegramNotifier{client: client, api: api, token: token, chatID: chatID}
+	} else if token != "" || chatID != "" {
+		log.Printf("notify: telegram needs both EXCHANGER_TELEGRAM_BOT_TOKEN and EXCHANGER_TELEGRAM_CHAT_ID")
+	}
+	return notifiers
+}
+
+// channelNames lists the configured channels in order.
+func (s *Server) channelNames() []string {
+	notifiers := s.current().notifiers
+	names := make([]string, 0, len(notifiers))
+	for name := range notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// notify posts text to channels, logging the ones that fail.
+func (s *Server) notify(ctx context.Context, channels []string, text string) {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	notifiers := s.current().notifiers
+	for _, channel := range channels {
+		notifier, ok := notifiers[channel]
+		if !ok {
+			log.Printf("notify: %s is not configured", channel)
+			continue
+		}
+		if err := notifier.notify(ctx, text); err != nil {
+			log.Printf("notify: %s: %v", channel, err)
+		}
+	}
+}
+
+// alertText describes an alert event for a chat channel, e.g.
+// "USD/KHR rose above 4200: 4215 (was 4190, fixerio)".
+func alertText(event alertEvent) string {
+	alert := event.Alert
+	pair := alert.From + "/" + alert.To
+	var what string
+	was := event.PreviousRate
+	switch event.Event {
+	case "above":
+		what = fmt.Sprintf("rose above %g", *alert.Above)
+	case "below":
+		what = fmt.Sprintf("fell below %g", *alert.Below)
+	default:
+		change := (event.Rate - event.Baseline) / event.Baseline * 100
+		what = fmt.Sprintf("moved %+.2f%%", change)
+		was = event.Baseline
+	}
+	return fmt.Sprintf("%s %s: %g (was %g, %s)", pair, what, event.Rate, was, event.Provider)
+}
+
+// outageChannels are the channels of EXCHANGER_OUTAGE_NOTIFY, which hear of
+// providers whose circuit breaker opens or closes.
+func outageChannels() []string {
+	var channels []string
+	for _, channel := range strings.Split(os.Getenv("EXCHANGER_OUTAGE_NOTIFY"), ",") {
+		if channel = strings.TrimSpace(channel); channel != "" {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// notifyOutage posts that provider went down or recovered to the outage
+// channels.
+func (s *Server) notifyOutage(provider string, open bool, err error) {
+	channels := s.current().outageChannels
+	if len(channels) == 0 {
+		return
+	}
+	text := fmt.Sprintf("%s recovered, its circuit breaker closed", provider)
+	if open {
+		text = fmt.Sprintf("%s is down, its circuit breaker opened: %v", provider, err)
+	}
+	s.notify(context.Background(), channels, text)
+}
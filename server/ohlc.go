@@ -0,0 +1,117 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCandles is the most candles one /rates/ohlc request may span.
+const maxCandles = 1000
+
+// ohlcIntervals are the candle lengths /rates/ohlc aggregates into.
+var ohlcIntervals = map[string]time.Duration{
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// OHLCHandler aggregates the recorded rates of a pair into candles, e.g.
+// /rates/ohlc?from=USD&to=KHR&interval=1h&start=2024-05-01&end=2024-05-07.
+// Candles come from the first provider of the source list with rates
+// recorded in the range.
+func (s *Server) OHLCHandler(c *gin.Context) {
+	countConversions(c, 0)
+	if s.history == nil {
+		respondError(c, http.StatusNotImplemented, CodeFeatureDisabled, "Rate history is disabled", "")
+		return
+	}
+
+	source := s.source(c.Query("source"))
+	var providers []string
+	for _, name := range strings.Split(source, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := s.registry.New(name, ""); !ok {
+			respondError(c, http.StatusBadRequest, CodeInvalidSource, errInvalidSource.Error(), "source")
+			return
+		}
+		providers = append(providers, name)
+	}
+
+	from, ok := requestCurrency(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := requestCurrency(c, "to")
+	if !ok {
+		return
+	}
+	intervalName := c.DefaultQuery("interval", "1d")
+	interval, ok := ohlcIntervals[intervalName]
+	if !ok {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid interval, expected 1h or 1d", "interval")
+		return
+	}
+
+	start, ok := requestTime(c, "start", time.Time{})
+	if !ok {
+		return
+	}
+	end, ok := requestTime(c, "end", time.Now().UTC())
+	if !ok {
+		return
+	}
+	if len(c.Query("end")) == len("2006-01-02") {
+		// An end date includes its day
+		end = end.AddDate(0, 0, 1)
+	}
+	if !end.After(start) {
+		respondError(c, http.StatusBadRequest, CodeInvalidDate, "End must be after start", "end")
+		return
+	}
+	if end.Sub(start) > maxCandles*interval {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("The range spans more than %d candles", maxCandles), "end")
+		return
+	}
+
+	for _, provider := range providers {
+		candles, err := s.history.Candles(provider, from, to, start, end, interval)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+			return
+		}
+		if len(candles) > 0 || provider == providers[len(providers)-1] {
+			c.JSON(http.StatusOK, gin.H{
+				"source":   source,
+				"provider": provider,
+				"from":     from,
+				"to":       to,
+				"interval": intervalName,
+				"start":    start,
+				"end":      end,
+				"candles":  candles,
+			})
+			return
+		}
+	}
+}
+
+// requestTime reads an RFC 3339 time or YYYY-MM-DD date parameter, required
+// or def when it is absent and def isn't zero. A date stands for midnight
+// UTC. On failure it writes the error response and returns false.
+func requestTime(c *gin.Context, name string, def time.Time) (time.Time, bool) {
+	value := c.Query(name)
+	if value == "" && !def.IsZero() {
+		return def, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), true
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true
+	}
+	respondError(c, http.StatusBadRequest, CodeInvalidDate, fmt.Sprintf("Invalid %s, expected an RFC 3339 time or YYYY-MM-DD", name), name)
+	return time.Time{}, false
+}
@@ -0,0 +1,891 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiOperation documents one route of the REST API for /openapi.json.
+type apiOperation struct {
+	Method  string
+	Path    string
+	Summary string
+	Tag     string
+	// Scope is the API key scope the route requires, "admin" for the /admin
+	// routes, or "" for public ones.
+	Scope  string
+	Params []apiParam
+	// Body names the schema of the JSON request body, if any. Upload accepts
+	// the body as CSV, or as the file field of a multipart form, too.
+	Body   string
+	Upload bool
+	// Status and Response are the success status and the name of its
+	// schema; Content overrides the JSON media type, e.g. for event streams.
+	// Formats also offers the response as XML and CSV.
+	Status   int
+	Response string
+	Content  string
+	Formats  bool
+}
+
+// apiParam is a query, path or header parameter of an apiOperation.
+type apiParam struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Schema      gin.H
+}
+
+// Parameters shared by the rate routes.
+var (
+	sourceParam      = apiParam{Name: "source", In: "query", Description: "Comma-separated providers tried in order, e.g. openexchangerates,fixerio, or consensus to combine several; defaults to the server's", Schema: stringSchema()}
+	keyParam         = apiParam{Name: "key", In: "query", Description: "Provider API key, overriding the server's", Schema: stringSchema()}
+	keysParam        = apiParam{Name: "key[provider]", In: "query", Description: "Provider API key for one provider of the source list, e.g. key[fixerio]=...", Schema: stringSchema()}
+	rawParam         = apiParam{Name: "raw", In: "query", Description: "true to skip rounding converted amounts to the target's minor unit", Schema: gin.H{"type": "boolean"}}
+	verboseParam     = apiParam{Name: "verbose", In: "query", Description: "true to add the effectiveRate, converted per unit paid once markup, fee and rounding are applied", Schema: gin.H{"type": "boolean"}}
+	amountMinorParam = apiParam{Name: "amount_minor", In: "query", Description: "Amount to convert as an integer number of from's minor units, e.g. 12345 for 123.45 USD, instead of amount; the response adds the amounts in minor units", Schema: gin.H{"type": "integer", "example": 12345}}
+	idempotencyParam = apiParam{Name: "Idempotency-Key", In: "header", Description: "Replays the first successful response for retries with the same key; X-Idempotency-Key is accepted too", Schema: stringSchema()}
+	localeParam      = apiParam{Name: "locale", In: "query", Description: "BCP 47 locale, e.g. de-DE, to add the converted amount formatted for display; defaults to the Accept-Language header", Schema: gin.H{"type": "string", "example": "en-US"}}
+	formatParam      = apiParam{Name: "format", In: "query", Description: "Response format, overriding the Accept header", Schema: gin.H{"type": "string", "enum": []string{"json", "xml", "csv"}}}
+)
+
+func currencyParam(name, description string, required bool) apiParam {
+	return apiParam{Name: name, In: "query", Description: description, Required: required, Schema: gin.H{"type": "string", "example": "USD"}}
+}
+
+// pairPathParams are the from and to path parameters of the override routes.
+var pairPathParams = []apiParam{
+	{Name: "from", In: "path", Description: "Base currency", Required: true, Schema: currencySchema},
+	{Name: "to", In: "path", Description: "Target currency", Required: true, Schema: currencySchema},
+}
+
+func dateParam(name, description string, required bool) apiParam {
+	return apiParam{Name: name, In: "query", Description: description, Required: required, Schema: gin.H{"type": "string", "format": "date"}}
+}
+
+func providerParams(params ...apiParam) []apiParam {
+	return append(params, sourceParam, keyParam, keysParam)
+}
+
+// apiOperations lists every documented route, with paths relative to
+// /api/v1 unless they start with another root. Routes added to the router
+// must be added here too; Router logs the ones that are missing.
+var apiOperations = []apiOperation{
+	{Method: http.MethodGet, Path: "/exchange", Summary: "Convert an amount into one or more currencies", Tag: "conversion", Scope: "convert",
+		Params: providerParams(
+			apiParam{Name: "amount", In: "query", Description: "Amount to convert, or a percentage such as 10% of reference; required unless target_amount is given", Schema: gin.H{"type": "string", "example": "100"}},
+			apiParam{Name: "target_amount", In: "query", Description: "Amount of to to end up with, instead of amount; the response's amount is what it takes", Schema: stringSchema()},
+			amountMinorParam,
+			currencyParam("from", "Currency to convert from", true),
+			apiParam{Name: "to", In: "query", Description: "Currency to convert into, or a comma-separated list of them", Required: true, Schema: gin.H{"type": "string", "example": "EUR,KHR"}},
+			apiParam{Name: "reference", In: "query", Description: "Amount a percentage amount is taken of", Schema: stringSchema()},
+			rawParam,
+			verboseParam,
+			localeParam,
+			idempotencyParam,
+		),
+		Status: http.StatusOK, Response: "Conversion", Formats: true},
+	{Method: http.MethodGet, Path: "/exchange/historical", Summary: "Convert at the rate of a past day", Tag: "conversion", Scope: "historical",
+		Params: providerParams(
+			currencyParam("from", "Currency to convert from", true),
+			currencyParam("to", "Currency to convert into", true),
+			dateParam("date", "Day of the rate", true),
+			apiParam{Name: "rollback", In: "query", Description: "true to take the rate of the latest business day up to date when date has none, such as a weekend", Schema: gin.H{"type": "boolean"}},
+			apiParam{Name: "amount", In: "query", Description: "Amount to convert; without it only the rate is returned", Schema: stringSchema()},
+			amountMinorParam,
+			rawParam,
+			localeParam,
+		),
+		Status: http.StatusOK, Response: "HistoricalConversion", Formats: true},
+	{Method: http.MethodPost, Path: "/exchange/batch", Summary: "Convert many amounts in one request", Tag: "conversion", Scope: "convert",
+		Params: providerParams(rawParam, idempotencyParam,
+			apiParam{Name: "expected_total", In: "query", Description: "Total the converted amounts should add up to, checked in totalCheck", Schema: stringSchema()},
+			currencyParam("expected_currency", "Currency of expected_total; conversions into others are converted into it first", false),
+			apiParam{Name: "tolerance", In: "query", Description: "How far the total may be from expected_total (default one minor unit)", Schema: stringSchema()}),
+		Body: "BatchRequest", Status: http.StatusOK, Response: "BatchResponse"},
+	{Method: http.MethodPost, Path: "/exchange/file", Summary: "Convert every row of a CSV ledger", Tag: "conversion", Scope: "convert",
+		Params: providerParams(rawParam, idempotencyParam,
+			currencyParam("from", "Base currency of a ledger without a from column", false),
+			currencyParam("to", "Target currency of a ledger without a to column", false)),
+		Upload: true, Status: http.StatusOK, Content: "text/csv"},
+	{Method: http.MethodGet, Path: "/exchange/compare", Summary: "Compare the quotes of every provider for a pair", Tag: "conversion", Scope: "convert",
+		Params: providerParams(
+			currencyParam("from", "Currency to convert from", true),
+			currencyParam("to", "Currency to convert into", true),
+			apiParam{Name: "amount", In: "query", Description: "Amount to convert (default 1)", Schema: stringSchema()},
+			rawParam,
+		),
+		Status: http.StatusOK, Response: "Comparison"},
+	{Method: http.MethodGet, Path: "/exchange/series", Summary: "Convert an amount at the daily rates of a range, for charts", Tag: "conversion", Scope: "historical",
+		Params: providerParams(
+			currencyParam("from", "Currency to convert from", true),
+			currencyParam("to", "Currency to convert into", true),
+			apiParam{Name: "amount", In: "query", Description: "Amount to convert on every day", Required: true, Schema: gin.H{"type": "string", "example": "100"}},
+			dateParam("start", "First day", true),
+			dateParam("end", "Last day", true),
+			apiParam{Name: "limit", In: "query", Description: "Days per page; the response links the next page", Schema: gin.H{"type": "integer", "minimum": 1, "maximum": maxSeriesLimit}},
+			rawParam,
+		),
+		Status: http.StatusOK, Response: "ConversionSeries"},
+	{Method: http.MethodGet, Path: "/currencies", Summary: "List currencies, or those a provider supports", Tag: "rates", Scope: "convert",
+		Params: providerParams(), Status: http.StatusOK, Response: "Currencies"},
+	{Method: http.MethodGet, Path: "/providers", Summary: "List providers with their circuit breakers, error rates, latency and quotas", Tag: "rates", Scope: "convert",
+		Status: http.StatusOK, Response: "Providers"},
+	{Method: http.MethodGet, Path: "/rates", Summary: "Get a provider's whole rate table", Tag: "rates", Scope: "convert",
+		Params: providerParams(currencyParam("base", "Currency the rates are quoted against", true),
+			apiParam{Name: "decimals", In: "query", Description: "Places every rate is rounded to, from 0 to 10 (default full precision)", Schema: gin.H{"type": "integer", "minimum": 0, "maximum": maxRateDecimals}}),
+		Status: http.StatusOK, Response: "RateTable", Formats: true},
+	{Method: http.MethodGet, Path: "/rates/timeseries", Summary: "Get the daily rates of a pair over a range", Tag: "rates", Scope: "historical",
+		Params: providerParams(
+			currencyParam("from", "Base currency", true),
+			currencyParam("to", "Target currency", true),
+			dateParam("start", "First day", true),
+			dateParam("end", "Last day", true),
+			apiParam{Name: "limit", In: "query", Description: "Days per page; the response links the next page", Schema: gin.H{"type": "integer", "minimum": 1, "maximum": maxSeriesLimit}},
+		),
+		Status: http.StatusOK, Response: "TimeSeries", Formats: true},
+	{Method: http.MethodGet, Path: "/rates/change", Summary: "Compare the latest rate of a pair with its rate a period ago", Tag: "rates", Scope: "historical",
+		Params: providerParams(
+			currencyParam("from", "Base currency", true),
+			currencyParam("to", "Target currency", true),
+			apiParam{Name: "period", In: "query", Description: "How far back to compare (default 24h)", Schema: gin.H{"type": "string", "enum": []string{"24h", "7d", "30d"}}},
+		),
+		Status: http.StatusOK, Response: "RateChange"},
+	{Method: http.MethodGet, Path: "/rates/ohlc", Summary: "Aggregate the recorded rates of a pair into candles", Tag: "rates", Scope: "historical",
+		Params: []apiParam{
+			{Name: "source", In: "query", Description: "Provider, or comma-separated providers of which the first with recorded rates answers", Schema: stringSchema()},
+			currencyParam("from", "Base currency", true),
+			currencyParam("to", "Target currency", true),
+			{Name: "interval", In: "query", Description: "Length of each candle (default 1d)", Schema: gin.H{"type": "string", "enum": []string{"1h", "1d"}}},
+			{Name: "start", In: "query", Description: "Start of the range, an RFC 3339 time or a date", Required: true, Schema: stringSchema()},
+			{Name: "end", In: "query", Description: "End of the range, exclusive for a time and inclusive for a date (default now)", Schema: stringSchema()},
+		},
+		Status: http.StatusOK, Response: "OHLC"},
+	{Method: http.MethodPost, Path: "/graphql", Summary: "Run a GraphQL query", Tag: "streaming", Scope: "convert",
+		Body: "GraphQLRequest", Status: http.StatusOK, Response: "GraphQLResponse"},
+	{Method: http.MethodGet, Path: "/ws/rates", Summary: "Subscribe to rate changes over WebSocket", Tag: "streaming", Scope: "convert",
+		Status: http.StatusSwitchingProtocols},
+	{Method: http.MethodGet, Path: "/stream/rates", Summary: "Stream rate changes as Server-Sent Events", Tag: "streaming", Scope: "convert",
+		Params: []apiParam{{Name: "pairs", In: "query", Description: "Comma-separated pairs, e.g. USD-KHR,EUR-USD", Required: true, Schema: stringSchema()}},
+		Status: http.StatusOK, Content: "text/event-stream"},
+	{Method: http.MethodPost, Path: "/alerts", Summary: "Register a webhook called when a rate crosses a threshold or moves by a percentage", Tag: "alerts", Scope: "convert",
+		Params: providerParams(), Body: "AlertRequest", Status: http.StatusCreated, Response: "CreatedAlert"},
+	{Method: http.MethodGet, Path: "/alerts", Summary: "List the rate alerts of the caller's key", Tag: "alerts", Scope: "convert",
+		Status: http.StatusOK, Response: "Alerts"},
+	{Method: http.MethodDelete, Path: "/alerts/:id", Summary: "Remove a rate alert", Tag: "alerts", Scope: "convert",
+		Params: []apiParam{{Name: "id", In: "path", Required: true, Schema: stringSchema()}},
+		Status: http.StatusNoContent},
+
+	{Method: http.MethodGet, Path: "/admin/refresh", Summary: "Report the background refresh of every provider", Tag: "admin", Scope: "admin",
+		Status: http.StatusOK, Response: "RefreshStatus"},
+	{Method: http.MethodPost, Path: "/admin/reload", Summary: "Reload the configuration, provider credentials and markups", Tag: "admin", Scope: "admin",
+		Status: http.StatusOK, Response: "Reload"},
+	{Method: http.MethodGet, Path: "/admin/history", Summary: "List recorded rates, newest first", Tag: "admin", Scope: "admin",
+		Params: []apiParam{
+			{Name: "provider", In: "query", Schema: stringSchema()},
+			currencyParam("from", "Base currency", false),
+			currencyParam("to", "Target currency", false),
+			{Name: "since", In: "query", Description: "Earliest fetch time", Schema: gin.H{"type": "string", "format": "date-time"}},
+			{Name: "until", In: "query", Description: "Latest fetch time", Schema: gin.H{"type": "string", "format": "date-time"}},
+			{Name: "limit", In: "query", Schema: gin.H{"type": "integer", "minimum": 1, "maximum": maxHistoryLimit, "default": defaultHistoryLimit}},
+		},
+		Status: http.StatusOK, Response: "HistoryRecords"},
+	{Method: http.MethodGet, Path: "/admin/audit", Summary: "List the conversions made, newest first, or export them as CSV", Tag: "admin", Scope: "admin",
+		Params: []apiParam{
+			currencyParam("from", "Base currency", false),
+			currencyParam("to", "Target currency", false),
+			{Name: "key", In: "query", Description: "Only the conversions of this key ID", Schema: stringSchema()},
+			{Name: "since", In: "query", Description: "Earliest conversion time", Schema: gin.H{"type": "string", "format": "date-time"}},
+			{Name: "until", In: "query", Description: "Latest conversion time", Schema: gin.H{"type": "string", "format": "date-time"}},
+			{Name: "before", In: "query", Description: "Only conversions with a lower ID, to page on from the last one listed", Schema: integerSchema},
+			{Name: "limit", In: "query", Description: "Defaults to 100, or every match for CSV exports", Schema: gin.H{"type": "integer", "minimum": 1, "maximum": maxHistoryLimit}},
+		},
+		Status: http.StatusOK, Response: "AuditLog", Formats: true},
+	{Method: http.MethodPost, Path: "/admin/keys", Summary: "Create a service API key", Tag: "admin", Scope: "admin",
+		Body: "KeyRequest", Status: http.StatusCreated, Response: "CreatedKey"},
+	{Method: http.MethodGet, Path: "/admin/keys", Summary: "List the service API keys", Tag: "admin", Scope: "admin",
+		Status: http.StatusOK, Response: "Keys"},
+	{Method: http.MethodDelete, Path: "/admin/keys/:id", Summary: "Revoke a service API key", Tag: "admin", Scope: "admin",
+		Params: []apiParam{{Name: "id", In: "path", Required: true, Schema: stringSchema()}},
+		Status: http.StatusNoContent},
+	{Method: http.MethodPost, Path: "/admin/rates/import", Summary: "Backfill the rate history from a CSV or JSON snapshot", Tag: "admin", Scope: "admin",
+		Params: []apiParam{{Name: "provider", In: "query", Description: "Source of the rows that name none", Schema: stringSchema()}},
+		Body:   "ImportRows", Upload: true, Status: http.StatusOK, Response: "ImportResult"},
+	{Method: http.MethodPut, Path: "/admin/rates/:from/:to", Summary: "Pin the rate of a pair ahead of the providers", Tag: "admin", Scope: "admin",
+		Params: pairPathParams, Body: "OverrideRequest", Status: http.StatusOK, Response: "Override"},
+	{Method: http.MethodGet, Path: "/admin/rates", Summary: "List the rate overrides in effect", Tag: "admin", Scope: "admin",
+		Status: http.StatusOK, Response: "Overrides"},
+	{Method: http.MethodDelete, Path: "/admin/rates/:from/:to", Summary: "Remove the rate override of a pair", Tag: "admin", Scope: "admin",
+		Params: pairPathParams, Status: http.StatusNoContent},
+	{Method: http.MethodGet, Path: "/admin/alerts/dead-letters", Summary: "List the alert deliveries that failed every attempt", Tag: "admin", Scope: "admin",
+		Status: http.StatusOK, Response: "DeadLetters"},
+	{Method: http.MethodPost, Path: "/admin/alerts/dead-letters/redeliver", Summary: "Retry failed alert deliveries", Tag: "admin", Scope: "admin",
+		Params: []apiParam{{Name: "alert", In: "query", Description: "Only the deliveries of this alert ID", Schema: stringSchema()}},
+		Status: http.StatusAccepted, Response: "Redelivery"},
+	{Method: http.MethodGet, Path: "/admin/usage", Summary: "Report the daily usage of the service API keys", Tag: "admin", Scope: "admin",
+		Params: []apiParam{
+			{Name: "key", In: "query", Description: "Only the usage of this key ID", Schema: stringSchema()},
+			dateParam("since", "First day (default 30 days ago)", false),
+			dateParam("until", "Last day", false),
+		},
+		Status: http.StatusOK, Response: "Usage"},
+
+	{Method: http.MethodGet, Path: "/health", Summary: "Report that the service is up", Tag: "health", Status: http.StatusOK, Response: "Health"},
+	{Method: http.MethodGet, Path: "/healthz", Summary: "Liveness probe", Tag: "health", Status: http.StatusOK, Response: "Health"},
+	{Method: http.MethodGet, Path: "/readyz", Summary: "Readiness probe checking the cache, history and key store", Tag: "health", Status: http.StatusOK, Response: "Readiness"},
+	{Method: http.MethodGet, Path: "/status", Summary: "Report dependencies, provider reachability and circuit breakers", Tag: "health", Status: http.StatusOK, Response: "Status"},
+	{Method: http.MethodGet, Path: "/metrics", Summary: "Prometheus metrics", Tag: "health", Status: http.StatusOK, Content: "text/plain"},
+}
+
+// rootPaths are the documented paths that aren't mounted under /api/v1.
+var rootPaths = map[string]bool{"/health": true, "/healthz": true, "/readyz": true, "/status": true, "/metrics": true}
+
+// specPath returns the full path of op.
+func (op apiOperation) specPath() string {
+	if rootPaths[op.Path] {
+		return op.Path
+	}
+	return apiPrefix + op.Path
+}
+
+// openAPIPath turns a gin path into an OpenAPI one, e.g. /keys/:id into
+// /keys/{id}.
+func openAPIPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// openAPIDocument builds the OpenAPI 3 document of operations.
+func openAPIDocument(operations []apiOperation) gin.H {
+	paths := gin.H{}
+	for _, op := range operations {
+		path := openAPIPath(op.specPath())
+		item, _ := paths[path].(gin.H)
+		if item == nil {
+			item = gin.H{}
+			paths[path] = item
+		}
+		item[strings.ToLower(op.Method)] = op.document()
+	}
+
+	return gin.H{
+		"openapi": "3.0.3",
+		"info": gin.H{
+			"title":   "Exchanger API",
+			"version": "1.0.0",
+			"description": "Currency conversion across exchange rate providers. Every route under /api/v1 is also " +
+				"served without the prefix as a deprecated alias.",
+		},
+		"servers": []gin.H{{"url": "/"}},
+		"tags": []gin.H{
+			{"name": "conversion"}, {"name": "rates"}, {"name": "streaming"}, {"name": "alerts"}, {"name": "admin"}, {"name": "health"},
+		},
+		"paths": paths,
+		"components": gin.H{
+			"schemas": openAPISchemas,
+			"securitySchemes": gin.H{
+				"apiKey": gin.H{"type": "apiKey", "in": "header", "name": "X-API-Key",
+					"description": "Service API key, required when the server has a key store"},
+				"bearer": gin.H{"type": "http", "scheme": "bearer", "bearerFormat": "JWT",
+					"description": "Token of a configured identity provider, or the admin token on /admin"},
+			},
+			"responses": gin.H{
+				"Error": gin.H{
+					"description": "Error envelope; see the code for what failed",
+					"content":     gin.H{"application/json": gin.H{"schema": schemaRef("Error")}},
+				},
+			},
+		},
+	}
+}
+
+// document returns the OpenAPI operation object of op.
+func (op apiOperation) document() gin.H {
+	doc := gin.H{
+		"summary":     op.Summary,
+		"operationId": operationID(op),
+		"tags":        []string{op.Tag},
+	}
+
+	opParams := op.Params
+	if op.Formats {
+		opParams = append(opParams[:len(opParams):len(opParams)], formatParam)
+	}
+	if len(opParams) > 0 {
+		params := make([]gin.H, len(opParams))
+		for i, param := range opParams {
+			params[i] = gin.H{"name": param.Name, "in": param.In, "required": param.Required, "schema": param.Schema}
+			if param.Description != "" {
+				params[i]["description"] = param.Description
+			}
+		}
+		doc["parameters"] = params
+	}
+	content := gin.H{}
+	if op.Body != "" {
+		content["application/json"] = gin.H{"schema": schemaRef(op.Body)}
+	}
+	if op.Upload {
+		content["text/csv"] = gin.H{"schema": stringSchema()}
+		content["multipart/form-data"] = gin.H{"schema": objectSchema(gin.H{
+			"file": gin.H{"type": "string", "format": "binary"},
+		}, "file")}
+	}
+	if len(content) > 0 {
+		doc["requestBody"] = gin.H{"required": true, "content": content}
+	}
+
+	success := gin.H{"description": http.StatusText(op.Status)}
+	switch {
+	case op.Content != "":
+		success["content"] = gin.H{op.Content: gin.H{"schema": stringSchema()}}
+	case op.Response != "":
+		content := gin.H{"application/json": gin.H{"schema": schemaRef(op.Response)}}
+		if op.Formats {
+			// XML carries the same fields, so it shares the schema
+			content["application/xml"] = gin.H{"schema": schemaRef(op.Response)}
+			content["text/csv"] = gin.H{"schema": stringSchema()}
+		}
+		success["content"] = content
+	}
+	doc["responses"] = gin.H{
+		strconv.Itoa(op.Status): success,
+		"default":               gin.H{"$ref": "#/components/responses/Error"},
+	}
+
+	switch op.Scope {
+	case "":
+		doc["security"] = []gin.H{}
+	case "admin":
+		doc["security"] = []gin.H{{"bearer": []string{}}, {"apiKey": []string{}}}
+		doc["description"] = "Needs the admin token as a bearer token, or a key with the admin scope."
+	default:
+		// Credentials are only checked when the server has keys or JWT tenants
+		doc["security"] = []gin.H{{"apiKey": []string{}}, {"bearer": []string{}}, {}}
+		doc["description"] = "Needs the " + op.Scope + " scope when API keys are enabled."
+	}
+	return doc
+}
+
+// operationID names op after its method and path, e.g. getExchangeHistorical.
+func operationID(op apiOperation) string {
+	id := strings.ToLower(op.Method)
+	for _, part := range strings.FieldsFunc(op.Path, func(r rune) bool { return r == '/' || r == ':' }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+// checkAPIDocs logs the routes of r that have no operation in apiOperations,
+// so the document can't silently fall behind the router.
+func checkAPIDocs(routes gin.RoutesInfo, operations []apiOperation) {
+	documented := map[string]bool{"GET /openapi.json": true, "GET /docs": true}
+	for _, op := range operations {
+		documented[op.Method+" "+op.specPath()] = true
+		documented[op.Method+" "+op.Path] = true
+	}
+
+	var missing []string
+	for _, route := range routes {
+		if strings.HasPrefix(route.Path, pprofPrefix) {
+			continue
+		}
+		if !documented[route.Method+" "+strings.TrimPrefix(route.Path, apiPrefix)] {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		log.Printf("routes missing from /openapi.json: %s", strings.Join(missing, ", "))
+	}
+}
+
+// OpenAPIHandler serves the OpenAPI document.
+func OpenAPIHandler(document gin.H) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, document)
+	}
+}
+
+// swaggerUI renders /openapi.json with Swagger UI from its CDN.
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Exchanger API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+// DocsHandler serves Swagger UI for the OpenAPI document.
+func DocsHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUI))
+}
+
+func stringSchema() gin.H {
+	return gin.H{"type": "string"}
+}
+
+func schemaRef(name string) gin.H {
+	return gin.H{"$ref": "#/components/schemas/" + name}
+}
+
+func arraySchema(items gin.H) gin.H {
+	return gin.H{"type": "array", "items": items}
+}
+
+func objectSchema(properties gin.H, required ...string) gin.H {
+	schema := gin.H{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+var (
+	numberSchema    = gin.H{"type": "number"}
+	integerSchema   = gin.H{"type": "integer"}
+	booleanSchema   = gin.H{"type": "boolean"}
+	timeSchema      = gin.H{"type": "string", "format": "date-time"}
+	daySchema       = gin.H{"type": "string", "format": "date"}
+	currencySchema  = gin.H{"type": "string", "example": "USD"}
+	staleSchema     = gin.H{"type": "boolean", "description": "Set when a cached rate past its TTL stands in for failing providers"}
+	minorSchema     = gin.H{"type": "integer", "description": "Amount in minor units of its currency, answered with amount_minor"}
+	formattedSchema = gin.H{"type": "string", "example": "$1,234.56"}
+	cacheAgeSchema  = gin.H{"type": "number", "description": "Seconds since the rate was fetched"}
+	// overriddenSchema lists the targets answered by a rate override
+	overriddenSchema = arraySchema(currencySchema)
+	// consensusQuotesSchema lists what each provider answered for a consensus
+	// rate
+	consensusQuotesSchema = arraySchema(objectSchema(gin.H{
+		"provider": stringSchema(),
+		"rate":     numberSchema,
+		"outlier":  gin.H{"type": "boolean", "description": "Whether the rate strays from the median by more than the tolerance"},
+		"error":    stringSchema(),
+	}, "provider"))
+	// pathsSchema has the path of every target crossed through intermediate
+	// currencies
+	pathsSchema = gin.H{"type": "object", "additionalProperties": arraySchema(currencySchema), "description": "Currencies each crossed target's rate went through"}
+	// Converted amounts are decimal strings so no precision is lost
+	decimalSchema = gin.H{"type": "string", "example": "100.25"}
+	ratesSchema   = gin.H{"type": "object", "additionalProperties": numberSchema}
+)
+
+// errorCodes lists the codes of the error envelope, in the order the README
+// documents them.
+var errorCodes = []string{
+	CodeInvalidRequest, CodeInvalidCurrency, CodeInvalidAmount, CodeInvalidDate, CodeInvalidSource,
+	CodeProviderKeyRequired, CodeUnauthenticated, CodeForbidden, CodeNotFound, CodeConflict,
+	CodeRequestInProgress, CodeQuotaExceeded, CodeRateLimited, CodeNotSupported, CodeFeatureDisabled,
+	CodeProviderAuthFailed, CodeProviderQuotaExceeded, CodeProviderError, CodeProviderUnavailable,
+	CodeProviderSchemaMismatch, CodeUpstreamTimeout, CodeMaintenance, CodeServiceUnavailable, CodeInternal,
+}
+
+// openAPISchemas are the component schemas of the request and response
+// bodies.
+var openAPISchemas = gin.H{
+	"Error": objectSchema(gin.H{
+		"code":      gin.H{"type": "string", "enum": errorCodes},
+		"message":   stringSchema(),
+		"field":     gin.H{"type": "string", "description": "Parameter, header or body field the error is about"},
+		"provider":  gin.H{"type": "string", "description": "Provider that failed"},
+		"retriable": gin.H{"type": "boolean", "description": "Whether the same request may succeed later"},
+		"error":     gin.H{"type": "string", "description": "Same as message, for older clients", "deprecated": true},
+		"name":      gin.H{"type": "string", "description": "Same as field, for older clients", "deprecated": true},
+	}, "code", "message", "retriable"),
+	"Conversion": objectSchema(gin.H{
+		"source":    stringSchema(),
+		"provider":  stringSchema(),
+		"from":      currencySchema,
+		"to":        currencySchema,
+		"amount":    decimalSchema,
+		"rate":      numberSchema,
+		"converted": decimalSchema,
+		"formatted": gin.H{"description": "Converted amount formatted for the requested locale, or with several targets a map of them by target",
+			"oneOf": []gin.H{formattedSchema, {"type": "object", "additionalProperties": formattedSchema}}},
+		"rates":          gin.H{"type": "object", "additionalProperties": numberSchema, "description": "Rate of every target, when to lists several"},
+		"partial":        gin.H{"type": "boolean", "description": "Whether some of several targets failed; they are listed in errors"},
+		"errors":         gin.H{"type": "object", "additionalProperties": schemaRef("Error"), "description": "Error envelope of every target that failed, when to lists several"},
+		"midRate":        numberSchema,
+		"effectiveRate":  numberSchema,
+		"midRates":       gin.H{"type": "object", "additionalProperties": numberSchema, "description": "Mid-market rate of every marked up target, when to lists several"},
+		"fees":           gin.H{"type": "object", "additionalProperties": decimalSchema, "description": "Fee of every marked up target in from, when to lists several"},
+		"totals":         gin.H{"type": "object", "additionalProperties": decimalSchema, "description": "Total charged in from, amount plus fee, for every marked up target, when to lists several"},
+		"effectiveRates": gin.H{"type": "object", "additionalProperties": numberSchema, "description": "Effective rate of every target with verbose, when to lists several"},
+		"markupPercent":  numberSchema,
+		"fee":            decimalSchema,
+		"total":          decimalSchema,
+		"amountMinor":    minorSchema,
+		"convertedMinor": gin.H{"description": "Converted amount in to's minor units with amount_minor, or with several targets a map of them by target",
+			"oneOf": []gin.H{minorSchema, {"type": "object", "additionalProperties": minorSchema}}},
+		"feeMinor":     minorSchema,
+		"totalMinor":   minorSchema,
+		"targetAmount": decimalSchema,
+		"percentage":   decimalSchema,
+		"reference":    decimalSchema,
+		"timestamp":    timeSchema,
+		"cached":       booleanSchema,
+		"cacheAge":     cacheAgeSchema,
+		"stale":        staleSchema,
+		"overridden":   overriddenSchema,
+		"path":         gin.H{"type": "array", "items": currencySchema, "description": "Currencies a rate the provider doesn't quote directly was crossed through, from from to to"},
+		"paths":        pathsSchema,
+		"quotes": gin.H{"description": "Provider quotes of a consensus rate, or with several targets a map of them by target",
+			"oneOf": []gin.H{consensusQuotesSchema, {"type": "object", "additionalProperties": consensusQuotesSchema}}},
+	}, "source", "provider", "from", "amount", "timestamp"),
+	"HistoricalConversion": objectSchema(gin.H{
+		"source":         stringSchema(),
+		"provider":       stringSchema(),
+		"from":           currencySchema,
+		"to":             currencySchema,
+		"date":           daySchema,
+		"requestedDate":  daySchema,
+		"rate":           numberSchema,
+		"amount":         decimalSchema,
+		"converted":      decimalSchema,
+		"amountMinor":    minorSchema,
+		"convertedMinor": minorSchema,
+		"formatted":      formattedSchema,
+		"timestamp":      timeSchema,
+		"cached":         booleanSchema,
+		"cacheAge":       cacheAgeSchema,
+		"stale":          staleSchema,
+		"quotes":         consensusQuotesSchema,
+	}, "source", "provider", "from", "to", "date", "rate"),
+	"BatchRequest": arraySchema(objectSchema(gin.H{
+		"amount": decimalSchema,
+		"from":   currencySchema,
+		"to":     currencySchema,
+	}, "from", "to")),
+	"BatchResponse": objectSchema(gin.H{
+		"source": stringSchema(),
+		"results": arraySchema(gin.H{
+			"description": "The conversion of the item at the same index, or its error envelope",
+			"oneOf": []gin.H{
+				objectSchema(gin.H{
+					"amount":    decimalSchema,
+					"from":      currencySchema,
+					"to":        currencySchema,
+					"rate":      numberSchema,
+					"converted": decimalSchema,
+					"provider":  stringSchema(),
+					"midRate":   numberSchema,
+					"fee":       decimalSchema,
+					"total":     decimalSchema,
+					"rateOnly":  booleanSchema,
+				}),
+				schemaRef("Error"),
+			},
+		}),
+		"totalCheck": gin.H{
+			"description": "The sum of the conversions against expected_total, or the error envelope of a rate it needed",
+			"oneOf": []gin.H{
+				objectSchema(gin.H{
+					"currency":   currencySchema,
+					"expected":   decimalSchema,
+					"actual":     decimalSchema,
+					"difference": decimalSchema,
+					"tolerance":  decimalSchema,
+					"matches":    booleanSchema,
+				}),
+				schemaRef("Error"),
+			},
+		},
+	}),
+	"Comparison": objectSchema(gin.H{
+		"source": stringSchema(),
+		"from":   currencySchema,
+		"to":     currencySchema,
+		"amount": decimalSchema,
+		"quotes": arraySchema(objectSchema(gin.H{
+			"provider":  stringSchema(),
+			"latencyMs": integerSchema,
+			"rate":      numberSchema,
+			"converted": decimalSchema,
+			"timestamp": timeSchema,
+			"cached":    booleanSchema,
+			"stale":     staleSchema,
+			"code":      gin.H{"type": "string", "description": "Error code when the provider failed"},
+			"message":   stringSchema(),
+		})),
+		"best": objectSchema(gin.H{
+			"provider":  stringSchema(),
+			"rate":      numberSchema,
+			"converted": decimalSchema,
+		}),
+	}),
+	"Currencies": objectSchema(gin.H{
+		"source": stringSchema(),
+		"currencies": arraySchema(objectSchema(gin.H{
+			"code":     currencySchema,
+			"name":     stringSchema(),
+			"symbol":   stringSchema(),
+			"exponent": integerSchema,
+		})),
+		"other": arraySchema(objectSchema(gin.H{"code": stringSchema(), "name": stringSchema()})),
+	}, "currencies"),
+	"RateTable": objectSchema(gin.H{
+		"source":     stringSchema(),
+		"provider":   stringSchema(),
+		"base":       currencySchema,
+		"rates":      ratesSchema,
+		"timestamp":  timeSchema,
+		"cached":     booleanSchema,
+		"cacheAge":   gin.H{"type": "number", "description": "Seconds since the oldest stale rate was fetched"},
+		"stale":      staleSchema,
+		"overridden": overriddenSchema,
+		"quotes":     gin.H{"type": "object", "additionalProperties": consensusQuotesSchema, "description": "Provider quotes of each consensus rate"},
+	}, "source", "provider", "base", "rates"),
+	"RateChange": objectSchema(gin.H{
+		"source":         stringSchema(),
+		"provider":       stringSchema(),
+		"from":           currencySchema,
+		"to":             currencySchema,
+		"period":         stringSchema(),
+		"rate":           numberSchema,
+		"timestamp":      timeSchema,
+		"startRate":      gin.H{"type": "number", "description": "Rate at the start of the period"},
+		"startTimestamp": timeSchema,
+		"change":         numberSchema,
+		"changePercent":  numberSchema,
+		"direction":      gin.H{"type": "string", "enum": []string{"up", "down", "unchanged"}},
+		"cached":         booleanSchema,
+		"cacheAge":       cacheAgeSchema,
+		"stale":          staleSchema,
+	}, "source", "provider", "from", "to", "period", "rate", "startRate", "change", "changePercent"),
+	"OHLC": objectSchema(gin.H{
+		"source":   stringSchema(),
+		"provider": stringSchema(),
+		"from":     currencySchema,
+		"to":       currencySchema,
+		"interval": stringSchema(),
+		"start":    timeSchema,
+		"end":      timeSchema,
+		"candles": arraySchema(objectSchema(gin.H{
+			"start": timeSchema,
+			"open":  numberSchema,
+			"high":  numberSchema,
+			"low":   numberSchema,
+			"close": numberSchema,
+			"count": gin.H{"type": "integer", "description": "Rates recorded in the candle"},
+		}, "start", "open", "high", "low", "close", "count")),
+	}, "source", "provider", "from", "to", "interval", "candles"),
+	"TimeSeries": objectSchema(gin.H{
+		"source":   stringSchema(),
+		"provider": stringSchema(),
+		"from":     currencySchema,
+		"to":       currencySchema,
+		"start":    daySchema,
+		"end":      daySchema,
+		"rates":    arraySchema(objectSchema(gin.H{"date": daySchema, "rate": numberSchema})),
+		"next":     gin.H{"type": "string", "description": "URL of the next page"},
+	}, "source", "provider", "from", "to", "start", "end", "rates"),
+	"ConversionSeries": objectSchema(gin.H{
+		"source":      stringSchema(),
+		"provider":    stringSchema(),
+		"from":        currencySchema,
+		"to":          currencySchema,
+		"amount":      decimalSchema,
+		"start":       daySchema,
+		"end":         daySchema,
+		"conversions": arraySchema(objectSchema(gin.H{"date": daySchema, "rate": numberSchema, "converted": decimalSchema})),
+		"next":        gin.H{"type": "string", "description": "Start of the next page"},
+	}, "source", "provider", "from", "to", "amount", "start", "end", "conversions"),
+	"GraphQLRequest": objectSchema(gin.H{
+		"query":         stringSchema(),
+		"operationName": stringSchema(),
+		"variables":     gin.H{"type": "object"},
+	}, "query"),
+	"GraphQLResponse": objectSchema(gin.H{
+		"data":   gin.H{"type": "object"},
+		"errors": arraySchema(gin.H{"type": "object"}),
+	}),
+	"Reload": objectSchema(gin.H{
+		"reloaded":   booleanSchema,
+		"reloadedAt": timeSchema,
+	}),
+	"RefreshStatus": objectSchema(gin.H{
+		"providers": arraySchema(objectSchema(gin.H{
+			"provider":    stringSchema(),
+			"interval":    stringSchema(),
+			"pairs":       integerSchema,
+			"lastRefresh": timeSchema,
+			"nextRefresh": timeSchema,
+			"lastError":   stringSchema(),
+		})),
+	}),
+	"HistoryRecords": objectSchema(gin.H{
+		"records": arraySchema(objectSchema(gin.H{
+			"provider":  stringSchema(),
+			"from":      currencySchema,
+			"to":        currencySchema,
+			"rate":      numberSchema,
+			"day":       daySchema,
+			"timestamp": timeSchema,
+			"fetchedAt": timeSchema,
+		})),
+	}),
+	"AuditLog": objectSchema(gin.H{
+		"conversions": arraySchema(objectSchema(gin.H{
+			"id":            integerSchema,
+			"time":          timeSchema,
+			"requestId":     stringSchema(),
+			"keyId":         stringSchema(),
+			"route":         stringSchema(),
+			"provider":      stringSchema(),
+			"from":          currencySchema,
+			"to":            currencySchema,
+			"amount":        decimalSchema,
+			"rate":          numberSchema,
+			"midRate":       numberSchema,
+			"fee":           decimalSchema,
+			"converted":     decimalSchema,
+			"date":          stringSchema(),
+			"rateTimestamp": timeSchema,
+		})),
+	}),
+	"Quota": objectSchema(gin.H{"daily": integerSchema, "monthly": integerSchema}),
+	"Key": objectSchema(gin.H{
+		"id":        stringSchema(),
+		"name":      stringSchema(),
+		"prefix":    stringSchema(),
+		"scopes":    arraySchema(gin.H{"type": "string", "enum": []string{"convert", "historical", "admin"}}),
+		"quota":     schemaRef("Quota"),
+		"createdAt": timeSchema,
+	}),
+	"KeyRequest": objectSchema(gin.H{
+		"name":   stringSchema(),
+		"scopes": arraySchema(gin.H{"type": "string", "enum": []string{"convert", "historical", "admin"}}),
+		"quota":  schemaRef("Quota"),
+	}, "name", "scopes"),
+	"CreatedKey": objectSchema(gin.H{
+		"key":    schemaRef("Key"),
+		"secret": gin.H{"type": "string", "description": "The key to send in X-API-Key, shown only once"},
+	}),
+	"Keys": objectSchema(gin.H{"keys": arraySchema(schemaRef("Key"))}),
+	"ImportRows": arraySchema(objectSchema(gin.H{
+		"from":     currencySchema,
+		"to":       currencySchema,
+		"pair":     gin.H{"type": "string", "example": "USD/KHR", "description": "Stands in for from and to"},
+		"rate":     numberSchema,
+		"date":     daySchema,
+		"provider": stringSchema(),
+	}, "rate", "date")),
+	"ImportResult": objectSchema(gin.H{
+		"imported":  integerSchema,
+		"days":      integerSchema,
+		"providers": arraySchema(stringSchema()),
+	}),
+	"RateOverride": objectSchema(gin.H{
+		"from":      currencySchema,
+		"to":        currencySchema,
+		"rate":      numberSchema,
+		"reason":    stringSchema(),
+		"setAt":     timeSchema,
+		"expiresAt": timeSchema,
+	}),
+	"OverrideRequest": objectSchema(gin.H{
+		"rate":      numberSchema,
+		"expiresAt": gin.H{"type": "string", "format": "date-time", "description": "When the override lapses; omit to keep it until deleted"},
+		"reason":    stringSchema(),
+	}, "rate"),
+	"Override":  objectSchema(gin.H{"override": schemaRef("RateOverride")}),
+	"Overrides": objectSchema(gin.H{"overrides": arraySchema(schemaRef("RateOverride"))}),
+	"Alert": objectSchema(gin.H{
+		"id":            stringSchema(),
+		"from":          currencySchema,
+		"to":            currencySchema,
+		"above":         numberSchema,
+		"below":         numberSchema,
+		"changePercent": numberSchema,
+		"url":           stringSchema(),
+		"notify":        arraySchema(stringSchema()),
+		"secret":        gin.H{"type": "string", "description": "Signs the webhook calls; only returned when the alert is created"},
+		"createdAt":     timeSchema,
+		"lastRate":      numberSchema,
+		"baseline":      gin.H{"type": "number", "description": "Rate changePercent is measured from"},
+		"lastTriggered": timeSchema,
+	}),
+	"AlertRequest": objectSchema(gin.H{
+		"pair":          gin.H{"type": "string", "example": "USD/KHR"},
+		"from":          currencySchema,
+		"to":            currencySchema,
+		"above":         gin.H{"type": "number", "description": "Fire when the rate rises to it"},
+		"below":         gin.H{"type": "number", "description": "Fire when the rate falls to it"},
+		"changePercent": gin.H{"type": "number", "description": "Fire when the rate moved this many percent since the alert last fired"},
+		"url":           gin.H{"type": "string", "format": "uri", "description": "Webhook called with the signed event"},
+		"notify":        gin.H{"type": "array", "items": gin.H{"type": "string", "enum": []string{"slack", "telegram"}}, "description": "Chat channels told of the event; url, notify or both are required"},
+	}),
+	"CreatedAlert": objectSchema(gin.H{"alert": schemaRef("Alert")}),
+	"Alerts":       objectSchema(gin.H{"alerts": arraySchema(schemaRef("Alert"))}),
+	"DeadLetters": objectSchema(gin.H{
+		"deadLetters": arraySchema(objectSchema(gin.H{
+			"alertId":  stringSchema(),
+			"url":      stringSchema(),
+			"payload":  gin.H{"type": "object", "description": "Body of the failed calls"},
+			"attempts": integerSchema,
+			"error":    stringSchema(),
+			"failedAt": timeSchema,
+		})),
+	}),
+	"Redelivery": objectSchema(gin.H{"redelivering": integerSchema}),
+	"Usage": objectSchema(gin.H{
+		"usage": arraySchema(objectSchema(gin.H{
+			"keyId":         stringSchema(),
+			"day":           daySchema,
+			"conversions":   integerSchema,
+			"upstreamCalls": integerSchema,
+		})),
+	}),
+	"Health": objectSchema(gin.H{"status": stringSchema()}),
+	"Readiness": objectSchema(gin.H{
+		"checks": gin.H{"type": "object", "additionalProperties": objectSchema(gin.H{
+			"ok":        booleanSchema,
+			"latencyMs": numberSchema,
+			"error":     stringSchema(),
+		})},
+	}),
+	"Providers": objectSchema(gin.H{
+		"providers": arraySchema(objectSchema(gin.H{
+			"provider":     stringSchema(),
+			"priority":     integerSchema,
+			"calls":        integerSchema,
+			"errorRate":    numberSchema,
+			"p95LatencyMs": numberSchema,
+			"lastSuccess":  timeSchema,
+			"lastError":    stringSchema(),
+			"lastErrorAt":  timeSchema,
+			"quota": objectSchema(gin.H{
+				"remaining":  integerSchema,
+				"limit":      integerSchema,
+				"reportedAt": timeSchema,
+			}),
+			"circuit": gin.H{"type": "object"},
+		})),
+	}),
+	"Status": objectSchema(gin.H{
+		"status":    stringSchema(),
+		"checks":    gin.H{"type": "object"},
+		"providers": arraySchema(gin.H{"type": "object"}),
+		"circuits":  arraySchema(gin.H{"type": "object"}),
+	}),
+}
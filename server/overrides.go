@@ -0,0 +1,97 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pathPair reads and normalizes the from and to path parameters. On failure
+// it writes the error response and returns false.
+func pathPair(c *gin.Context) (string, string, bool) {
+	var codes [2]string
+	for i, name := range []string{"from", "to"} {
+		code, err := normalizeCurrency(c.Param(name))
+		if err != nil {
+			respondCurrencyError(c, name, err)
+			return "", "", false
+		}
+		codes[i] = code
+	}
+	if codes[0] == codes[1] {
+		respondError(c, http.StatusBadRequest, CodeInvalidCurrency, "Currencies must differ", "to")
+		return "", "", false
+	}
+	return codes[0], codes[1], true
+}
+
+// SetOverrideHandler pins the rate of the pair in the path, e.g.
+// PUT /admin/rates/USD/KHR {"rate": 4100, "expiresAt": "2024-06-01T00:00:00Z"}.
+// The rate answers conversions of the pair, and its inverse those of the
+// reverse pair, ahead of any provider until it is deleted or expires.
+func (s *Server) SetOverrideHandler(c *gin.Context) {
+	from, to, ok := pathPair(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Rate      float64    `json:"rate"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+		Reason    string     `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid override, expected {\"rate\", \"expiresAt\", \"reason\"}", "")
+		return
+	}
+	if request.Rate <= 0 {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Rate must be positive", "rate")
+		return
+	}
+	now := time.Now().UTC()
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(now) {
+		respondError(c, http.StatusBadRequest, CodeInvalidDate, "Expiry must be in the future", "expiresAt")
+		return
+	}
+
+	override := adapters.RateOverride{
+		From:      from,
+		To:        to,
+		Rate:      request.Rate,
+		Reason:    strings.TrimSpace(request.Reason),
+		SetAt:     now,
+		ExpiresAt: request.ExpiresAt,
+	}
+	if err := s.overrides.Set(override); err != nil {
+		respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"override": override})
+}
+
+// ListOverridesHandler lists the rate overrides in effect.
+func (s *Server) ListOverridesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"overrides": s.overrides.List()})
+}
+
+// DeleteOverrideHandler removes the override of the pair in the path, so
+// providers answer it again.
+func (s *Server) DeleteOverrideHandler(c *gin.Context) {
+	from, to, ok := pathPair(c)
+	if !ok {
+		return
+	}
+	deleted, err := s.overrides.Delete(from, to)
+	switch {
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+	case !deleted:
+		respondError(c, http.StatusNotFound, CodeNotFound, "No override for "+from+"/"+to, "")
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
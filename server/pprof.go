@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pprofPrefix is the path the profiling routes are mounted under. pprof's
+// index links to the profiles relative to it.
+const pprofPrefix = "/debug/pprof"
+
+// pprofRoutes mounts the net/http/pprof handlers under /debug/pprof, behind
+// the same authorization as the admin routes. They fail closed: while no
+// admin token is set, say after a reload cleared it, they answer 403.
+func (s *Server) pprofRoutes(r *gin.Engine) {
+	g := r.Group(pprofPrefix, s.requireAdminToken, s.adminAuth())
+	g.GET("/*profile", PprofHandler)
+	g.POST("/symbol", gin.WrapF(pprof.Symbol))
+}
+
+// requireAdminToken rejects requests while no admin token is configured.
+func (s *Server) requireAdminToken(c *gin.Context) {
+	if s.current().adminToken == "" {
+		abortError(c, http.StatusForbidden, CodeForbidden, "Profiling requires EXCHANGER_ADMIN_TOKEN to be set", "")
+		return
+	}
+	c.Next()
+}
+
+// PprofHandler serves the pprof index at /debug/pprof/ and the profile its
+// path names, e.g. /debug/pprof/heap or /debug/pprof/profile?seconds=30.
+func PprofHandler(c *gin.Context) {
+	switch strings.Trim(c.Param("profile"), "/") {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		// Index serves the named runtime profiles (heap, goroutine, ...)
+		// as well as the index itself
+		pprof.Index(c.Writer, c.Request)
+	}
+}
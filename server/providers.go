@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"cubetiq-samples/exchanger-go/adapters"
+
+	"github.com/gin-gonic/gin"
+)
+
+// providerReport is an entry of /providers.
+type providerReport struct {
+	adapters.ProviderStats
+	// Priority is the position of the provider in the default source list,
+	// from 1, and zero when it isn't in it.
+	Priority int                    `json:"priority,omitempty"`
+	Circuit  adapters.BreakerStatus `json:"circuit"`
+}
+
+// ProvidersHandler lists every provider the server can call, keyless or
+// with a server-side key, with its circuit breaker, the error rate and p95
+// latency of its latest calls, its last success and the quota it reports.
+func (s *Server) ProvidersHandler(c *gin.Context) {
+	countConversions(c, 0)
+	priorities := map[string]int{}
+	for i, name := range strings.Split(s.source(""), ",") {
+		priorities[strings.TrimSpace(name)] = i + 1
+	}
+
+	credentials := s.current().credentials
+	reports := []providerReport{}
+	for _, name := range s.registry.Names() {
+		if !s.registry.Keyless(name) && credentials.Key(name) == "" {
+			continue
+		}
+		reports = append(reports, providerReport{
+			ProviderStats: s.stats.Get(name).Snapshot(),
+			Priority:      priorities[name],
+			Circuit:       s.breakers.Get(name).Status(),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"providers": reports})
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/cache"
+)
+
+// keyedAdapter quotes every pair at 4100 for the API key it was built with,
+// and refuses the key "bad".
+type keyedAdapter struct {
+	stubAdapter
+	apiKey string
+	calls  map[string]int
+	mu     *sync.Mutex
+}
+
+func (a *keyedAdapter) GetRate(ctx context.Context, from, to string) (adapters.RateResult, error) {
+	a.mu.Lock()
+	a.calls[a.apiKey]++
+	a.mu.Unlock()
+	if a.apiKey == "bad" {
+		return adapters.RateResult{}, fmt.Errorf("stub refused the key: %w", adapters.ErrProviderAuth)
+	}
+	return adapters.RateResult{Rate: 4100, Base: from, Target: to, Provider: "stub", Timestamp: time.Now()}, nil
+}
+
+func TestCachePerProviderKey(t *testing.T) {
+	calls := map[string]int{}
+	mu := &sync.Mutex{}
+	registry := adapters.NewRegistry()
+	registry.Register("stub", func(apiKey string) adapters.ExchangeRateAdapter {
+		return &keyedAdapter{apiKey: apiKey, calls: calls, mu: mu}
+	}, false)
+	t.Setenv("EXCHANGER_KEY_STUB", "server")
+	router := New(Options{Registry: registry, Sources: "stub", Cache: cache.NewMemory()}).Router()
+
+	tests := []struct {
+		key    string
+		status int
+	}{
+		{"", http.StatusOK},
+		{"", http.StatusOK},
+		{"client", http.StatusOK},
+		{"client", http.StatusOK},
+		{"bad", http.StatusUnauthorized},
+		{"bad", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		target := "/api/v1/exchange?from=USD&to=KHR&amount=10"
+		if test.key != "" {
+			target += "&key=" + test.key
+		}
+		if response := serve(router, http.MethodGet, target, "", nil); response.Code != test.status {
+			t.Errorf("key %q: status = %d, want %d: %s", test.key, response.Code, test.status, response.Body)
+		}
+	}
+
+	// Each key reaches the provider once and is then served from its own
+	// cache entries; a refused key is asked again
+	want := map[string]int{"server": 1, "client": 1, "bad": 2}
+	for key, n := range want {
+		if calls[key] != n {
+			t.Errorf("key %q reached the provider %d times, want %d", key, calls[key], n)
+		}
+	}
+}
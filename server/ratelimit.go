@@ -0,0 +1,59 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"cubetiq-samples/exchanger-go/cache"
+	"cubetiq-samples/exchanger-go/keys"
+	"cubetiq-samples/exchanger-go/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Client identities the rate limit can be applied to.
+const (
+	RateLimitByIP  = "ip"
+	RateLimitByKey = "key"
+)
+
+// RateLimit allows each client perMinute requests on average with bursts of
+// up to burst, answering 429 with a Retry-After header beyond that. Clients
+// are told apart by IP, or with by set to RateLimitByKey by the service key
+// they authenticated as, falling back to their IP. It goes after the scope
+// check, so only authenticated keys pick the bucket.
+func RateLimit(limiter cache.Limiter, perMinute, burst int, by string) gin.HandlerFunc {
+	bucket := cache.Bucket{Rate: float64(perMinute) / 60, Burst: burst}
+	limit := strconv.Itoa(perMinute)
+	return func(c *gin.Context) {
+		ok, remaining, retryAfter := limiter.Take("ratelimit:"+rateLimitClient(c, by), bucket)
+		c.Header("X-RateLimit-Limit", limit)
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if ok {
+			c.Next()
+			return
+		}
+
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		metrics.RateLimited.WithLabelValues(c.FullPath()).Inc()
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		body := errorBody(CodeRateLimited, "Rate limit exceeded, please retry later", "", "")
+		body["retryAfter"] = seconds
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, body)
+	}
+}
+
+// rateLimitClient identifies the client a request is counted against. A
+// credential the client sends but that didn't authenticate, like the
+// provider key of the key parameter, doesn't count: anyone could send a new
+// one per request.
+func rateLimitClient(c *gin.Context, by string) string {
+	if by == RateLimitByKey {
+		if key, ok := c.Request.Context().Value(serviceKeyContextKey{}).(keys.Key); ok && key.ID != "" {
+			// JWT subjects are only unique within their tenant, the key Name
+			return "key:" + key.Name + "/" + key.ID
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
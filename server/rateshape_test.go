@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"cubetiq-samples/exchanger-go/adapters"
+)
+
+// TestRateShapes checks that a generic source answering its rates as an
+// array of {"currency","rate"} objects converts exactly like one answering
+// them as an object.
+func TestRateShapes(t *testing.T) {
+	tests := []struct {
+		name      string
+		payload   string
+		ratesPath string
+	}{
+		{"object", `{"base":"USD","timestamp":1714752000,"rates":{"EUR":0.92,"KHR":4100}}`, "$.rates"},
+		{"array", `{"base":"USD","timestamp":1714752000,"rates":[{"currency":"EUR","rate":0.92},{"currency":"KHR","rate":4100}]}`, "$.rates"},
+		{"padded array", `{"base":"USD","timestamp":1714752000,"rates":  [ {"currency":"KHR","rate":4100}, {"currency":"EUR","rate":0.92} ]}`, "$.rates"},
+		{"nested array", `{"data":[{"base":"USD","timestamp":1714752000,"rates":[{"currency":"EUR","rate":0.92},{"currency":"KHR","rate":4100}]}]}`, "$.data[0].rates"},
+	}
+
+	var want map[string]interface{}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, test.payload)
+			}))
+			defer upstream.Close()
+
+			var config adapters.GenericAdapterConfig
+			config.Name = "stub"
+			config.URL = upstream.URL + "/latest?base={base}"
+			config.RatesPath = test.ratesPath
+			router := newTestServer(t, adapters.NewGeneric(upstream.Client(), config, ""), Options{})
+
+			response := serve(router, http.MethodGet, "/api/v1/exchange?from=EUR&to=KHR&amount=10", "", nil)
+			if response.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", response.Code, response.Body)
+			}
+			body := decode(t, response)
+			delete(body, "timestamp")
+			if want == nil {
+				if rate, _ := body["rate"].(float64); rate < 4456 || rate > 4457 {
+					t.Fatalf("rate = %v, want 4100/0.92", body["rate"])
+				}
+				want = body
+				return
+			}
+			if !reflect.DeepEqual(body, want) {
+				t.Errorf("response = %v, want %v", body, want)
+			}
+		})
+	}
+}
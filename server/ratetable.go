@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cubetiq-samples/exchanger-go/adapters"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// maxRateDecimals bounds the decimals parameter of /rates.
+const maxRateDecimals = 10
+
+// RatesHandler returns the provider's whole rate table against base. Tables
+// quoted against a fixed base (e.g. USD or EUR) are rebased server-side.
+// With decimals every rate is rounded to that many places.
+func (s *Server) RatesHandler(c *gin.Context) {
+	countConversions(c, 0)
+	adapter, source, ok := s.requestAdapter(c)
+	if !ok {
+		return
+	}
+
+	base, ok := requestCurrency(c, "base")
+	if !ok {
+		return
+	}
+	decimals := -1
+	if decimalsStr := c.Query("decimals"); decimalsStr != "" {
+		var err error
+		decimals, err = strconv.Atoi(decimalsStr)
+		if err != nil || decimals < 0 || decimals > maxRateDecimals {
+			respondError(c, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Decimals must be between 0 and %d", maxRateDecimals), "decimals")
+			return
+		}
+	}
+
+	results, err := adapters.FetchRates(c.Request.Context(), adapter, base, nil)
+	if err != nil {
+		respondRateError(c, err)
+		return
+	}
+
+	response := rateSummary(results)
+	if decimals >= 0 {
+		rates := response["rates"].(map[string]float64)
+		for target, rate := range rates {
+			rates[target] = decimal.NewFromFloat(rate).Round(int32(decimals)).InexactFloat64()
+		}
+	}
+	response["source"] = source
+	response["base"] = base
+	logProvider(c, response["provider"].(string))
+	if notModified(c, rateMaxAge(rateValues(results)...), response["provider"], base, response["timestamp"], response["rates"]) {
+		return
+	}
+	respond(c, http.StatusOK, response)
+}
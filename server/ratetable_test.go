@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+)
+
+// tableAdapter answers its table for every base.
+type tableAdapter struct {
+	stubAdapter
+	table map[string]float64
+}
+
+func (a *tableAdapter) GetRates(ctx context.Context, from string, to []string) (map[string]adapters.RateResult, error) {
+	results := map[string]adapters.RateResult{}
+	for target, rate := range a.table {
+		results[target] = adapters.RateResult{Rate: rate, Base: from, Target: target, Provider: "stub", Timestamp: time.Date(2024, 5, 3, 16, 0, 0, 0, time.UTC)}
+	}
+	return results, nil
+}
+
+func TestRatesDecimals(t *testing.T) {
+	table := map[string]float64{"KHR": 4100.2487, "EUR": 0.929351, "JPY": 155.6049, "BTC": 0.0000158732}
+	router := newTestServer(t, &tableAdapter{table: table}, Options{})
+
+	tests := []struct {
+		decimals string
+		rates    map[string]interface{}
+	}{
+		{"", map[string]interface{}{"KHR": 4100.2487, "EUR": 0.929351, "JPY": 155.6049, "BTC": 0.0000158732}},
+		{"0", map[string]interface{}{"KHR": 4100.0, "EUR": 1.0, "JPY": 156.0, "BTC": 0.0}},
+		{"2", map[string]interface{}{"KHR": 4100.25, "EUR": 0.93, "JPY": 155.6, "BTC": 0.0}},
+		{"10", map[string]interface{}{"KHR": 4100.2487, "EUR": 0.929351, "JPY": 155.6049, "BTC": 0.0000158732}},
+		{"6", map[string]interface{}{"KHR": 4100.2487, "EUR": 0.929351, "JPY": 155.6049, "BTC": 0.000016}},
+	}
+	for _, test := range tests {
+		response := serve(router, http.MethodGet, "/api/v1/rates?base=USD&decimals="+test.decimals, "", nil)
+		if response.Code != http.StatusOK {
+			t.Errorf("decimals %q: status = %d, want 200: %s", test.decimals, response.Code, response.Body)
+			continue
+		}
+		if rates := decode(t, response)["rates"]; !reflect.DeepEqual(rates, test.rates) {
+			t.Errorf("decimals %q: rates = %v, want %v", test.decimals, rates, test.rates)
+		}
+	}
+
+	for _, decimals := range []string{"-1", "11", "2.5", "two"} {
+		response := serve(router, http.MethodGet, "/api/v1/rates?base=USD&decimals="+decimals, "", nil)
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("decimals %q: status = %d, want 400", decimals, response.Code)
+			continue
+		}
+		if body := decode(t, response); body["code"] != CodeInvalidRequest || body["field"] != "decimals" {
+			t.Errorf("decimals %q: error = %v, want %s on decimals", decimals, body, CodeInvalidRequest)
+		}
+	}
+}
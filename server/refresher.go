@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/config"
+	"cubetiq-samples/exchanger-go/events"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultRefreshInterval is used for providers without their own refresh
+// interval.
+const defaultRefreshInterval = 5 * time.Minute
+
+// refreshInterval returns how often provider is refreshed, taken from
+// EXCHANGER_REFRESH_INTERVAL_<PROVIDER> or EXCHANGER_REFRESH_INTERVAL.
+func refreshInterval(provider string) time.Duration {
+	interval := config.Duration("EXCHANGER_REFRESH_INTERVAL", defaultRefreshInterval)
+	return config.Duration("EXCHANGER_REFRESH_INTERVAL_"+strings.ToUpper(provider), interval)
+}
+
+// RefreshStatus reports the background refresh of one provider.
+type RefreshStatus struct {
+	Provider    string     `json:"provider"`
+	Interval    string     `json:"interval"`
+	Pairs       int        `json:"pairs"`
+	LastRefresh *time.Time `json:"lastRefresh,omitempty"`
+	NextRefresh *time.Time `json:"nextRefresh,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+// refresher records the status of each provider's refresh loop.
+type refresher struct {
+	mu     sync.Mutex
+	status map[string]*RefreshStatus
+}
+
+// RunRefresher pre-fetches the refresh pairs from each default provider
+// into the rate cache until ctx is done, so client requests for them are
+// always answered from the cache. Each refresh stores the rates for at least
+// twice the provider's interval, so they don't expire between runs. The
+// first provider also refreshes the pairs of rate alerts, and its refreshed
+// rates are pushed to streaming subscribers and checked against the alerts.
+// Without refresh pairs only the first provider runs, for the alerts.
+func (s *Server) RunRefresher(ctx context.Context) {
+	var pairs []adapters.Pair
+	for _, input := range strings.Split(s.refreshPairs, ",") {
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+		pair, err := parsePair(input)
+		if err != nil {
+			log.Printf("refresher: skipping %v", err)
+			continue
+		}
+		pairs = append(pairs, pair)
+	}
+
+	providers, err := s.providers(s.source(""), "", nil)
+	if err != nil {
+		log.Printf("refresher: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i, provider := range providers {
+		if i > 0 && len(pairs) == 0 {
+			break
+		}
+		cached, ok := provider.Adapter.(*adapters.CachedAdapter)
+		if !ok {
+			log.Printf("refresher: caching is disabled for %s, not refreshing it", provider.Name)
+			continue
+		}
+
+		wg.Add(1)
+		go func(name string, cached *adapters.CachedAdapter, primary bool) {
+			defer wg.Done()
+			s.refreshLoop(ctx, name, cached, pairs, primary)
+		}(provider.Name, cached, i == 0)
+	}
+	wg.Wait()
+}
+
+func (s *Server) refreshLoop(ctx context.Context, name string, cached *adapters.CachedAdapter, pairs []adapters.Pair, primary bool) {
+	interval := refreshInterval(name)
+	ttl := adapters.CacheTTL(name)
+	if ttl < 2*interval {
+		ttl = 2 * interval
+	}
+
+	status := &RefreshStatus{Provider: name, Interval: interval.String(), Pairs: len(pairs)}
+	s.refresher.mu.Lock()
+	s.refresher.status[name] = status
+	s.refresher.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		refreshed := pairs
+		if primary {
+			refreshed = withPairs(pairs, s.alerts.pairs())
+		}
+		targets := map[string][]string{}
+		for _, pair := range refreshed {
+			targets[pair.From] = append(targets[pair.From], pair.To)
+		}
+
+		var failures []string
+		for base, to := range targets {
+			results, err := cached.Refresh(ctx, base, to, ttl)
+			if err != nil {
+				log.Printf("refresher: %s %s: %v", name, base, err)
+				failures = append(failures, base+": "+err.Error())
+				continue
+			}
+			s.publishRates(ctx, results)
+			if primary {
+				for target, result := range results {
+					pair := adapters.Pair{From: base, To: target}
+					s.hub.publish(pair, result)
+					s.checkAlerts(ctx, pair, result)
+				}
+			}
+		}
+
+		now := time.Now()
+		next := now.Add(interval)
+		s.refresher.mu.Lock()
+		status.Pairs = len(refreshed)
+		status.LastRefresh = &now
+		status.NextRefresh = &next
+		status.LastError = strings.Join(failures, "; ")
+		s.refresher.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// withPairs returns pairs followed by those of extra it lacks.
+func withPairs(pairs, extra []adapters.Pair) []adapters.Pair {
+	merged := append([]adapters.Pair(nil), pairs...)
+	seen := map[adapters.Pair]bool{}
+	for _, pair := range pairs {
+		seen[pair] = true
+	}
+	for _, pair := range extra {
+		if !seen[pair] {
+			seen[pair] = true
+			merged = append(merged, pair)
+		}
+	}
+	return merged
+}
+
+// publishTimeout bounds publishing one refresh to the event bus.
+const publishTimeout = 10 * time.Second
+
+// publishRates sends refreshed rates to the event bus, if there is one.
+// Failures are logged and the refresh goes on.
+func (s *Server) publishRates(ctx context.Context, results map[string]adapters.RateResult) {
+	if s.events == nil || len(results) == 0 {
+		return
+	}
+	rates := make([]events.Rate, 0, len(results))
+	for _, result := range results {
+		rates = append(rates, events.Rate{
+			Provider:  result.Provider,
+			From:      result.Base,
+			To:        result.Target,
+			Rate:      result.Rate,
+			Timestamp: result.Timestamp,
+			FetchedAt: result.FetchedAt,
+		})
+	}
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+	if err := s.events.Publish(ctx, rates); err != nil {
+		log.Printf("events: publishing %d rates: %v", len(rates), err)
+	}
+}
+
+// checkAlerts delivers the alerts a refreshed rate fires, in the background.
+func (s *Server) checkAlerts(ctx context.Context, pair adapters.Pair, result adapters.RateResult) {
+	events, err := s.alerts.check(pair, result)
+	if err != nil {
+		log.Printf("alerts: %v", err)
+	}
+	for _, event := range events {
+		if event.Alert.URL != "" {
+			go s.webhooks.deliver(ctx, event)
+		}
+		if len(event.Alert.Notify) > 0 {
+			go s.notify(ctx, event.Alert.Notify, alertText(event))
+		}
+	}
+}
+
+// RefreshStatusHandler lists when each provider was last refreshed.
+func (s *Server) RefreshStatusHandler(c *gin.Context) {
+	s.refresher.mu.Lock()
+	providers := make([]RefreshStatus, 0, len(s.refresher.status))
+	for _, status := range s.refresher.status {
+		providers = append(providers, *status)
+	}
+	s.refresher.mu.Unlock()
+
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Provider < providers[j].Provider
+	})
+	c.JSON(http.StatusOK, gin.H{"providers": providers})
+}
+
+// AdminAuth requires the bearer token on admin routes. An empty token closes
+// them.
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			abortError(c, http.StatusForbidden, CodeForbidden, "Admin routes are disabled until EXCHANGER_ADMIN_TOKEN is set", "")
+			return
+		}
+		given := []byte(c.GetHeader("Authorization"))
+		if subtle.ConstantTimeCompare(given, []byte("Bearer "+token)) != 1 {
+			abortError(c, http.StatusUnauthorized, CodeUnauthenticated, "Admin token is required", "Authorization")
+			return
+		}
+		c.Next()
+	}
+}
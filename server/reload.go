@@ -0,0 +1,90 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errReloadDisabled is returned by Reload on servers without Options.Reload.
+var errReloadDisabled = errors.New("reloading is not configured")
+
+// liveSettings are the settings Reload replaces while the server runs.
+// Requests read them once, so those in flight finish with the settings they
+// started with.
+type liveSettings struct {
+	adminToken     string
+	credentials    config.Credentials
+	markups        MarkupConfig
+	consensus      adapters.Consensus
+	notifiers      map[string]notifier
+	outageChannels []string
+}
+
+// newLiveSettings takes the runtime settings from opts and the notification
+// channels from the environment.
+func newLiveSettings(opts Options) liveSettings {
+	live := liveSettings{
+		adminToken:     opts.AdminToken,
+		credentials:    opts.Credentials,
+		markups:        opts.Markups,
+		consensus:      opts.Consensus,
+		notifiers:      newNotifiers(),
+		outageChannels: outageChannels(),
+	}
+	for _, channel := range live.outageChannels {
+		if _, ok := live.notifiers[channel]; !ok {
+			log.Printf("EXCHANGER_OUTAGE_NOTIFY names %s, which is not configured", channel)
+		}
+	}
+	return live
+}
+
+// current returns the settings in effect.
+func (s *Server) current() liveSettings {
+	s.liveMu.RLock()
+	defer s.liveMu.RUnlock()
+	return s.live
+}
+
+// Reload re-reads the settings Options.Reload covers, along with the
+// notification channels and alert delivery retries, and applies them to the
+// requests that start afterwards. When reading fails the current settings
+// stay.
+func (s *Server) Reload() error {
+	if s.reload == nil {
+		return errReloadDisabled
+	}
+	var opts Options
+	if err := s.reload(&opts); err != nil {
+		return err
+	}
+
+	live := newLiveSettings(opts)
+	s.liveMu.Lock()
+	s.live = live
+	s.liveMu.Unlock()
+	s.webhooks.configure()
+	log.Printf("Configuration reloaded")
+	return nil
+}
+
+// ReloadHandler reloads the configuration, as SIGHUP does.
+func (s *Server) ReloadHandler(c *gin.Context) {
+	if err := s.Reload(); err != nil {
+		if errors.Is(err, errReloadDisabled) {
+			respondError(c, http.StatusNotImplemented, CodeFeatureDisabled, "Reloading is not configured", "")
+			return
+		}
+		log.Printf("reload: %v", err)
+		respondError(c, http.StatusInternalServerError, CodeInternal, "Invalid configuration, keeping the current one: "+err.Error(), "")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reloaded": true, "reloadedAt": time.Now().UTC()})
+}
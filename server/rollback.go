@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/config"
+)
+
+// defaultRollbackDays is how many days before the requested one a rolled
+// back lookup may go, enough for a weekend next to a few holidays.
+const defaultRollbackDays = 7
+
+// holidays returns the days of EXCHANGER_HOLIDAYS, comma-separated
+// YYYY-MM-DD dates on which markets publish no rates.
+func holidays() map[string]bool {
+	days := map[string]bool{}
+	for _, day := range strings.Split(os.Getenv("EXCHANGER_HOLIDAYS"), ",") {
+		if day = strings.TrimSpace(day); day != "" {
+			days[day] = true
+		}
+	}
+	return days
+}
+
+// businessDay returns date when it is a business day, or else the latest
+// one before it. Weekends and holidays aren't business days.
+func businessDay(date time.Time, holidays map[string]bool) time.Time {
+	for date.Weekday() == time.Saturday || date.Weekday() == time.Sunday || holidays[date.Format("2006-01-02")] {
+		date = date.AddDate(0, 0, -1)
+	}
+	return date
+}
+
+// rolledBackRate looks up the rate of from/to on the latest business day up
+// to date, going back a business day at a time while the provider has no
+// rates for the day, at most EXCHANGER_ROLLBACK_DAYS before date. It
+// returns the day the rate is of.
+func rolledBackRate(ctx context.Context, adapter adapters.ExchangeRateAdapter, from, to string, date time.Time) (adapters.RateResult, time.Time, error) {
+	holidays := holidays()
+	earliest := date.AddDate(0, 0, -config.Int("EXCHANGER_ROLLBACK_DAYS", defaultRollbackDays))
+	day := businessDay(date, holidays)
+	for {
+		result, err := adapter.GetExchangeRateAt(ctx, from, to, day)
+		if !errors.Is(err, adapters.ErrNoRatesOnDate) {
+			return result, day, err
+		}
+		previous := businessDay(day.AddDate(0, 0, -1), holidays)
+		if previous.Before(earliest) {
+			return result, day, err
+		}
+		day = previous
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBusinessDay(t *testing.T) {
+	holidays := map[string]bool{"2024-04-01": true}
+	tests := []struct {
+		day, want string
+	}{
+		{"2024-05-03", "2024-05-03"}, // Friday
+		{"2024-05-04", "2024-05-03"}, // Saturday
+		{"2024-05-05", "2024-05-03"}, // Sunday
+		{"2024-04-01", "2024-03-29"}, // Easter Monday, after the weekend
+	}
+	for _, test := range tests {
+		day, _ := time.Parse("2006-01-02", test.day)
+		if got := businessDay(day, holidays).Format("2006-01-02"); got != test.want {
+			t.Errorf("businessDay(%s) = %s, want %s", test.day, got, test.want)
+		}
+	}
+}
+
+func TestHistoricalRollback(t *testing.T) {
+	t.Setenv("EXCHANGER_HOLIDAYS", "2024-05-01")
+	stub := &stubAdapter{days: map[string]float64{"2024-05-03": 1.07, "2024-04-30": 1.06}}
+	router := newTestServer(t, stub, Options{})
+
+	tests := []struct {
+		name, query   string
+		status        int
+		date, request string
+	}{
+		{"saturday rolls back to friday", "date=2024-05-04&rollback=true", http.StatusOK, "2024-05-03", "2024-05-04"},
+		{"business day is kept", "date=2024-05-03&rollback=true", http.StatusOK, "2024-05-03", "2024-05-03"},
+		{"holiday rolls back", "date=2024-05-01&rollback=true", http.StatusOK, "2024-04-30", "2024-05-01"},
+		{"day without rates rolls back", "date=2024-05-02&rollback=true", http.StatusOK, "2024-04-30", "2024-05-02"},
+		{"no rollback without the parameter", "date=2024-05-04", http.StatusNotFound, "", ""},
+		{"invalid rollback", "date=2024-05-04&rollback=maybe", http.StatusBadRequest, "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := serve(router, http.MethodGet, "/api/v1/exchange/historical?from=EUR&to=USD&amount=10&"+test.query, "", nil)
+			if response.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", response.Code, test.status, response.Body)
+			}
+			if test.status != http.StatusOK {
+				return
+			}
+			body := decode(t, response)
+			if body["date"] != test.date || body["requestedDate"] != test.request {
+				t.Errorf("date = %v, requestedDate = %v, want %s and %s", body["date"], body["requestedDate"], test.date, test.request)
+			}
+		})
+	}
+}
+
+func TestHistoricalRollbackLimit(t *testing.T) {
+	t.Setenv("EXCHANGER_ROLLBACK_DAYS", "3")
+	stub := &stubAdapter{days: map[string]float64{"2024-04-30": 1.06}}
+	router := newTestServer(t, stub, Options{})
+
+	response := serve(router, http.MethodGet, "/api/v1/exchange/historical?from=EUR&to=USD&date=2024-05-06&rollback=true", "", nil)
+	if response.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404 past EXCHANGER_ROLLBACK_DAYS: %s", response.Code, response.Body)
+	}
+}
@@ -0,0 +1,483 @@
+// Package server exposes the exchange rate adapters over HTTP.
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/cache"
+	"cubetiq-samples/exchanger-go/config"
+	"cubetiq-samples/exchanger-go/events"
+	"cubetiq-samples/exchanger-go/history"
+	"cubetiq-samples/exchanger-go/keys"
+	"cubetiq-samples/exchanger-go/metrics"
+	"cubetiq-samples/exchanger-go/tracing"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// DefaultSource is used when neither the request nor Options.Sources names a
+// provider. It needs no API key, so the service works out of the box.
+const DefaultSource = "exchangeratehost"
+
+// Options configures a Server. Zero values fall back to the defaults noted on
+// each field.
+type Options struct {
+	// Registry resolves source names; defaults to the built-in providers
+	// sharing an adapters.NewHTTPClient.
+	Registry *adapters.Registry
+	// Cache backs the rate cache and idempotency store; defaults to an
+	// in-process cache.
+	Cache cache.Cache
+	// Credentials holds server-side provider keys.
+	Credentials config.Credentials
+	// Markups holds the margins applied to conversions.
+	Markups MarkupConfig
+	// Sources lists the providers tried when a request names none, in
+	// priority order; defaults to DefaultSource.
+	Sources string
+
+	// Maintenance rejects every route except the health checks and /metrics
+	// with 503 and a Retry-After of MaintenanceRetryAfter seconds.
+	Maintenance           bool
+	MaintenanceRetryAfter int
+	// IdempotencyTTL is how long /exchange, /exchange/batch and
+	// /exchange/file responses are replayed for a repeated Idempotency-Key;
+	// defaults to an hour.
+	IdempotencyTTL time.Duration
+
+	// RefreshPairs lists the comma-separated FROM/TO pairs RunRefresher
+	// keeps in the cache.
+	RefreshPairs string
+	// AdminToken is the bearer token required on /admin routes; empty
+	// closes them to everything but admin-scoped keys and JWTs.
+	AdminToken string
+	// Keys, when set, requires every rate route to be called with an
+	// X-API-Key granting its scope, and serves /admin/keys to manage them.
+	// Keys with the admin scope open the /admin routes too.
+	Keys *keys.Store
+	// Quota caps the conversions of every key without a quota of its own,
+	// per UTC day and calendar month; zero fields leave them uncapped.
+	// Usage is only metered with Keys set.
+	Quota keys.Quota
+	// JWT, when set, accepts bearer tokens of its tenants wherever an
+	// X-API-Key is required, with the scopes their claims grant.
+	JWT *keys.JWTVerifier
+
+	// RateLimit caps each client at that many rate requests per minute,
+	// with bursts of up to RateLimitBurst (default 10); 0 disables the
+	// limit. RateLimitBy is RateLimitByIP (the default) or RateLimitByKey.
+	// The limit is shared through Cache when it is a cache.Limiter such as
+	// Redis, and kept per instance otherwise.
+	RateLimit      int
+	RateLimitBurst int
+	RateLimitBy    string
+	// CORS lets browsers call the API from the origins it allows.
+	CORS CORSOptions
+	// TrustedProxies lists the proxies, as IPs or CIDRs, whose
+	// X-Forwarded-For header gives the client IP; nil trusts none.
+	TrustedProxies []string
+
+	// History, when set, records every fetched rate and answers rates of
+	// past days.
+	History *history.Store
+	// Events, when set, receives every rate the background refresher
+	// fetches.
+	Events events.Publisher
+	// Consensus combines the providers of source=consensus.
+	Consensus adapters.Consensus
+	// Pprof mounts the net/http/pprof profiles under /debug/pprof, behind
+	// the admin authorization.
+	Pprof bool
+	// Reload, when set, re-reads the settings that can change at runtime:
+	// AdminToken, Credentials, Markups and Consensus. Server.Reload applies what it sets.
+	Reload func(opts *Options) error
+}
+
+// Server serves the exchange API.
+type Server struct {
+	registry       *adapters.Registry
+	cache          cache.Cache
+	sources        string
+	maintenance    bool
+	retryAfter     int
+	idempotencyTTL time.Duration
+	refreshPairs   string
+	rateLimit      gin.HandlerFunc
+	trustedProxies []string
+	cors           CORSOptions
+	history        *history.Store
+	events         events.Publisher
+	overrides      *adapters.Overrides
+	alerts         *alerts
+	webhooks       *webhooks
+	keys           *keys.Store
+	jwt            *keys.JWTVerifier
+	quota          keys.Quota
+	graphql        *graphql.Schema
+	hub            *rateHub
+	refresher      refresher
+	prober         prober
+	breakers       *adapters.Breakers
+	stats          *adapters.Stats
+	pprof          bool
+	reload         func(opts *Options) error
+	liveMu         sync.RWMutex
+	live           liveSettings
+	draining       chan struct{}
+	drain          sync.Once
+}
+
+// New returns a Server configured by opts.
+func New(opts Options) *Server {
+	s := &Server{
+		registry:       opts.Registry,
+		cache:          opts.Cache,
+		sources:        opts.Sources,
+		maintenance:    opts.Maintenance,
+		retryAfter:     opts.MaintenanceRetryAfter,
+		idempotencyTTL: opts.IdempotencyTTL,
+		refreshPairs:   opts.RefreshPairs,
+		trustedProxies: opts.TrustedProxies,
+		cors:           opts.CORS,
+		history:        opts.History,
+		events:         opts.Events,
+		keys:           opts.Keys,
+		jwt:            opts.JWT,
+		quota:          opts.Quota,
+		refresher:      refresher{status: map[string]*RefreshStatus{}},
+		breakers:       adapters.NewBreakers(),
+		stats:          adapters.NewStats(),
+		pprof:          opts.Pprof,
+		reload:         opts.Reload,
+		draining:       make(chan struct{}),
+	}
+	if s.registry == nil {
+		client, err := adapters.NewHTTPClient()
+		if err != nil {
+			log.Printf("HTTP client: %v, using http.DefaultClient", err)
+		}
+		s.registry = adapters.DefaultRegistry("", client)
+	}
+	if s.cache == nil {
+		s.cache = cache.NewMemory()
+	}
+	s.overrides = adapters.NewOverrides(s.cache)
+	s.alerts = newAlerts(s.cache)
+	s.webhooks = newWebhooks(s.cache)
+	s.live = newLiveSettings(opts)
+	s.breakers.OnChange(s.notifyOutage)
+	if s.idempotencyTTL == 0 {
+		s.idempotencyTTL = time.Hour
+	}
+	s.rateLimit = func(c *gin.Context) { c.Next() }
+	if opts.RateLimit > 0 {
+		limiter, ok := s.cache.(cache.Limiter)
+		if !ok {
+			limiter = cache.NewMemoryLimiter()
+		}
+		burst := opts.RateLimitBurst
+		if burst <= 0 {
+			burst = 10
+		}
+		s.rateLimit = RateLimit(limiter, opts.RateLimit, burst, opts.RateLimitBy)
+	}
+	s.graphql = newGraphQLSchema(s)
+	s.hub = newRateHub(s)
+	return s
+}
+
+// Drain prepares the server for shutdown: /readyz starts failing so load
+// balancers stop sending it traffic, rate streams are closed and the stream
+// poller stops. Regular requests keep being served until the HTTP server is
+// shut down.
+func (s *Server) Drain() {
+	s.drain.Do(func() { close(s.draining) })
+}
+
+// Router returns the HTTP handler with every route mounted under /api/v1.
+// The unversioned routes predate /api/v1 and stay as deprecated aliases.
+func (s *Server) Router() *gin.Engine {
+	r := gin.New()
+	// Without trusted proxies X-Forwarded-For is ignored, so clients can't
+	// pick the IP they are rate limited and logged by
+	if err := r.SetTrustedProxies(s.trustedProxies); err != nil {
+		log.Printf("trusted proxies: %v", err)
+	}
+	r.Use(otelgin.Middleware(tracing.ServiceName, otelgin.WithFilter(traced)))
+	r.Use(RequestLogger(), gin.Recovery())
+	r.Use(MetricsMiddleware())
+	if len(s.cors.Origins) > 0 {
+		r.Use(CORS(s.cors))
+	}
+
+	if s.maintenance {
+		r.Use(MaintenanceMiddleware(s.retryAfter))
+	}
+
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	r.GET("/health", HealthHandler)
+	r.GET("/healthz", HealthHandler)
+	r.GET("/readyz", s.ReadyHandler)
+	r.GET("/status", s.StatusHandler)
+	r.GET(apiPrefix+"/health", HealthHandler)
+	s.routes(r.Group(apiPrefix))
+	s.routes(r.Group("/", DeprecatedAlias(apiPrefix)))
+	if s.pprof {
+		s.pprofRoutes(r)
+	}
+
+	r.GET("/openapi.json", OpenAPIHandler(openAPIDocument(apiOperations)))
+	r.GET("/docs", DocsHandler)
+	checkAPIDocs(r.Routes(), apiOperations)
+	return r
+}
+
+// traced leaves scrapes, health checks and profiling out of traces.
+func traced(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, pprofPrefix) {
+		return false
+	}
+	switch r.URL.Path {
+	case "/metrics", "/health", apiPrefix + "/health", "/healthz", "/readyz":
+		return false
+	}
+	return true
+}
+
+// apiPrefix is the path every versioned route is mounted under.
+const apiPrefix = "/api/v1"
+
+func (s *Server) routes(g *gin.RouterGroup) {
+	convert, historical := s.requireScope(keys.ScopeConvert), s.requireScope(keys.ScopeHistorical)
+	g.GET("/exchange/historical", historical, s.rateLimit, Negotiate("exchange"), s.HistoricalExchangeHandler)
+	g.POST("/exchange/batch", convert, s.rateLimit, Compress(), IdempotencyMiddleware(s.cache, s.idempotencyTTL), s.BatchExchangeHandler)
+	g.POST("/exchange/file", convert, s.rateLimit, IdempotencyMiddleware(s.cache, s.idempotencyTTL), s.FileExchangeHandler)
+	g.GET("/exchange/compare", convert, s.rateLimit, s.CompareExchangeHandler)
+	g.GET("/exchange/series", historical, s.rateLimit, Compress(), s.ConversionSeriesHandler)
+	g.GET("/currencies", convert, s.rateLimit, Compress(), s.CurrenciesHandler)
+	g.GET("/providers", convert, s.rateLimit, s.ProvidersHandler)
+	g.GET("/rates", convert, s.rateLimit, Compress(), Negotiate("rates"), s.RatesHandler)
+	g.GET("/rates/timeseries", historical, s.rateLimit, Compress(), Negotiate("timeseries"), s.TimeSeriesHandler)
+	g.GET("/rates/change", historical, s.rateLimit, s.RateChangeHandler)
+	g.GET("/rates/ohlc", historical, s.rateLimit, Compress(), s.OHLCHandler)
+	g.POST("/graphql", convert, s.rateLimit, s.GraphQLHandler)
+	g.GET("/ws/rates", convert, s.rateLimit, s.RatesWebSocketHandler)
+	g.GET("/stream/rates", convert, s.rateLimit, s.RatesStreamHandler)
+	g.POST("/alerts", convert, s.rateLimit, s.CreateAlertHandler)
+	g.GET("/alerts", convert, s.rateLimit, s.ListAlertsHandler)
+	g.DELETE("/alerts/:id", convert, s.rateLimit, s.DeleteAlertHandler)
+
+	admin := g.Group("/admin", s.adminAuth())
+	admin.GET("/refresh", s.RefreshStatusHandler)
+	admin.POST("/reload", s.ReloadHandler)
+	admin.GET("/history", s.HistoryHandler)
+	admin.GET("/audit", Negotiate("audit"), s.AuditHandler)
+	admin.POST("/keys", s.CreateKeyHandler)
+	admin.GET("/keys", s.ListKeysHandler)
+	admin.DELETE("/keys/:id", s.DeleteKeyHandler)
+	admin.GET("/usage", s.UsageHandler)
+	admin.POST("/rates/import", s.ImportRatesHandler)
+	admin.PUT("/rates/:from/:to", s.SetOverrideHandler)
+	admin.GET("/rates", s.ListOverridesHandler)
+	admin.DELETE("/rates/:from/:to", s.DeleteOverrideHandler)
+	admin.GET("/alerts/dead-letters", s.DeadLettersHandler)
+	admin.POST("/alerts/dead-letters/redeliver", s.RedeliverHandler)
+	g.GET("/exchange", convert, s.rateLimit, Negotiate("exchange"), IdempotencyMiddleware(s.cache, s.idempotencyTTL), s.MoneyExchangeHandler)
+}
+
+// DeprecatedAlias marks responses as deprecated and links the successor
+// route under prefix.
+func DeprecatedAlias(prefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+prefix+c.Request.URL.Path+">; rel=\"successor-version\"")
+		c.Next()
+	}
+}
+
+// requestAdapter builds the adapter chain selected by the request's source
+// and key parameters. On failure it writes the error response and returns
+// false.
+func (s *Server) requestAdapter(c *gin.Context) (adapters.ExchangeRateAdapter, string, bool) {
+	source := s.source(c.Query("source"))
+	if source == consensusSource {
+		return s.consensusAdapter(c)
+	}
+	providers, ok := s.requestProviders(c, source)
+	if !ok {
+		return nil, "", false
+	}
+	return s.chain(providers), source, true
+}
+
+// consensusSource is the source combining the rates of several providers.
+const consensusSource = "consensus"
+
+// consensusAdapter combines the providers of EXCHANGER_CONSENSUS_SOURCES,
+// or every source the request can use, with the rate overrides ahead of
+// them.
+func (s *Server) consensusAdapter(c *gin.Context) (adapters.ExchangeRateAdapter, string, bool) {
+	sources := os.Getenv("EXCHANGER_CONSENSUS_SOURCES")
+	if sources == "" {
+		sources = s.comparableSources(c)
+	}
+	providers, ok := s.requestProviders(c, sources)
+	if !ok {
+		return nil, "", false
+	}
+	for i, provider := range providers {
+		providers[i].Adapter = adapters.NewStale(adapters.NewCross(provider.Adapter))
+	}
+	return adapters.NewOverride(adapters.NewConsensus(providers, s.current().consensus), s.overrides), consensusSource, true
+}
+
+// source returns the requested source list, or the server's default one.
+func (s *Server) source(requested string) string {
+	if requested != "" {
+		return requested
+	}
+	if s.sources != "" {
+		return s.sources
+	}
+	return DefaultSource
+}
+
+// chain returns the adapter of the first provider, falling back to the rest,
+// with the rate overrides ahead of them all.
+func (s *Server) chain(providers []adapters.NamedAdapter) adapters.ExchangeRateAdapter {
+	// Each provider crosses the pairs it lacks before the next one is tried
+	crossed := make([]adapters.NamedAdapter, len(providers))
+	for i, provider := range providers {
+		crossed[i] = adapters.NamedAdapter{Name: provider.Name, Adapter: adapters.NewCross(provider.Adapter)}
+	}
+	adapter := crossed[0].Adapter
+	if len(crossed) > 1 {
+		adapter = adapters.NewFallback(crossed)
+	}
+	// Stale cached rates only answer once every provider failed
+	return adapters.NewOverride(adapters.NewStale(adapter), s.overrides)
+}
+
+// requestProviders builds the adapters of a comma-separated source list in
+// priority order, using the request's key parameters. On failure it writes
+// the error response and returns false.
+func (s *Server) requestProviders(c *gin.Context, source string) ([]adapters.NamedAdapter, bool) {
+	providers, err := s.providers(source, c.Query("key"), c.QueryMap("key"))
+	switch {
+	case errors.Is(err, errKeyRequired):
+		respondError(c, http.StatusUnauthorized, CodeProviderKeyRequired, err.Error(), "key")
+		return nil, false
+	case err != nil:
+		respondError(c, http.StatusBadRequest, CodeInvalidSource, err.Error(), "source")
+		return nil, false
+	}
+	if len(providers) == 1 {
+		c.Set(sourceKey, providers[0].Name)
+	}
+	return providers, true
+}
+
+// statusClientClosedRequest is logged for requests the client abandoned
+// before the providers answered, following nginx.
+const statusClientClosedRequest = 499
+
+// Errors returned by providers for an unusable source list.
+var (
+	errKeyRequired   = errors.New("API key is required!")
+	errInvalidSource = errors.New("Invalid exchange rate source")
+)
+
+// providers builds the adapters of a comma-separated source list in priority
+// order. keys holds per-provider API keys and key the one used for providers
+// without an entry; both override the server-side credentials.
+func (s *Server) providers(source, key string, keys map[string]string) ([]adapters.NamedAdapter, error) {
+	var providers []adapters.NamedAdapter
+	for _, name := range strings.Split(source, ",") {
+		name = strings.TrimSpace(name)
+		adapter, err := s.provider(name, key, keys)
+		if err != nil {
+			return nil, err
+		}
+		apiKey, clientKey := s.providerKey(name, key, keys)
+		adapter = adapters.NewInstrumented(adapter, name, s.stats.Get(name))
+		adapter = adapters.NewBreaker(adapter, s.breakers.Get(name), clientKey)
+		if s.history != nil {
+			adapter = adapters.NewHistory(adapter, s.history, name)
+		}
+		adapter = adapters.NewShared(adapter, name, apiKey)
+		if ttl := adapters.CacheTTL(name); ttl > 0 {
+			adapter = adapters.NewCached(adapter, s.cache, name, apiKey, ttl)
+		}
+		providers = append(providers, adapters.NamedAdapter{Name: name, Adapter: adapter})
+	}
+	return providers, nil
+}
+
+// provider builds the adapter of one provider, bounded by its upstream
+// timeout but without the cache or history, resolving its API key like
+// providers does.
+func (s *Server) provider(name, key string, keys map[string]string) (adapters.ExchangeRateAdapter, error) {
+	apiKey, _ := s.providerKey(name, key, keys)
+	if apiKey == "" && !s.registry.Keyless(name) {
+		return nil, errKeyRequired
+	}
+
+	adapter, ok := s.registry.New(name, apiKey)
+	if !ok {
+		return nil, errInvalidSource
+	}
+	return adapters.NewTimeout(adapter, adapters.UpstreamTimeout(name)), nil
+}
+
+// providerKey resolves the API key of provider name: its entry in keys, else
+// key, else the server-side credential. client tells whether the client
+// supplied it.
+func (s *Server) providerKey(name, key string, keys map[string]string) (apiKey string, client bool) {
+	if apiKey = keys[name]; apiKey != "" {
+		return apiKey, true
+	}
+	if key != "" {
+		return key, true
+	}
+	return s.current().credentials.Key(name), false
+}
+
+// respondRateError writes the response for a failed rate lookup.
+func respondRateError(c *gin.Context, err error) {
+	c.Error(err)
+	if c.Request.Context().Err() != nil {
+		// The client went away, so nobody reads the response
+		metrics.ConversionErrors.WithLabelValues("canceled").Inc()
+		c.AbortWithStatus(statusClientClosedRequest)
+		return
+	}
+
+	status, body := rateErrorBody(c, err)
+	reason := rateErrorReasons[body["code"].(string)]
+	if errors.Is(err, adapters.ErrCircuitOpen) {
+		reason = "circuit_open"
+	}
+	metrics.ConversionErrors.WithLabelValues(reason).Inc()
+	c.JSON(status, body)
+}
+
+// rateErrorReasons are the ConversionErrors reasons of the rate error codes.
+var rateErrorReasons = map[string]string{
+	CodeInvalidCurrency:        "invalid_currency",
+	CodeProviderUnavailable:    "provider",
+	CodeUpstreamTimeout:        "timeout",
+	CodeProviderSchemaMismatch: "schema_mismatch",
+	CodeNotSupported:           "unsupported",
+	CodeProviderQuotaExceeded:  "provider",
+	CodeProviderAuthFailed:     "provider",
+	CodeProviderError:          "provider",
+}
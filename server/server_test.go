@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// stubAdapter quotes every pair at rate, and past days at their rate in
+// days, failing with adapters.ErrNoRatesOnDate for the others.
+type stubAdapter struct {
+	rate  float64
+	days  map[string]float64
+	calls int64
+}
+
+func (a *stubAdapter) GetRate(ctx context.Context, from, to string) (adapters.RateResult, error) {
+	atomic.AddInt64(&a.calls, 1)
+	return adapters.RateResult{Rate: a.rate, Base: from, Target: to, Provider: "stub", Timestamp: time.Date(2024, 5, 3, 16, 0, 0, 0, time.UTC)}, nil
+}
+
+func (a *stubAdapter) GetExchangeRateAt(ctx context.Context, from, to string, date time.Time) (adapters.RateResult, error) {
+	atomic.AddInt64(&a.calls, 1)
+	day := date.Format("2006-01-02")
+	rate, ok := a.days[day]
+	if !ok {
+		return adapters.RateResult{}, adapters.ErrNoRatesOnDate
+	}
+	return adapters.RateResult{Rate: rate, Base: from, Target: to, Provider: "stub", Timestamp: date, Date: day}, nil
+}
+
+func (a *stubAdapter) GetExchangeRate(ctx context.Context, from, to string) (float64, error) {
+	return a.rate, nil
+}
+
+func (a *stubAdapter) ConvertCurrency(ctx context.Context, amount float64, from, to string) (float64, error) {
+	return amount * a.rate, nil
+}
+
+// newTestServer returns the router of a server whose only source, stub,
+// is adapter.
+func newTestServer(t *testing.T, adapter adapters.ExchangeRateAdapter, opts Options) http.Handler {
+	t.Helper()
+	registry := adapters.NewRegistry()
+	registry.Register("stub", func(string) adapters.ExchangeRateAdapter { return adapter }, true)
+	opts.Registry = registry
+	opts.Sources = "stub"
+	if opts.Cache == nil {
+		opts.Cache = cache.NewMemory()
+	}
+	return New(opts).Router()
+}
+
+// serve sends a request with body, when set, to handler.
+func serve(handler http.Handler, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	request := httptest.NewRequest(method, target, reader)
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	if body != "" && request.Header.Get("Content-Type") == "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// decode unmarshals the JSON body of response.
+func decode(t *testing.T, response *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response %d is not JSON: %v: %s", response.Code, err, response.Body)
+	}
+	return body
+}
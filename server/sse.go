@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sseHeartbeat is how often an idle event stream gets a heartbeat event, so
+// proxies don't close it.
+const sseHeartbeat = 15 * time.Second
+
+// RatesStreamHandler streams rate changes as Server-Sent Events, e.g.
+// /stream/rates?pairs=USD-KHR,EUR-USD. Each change is a "rate" event carrying
+// the same JSON as the WebSocket rate messages, starting with the latest known
+// rate of each pair; "heartbeat" events keep idle streams open.
+func (s *Server) RatesStreamHandler(c *gin.Context) {
+	countConversions(c, 0)
+	var pairs []adapters.Pair
+	seen := map[adapters.Pair]bool{}
+	for _, input := range strings.Split(c.Query("pairs"), ",") {
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+		pair, err := parsePair(input)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, pairErrorCode(err), err.Error(), "pairs")
+			return
+		}
+		if !seen[pair] {
+			seen[pair] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	if len(pairs) == 0 {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, "Pairs are required", "pairs")
+		return
+	}
+	if maxPairs := config.Int("EXCHANGER_STREAM_MAX_PAIRS", 20); len(pairs) > maxPairs {
+		respondError(c, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("At most %d pairs per stream", maxPairs), "pairs")
+		return
+	}
+
+	sub := newSubscriber()
+	for _, pair := range pairs {
+		s.hub.subscribe(sub, pair)
+		defer s.hub.unsubscribe(sub, pair)
+	}
+
+	markupOf := s.requestMarkups(c)
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	// Keep proxies such as nginx from buffering the stream
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-s.draining:
+			return false
+		case result := <-sub.updates:
+			c.SSEvent("rate", rateMessage(result, markupOf))
+		case now := <-heartbeat.C:
+			c.SSEvent("heartbeat", gin.H{"time": now.UTC()})
+		}
+		return true
+	})
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultSeriesLimit and maxSeriesLimit bound how many days one
+	// time-series page covers.
+	defaultSeriesLimit = 90
+	maxSeriesLimit     = 366
+)
+
+// seriesPage is the page of days a series request asks for.
+type seriesPage struct {
+	from, to   string
+	start, end time.Time
+	// next is the start of the following page, if any.
+	next string
+}
+
+// days is the number of days the page covers.
+func (p seriesPage) days() int {
+	return int(p.end.Sub(p.start).Hours()/24) + 1
+}
+
+// response starts the response body of a series page.
+func (p seriesPage) response(source, provider string) gin.H {
+	response := gin.H{
+		"source":   source,
+		"provider": provider,
+		"from":     p.from,
+		"to":       p.to,
+		"start":    p.start.Format("2006-01-02"),
+		"end":      p.end.Format("2006-01-02"),
+	}
+	if p.next != "" {
+		response["next"] = p.next
+	}
+	return response
+}
+
+// requestSeries reads the from, to, start, end and limit parameters of a
+// series request. Ranges longer than limit days are paged. On failure it
+// writes the error response and returns false.
+func requestSeries(c *gin.Context) (seriesPage, bool) {
+	from, ok := requestCurrency(c, "from")
+	if !ok {
+		return seriesPage{}, false
+	}
+	to, ok := requestCurrency(c, "to")
+	if !ok {
+		return seriesPage{}, false
+	}
+
+	start, err := time.Parse("2006-01-02", c.Query("start"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidDate, "Invalid start date, expected YYYY-MM-DD", "start")
+		return seriesPage{}, false
+	}
+	end, err := time.Parse("2006-01-02", c.Query("end"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidDate, "Invalid end date, expected YYYY-MM-DD", "end")
+		return seriesPage{}, false
+	}
+	if end.Before(start) {
+		respondError(c, http.StatusBadRequest, CodeInvalidDate, "End date must not be before start date", "end")
+		return seriesPage{}, false
+	}
+	if end.After(time.Now().UTC()) {
+		respondError(c, http.StatusBadRequest, CodeInvalidDate, "End date must not be in the future", "end")
+		return seriesPage{}, false
+	}
+
+	limit := defaultSeriesLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxSeriesLimit {
+			respondError(c, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Limit must be between 1 and %d days", maxSeriesLimit), "limit")
+			return seriesPage{}, false
+		}
+	}
+
+	// Serve one page and point at the next one
+	page := seriesPage{from: from, to: to, start: start, end: end}
+	if last := start.AddDate(0, 0, limit-1); last.Before(end) {
+		page.end = last
+		page.next = last.AddDate(0, 0, 1).Format("2006-01-02")
+	}
+	return page, true
+}
+
+// TimeSeriesHandler serves daily rates for a date range, e.g.
+// /rates/timeseries?from=USD&to=EUR&start=2024-01-01&end=2024-03-31.
+// Ranges longer than limit days are paged: the response's "next" is the
+// start date of the following page.
+func (s *Server) TimeSeriesHandler(c *gin.Context) {
+	countConversions(c, 0)
+	adapter, source, ok := s.requestAdapter(c)
+	if !ok {
+		return
+	}
+	page, ok := requestSeries(c)
+	if !ok {
+		return
+	}
+
+	series, err := adapters.FetchTimeSeries(c.Request.Context(), adapter, page.from, page.to, page.start, page.end)
+	if err != nil {
+		respondRateError(c, err)
+		return
+	}
+
+	rates := make([]gin.H, 0, len(series))
+	provider := ""
+	for _, result := range series {
+		provider = result.Provider
+		rates = append(rates, gin.H{
+			"date": result.Timestamp.Format("2006-01-02"),
+			"rate": result.Rate,
+		})
+	}
+
+	logProvider(c, provider)
+
+	response := page.response(source, provider)
+	response["rates"] = rates
+	if notModified(c, dayMaxAge(page.end, series...), provider, page.from, page.to, page.start, page.end, rates) {
+		return
+	}
+	respond(c, http.StatusOK, response)
+}
+
+// ConversionSeriesHandler converts an amount at the daily rates of a date
+// range, for charts such as 100 USD in EUR over the last 30 days:
+// /exchange/series?from=USD&to=EUR&amount=100&start=2024-04-04&end=2024-05-03.
+// It is paged like TimeSeriesHandler, and each day counts as a conversion.
+func (s *Server) ConversionSeriesHandler(c *gin.Context) {
+	countConversions(c, 0)
+	adapter, source, ok := s.requestAdapter(c)
+	if !ok {
+		return
+	}
+	page, ok := requestSeries(c)
+	if !ok {
+		return
+	}
+	if c.Query("amount") == "" {
+		respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Amount is required", "amount")
+		return
+	}
+	amount, err := parseAmount(c.Query("amount"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidAmount, "Amount "+err.Error(), "amount")
+		return
+	}
+	if !s.allowConversions(c, page.days()) {
+		return
+	}
+
+	series, err := adapters.FetchTimeSeries(c.Request.Context(), adapter, page.from, page.to, page.start, page.end)
+	if err != nil {
+		respondRateError(c, err)
+		return
+	}
+
+	raw := rawAmounts(c)
+	conversions := make([]gin.H, 0, len(series))
+	provider := ""
+	for _, result := range series {
+		provider = result.Provider
+		conversions = append(conversions, gin.H{
+			"date":      result.Timestamp.Format("2006-01-02"),
+			"rate":      result.Rate,
+			"converted": convertAmount(amount, result.Rate, page.to, raw),
+		})
+	}
+
+	logProvider(c, provider)
+	countConversions(c, len(conversions))
+	response := page.response(source, provider)
+	response["amount"] = amount
+	response["conversions"] = conversions
+	if notModified(c, dayMaxAge(page.end, series...), provider, page.from, page.to, page.start, page.end, amount, conversions) {
+		return
+	}
+	respond(c, http.StatusOK, response)
+}
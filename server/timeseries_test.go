@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestConversionSeries(t *testing.T) {
+	stub := &stubAdapter{days: map[string]float64{
+		"2024-05-01": 0.9351,
+		"2024-05-02": 0.9327,
+		"2024-05-03": 0.9289,
+		"2024-05-04": 0.9289,
+		"2024-05-05": 0.9290,
+	}}
+	router := newTestServer(t, stub, Options{})
+	target := "/api/v1/exchange/series?from=USD&to=EUR&amount=100&start=2024-05-01&end=2024-05-05"
+
+	response := serve(router, http.MethodGet, target, "", nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", response.Code, response.Body)
+	}
+	body := decode(t, response)
+	conversions := body["conversions"].([]interface{})
+	want := []struct {
+		date      string
+		rate      float64
+		converted float64
+	}{
+		{"2024-05-01", 0.9351, 93.51},
+		{"2024-05-02", 0.9327, 93.27},
+		{"2024-05-03", 0.9289, 92.89},
+		{"2024-05-04", 0.9289, 92.89},
+		{"2024-05-05", 0.929, 92.9},
+	}
+	if len(conversions) != len(want) {
+		t.Fatalf("got %d conversions, want one per day: %v", len(conversions), conversions)
+	}
+	for i, day := range want {
+		conversion := conversions[i].(map[string]interface{})
+		if conversion["date"] != day.date || conversion["rate"] != day.rate || conversion["converted"] != day.converted {
+			t.Errorf("conversion %d = %v, want %s at %v into %v", i, conversion, day.date, day.rate, day.converted)
+		}
+	}
+	if body["amount"] != 100.0 || body["next"] != nil {
+		t.Errorf("amount %v next %v, want 100 and no next page", body["amount"], body["next"])
+	}
+
+	// Past days are served from the cache the second time
+	calls := stub.calls
+	serve(router, http.MethodGet, target, "", nil)
+	if stub.calls != calls {
+		t.Errorf("the provider was called %d more times for cached days", stub.calls-calls)
+	}
+
+	body = decode(t, serve(router, http.MethodGet, target+"&limit=2", "", nil))
+	if conversions := body["conversions"].([]interface{}); len(conversions) != 2 || body["end"] != "2024-05-02" || body["next"] != "2024-05-03" {
+		t.Errorf("page = %d conversions up to %v, next %v, want 2 up to 2024-05-02 and next 2024-05-03", len(conversions), body["end"], body["next"])
+	}
+
+	rejected := []struct {
+		query string
+		code  string
+		field string
+	}{
+		{"from=USD&to=EUR&start=2024-05-01&end=2024-05-05", CodeInvalidAmount, "amount"},
+		{"from=USD&to=EUR&amount=-1&start=2024-05-01&end=2024-05-05", CodeInvalidAmount, "amount"},
+		{"from=USD&to=EUR&amount=100&start=2024-05-05&end=2024-05-01", CodeInvalidDate, "end"},
+		{"from=USD&to=EUR&amount=100&start=2024-05-01", CodeInvalidDate, "end"},
+		{"from=USD&to=EUR&amount=100&start=2024-05-01&end=2024-05-05&limit=367", CodeInvalidRequest, "limit"},
+	}
+	for _, test := range rejected {
+		response := serve(router, http.MethodGet, "/api/v1/exchange/series?"+test.query, "", nil)
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400: %s", test.query, response.Code, response.Body)
+			continue
+		}
+		if body := decode(t, response); body["code"] != test.code || body["field"] != test.field {
+			t.Errorf("%s: error = %v, want %s on %s", test.query, body, test.code, test.field)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/keys"
+	"cubetiq-samples/exchanger-go/metrics"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultUsageDays is how far back /admin/usage reports without a since
+// parameter.
+const defaultUsageDays = 30
+
+// conversionsKey holds the number of conversions a request made. Every
+// metered handler sets it: 0 for lookups, one per converted amount otherwise.
+const conversionsKey = "conversions"
+
+// meteredKey holds the service key a request is metered for.
+const meteredKey = "meteredKey"
+
+// countConversions records that the request made n conversions.
+func countConversions(c *gin.Context, n int) {
+	c.Set(conversionsKey, n)
+}
+
+// conversionQuota returns a quotaError when the key the request is metered
+// for has no room left for n conversions. Unmetered requests always pass.
+func (s *Server) conversionQuota(c *gin.Context, n int) *quotaError {
+	key, ok := c.Get(meteredKey)
+	if !ok || n <= 1 {
+		return nil
+	}
+	return s.checkQuota(key.(keys.Key), time.Now().UTC(), n)
+}
+
+// allowConversions answers the quota error and returns false when the request
+// has no room left for n conversions. Handlers converting more than one
+// amount call it before converting.
+func (s *Server) allowConversions(c *gin.Context, n int) bool {
+	if err := s.conversionQuota(c, n); err != nil {
+		respondQuotaError(c, err, time.Now().UTC())
+		return false
+	}
+	return true
+}
+
+// quotaError reports a key that used up its quota of a period.
+type quotaError struct {
+	period   string
+	limit    int
+	resetsAt time.Time
+}
+
+func (e *quotaError) Error() string {
+	return fmt.Sprintf("The %s quota of %d conversions is exceeded", e.period, e.limit)
+}
+
+// checkQuota returns a quotaError when n more conversions would take key
+// over its daily or monthly quota. The key's own quota wins over the
+// server's default. If usage can't be read the request is let through, like
+// a rate limiter that fails.
+func (s *Server) checkQuota(key keys.Key, now time.Time, n int) *quotaError {
+	quota := key.Quota
+	if quota.Daily == 0 {
+		quota.Daily = s.quota.Daily
+	}
+	if quota.Monthly == 0 {
+		quota.Monthly = s.quota.Monthly
+	}
+	if quota.Daily == 0 && quota.Monthly == 0 {
+		return nil
+	}
+
+	daily, monthly, err := s.keys.Used(key.ID, now.Format("2006-01-02"))
+	if err != nil {
+		log.Printf("reading usage of key %s failed: %v", key.ID, err)
+		return nil
+	}
+	year, month, day := now.Date()
+	switch {
+	case quota.Monthly > 0 && monthly+n > quota.Monthly:
+		return &quotaError{period: "monthly", limit: quota.Monthly, resetsAt: time.Date(year, month+1, 1, 0, 0, 0, 0, time.UTC)}
+	case quota.Daily > 0 && daily+n > quota.Daily:
+		return &quotaError{period: "daily", limit: quota.Daily, resetsAt: time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)}
+	}
+	return nil
+}
+
+// recordUsage adds a request's conversions and upstream calls to the usage of
+// key.
+func (s *Server) recordUsage(key keys.Key, now time.Time, conversions int, upstreamCalls int64) {
+	if conversions == 0 && upstreamCalls == 0 {
+		return
+	}
+	if err := s.keys.Record(key.ID, now.Format("2006-01-02"), conversions, int(upstreamCalls)); err != nil {
+		log.Printf("recording usage of key %s failed: %v", key.ID, err)
+	}
+}
+
+// meter enforces the quota of key on the rest of the request chain and
+// records its usage once it is answered. Requests answered with an error,
+// and routes that don't count their conversions, count none, but their
+// upstream calls are still recorded. Every request needs room for one
+// conversion; handlers making more check with allowConversions.
+func (s *Server) meter(c *gin.Context, key keys.Key) {
+	now := time.Now().UTC()
+	if err := s.checkQuota(key, now, 1); err != nil {
+		respondQuotaError(c, err, now)
+		return
+	}
+	c.Set(meteredKey, key)
+
+	ctx, calls := adapters.CountCalls(c.Request.Context())
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+
+	conversions := 0
+	if c.Writer.Status() < http.StatusBadRequest {
+		conversions = c.GetInt(conversionsKey)
+	}
+	s.recordUsage(key, now, conversions, atomic.LoadInt64(calls))
+}
+
+// respondQuotaError answers 402 once the monthly quota is used up, since
+// only a bigger plan helps, and 429 with a Retry-After until the next day
+// for the daily one.
+func respondQuotaError(c *gin.Context, err *quotaError, now time.Time) {
+	metrics.QuotaExceeded.WithLabelValues(err.period).Inc()
+	code := http.StatusPaymentRequired
+	if err.period == "daily" {
+		code = http.StatusTooManyRequests
+		c.Header("Retry-After", strconv.Itoa(int(err.resetsAt.Sub(now).Seconds())+1))
+	}
+	body := errorBody(CodeQuotaExceeded, err.Error(), "", "")
+	body["period"], body["limit"], body["resetsAt"] = err.period, err.limit, err.resetsAt
+	c.AbortWithStatusJSON(code, body)
+}
+
+// grpcMeter enforces and records usage like meter for a gRPC call made with
+// key. The returned function records the call and the conversions it made
+// once it returns.
+func (s *Server) grpcMeter(ctx context.Context, key keys.Key) (context.Context, func(conversions int), error) {
+	now := time.Now().UTC()
+	if err := s.checkQuota(key, now, 1); err != nil {
+		metrics.QuotaExceeded.WithLabelValues(err.period).Inc()
+		return nil, nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	ctx, calls := adapters.CountCalls(ctx)
+	return ctx, func(conversions int) {
+		s.recordUsage(key, now, conversions, atomic.LoadInt64(calls))
+	}, nil
+}
+
+// UsageHandler lists the daily conversions and upstream calls of every key,
+// or of the one in the key parameter, between the since and until days.
+func (s *Server) UsageHandler(c *gin.Context) {
+	if s.keys == nil {
+		respondError(c, http.StatusNotImplemented, CodeFeatureDisabled, "API keys are disabled", "")
+		return
+	}
+
+	filter := keys.UsageFilter{
+		KeyID: c.Query("key"),
+		Since: time.Now().UTC().AddDate(0, 0, 1-defaultUsageDays).Format("2006-01-02"),
+	}
+	for name, day := range map[string]*string{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(name); value != "" {
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				respondError(c, http.StatusBadRequest, CodeInvalidDate, "Invalid date, expected YYYY-MM-DD", name)
+				return
+			}
+			*day = value
+		}
+	}
+
+	usage, err := s.keys.Usage(filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, CodeInternal, err.Error(), "")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"usage": usage})
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cubetiq-samples/exchanger-go/keys"
+)
+
+func TestQuotaBatchBoundary(t *testing.T) {
+	store, err := keys.Open(filepath.Join(t.TempDir(), "keys.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	stub := &stubAdapter{rate: 4100}
+	router := newTestServer(t, stub, Options{Keys: store})
+	today := time.Now().UTC().Format("2006-01-02")
+
+	batch := func(n int) string {
+		items := make([]string, n)
+		for i := range items {
+			items[i] = `{"amount": 10, "from": "USD", "to": "KHR"}`
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	}
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		used   int
+	}{
+		// 3 of 5 conversions are used, so a batch of 3 doesn't fit
+		{"over", batch(3), http.StatusTooManyRequests, 3},
+		// Rate lookups and invalid items don't count against the quota
+		{"lookups", `[
+			{"amount": 10, "from": "USD", "to": "KHR"},
+			{"from": "USD", "to": "KHR"},
+			{"amount": "abc", "from": "USD", "to": "KHR"},
+			{"amount": 10, "from": "USD", "to": "KHR"}
+		]`, http.StatusOK, 5},
+		{"exactly", batch(2), http.StatusOK, 5},
+		{"multi-target", "", http.StatusTooManyRequests, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, secret, err := store.Create(test.name, []string{keys.ScopeConvert}, keys.Quota{Daily: 5})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Record(key.ID, today, 3, 0); err != nil {
+				t.Fatal(err)
+			}
+			calls := stub.calls
+
+			header := http.Header{"X-Api-Key": {secret}}
+			response := serve(router, http.MethodPost, "/api/v1/exchange/batch", test.body, header)
+			if test.body == "" {
+				response = serve(router, http.MethodGet, "/api/v1/exchange?from=USD&to=KHR,EUR,JPY&amount=10", "", header)
+			}
+			if response.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", response.Code, test.status, response.Body)
+			}
+			if test.status != http.StatusOK {
+				if body := decode(t, response); body["code"] != CodeQuotaExceeded || body["period"] != "daily" {
+					t.Errorf("error = %v, want a daily %s", body, CodeQuotaExceeded)
+				}
+				if stub.calls != calls {
+					t.Errorf("a refused request reached the provider %d times", stub.calls-calls)
+				}
+			}
+			if daily, _, _ := store.Used(key.ID, today); daily != test.used {
+				t.Errorf("used %d conversions, want %d", daily, test.used)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"cubetiq-samples/exchanger-go/cache"
+	"cubetiq-samples/exchanger-go/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// deadLettersKey holds the deliveries that ran out of attempts.
+	deadLettersKey = "alerts:dead-letters"
+	// maxDeadLetters is how many failed deliveries are kept, newest first.
+	maxDeadLetters = 100
+	// webhookTimeout bounds each delivery attempt.
+	webhookTimeout = 10 * time.Second
+)
+
+// DeadLetter is an alert delivery given up on after every attempt failed.
+type DeadLetter struct {
+	AlertID  string          `json:"alertId"`
+	URL      string          `json:"url"`
+	Payload  json.RawMessage `json:"payload"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	FailedAt time.Time       `json:"failedAt"`
+}
+
+// errBlockedCallback rejects callbacks to addresses webhooks may not reach.
+var errBlockedCallback = errors.New("callback address is loopback, link-local or private")
+
+// webhooks delivers alert events to their callback URLs.
+type webhooks struct {
+	client   *http.Client
+	store    cache.Cache
+	attempts int
+	backoff  time.Duration
+	// allowed are the networks exempt from the private address check.
+	allowed []*net.IPNet
+
+	mu sync.Mutex
+}
+
+func newWebhooks(store cache.Cache) *webhooks {
+	w := &webhooks{store: store}
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: w.checkDial}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	w.client = &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		// A redirect could lead anywhere, so deliveries don't follow them
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	w.configure()
+	return w
+}
+
+// configure reads the retry settings, EXCHANGER_ALERT_ATTEMPTS and
+// EXCHANGER_ALERT_BACKOFF, and the networks of EXCHANGER_ALERT_ALLOWED_NETWORKS.
+func (w *webhooks) configure() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.attempts = config.Int("EXCHANGER_ALERT_ATTEMPTS", 5)
+	w.backoff = config.Duration("EXCHANGER_ALERT_BACKOFF", time.Second)
+	w.allowed = nil
+	for _, cidr := range strings.Split(os.Getenv("EXCHANGER_ALERT_ALLOWED_NETWORKS"), ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Printf("alerts: EXCHANGER_ALERT_ALLOWED_NETWORKS: %v", err)
+			continue
+		}
+		w.allowed = append(w.allowed, network)
+	}
+}
+
+// checkDial refuses connections to blocked addresses. It runs on the
+// resolved address, so host names pointing inside the network are caught
+// as well as IP literals.
+func (w *webhooks) checkDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || w.blocked(ip) {
+		return fmt.Errorf("%s: %w", host, errBlockedCallback)
+	}
+	return nil
+}
+
+// blocked tells whether ip is loopback, link-local, private, shared or
+// unspecified, and not in an allowed network.
+func (w *webhooks) blocked(ip net.IP) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, network := range w.allowed {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// blockedHost tells whether the host of a callback URL is localhost or an
+// IP address webhooks may not reach. Names are checked again once resolved,
+// when delivering.
+func (w *webhooks) blockedHost(host string) bool {
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && w.blocked(ip)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// deliver POSTs event to its alert's URL, retrying failed attempts with a
+// doubling backoff, and keeps it as a dead letter when all of them fail.
+// The body is signed with the alert's secret: X-Exchanger-Signature is
+// sha256= and the hex HMAC-SHA256 of the X-Exchanger-Timestamp, a dot and
+// the body.
+func (w *webhooks) deliver(ctx context.Context, event alertEvent) {
+	secret := event.Alert.Secret
+	event.Alert.Secret = ""
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("alerts: %s: %v", event.Alert.ID, err)
+		return
+	}
+
+	w.mu.Lock()
+	attempts, backoff := w.attempts, w.backoff
+	w.mu.Unlock()
+	attempt := 1
+	for ; ; attempt++ {
+		err = w.post(ctx, event.Alert.URL, secret, payload)
+		if err == nil {
+			return
+		}
+		var status *webhookError
+		if attempt >= attempts || (errors.As(err, &status) && !status.retriable()) || errors.Is(err, errBlockedCallback) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	log.Printf("alerts: giving up on %s after %d attempts: %v", event.Alert.ID, attempt, err)
+	w.deadLetter(DeadLetter{
+		AlertID:  event.Alert.ID,
+		URL:      event.Alert.URL,
+		Payload:  payload,
+		Attempts: attempt,
+		Error:    err.Error(),
+		FailedAt: time.Now().UTC(),
+	})
+}
+
+// webhookError is a callback answering with a status other than 2xx.
+type webhookError struct {
+	status int
+}
+
+func (e *webhookError) Error() string {
+	return fmt.Sprintf("callback answered %d", e.status)
+}
+
+// retriable tells whether another attempt may succeed: client errors other
+// than timeouts and rate limits won't.
+func (e *webhookError) retriable() bool {
+	return e.status >= 500 || e.status == http.StatusRequestTimeout || e.status == http.StatusTooManyRequests
+}
+
+// post makes one delivery attempt.
+func (w *webhooks) post(ctx context.Context, url, secret string, payload []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "exchanger-alerts")
+	request.Header.Set("X-Exchanger-Timestamp", timestamp)
+	request.Header.Set("X-Exchanger-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return &webhookError{status: response.StatusCode}
+	}
+	return nil
+}
+
+// deadLetter keeps letter ahead of the older ones.
+func (w *webhooks) deadLetter(letter DeadLetter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	letters := append([]DeadLetter{letter}, w.deadLetters()...)
+	if len(letters) > maxDeadLetters {
+		letters = letters[:maxDeadLetters]
+	}
+	data, err := json.Marshal(letters)
+	if err != nil {
+		log.Printf("alerts: dead letter: %v", err)
+		return
+	}
+	w.store.Set(deadLettersKey, data, alertsTTL)
+}
+
+// deadLetters returns the failed deliveries, newest first.
+func (w *webhooks) deadLetters() []DeadLetter {
+	letters := []DeadLetter{}
+	if data, ok := w.store.Get(deadLettersKey); ok {
+		if err := json.Unmarshal(data, &letters); err != nil {
+			return []DeadLetter{}
+		}
+	}
+	return letters
+}
+
+// DeadLettersHandler lists the alert deliveries that failed every attempt.
+func (s *Server) DeadLettersHandler(c *gin.Context) {
+	s.webhooks.mu.Lock()
+	letters := s.webhooks.deadLetters()
+	s.webhooks.mu.Unlock()
+	c.JSON(http.StatusOK, gin.H{"deadLetters": letters})
+}
+
+// RedeliverHandler retries the dead letters of an alert, or all of them
+// without the alert query parameter, dropping them from the list. Letters
+// failing again return to it.
+func (s *Server) RedeliverHandler(c *gin.Context) {
+	alertID := c.Query("alert")
+	s.webhooks.mu.Lock()
+	var retry, kept []DeadLetter
+	for _, letter := range s.webhooks.deadLetters() {
+		if alertID == "" || letter.AlertID == alertID {
+			retry = append(retry, letter)
+		} else {
+			kept = append(kept, letter)
+		}
+	}
+	if kept == nil {
+		kept = []DeadLetter{}
+	}
+	data, _ := json.Marshal(kept)
+	s.webhooks.store.Set(deadLettersKey, data, alertsTTL)
+	s.webhooks.mu.Unlock()
+
+	for _, letter := range retry {
+		go s.redeliver(letter)
+	}
+	c.JSON(http.StatusAccepted, gin.H{"redelivering": len(retry)})
+}
+
+// redeliver makes the attempts of a delivery again, signed with the alert's
+// current secret. Letters of deleted alerts are dropped.
+func (s *Server) redeliver(letter DeadLetter) {
+	var event alertEvent
+	if err := json.Unmarshal(letter.Payload, &event); err != nil {
+		return
+	}
+	s.alerts.mu.Lock()
+	stored, ok := s.alerts.current()[letter.AlertID]
+	s.alerts.mu.Unlock()
+	if !ok {
+		log.Printf("alerts: dropping the dead letter of deleted alert %s", letter.AlertID)
+		return
+	}
+	event.Alert.Secret = stored.Secret
+	s.webhooks.deliver(context.Background(), event)
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsPongWait is how long a connection may stay silent, including pongs,
+	// before it is dropped; pings go out every wsPingInterval.
+	wsPongWait     = time.Minute
+	wsPingInterval = wsPongWait * 9 / 10
+	wsWriteWait    = 10 * time.Second
+)
+
+var wsUpgrader = websocket.Upgrader{
+	// Rates are public, so pages on any origin may subscribe
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsMessage is a client message on /ws/rates, e.g.
+// {"action": "subscribe", "pairs": ["USD/KHR", "EUR/USD"]}.
+type wsMessage struct {
+	Action string   `json:"action"`
+	Pairs  []string `json:"pairs"`
+}
+
+// RatesWebSocketHandler streams rate changes of the pairs a client subscribes
+// to. Every subscribe and unsubscribe is answered with the connection's
+// current pairs; rates arrive as {"type": "rate", ...} messages, starting with
+// the latest known rate of a newly subscribed pair.
+func (s *Server) RatesWebSocketHandler(c *gin.Context) {
+	countConversions(c, 0)
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already answered the request
+		return
+	}
+	defer conn.Close()
+
+	maxPairs := config.Int("EXCHANGER_WS_MAX_PAIRS", 20)
+	sub := newSubscriber()
+	pairs := map[adapters.Pair]bool{}
+	defer func() {
+		for pair := range pairs {
+			s.hub.unsubscribe(sub, pair)
+		}
+	}()
+
+	replies := make(chan gin.H)
+	stopped := make(chan struct{})
+	go wsWrite(conn, sub, replies, stopped, s.draining, s.requestMarkups(c))
+	reply := func(message gin.H) bool {
+		select {
+		case replies <- message:
+			return true
+		case <-stopped:
+			return false
+		}
+	}
+
+	conn.SetReadLimit(4096)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var message wsMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			if !reply(wsError(CodeInvalidRequest, "Invalid message", "")) {
+				return
+			}
+			continue
+		}
+
+		var response gin.H
+		switch message.Action {
+		case "subscribe":
+			response = s.wsSubscribe(sub, pairs, message.Pairs, maxPairs)
+		case "unsubscribe":
+			response = s.wsUnsubscribe(sub, pairs, message.Pairs)
+		default:
+			response = wsError(CodeInvalidRequest, "Unknown action, expected subscribe or unsubscribe", "action")
+		}
+		if !reply(response) {
+			return
+		}
+	}
+}
+
+// wsSubscribe adds inputs to the connection's pairs. Either every pair is
+// subscribed or, on an invalid pair or the limit being exceeded, none is.
+func (s *Server) wsSubscribe(sub *subscriber, pairs map[adapters.Pair]bool, inputs []string, maxPairs int) gin.H {
+	var added []adapters.Pair
+	for _, input := range inputs {
+		pair, err := parsePair(input)
+		if err != nil {
+			return wsError(pairErrorCode(err), err.Error(), "pairs")
+		}
+		if !pairs[pair] {
+			added = append(added, pair)
+		}
+	}
+	if len(pairs)+len(added) > maxPairs {
+		return wsError(CodeInvalidRequest, fmt.Sprintf("At most %d pairs per connection", maxPairs), "pairs")
+	}
+
+	for _, pair := range added {
+		pairs[pair] = true
+		s.hub.subscribe(sub, pair)
+	}
+	return gin.H{"type": "subscribed", "pairs": pairList(pairs)}
+}
+
+// wsUnsubscribe removes inputs from the connection's pairs.
+func (s *Server) wsUnsubscribe(sub *subscriber, pairs map[adapters.Pair]bool, inputs []string) gin.H {
+	for _, input := range inputs {
+		pair, err := parsePair(input)
+		if err != nil {
+			return wsError(pairErrorCode(err), err.Error(), "pairs")
+		}
+		if pairs[pair] {
+			delete(pairs, pair)
+			s.hub.unsubscribe(sub, pair)
+		}
+	}
+	return gin.H{"type": "unsubscribed", "pairs": pairList(pairs)}
+}
+
+// wsWrite is the connection's only writer: it sends replies, rate updates and
+// pings until a write fails or the server drains, then closes the connection
+// and stopped.
+func wsWrite(conn *websocket.Conn, sub *subscriber, replies <-chan gin.H, stopped chan<- struct{}, draining <-chan struct{}, markupOf markupLookup) {
+	defer close(stopped)
+	defer conn.Close()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		var message gin.H
+		select {
+		case message = <-replies:
+		case result := <-sub.updates:
+			message = rateMessage(result, markupOf)
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+			continue
+		case <-draining:
+			// 1001 tells clients to reconnect, which lands them on another instance
+			message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "Server is shutting down")
+			conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(wsWriteWait))
+			return
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		if err := conn.WriteJSON(message); err != nil {
+			return
+		}
+	}
+}
+
+// rateMessage is the streamed form of a rate update.
+func rateMessage(result adapters.RateResult, markupOf markupLookup) gin.H {
+	message := gin.H{
+		"type":      "rate",
+		"from":      result.Base,
+		"to":        result.Target,
+		"rate":      result.Rate,
+		"provider":  result.Provider,
+		"timestamp": result.Timestamp,
+	}
+	if markup, ok := markupOf(result.Base, result.Target); ok {
+		message["midRate"] = result.Rate
+		message["rate"] = markup.effectiveRate(result.Rate)
+	}
+	return message
+}
+
+// pairList returns pairs as sorted FROM/TO strings.
+func pairList(pairs map[adapters.Pair]bool) []string {
+	list := make([]string, 0, len(pairs))
+	for pair := range pairs {
+		list = append(list, pair.From+"/"+pair.To)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// wsError is an error message: the error envelope with type "error".
+func wsError(code, message, field string) gin.H {
+	body := errorBody(code, message, field, "")
+	body["type"] = "error"
+	return body
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"cubetiq-samples/exchanger-go/adapters"
+	"cubetiq-samples/exchanger-go/config"
+)
+
+// newRegistry builds the sources the serve and the other commands share: the
+// built-in providers, those of EXCHANGER_ADAPTERS_FILE and
+// EXCHANGER_EXTERNAL_ADAPTERS, the static source of
+// EXCHANGER_STATIC_RATES_FILE and, with EXCHANGER_MOCK, the mock source.
+// Offline, only the static and mock sources are registered so nothing calls
+// a network. The static adapter is returned too, nil without a file, for its
+// file to be watched.
+func newRegistry(client *http.Client) (*adapters.Registry, *adapters.StaticAdapter, error) {
+	offline := config.Bool("EXCHANGER_OFFLINE")
+	var static *adapters.StaticAdapter
+	if path := os.Getenv("EXCHANGER_STATIC_RATES_FILE"); path != "" {
+		var err error
+		if static, err = adapters.NewStatic(path); err != nil {
+			return nil, nil, fmt.Errorf("loading static rates: %w", err)
+		}
+	} else if offline && !config.Bool("EXCHANGER_MOCK") {
+		return nil, nil, errors.New("EXCHANGER_OFFLINE needs EXCHANGER_STATIC_RATES_FILE or EXCHANGER_MOCK")
+	}
+
+	registry := adapters.NewRegistry()
+	if !offline {
+		registry = adapters.DefaultRegistry(os.Getenv("EXCHANGER_RATESSERVICE_URL"), client)
+		if path := os.Getenv("EXCHANGER_ADAPTERS_FILE"); path != "" {
+			generic, err := adapters.LoadGenericAdapters(path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("loading adapters: %w", err)
+			}
+			registry.RegisterGeneric(generic, client)
+		}
+		external, err := adapters.ExternalAdaptersFromEnv()
+		if err != nil {
+			return nil, nil, err
+		}
+		registry.RegisterExternal(external, client)
+	}
+	if static != nil {
+		registry.Register("static", func(apiKey string) adapters.ExchangeRateAdapter {
+			return static
+		}, true)
+	}
+	if config.Bool("EXCHANGER_MOCK") {
+		mockConfig, err := adapters.MockConfigFromEnv()
+		if err != nil {
+			return nil, nil, err
+		}
+		mock := adapters.NewMock(mockConfig)
+		registry.Register("mock", func(apiKey string) adapters.ExchangeRateAdapter {
+			// The key picks an error scenario, e.g. key=rate_limit
+			return mock.WithScenario(apiKey)
+		}, true)
+	}
+	return registry, static, nil
+}
+
+// defaultSources is EXCHANGER_SOURCES, or offline the static source, else the
+// mock one.
+func defaultSources() string {
+	if sources := os.Getenv("EXCHANGER_SOURCES"); sources != "" {
+		return sources
+	}
+	if !config.Bool("EXCHANGER_OFFLINE") {
+		return ""
+	}
+	if os.Getenv("EXCHANGER_STATIC_RATES_FILE") != "" {
+		return "static"
+	}
+	return "mock"
+}
@@ -0,0 +1,64 @@
+// Package tracing sets up OpenTelemetry tracing and exports spans over OTLP.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// ServiceName names the service in exported spans unless OTEL_SERVICE_NAME
+// overrides it.
+const ServiceName = "exchanger"
+
+// Setup installs a global tracer provider that exports spans with exporter,
+// "otlp" for OTLP over gRPC or "otlphttp" for OTLP over HTTP. The collector
+// endpoint, headers and sampler come from the standard OTEL_EXPORTER_OTLP_*
+// and OTEL_TRACES_SAMPLER variables. An empty exporter leaves tracing off, but
+// trace context of incoming requests is still propagated either way.
+//
+// The returned shutdown flushes buffered spans.
+func Setup(ctx context.Context, exporter string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var client otlptrace.Client
+	switch exporter {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		client = otlptracegrpc.NewClient()
+	case "otlphttp":
+		client = otlptracehttp.NewClient()
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q, expected otlp or otlphttp", exporter)
+	}
+
+	spanExporter, err := otlptrace.New(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("starting %s exporter: %w", exporter, err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("describing tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}